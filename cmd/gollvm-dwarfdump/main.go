@@ -0,0 +1,40 @@
+// gollvm-dwarfdump prints the debug metadata of LLVM bitcode files as a
+// human-readable tree.
+//
+// Usage:
+//
+//	gollvm-dwarfdump file.bc...
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/axw/gollvm/llvm"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: gollvm-dwarfdump file.bc...")
+		os.Exit(2)
+	}
+	status := 0
+	for _, name := range os.Args[1:] {
+		m, err := llvm.ParseBitcodeFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			status = 1
+			continue
+		}
+		if len(os.Args) > 2 {
+			fmt.Printf("%s:\n", name)
+		}
+		err = llvm.DumpDebugInfo(os.Stdout, m)
+		m.Dispose()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	os.Exit(status)
+}
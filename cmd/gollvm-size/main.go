@@ -0,0 +1,98 @@
+// gollvm-size reports the size of the data and code of an LLVM bitcode
+// file, attributed to its globals, and the bytes that merging duplicate
+// constant strings would save.
+//
+// Usage:
+//
+//	gollvm-size [-n count] [-triple triple] [-nocode] file.bc
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/axw/gollvm/llvm"
+)
+
+var (
+	count  = flag.Int("n", 20, "number of globals to list in each section")
+	triple = flag.String("triple", "", "target triple; defaults to that of the module, or the host")
+	nocode = flag.Bool("nocode", false, "do not generate code to measure function sizes")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gollvm-size [flags] file.bc")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(name string) error {
+	m, err := llvm.ParseBitcodeFile(name)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	defer m.Dispose()
+
+	llvm.InitializeAllTargetInfos()
+	llvm.InitializeAllTargets()
+	llvm.InitializeAllTargetMCs()
+	t := *triple
+	if t == "" {
+		t = m.Target()
+	}
+	if t == "" {
+		t = llvm.DefaultTargetTriple
+	}
+	target, err := llvm.GetTargetFromTriple(t)
+	if err != nil {
+		return err
+	}
+	tm := target.CreateTargetMachine(t, "", "", llvm.CodeGenLevelDefault, llvm.RelocDefault, llvm.CodeModelDefault)
+	defer tm.Dispose()
+
+	td := tm.TargetData()
+	if layout := m.DataLayout(); layout != "" {
+		td = llvm.NewTargetData(layout)
+		defer td.Dispose()
+	}
+	codegen := tm
+	if *nocode {
+		codegen = llvm.TargetMachine{}
+	}
+	r, err := llvm.ReportSizes(m, td, codegen)
+	if err != nil {
+		return err
+	}
+	printSizes("data", r.Data)
+	if !*nocode {
+		printSizes("code", r.Code)
+	}
+	fmt.Printf("duplicate string bytes: %d\n", r.DuplicateStringBytes)
+	return nil
+}
+
+func printSizes(section string, sizes []llvm.SymbolSize) {
+	var total uint64
+	for _, s := range sizes {
+		total += s.Size
+	}
+	fmt.Printf("%s: %d bytes in %d globals\n", section, total, len(sizes))
+	for i, s := range sizes {
+		if i == *count {
+			fmt.Printf("\t... %d more\n", len(sizes)-i)
+			break
+		}
+		fmt.Printf("\t%10d %s\n", s.Size, s.Name)
+	}
+}
@@ -0,0 +1,300 @@
+// Package debugtest runs programs under a debugger, driven through the
+// GDB/MI protocol, so that tests can check the debug information a
+// frontend generates: that breakpoints set by file and line are hit,
+// that stepping follows the source, and that variables have the expected
+// values. A test builds its module into an executable, e.g. with llvm.Link,
+// and then either drives a Session itself, or runs a script:
+//
+//	if !debugtest.Available("") {
+//		t.Skip("gdb not found")
+//	}
+//	debugtest.RunScript(t, exe, []string{"x.go:5"}, []debugtest.Step{
+//		{Command: "run", File: "x.go", Line: 5, Values: map[string]string{"n": "3"}},
+//		{Command: "next", File: "x.go", Line: 6},
+//		{Command: "continue"},
+//	})
+package debugtest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// DefaultDebugger is the debugger run by Start if none is given. Others
+// speaking GDB/MI, such as lldb-mi, may be used instead.
+const DefaultDebugger = "gdb"
+
+// Available reports whether the debugger, or DefaultDebugger if it is
+// empty, can be found, so that tests may be skipped where it cannot.
+func Available(debugger string) bool {
+	if debugger == "" {
+		debugger = DefaultDebugger
+	}
+	_, err := exec.LookPath(debugger)
+	return err == nil
+}
+
+// Record is the result record of an MI command.
+type Record struct {
+	Class   string // done, running, connected, error or exit
+	Results map[string]interface{}
+}
+
+// Stop describes where and why the program stopped.
+type Stop struct {
+	Reason   string // e.g. breakpoint-hit, end-stepping-range, exited-normally
+	Func     string
+	File     string // as recorded in the debug information
+	FullName string // as resolved by the debugger
+	Line     int
+	ExitCode int
+}
+
+// Exited reports whether the program has exited.
+func (s Stop) Exited() bool {
+	return strings.HasPrefix(s.Reason, "exited")
+}
+
+func (s Stop) String() string {
+	if s.Exited() {
+		return fmt.Sprintf("%s (exit code %d)", s.Reason, s.ExitCode)
+	}
+	return fmt.Sprintf("%s at %s:%d in %s", s.Reason, s.File, s.Line, s.Func)
+}
+
+// Session is a program running under a debugger.
+type Session struct {
+	cmd     *exec.Cmd
+	in      io.WriteCloser
+	out     *bufio.Reader
+	token   int
+	stopped []string
+	console []string
+}
+
+// Start runs the executable exe, with the arguments args, under the
+// debugger, or DefaultDebugger if it is empty. The program does not begin
+// until Exec("run") is called.
+func Start(debugger, exe string, args ...string) (*Session, error) {
+	if debugger == "" {
+		debugger = DefaultDebugger
+	}
+	cmd := exec.Command(debugger, append([]string{"--interpreter=mi2", "--nx", "--quiet", "--args", exe}, args...)...)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &Session{cmd: cmd, in: in, out: bufio.NewReader(out)}, nil
+}
+
+// Close ends the session, killing the program if it is still running.
+func (s *Session) Close() error {
+	if _, err := s.Command("-gdb-exit"); err != nil {
+		s.cmd.Process.Kill()
+	}
+	s.in.Close()
+	return s.cmd.Wait()
+}
+
+// Console returns the output of the debugger's console, and of the
+// program, if it shares the debugger's terminal, since the last call.
+func (s *Session) Console() string {
+	out := strings.Join(s.console, "")
+	s.console = nil
+	return out
+}
+
+// Command sends the MI command, e.g. "-break-insert x.go:5", and returns
+// its result record. A record of class error is returned as an error.
+func (s *Session) Command(command string) (Record, error) {
+	s.token++
+	if _, err := fmt.Fprintf(s.in, "%d%s\n", s.token, command); err != nil {
+		return Record{}, err
+	}
+	prefix := strconv.Itoa(s.token) + "^"
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return Record{}, err
+		}
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		class, rest := line[len(prefix):], ""
+		if i := strings.IndexByte(class, ','); i >= 0 {
+			class, rest = class[:i], class[i+1:]
+		}
+		results, err := parseMIResults(rest)
+		if err != nil {
+			return Record{}, err
+		}
+		if class == "error" {
+			return Record{}, fmt.Errorf("debugtest: %s: %s", command, str(results, "msg"))
+		}
+		return Record{class, results}, nil
+	}
+}
+
+// readLine reads a line of output from the debugger, recording stream
+// output and stops.
+func (s *Session) readLine() (string, error) {
+	line, err := s.out.ReadString('\n')
+	if err != nil {
+		if err == io.EOF {
+			err = errors.New("debugtest: debugger exited")
+		}
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case strings.HasPrefix(line, "~"), strings.HasPrefix(line, "@"):
+		if text, err := strconv.Unquote(line[1:]); err == nil {
+			s.console = append(s.console, text)
+		}
+	case strings.HasPrefix(line, "*stopped"):
+		s.stopped = append(s.stopped, strings.TrimPrefix(line[len("*stopped"):], ","))
+	}
+	return line, nil
+}
+
+// Break sets a breakpoint at the location, e.g. "x.go:5" or "main".
+func (s *Session) Break(location string) error {
+	_, err := s.Command("-break-insert " + strconv.Quote(location))
+	return err
+}
+
+var execCommands = map[string]string{
+	"run":      "-exec-run",
+	"continue": "-exec-continue",
+	"next":     "-exec-next",
+	"step":     "-exec-step",
+	"finish":   "-exec-finish",
+}
+
+// Exec runs the program with the command run, continue, next, step or
+// finish, and waits for it to stop.
+func (s *Session) Exec(command string) (Stop, error) {
+	mi, ok := execCommands[command]
+	if !ok {
+		return Stop{}, fmt.Errorf("debugtest: unknown command %q", command)
+	}
+	if _, err := s.Command(mi); err != nil {
+		return Stop{}, err
+	}
+	for len(s.stopped) == 0 {
+		if _, err := s.readLine(); err != nil {
+			return Stop{}, err
+		}
+	}
+	rec := s.stopped[0]
+	s.stopped = s.stopped[1:]
+	results, err := parseMIResults(rec)
+	if err != nil {
+		return Stop{}, err
+	}
+	stop := Stop{Reason: str(results, "reason")}
+	if code := str(results, "exit-code"); code != "" {
+		n, _ := strconv.ParseInt(code, 8, 32)
+		stop.ExitCode = int(n)
+	}
+	if frame, ok := results["frame"].(map[string]interface{}); ok {
+		stop.Func = str(frame, "func")
+		stop.File = str(frame, "file")
+		stop.FullName = str(frame, "fullname")
+		stop.Line, _ = strconv.Atoi(str(frame, "line"))
+	}
+	return stop, nil
+}
+
+// Eval returns the value of the expression in the selected frame, as
+// printed by the debugger.
+func (s *Session) Eval(expr string) (string, error) {
+	rec, err := s.Command("-data-evaluate-expression " + strconv.Quote(expr))
+	if err != nil {
+		return "", err
+	}
+	return str(rec.Results, "value"), nil
+}
+
+// Step is a step of a script run by RunScript.
+type Step struct {
+	// Command is run, continue, next, step or finish.
+	Command string
+
+	// File and Line, if not empty, are where the program must then have
+	// stopped. File is compared with the base name of the file in the
+	// debug information, if it has no slash.
+	File string
+	Line int
+
+	// Values maps expressions to their expected values, as printed by
+	// the debugger.
+	Values map[string]string
+}
+
+// RunScript runs the executable exe under DefaultDebugger with breakpoints
+// at the locations, and then each of the steps in turn, reporting stops
+// and values other than those expected as errors of the test t. The values
+// of a step are checked in the order of their expressions, so that output
+// is reproducible. It fails the test at once if the debugger fails, or the
+// program exits before the last step.
+func RunScript(t testing.TB, exe string, breakpoints []string, steps []Step) {
+	s, err := Start("", exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, loc := range breakpoints {
+		if err := s.Break(loc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, step := range steps {
+		stop, err := s.Exec(step.Command)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		file := stop.File
+		if !strings.Contains(step.File, "/") {
+			file = path.Base(file)
+		}
+		if step.File != "" && file != step.File || step.Line != 0 && stop.Line != step.Line {
+			t.Errorf("step %d (%s): stopped %v, want %s:%d", i, step.Command, stop, step.File, step.Line)
+		}
+		if stop.Exited() {
+			if i < len(steps)-1 {
+				t.Fatalf("step %d (%s): program exited before the end of the script", i, step.Command)
+			}
+			return
+		}
+		exprs := make([]string, 0, len(step.Values))
+		for expr := range step.Values {
+			exprs = append(exprs, expr)
+		}
+		sort.Strings(exprs)
+		for _, expr := range exprs {
+			want := step.Values[expr]
+			got, err := s.Eval(expr)
+			if err != nil {
+				t.Errorf("step %d: %v", i, err)
+			} else if got != want {
+				t.Errorf("step %d: %s = %s, want %s", i, expr, got, want)
+			}
+		}
+	}
+}
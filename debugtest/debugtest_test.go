@@ -0,0 +1,104 @@
+package debugtest_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/axw/gollvm/debugtest"
+	"github.com/axw/gollvm/llvm"
+)
+
+// buildModule returns a module whose main function is that of the Go
+// program in the file x.go, returning 0 at the closing brace:
+//
+//	3 func main() {
+//	4 	n := 3
+//	5 	n = n + 1
+//	6 }
+func buildModule(file string) llvm.Module {
+	m := llvm.NewModule("x")
+	f := llvm.AddFunction(m, "main", llvm.FunctionType(llvm.Int32Type(), nil, false))
+
+	path := llvm.FileDescriptor(file)
+	context := &llvm.ContextDescriptor{FileDescriptor: path}
+	cu := &llvm.CompileUnitDescriptor{Path: path, Language: llvm.DW_LANG_Go, Producer: "debugtest"}
+	info := &llvm.DebugInfo{CompileUnit: cu}
+	intType := &llvm.BasicTypeDescriptor{Name: "int", Size: 32, Alignment: 32, TypeEncoding: llvm.DW_ATE_signed}
+	sp := &llvm.SubprogramDescriptor{
+		Context:     context,
+		Name:        "main",
+		DisplayName: "main.main",
+		LinkageName: "main",
+		Type:        llvm.NewSubroutineCompositeType(intType, nil),
+		Line:        3,
+		Function:    f,
+		Path:        path,
+		ScopeLine:   3,
+	}
+	n := llvm.NewLocalVariableDescriptor(llvm.DW_TAG_auto_variable)
+	n.Context = sp
+	n.Name = "n"
+	n.File = context
+	n.Line = 4
+	n.Type = intType
+
+	b := llvm.NewBuilder()
+	defer b.Dispose()
+	b.SetInsertPointAtEnd(llvm.AddBasicBlock(f, "entry"))
+	line := func(l uint32) {
+		b.SetCurrentDebugLocation(info.MDNode(&llvm.LineDescriptor{Line: l, Column: 2, Context: sp}))
+	}
+	line(4)
+	p := b.CreateAlloca(llvm.Int32Type(), "n")
+	b.InsertDeclare(m, p, info.MDNode(n))
+	b.CreateStore(llvm.ConstInt(llvm.Int32Type(), 3, false), p)
+	line(5)
+	sum := b.CreateAdd(b.CreateLoad(p, ""), llvm.ConstInt(llvm.Int32Type(), 1, false), "")
+	b.CreateStore(sum, p)
+	line(6)
+	b.CreateRet(llvm.ConstNull(llvm.Int32Type()))
+
+	info.Finalize()
+	m.AddNamedMetadataOperand("llvm.dbg.cu", info.MDNode(cu))
+	return m
+}
+
+func TestRunScript(t *testing.T) {
+	if !debugtest.Available("") {
+		t.Skip("gdb not found")
+	}
+	dir, err := ioutil.TempDir("", "debugtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := buildModule(filepath.Join(dir, "x.go"))
+	defer m.Dispose()
+	if err := llvm.VerifyModule(m, llvm.ReturnStatusAction); err != nil {
+		t.Fatal(err)
+	}
+
+	llvm.InitializeAllTargetInfos()
+	llvm.InitializeAllTargets()
+	llvm.InitializeAllTargetMCs()
+	llvm.InitializeAllAsmPrinters()
+	target, err := llvm.GetTargetFromTriple(llvm.DefaultTargetTriple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := target.CreateTargetMachine(llvm.DefaultTargetTriple, "", "", llvm.CodeGenLevelNone, llvm.RelocDefault, llvm.CodeModelDefault)
+	defer tm.Dispose()
+
+	exe := filepath.Join(dir, "x")
+	if err := llvm.Link(tm, []llvm.Module{m}, llvm.LinkOptions{Output: exe}); err != nil {
+		t.Fatal(err)
+	}
+	debugtest.RunScript(t, exe, []string{"x.go:5"}, []debugtest.Step{
+		{Command: "run", File: "x.go", Line: 5, Values: map[string]string{"n": "3"}},
+		{Command: "next", File: "x.go", Line: 6, Values: map[string]string{"n": "4"}},
+		{Command: "continue"},
+	})
+}
@@ -0,0 +1,138 @@
+package debugtest
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// miParser parses the results of GDB/MI records, such as
+//
+//	reason="breakpoint-hit",frame={func="main",file="x.go",line="5"}
+//
+// into maps of strings to values, which are strings, maps, for tuples, and
+// slices, for lists, whose elements are the values of lists of results.
+type miParser struct {
+	s string
+	i int
+}
+
+func parseMIResults(s string) (map[string]interface{}, error) {
+	p := &miParser{s: s}
+	results, err := p.results(0)
+	if err == nil && p.i < len(p.s) {
+		err = p.errorf("unexpected %q", p.s[p.i])
+	}
+	return results, err
+}
+
+func (p *miParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("debugtest: malformed MI record at offset %d: %s", p.i, fmt.Sprintf(format, args...))
+}
+
+// results parses a comma-separated list of results, up to the byte end,
+// or the end of the input if end is zero.
+func (p *miParser) results(end byte) (map[string]interface{}, error) {
+	results := make(map[string]interface{})
+	for p.i < len(p.s) && p.s[p.i] != end {
+		if len(results) > 0 {
+			if p.s[p.i] != ',' {
+				return nil, p.errorf("expected ','")
+			}
+			p.i++
+		}
+		name, value, err := p.result()
+		if err != nil {
+			return nil, err
+		}
+		results[name] = value
+	}
+	return results, nil
+}
+
+func (p *miParser) result() (string, interface{}, error) {
+	start := p.i
+	for p.i < len(p.s) && p.s[p.i] != '=' {
+		p.i++
+	}
+	if p.i == len(p.s) {
+		return "", nil, p.errorf("expected '='")
+	}
+	name := p.s[start:p.i]
+	p.i++
+	value, err := p.value()
+	return name, value, err
+}
+
+func (p *miParser) value() (interface{}, error) {
+	if p.i == len(p.s) {
+		return nil, p.errorf("expected value")
+	}
+	switch p.s[p.i] {
+	case '"':
+		return p.cstring()
+	case '{':
+		p.i++
+		tuple, err := p.results('}')
+		if err != nil {
+			return nil, err
+		}
+		if p.i == len(p.s) {
+			return nil, p.errorf("expected '}'")
+		}
+		p.i++
+		return tuple, nil
+	case '[':
+		p.i++
+		var list []interface{}
+		for p.i < len(p.s) && p.s[p.i] != ']' {
+			if len(list) > 0 {
+				if p.s[p.i] != ',' {
+					return nil, p.errorf("expected ','")
+				}
+				p.i++
+			}
+			var v interface{}
+			var err error
+			if c := p.s[p.i]; c == '"' || c == '{' || c == '[' {
+				v, err = p.value()
+			} else {
+				_, v, err = p.result()
+			}
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		if p.i == len(p.s) {
+			return nil, p.errorf("expected ']'")
+		}
+		p.i++
+		return list, nil
+	}
+	return nil, p.errorf("unexpected %q", p.s[p.i])
+}
+
+// cstring parses a C string, whose escapes are those of Go.
+func (p *miParser) cstring() (string, error) {
+	start := p.i
+	for p.i++; p.i < len(p.s) && p.s[p.i] != '"'; p.i++ {
+		if p.s[p.i] == '\\' {
+			p.i++
+		}
+	}
+	if p.i >= len(p.s) {
+		return "", p.errorf("unterminated string")
+	}
+	p.i++
+	s, err := strconv.Unquote(p.s[start:p.i])
+	if err != nil {
+		return "", p.errorf("%v", err)
+	}
+	return s, nil
+}
+
+// str returns the string value of the named result in results, or "".
+func str(results map[string]interface{}, name string) string {
+	s, _ := results[name].(string)
+	return s
+}
@@ -0,0 +1,77 @@
+package debugtest
+
+import (
+	"reflect"
+	"testing"
+)
+
+type tuple map[string]interface{}
+
+var miResultsTests = []struct {
+	in   string
+	want map[string]interface{}
+}{
+	{``, tuple{}},
+	{`msg="No symbol table is loaded."`, tuple{"msg": "No symbol table is loaded."}},
+	{
+		`reason="breakpoint-hit",frame={func="main",file="x.go",line="5"}`,
+		tuple{
+			"reason": "breakpoint-hit",
+			"frame":  map[string]interface{}{"func": "main", "file": "x.go", "line": "5"},
+		},
+	},
+	{`frame={}`, tuple{"frame": map[string]interface{}{}}},
+	{`value="\"a\\b\"\n\t\101"`, tuple{"value": "\"a\\b\"\n\tA"}},
+	{`groups=["i1","i2"]`, tuple{"groups": []interface{}{"i1", "i2"}}},
+	{`groups=[]`, tuple{"groups": []interface{}(nil)}},
+	{
+		`stack=[frame={level="0"},frame={level="1"}]`,
+		tuple{"stack": []interface{}{
+			map[string]interface{}{"level": "0"},
+			map[string]interface{}{"level": "1"},
+		}},
+	},
+	{
+		`bkpt={number="1",locations=[{addr="0x1"},["a",[]]]}`,
+		tuple{"bkpt": map[string]interface{}{
+			"number":    "1",
+			"locations": []interface{}{map[string]interface{}{"addr": "0x1"}, []interface{}{"a", []interface{}(nil)}},
+		}},
+	},
+}
+
+func TestParseMIResults(t *testing.T) {
+	for _, test := range miResultsTests {
+		got, err := parseMIResults(test.in)
+		if err != nil {
+			t.Errorf("parseMIResults(%q): %v", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseMIResults(%q) = %#v, want %#v", test.in, got, test.want)
+		}
+	}
+}
+
+var miMalformedTests = []string{
+	`msg`,
+	`msg=`,
+	`msg=abc`,
+	`msg="abc`,
+	`msg="\q"`,
+	`a="1"b="2"`,
+	`a="1",`,
+	`frame={func="main"`,
+	`frame={func="main"}}`,
+	`groups=["i1"`,
+	`groups=["i1" "i2"]`,
+	`groups=[a=]`,
+}
+
+func TestParseMIResultsMalformed(t *testing.T) {
+	for _, in := range miMalformedTests {
+		if got, err := parseMIResults(in); err == nil {
+			t.Errorf("parseMIResults(%q) = %#v, want error", in, got)
+		}
+	}
+}
@@ -0,0 +1,491 @@
+package llvm
+
+// ABIArgKind describes how an argument or return value is passed under a
+// platform's C calling convention.
+type ABIArgKind int
+
+const (
+	// ABIDirect values are passed as LLVM values: as is if Coerce is
+	// nil, or else reinterpreted as, and split into, the Coerce types.
+	ABIDirect ABIArgKind = iota
+
+	// ABIIndirect values are passed by pointer to a copy made by the
+	// caller. For arguments with ByVal set, the pointer parameter has the
+	// byval attribute and the copy is made in the argument area; for
+	// return values, the caller passes the pointer as a leading sret
+	// parameter.
+	ABIIndirect
+)
+
+// ABIArgInfo is the classification of an argument or return value.
+type ABIArgInfo struct {
+	Kind   ABIArgKind
+	Coerce []Type
+	ByVal  bool
+}
+
+// ABI classifies the parameters and results of functions according to a
+// platform's C calling convention, so that aggregates passed by value are
+// compatible with code compiled by C compilers for the platform.
+type ABI interface {
+	// LowerFunctionType classifies the parameters and result of the
+	// function type ft.
+	LowerFunctionType(ft Type) *FunctionABI
+}
+
+// FunctionABI describes how the parameters and result of a function type
+// are passed, and rewrites functions and call sites accordingly.
+//
+// Functions should be declared with Type rather than the original type, and
+// have SetAttributes applied. Calls are made with CreateCall, and function
+// bodies use Params and CreateRet to convert between the original and
+// lowered representations.
+type FunctionABI struct {
+	Type   Type // The lowered function type.
+	Return ABIArgInfo
+	Args   []ABIArgInfo // The classification of each parameter.
+	orig   Type
+	td     TargetData
+}
+
+func newFunctionABI(td TargetData, ft Type, ret ABIArgInfo, params []ABIArgInfo) *FunctionABI {
+	ctx := ft.Context()
+	var ptypes []Type
+	rt := ft.ReturnType()
+	switch {
+	case ret.Kind == ABIIndirect:
+		ptypes = append(ptypes, PointerType(rt, 0))
+		rt = ctx.VoidType()
+	case len(ret.Coerce) == 1:
+		rt = ret.Coerce[0]
+	case len(ret.Coerce) > 1:
+		rt = ctx.StructType(ret.Coerce, false)
+	}
+	for i, t := range ft.ParamTypes() {
+		switch p := params[i]; {
+		case p.Kind == ABIIndirect:
+			ptypes = append(ptypes, PointerType(t, 0))
+		case p.Coerce != nil:
+			ptypes = append(ptypes, p.Coerce...)
+		default:
+			ptypes = append(ptypes, t)
+		}
+	}
+	return &FunctionABI{
+		Type:   FunctionType(rt, ptypes, ft.IsFunctionVarArg()),
+		Return: ret,
+		Args:   params,
+		orig:   ft,
+		td:     td,
+	}
+}
+
+// SetAttributes adds the sret and byval attributes required by the ABI to
+// the parameters of f, which must have the type fa.Type.
+func (fa *FunctionABI) SetAttributes(f Value) {
+	i := 0
+	if fa.Return.Kind == ABIIndirect {
+		f.Param(0).AddAttribute(StructRetAttribute)
+		i++
+	}
+	for _, p := range fa.Args {
+		if p.Kind == ABIIndirect && p.ByVal {
+			f.Param(i).AddAttribute(ByValAttribute)
+		}
+		i += p.count()
+	}
+}
+
+// count returns the number of lowered parameters for the argument p.
+func (p ABIArgInfo) count() int {
+	if p.Kind == ABIDirect && p.Coerce != nil {
+		return len(p.Coerce)
+	}
+	return 1
+}
+
+// entryAlloca creates an alloca of type t at the start of the entry block
+// of the function being built, so that it is not repeated in loops.
+func (b Builder) entryAlloca(t Type) Value {
+	ip := b.SaveIP()
+	entry := b.GetInsertBlock().Parent().EntryBasicBlock()
+	if first := entry.FirstInstruction(); first.IsNil() {
+		b.SetInsertPointAtEnd(entry)
+	} else {
+		b.SetInsertPointBefore(first)
+	}
+	a := b.CreateAlloca(t, "")
+	b.RestoreIP(ip)
+	return a
+}
+
+// coerceTemp returns an entry block alloca large enough, and sufficiently
+// aligned, to hold a value of type t or of the types coerce.
+func (b Builder) coerceTemp(td TargetData, t Type, coerce Type) Value {
+	size := td.TypeAllocSize(t)
+	if csize := td.TypeAllocSize(coerce); csize > size {
+		size = csize
+	}
+	i64 := t.Context().Int64Type()
+	return b.entryAlloca(ArrayType(i64, int((size+7)/8)))
+}
+
+func coerceStruct(ctx Context, coerce []Type) Type {
+	return ctx.StructType(coerce, false)
+}
+
+// CreateCall calls fn, which must have the type fa.Type, with the arguments
+// args of the original parameter types, and returns the result as the
+// original result type.
+func (fa *FunctionABI) CreateCall(b Builder, fn Value, args []Value, name string) Value {
+	ctx := fa.orig.Context()
+	rt := fa.orig.ReturnType()
+	var lowered []Value
+	var sret Value
+	if fa.Return.Kind == ABIIndirect {
+		sret = b.entryAlloca(rt)
+		lowered = append(lowered, sret)
+	}
+	for i, arg := range args {
+		if i >= len(fa.Args) {
+			// Variadic arguments are passed as is.
+			lowered = append(lowered, arg)
+			continue
+		}
+		switch p := fa.Args[i]; {
+		case p.Kind == ABIIndirect:
+			tmp := b.entryAlloca(arg.Type())
+			b.CreateStore(arg, tmp)
+			lowered = append(lowered, tmp)
+		case p.Coerce != nil:
+			ct := coerceStruct(ctx, p.Coerce)
+			tmp := b.coerceTemp(fa.td, arg.Type(), ct)
+			b.CreateStore(arg, b.CreateBitCast(tmp, PointerType(arg.Type(), 0), ""))
+			cp := b.CreateBitCast(tmp, PointerType(ct, 0), "")
+			for j := range p.Coerce {
+				lowered = append(lowered, b.CreateLoad(b.CreateStructGEP(cp, j, ""), ""))
+			}
+		default:
+			lowered = append(lowered, arg)
+		}
+	}
+	call := b.CreateCall(fn, lowered, "")
+	i := 1
+	if fa.Return.Kind == ABIIndirect {
+		call.AddInstrAttribute(i, StructRetAttribute)
+		i++
+	}
+	for _, p := range fa.Args {
+		if p.Kind == ABIIndirect && p.ByVal {
+			call.AddInstrAttribute(i, ByValAttribute)
+		}
+		i += p.count()
+	}
+
+	switch {
+	case fa.Return.Kind == ABIIndirect:
+		return b.CreateLoad(sret, name)
+	case fa.Return.Coerce != nil:
+		tmp := b.coerceTemp(fa.td, rt, call.Type())
+		b.CreateStore(call, b.CreateBitCast(tmp, PointerType(call.Type(), 0), ""))
+		return b.CreateLoad(b.CreateBitCast(tmp, PointerType(rt, 0), ""), name)
+	}
+	call.SetName(name)
+	return call
+}
+
+// Params returns the parameters of f, which must have the type fa.Type, as
+// values of the original parameter types. The builder must be positioned in
+// f's entry block.
+func (fa *FunctionABI) Params(b Builder, f Value) []Value {
+	ctx := fa.orig.Context()
+	lowered := f.Params()
+	if fa.Return.Kind == ABIIndirect {
+		lowered = lowered[1:]
+	}
+	params := make([]Value, len(fa.Args))
+	for i, t := range fa.orig.ParamTypes() {
+		switch p := fa.Args[i]; {
+		case p.Kind == ABIIndirect:
+			params[i] = b.CreateLoad(lowered[0], "")
+		case p.Coerce != nil:
+			ct := coerceStruct(ctx, p.Coerce)
+			tmp := b.coerceTemp(fa.td, t, ct)
+			cp := b.CreateBitCast(tmp, PointerType(ct, 0), "")
+			for j := range p.Coerce {
+				b.CreateStore(lowered[j], b.CreateStructGEP(cp, j, ""))
+			}
+			params[i] = b.CreateLoad(b.CreateBitCast(tmp, PointerType(t, 0), ""), "")
+		default:
+			params[i] = lowered[0]
+		}
+		lowered = lowered[fa.Args[i].count():]
+	}
+	return params
+}
+
+// CreateRet returns v, of the original result type, from f, which must
+// have the type fa.Type. If the original result type is void, v is ignored.
+func (fa *FunctionABI) CreateRet(b Builder, f Value, v Value) Value {
+	rt := fa.orig.ReturnType()
+	switch {
+	case rt.TypeKind() == VoidTypeKind:
+		return b.CreateRetVoid()
+	case fa.Return.Kind == ABIIndirect:
+		b.CreateStore(v, f.Param(0))
+		return b.CreateRetVoid()
+	case fa.Return.Coerce != nil:
+		ct := fa.Type.ReturnType()
+		tmp := b.coerceTemp(fa.td, rt, ct)
+		b.CreateStore(v, b.CreateBitCast(tmp, PointerType(rt, 0), ""))
+		return b.CreateRet(b.CreateLoad(b.CreateBitCast(tmp, PointerType(ct, 0), ""), ""))
+	}
+	return b.CreateRet(v)
+}
+
+func isAggregate(t Type) bool {
+	k := t.TypeKind()
+	return k == StructTypeKind || k == ArrayTypeKind
+}
+
+// flattenFields calls fn with each scalar field of t and its byte offset.
+func flattenFields(td TargetData, t Type, offset uint64, fn func(t Type, offset uint64)) {
+	switch t.TypeKind() {
+	case StructTypeKind:
+		for i, et := range t.StructElementTypes() {
+			flattenFields(td, et, offset+td.ElementOffset(t, i), fn)
+		}
+	case ArrayTypeKind:
+		et := t.ElementType()
+		size := td.TypeAllocSize(et)
+		for i := 0; i < t.ArrayLength(); i++ {
+			flattenFields(td, et, offset+uint64(i)*size, fn)
+		}
+	default:
+		fn(t, offset)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// System V x86-64
+
+type amd64Class int
+
+const (
+	amd64NoClass amd64Class = iota
+	amd64Integer
+	amd64SSE
+	amd64SSEUp // the upper half of a 16-byte vector, passed with the lower
+	amd64Memory
+)
+
+func (a amd64Class) merge(b amd64Class) amd64Class {
+	switch {
+	case a == b:
+		return a
+	case a == amd64NoClass:
+		return b
+	case b == amd64NoClass:
+		return a
+	case a == amd64Memory || b == amd64Memory:
+		return amd64Memory
+	case a == amd64Integer || b == amd64Integer:
+		return amd64Integer
+	}
+	return amd64SSE
+}
+
+type sysVAMD64ABI struct{ td TargetData }
+
+// NewSysVAMD64ABI returns the ABI of the System V x86-64 C calling
+// convention, used on Linux, BSD and OS X. td must describe the target.
+func NewSysVAMD64ABI(td TargetData) ABI { return sysVAMD64ABI{td} }
+
+// classify classifies the aggregate t, returning the types its eightbytes
+// are coerced to, or nil if it is passed in memory, and the number of
+// integer and SSE registers required. An aggregate holding a 16-byte
+// vector is classified SSE and SSEUP, and coerced to the vector, which is
+// passed in a single SSE register.
+func (abi sysVAMD64ABI) classify(t Type) (coerce []Type, ints, sses int) {
+	td := abi.td
+	size := td.TypeAllocSize(t)
+	if size == 0 || size > 16 {
+		return nil, 0, 0
+	}
+	var classes [2]amd64Class
+	var doubles [2]bool
+	var vector Type
+	flattenFields(td, t, 0, func(ft Type, offset uint64) {
+		c := amd64Integer
+		switch ft.TypeKind() {
+		case FloatTypeKind:
+			c = amd64SSE
+		case DoubleTypeKind, VectorTypeKind:
+			c = amd64SSE
+			doubles[offset/8] = true
+		case X86_FP80TypeKind, FP128TypeKind, PPC_FP128TypeKind:
+			c = amd64Memory
+		}
+		if offset%uint64(td.ABITypeAlignment(ft)) != 0 {
+			c = amd64Memory // Unaligned fields of packed structs.
+		}
+		if ft.TypeKind() == VectorTypeKind && td.TypeStoreSize(ft) == 16 && c == amd64SSE {
+			classes[0] = classes[0].merge(amd64SSE)
+			classes[1] = classes[1].merge(amd64SSEUp)
+			vector = ft
+			return
+		}
+		end := offset + td.TypeStoreSize(ft) - 1
+		for i := offset / 8; i <= end/8 && i < 2; i++ {
+			classes[i] = classes[i].merge(c)
+		}
+	})
+	if classes[0] == amd64SSE && classes[1] == amd64SSEUp {
+		return []Type{vector}, 0, 1
+	}
+	ctx := t.Context()
+	for i := uint64(0); i*8 < size; i++ {
+		bytes := size - i*8
+		if bytes > 8 {
+			bytes = 8
+		}
+		switch classes[i] {
+		case amd64Memory:
+			return nil, 0, 0
+		case amd64SSE, amd64SSEUp:
+			// SSEUP not preceded by SSE is converted to SSE.
+			sses++
+			switch {
+			case bytes <= 4:
+				coerce = append(coerce, ctx.FloatType())
+			case doubles[i]:
+				coerce = append(coerce, ctx.DoubleType())
+			default:
+				coerce = append(coerce, VectorType(ctx.FloatType(), 2))
+			}
+		default:
+			ints++
+			coerce = append(coerce, ctx.IntType(int(bytes*8)))
+		}
+	}
+	return coerce, ints, sses
+}
+
+func (abi sysVAMD64ABI) LowerFunctionType(ft Type) *FunctionABI {
+	freeInts, freeSSEs := 6, 8
+	var ret ABIArgInfo
+	if rt := ft.ReturnType(); isAggregate(rt) {
+		if coerce, _, _ := abi.classify(rt); coerce != nil {
+			ret.Coerce = coerce
+		} else {
+			ret.Kind = ABIIndirect
+			freeInts--
+		}
+	}
+	params := make([]ABIArgInfo, ft.ParamTypesCount())
+	for i, t := range ft.ParamTypes() {
+		switch t.TypeKind() {
+		case StructTypeKind, ArrayTypeKind:
+			coerce, ints, sses := abi.classify(t)
+			if coerce != nil && ints <= freeInts && sses <= freeSSEs {
+				params[i].Coerce = coerce
+				freeInts -= ints
+				freeSSEs -= sses
+			} else {
+				params[i] = ABIArgInfo{Kind: ABIIndirect, ByVal: true}
+			}
+		case FloatTypeKind, DoubleTypeKind, VectorTypeKind:
+			freeSSEs--
+		default:
+			freeInts--
+		}
+	}
+	return newFunctionABI(abi.td, ft, ret, params)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// AArch64 AAPCS
+
+type aapcs64ABI struct{ td TargetData }
+
+// NewAAPCS64ABI returns the ABI of the AArch64 procedure call standard. td
+// must describe the target.
+func NewAAPCS64ABI(td TargetData) ABI { return aapcs64ABI{td} }
+
+// hfa returns the element type and count of t if it is a homogeneous
+// floating point aggregate of up to four members.
+func (abi aapcs64ABI) hfa(t Type) (et Type, n int) {
+	ok := true
+	flattenFields(abi.td, t, 0, func(ft Type, _ uint64) {
+		k := ft.TypeKind()
+		if k != FloatTypeKind && k != DoubleTypeKind {
+			ok = false
+		} else if n == 0 {
+			et = ft
+		} else if ft != et {
+			ok = false
+		}
+		n++
+	})
+	if !ok || n == 0 || n > 4 {
+		return Type{}, 0
+	}
+	return et, n
+}
+
+func (abi aapcs64ABI) classify(t Type) ABIArgInfo {
+	if !isAggregate(t) {
+		return ABIArgInfo{}
+	}
+	if et, n := abi.hfa(t); n > 0 {
+		return ABIArgInfo{Coerce: []Type{ArrayType(et, n)}}
+	}
+	i64 := t.Context().Int64Type()
+	switch size := abi.td.TypeAllocSize(t); {
+	case size <= 8:
+		return ABIArgInfo{Coerce: []Type{i64}}
+	case size <= 16:
+		return ABIArgInfo{Coerce: []Type{ArrayType(i64, 2)}}
+	}
+	return ABIArgInfo{Kind: ABIIndirect}
+}
+
+func (abi aapcs64ABI) LowerFunctionType(ft Type) *FunctionABI {
+	params := make([]ABIArgInfo, ft.ParamTypesCount())
+	for i, t := range ft.ParamTypes() {
+		params[i] = abi.classify(t)
+	}
+	return newFunctionABI(abi.td, ft, abi.classify(ft.ReturnType()), params)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// WebAssembly
+
+type wasmABI struct{ td TargetData }
+
+// NewWasmABI returns the basic C ABI of WebAssembly, in which aggregates
+// with a single scalar member are passed as that member, and others by
+// pointer. td must describe the target.
+func NewWasmABI(td TargetData) ABI { return wasmABI{td} }
+
+func (abi wasmABI) classify(t Type) ABIArgInfo {
+	if !isAggregate(t) {
+		return ABIArgInfo{}
+	}
+	var fields []Type
+	flattenFields(abi.td, t, 0, func(ft Type, _ uint64) {
+		fields = append(fields, ft)
+	})
+	if len(fields) == 1 {
+		return ABIArgInfo{Coerce: fields}
+	}
+	return ABIArgInfo{Kind: ABIIndirect}
+}
+
+func (abi wasmABI) LowerFunctionType(ft Type) *FunctionABI {
+	params := make([]ABIArgInfo, ft.ParamTypesCount())
+	for i, t := range ft.ParamTypes() {
+		params[i] = abi.classify(t)
+	}
+	return newFunctionABI(abi.td, ft, abi.classify(ft.ReturnType()), params)
+}
@@ -0,0 +1,99 @@
+package llvm
+
+import "testing"
+
+// amd64DataLayout is the data layout of LLVM 3.2's x86-64 targets.
+const amd64DataLayout = "e-p:64:64:64-i1:8:8-i8:8:8-i16:16:16-i32:32:32-i64:64:64-f32:32:32-f64:64:64-v64:64:64-v128:128:128-a0:0:64-s0:64:64-f80:128:128-n8:16:32:64-S128"
+
+func abiArgInfoEqual(a, b ABIArgInfo) bool {
+	if a.Kind != b.Kind || a.ByVal != b.ByVal || len(a.Coerce) != len(b.Coerce) {
+		return false
+	}
+	for i := range a.Coerce {
+		if a.Coerce[i] != b.Coerce[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSysVAMD64Classify(t *testing.T) {
+	td := NewTargetData(amd64DataLayout)
+	defer td.Dispose()
+	abi := NewSysVAMD64ABI(td)
+
+	i8, i32, i64 := Int8Type(), Int32Type(), Int64Type()
+	float, double := FloatType(), DoubleType()
+	v2f32, v4f32 := VectorType(float, 2), VectorType(float, 4)
+	memory := ABIArgInfo{Kind: ABIIndirect, ByVal: true}
+	direct := func(coerce ...Type) ABIArgInfo { return ABIArgInfo{Coerce: coerce} }
+
+	tests := []struct {
+		name string
+		t    Type
+		want ABIArgInfo
+	}{
+		{"two ints", StructType([]Type{i32, i32}, false), direct(i64)},
+		{"three bytes", StructType([]Type{i8, i8, i8}, false), direct(IntType(24))},
+		{"two floats", StructType([]Type{float, float}, false), direct(v2f32)},
+		{"float and int", StructType([]Type{float, i32}, false), direct(i64)},
+		{"double and int", StructType([]Type{double, i64}, false), direct(double, i64)},
+		{"two doubles", ArrayType(double, 2), direct(double, double)},
+		{"sixteen bytes", StructType([]Type{i64, i32, i32}, false), direct(i64, i64)},
+		{"vector", StructType([]Type{v4f32}, false), direct(v4f32)},
+		{"over sixteen bytes", StructType([]Type{i64, i64, i8}, false), memory},
+		{"three longs", StructType([]Type{i64, i64, i64}, false), memory},
+		{"long double", StructType([]Type{X86FP80Type()}, false), memory},
+		{"unaligned", StructType([]Type{i8, i32}, true), memory},
+	}
+	for _, test := range tests {
+		fa := abi.LowerFunctionType(FunctionType(VoidType(), []Type{test.t}, false))
+		if got := fa.Args[0]; !abiArgInfoEqual(got, test.want) {
+			t.Errorf("%s: %v classified as %+v, want %+v", test.name, test.t, got, test.want)
+		}
+	}
+}
+
+func TestSysVAMD64Return(t *testing.T) {
+	td := NewTargetData(amd64DataLayout)
+	defer td.Dispose()
+	abi := NewSysVAMD64ABI(td)
+
+	pair := StructType([]Type{Int64Type(), DoubleType()}, false)
+	fa := abi.LowerFunctionType(FunctionType(pair, nil, false))
+	if !abiArgInfoEqual(fa.Return, ABIArgInfo{Coerce: []Type{Int64Type(), DoubleType()}}) {
+		t.Errorf("%v returned as %+v, want in rax and xmm0", pair, fa.Return)
+	}
+
+	large := StructType([]Type{Int64Type(), Int64Type(), Int64Type()}, false)
+	fa = abi.LowerFunctionType(FunctionType(large, []Type{Int64Type()}, false))
+	if fa.Return.Kind != ABIIndirect {
+		t.Fatalf("%v returned as %+v, want indirect", large, fa.Return)
+	}
+	if n := fa.Type.ParamTypesCount(); n != 2 || fa.Type.ParamTypes()[0] != PointerType(large, 0) {
+		t.Errorf("lowered type %v does not take an sret pointer first", fa.Type)
+	}
+}
+
+func TestSysVAMD64RegisterExhaustion(t *testing.T) {
+	td := NewTargetData(amd64DataLayout)
+	defer td.Dispose()
+	abi := NewSysVAMD64ABI(td)
+
+	// Each pair needs two of the six integer registers, so the fourth is
+	// passed in memory.
+	pair := StructType([]Type{Int64Type(), Int64Type()}, false)
+	fa := abi.LowerFunctionType(FunctionType(VoidType(), []Type{pair, pair, pair, pair}, false))
+	for i, want := range []ABIArgKind{ABIDirect, ABIDirect, ABIDirect, ABIIndirect} {
+		if got := fa.Args[i].Kind; got != want {
+			t.Errorf("pair %d has kind %d, want %d", i, got, want)
+		}
+	}
+
+	// With a sret pointer taking an integer register, only two pairs fit.
+	large := StructType([]Type{Int64Type(), Int64Type(), Int64Type()}, false)
+	fa = abi.LowerFunctionType(FunctionType(large, []Type{pair, pair, pair}, false))
+	if fa.Args[1].Kind != ABIDirect || fa.Args[2].Kind != ABIIndirect {
+		t.Errorf("pairs after a sret pointer classified as %+v", fa.Args)
+	}
+}
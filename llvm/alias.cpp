@@ -0,0 +1,53 @@
+#include <llvm/Function.h>
+#include <llvm/Metadata.h>
+#include <llvm/Pass.h>
+#include <llvm/PassManager.h>
+#include <llvm/Analysis/AliasAnalysis.h>
+#include <stdint.h>
+
+struct aliasLocation {
+	llvm::Value *ptr;
+	uint64_t size;
+	llvm::MDNode *tbaa;
+};
+
+namespace {
+
+// aliasQueryPass answers alias queries for the function it is run on, using
+// the alias analyses scheduled before it.
+struct aliasQueryPass : public llvm::FunctionPass {
+	static char ID;
+	const aliasLocation *a, *b;
+	unsigned n;
+	int *results;
+
+	aliasQueryPass(const aliasLocation *a, const aliasLocation *b,
+	               unsigned n, int *results)
+		: llvm::FunctionPass(ID), a(a), b(b), n(n), results(results) {}
+
+	virtual void getAnalysisUsage(llvm::AnalysisUsage &AU) const {
+		AU.addRequired<llvm::AliasAnalysis>();
+		AU.setPreservesAll();
+	}
+
+	virtual bool runOnFunction(llvm::Function &) {
+		llvm::AliasAnalysis &AA = getAnalysis<llvm::AliasAnalysis>();
+		for (unsigned i = 0; i < n; i++) {
+			llvm::AliasAnalysis::Location la(a[i].ptr, a[i].size, a[i].tbaa);
+			llvm::AliasAnalysis::Location lb(b[i].ptr, b[i].size, b[i].tbaa);
+			results[i] = AA.alias(la, lb);
+		}
+		return false;
+	}
+};
+
+char aliasQueryPass::ID = 0;
+
+}
+
+extern "C" void addAliasQueryPass(llvm::PassManagerBase *pm,
+                                  const aliasLocation *a,
+                                  const aliasLocation *b,
+                                  unsigned n, int *results) {
+	pm->add(new aliasQueryPass(a, b, n, results));
+}
@@ -0,0 +1,102 @@
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+#include <llvm-c/Transforms/Scalar.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+typedef struct {
+	LLVMValueRef ptr;
+	uint64_t size;
+	LLVMValueRef tbaa;
+} aliasLocation;
+
+extern void addAliasQueryPass(LLVMPassManagerRef, const aliasLocation *,
+                              const aliasLocation *, unsigned, int *);
+*/
+import "C"
+import "unsafe"
+
+// AliasResult is the result of an alias query.
+// See llvm::AliasAnalysis::AliasResult.
+type AliasResult int
+
+const (
+	NoAlias AliasResult = iota
+	MayAlias
+	PartialAlias
+	MustAlias
+)
+
+func (r AliasResult) String() string {
+	switch r {
+	case NoAlias:
+		return "NoAlias"
+	case MayAlias:
+		return "MayAlias"
+	case PartialAlias:
+		return "PartialAlias"
+	case MustAlias:
+		return "MustAlias"
+	}
+	return "AliasResult(?)"
+}
+
+// MemoryLocation is a range of memory accessed by an instruction.
+// See llvm::AliasAnalysis::Location.
+type MemoryLocation struct {
+	Ptr  Value
+	Size uint64 // in bytes; 0 if unknown
+	TBAA Value  // the access's !tbaa node, if any
+}
+
+// AliasQuery asks whether two memory locations may overlap.
+type AliasQuery struct {
+	A, B MemoryLocation
+}
+
+// QueryAlias answers alias queries between memory locations in the function
+// f, using the type-based and basic alias analyses, as the standard
+// optimization pipelines do. The function is not modified.
+func QueryAlias(f Value, queries []AliasQuery) []AliasResult {
+	if len(queries) == 0 {
+		return nil
+	}
+	// The query pass refers to its arguments until it is run, so they are
+	// allocated in C memory.
+	n := len(queries)
+	a := (*[1 << 24]C.aliasLocation)(C.malloc(C.size_t(n) * C.sizeof_aliasLocation))[:n:n]
+	defer C.free(unsafe.Pointer(&a[0]))
+	b := (*[1 << 24]C.aliasLocation)(C.malloc(C.size_t(n) * C.sizeof_aliasLocation))[:n:n]
+	defer C.free(unsafe.Pointer(&b[0]))
+	results := (*[1 << 24]C.int)(C.malloc(C.size_t(n) * C.sizeof_int))[:n:n]
+	defer C.free(unsafe.Pointer(&results[0]))
+	for i, q := range queries {
+		a[i] = q.A.c()
+		b[i] = q.B.c()
+	}
+
+	pm := NewFunctionPassManagerForModule(f.GlobalParent())
+	defer pm.Dispose()
+	C.LLVMAddTypeBasedAliasAnalysisPass(pm.C)
+	C.LLVMAddBasicAliasAnalysisPass(pm.C)
+	C.addAliasQueryPass(pm.C, &a[0], &b[0], C.unsigned(n), &results[0])
+	pm.InitializeFunc()
+	pm.RunFunc(f)
+	pm.FinalizeFunc()
+
+	out := make([]AliasResult, len(results))
+	for i, r := range results {
+		out[i] = AliasResult(r)
+	}
+	return out
+}
+
+func (l MemoryLocation) c() C.aliasLocation {
+	size := C.uint64_t(l.Size)
+	if l.Size == 0 {
+		size = ^C.uint64_t(0) // AliasAnalysis::UnknownSize
+	}
+	return C.aliasLocation{ptr: l.Ptr.C, size: size, tbaa: l.TBAA.C}
+}
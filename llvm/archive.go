@@ -0,0 +1,250 @@
+package llvm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// ArchiveMember is a file in a static archive.
+type ArchiveMember struct {
+	Name string
+	Data []byte
+
+	// Symbols lists the global symbols defined by the member, which are
+	// recorded in the archive's symbol index so that linkers know which
+	// members to extract. See ObjectSymbols.
+	Symbols []string
+}
+
+const (
+	archiveMagic      = "!<arch>\n"
+	archiveHeaderSize = 60
+)
+
+// archiveMaxOffset32 is the largest member offset WriteArchive records in
+// a symbol index with 4-byte offsets; it is a variable so that tests can
+// exercise "/SYM64/" indexes without writing 4 GiB archives.
+var archiveMaxOffset32 uint64 = 1<<32 - 1
+
+// WriteArchive writes a static archive in the GNU ar format, as used on
+// ELF systems, containing members. If any member lists Symbols, a symbol
+// index is written, with 64-bit offsets if the archive is too large for
+// 32-bit ones; otherwise the archive must be indexed with ranlib before it
+// is linked. Timestamps and owners are zeroed, so the output depends only
+// on members.
+func WriteArchive(w io.Writer, members []ArchiveMember) error {
+	// Names longer than 15 bytes, or containing a slash, are stored in the
+	// "//" member and referred to by offset.
+	var longnames bytes.Buffer
+	names := make([]string, len(members))
+	for i, m := range members {
+		if m.Name == "" {
+			return errors.New("archive member has no name")
+		}
+		if len(m.Name) < 16 && !strings.Contains(m.Name, "/") {
+			names[i] = m.Name + "/"
+		} else {
+			names[i] = "/" + strconv.Itoa(longnames.Len())
+			longnames.WriteString(m.Name + "/\n")
+		}
+	}
+
+	nsyms, symnames := 0, 0
+	for _, m := range members {
+		for _, s := range m.Symbols {
+			nsyms++
+			symnames += len(s) + 1
+		}
+	}
+
+	// Compute the offset of each member's header, for the symbol index,
+	// whose count and offsets are 4 bytes wide unless an offset does not
+	// fit, in which case the index is written as "/SYM64/", with 8-byte
+	// count and offsets.
+	var word, symsize int
+	var offsets []uint64
+	for _, word = range []int{4, 8} {
+		symsize = word + word*nsyms + symnames
+		offset := uint64(len(archiveMagic))
+		if nsyms > 0 {
+			offset += uint64(archiveHeaderSize + symsize + symsize%2)
+		}
+		if longnames.Len() > 0 {
+			offset += uint64(archiveHeaderSize + longnames.Len() + longnames.Len()%2)
+		}
+		offsets = make([]uint64, len(members))
+		for i, m := range members {
+			offsets[i] = offset
+			offset += uint64(archiveHeaderSize + len(m.Data) + len(m.Data)%2)
+		}
+		if len(members) == 0 || offsets[len(members)-1] <= archiveMaxOffset32 {
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(archiveMagic)
+	if nsyms > 0 {
+		put := func(b []byte, v uint64) []byte {
+			var w [8]byte
+			binary.BigEndian.PutUint64(w[:], v)
+			return append(b, w[8-word:]...)
+		}
+		symtab := put(make([]byte, 0, symsize), uint64(nsyms))
+		for i, m := range members {
+			for _ = range m.Symbols {
+				symtab = put(symtab, offsets[i])
+			}
+		}
+		for _, m := range members {
+			for _, s := range m.Symbols {
+				symtab = append(symtab, s...)
+				symtab = append(symtab, 0)
+			}
+		}
+		name := "/"
+		if word == 8 {
+			name = "/SYM64/"
+		}
+		writeArchiveMember(&buf, name, symtab)
+	}
+	if longnames.Len() > 0 {
+		writeArchiveMember(&buf, "//", longnames.Bytes())
+	}
+	for i, m := range members {
+		writeArchiveMember(&buf, names[i], m.Data)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func writeArchiveMember(buf *bytes.Buffer, name string, data []byte) {
+	mode := "644"
+	if name == "/" || name == "/SYM64/" || name == "//" {
+		mode = "0"
+	}
+	fmt.Fprintf(buf, "%-16s%-12s%-6s%-6s%-8s%-10d`\n", name, "0", "0", "0", mode, len(data))
+	buf.Write(data)
+	if len(data)%2 != 0 {
+		buf.WriteByte('\n')
+	}
+}
+
+// ReadArchive reads the members of a static archive in the GNU or BSD ar
+// format. The archive's symbol index, if any, is used to fill in each
+// member's Symbols.
+func ReadArchive(r io.Reader) ([]ArchiveMember, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte(archiveMagic)) {
+		return nil, errors.New("not an archive")
+	}
+
+	var members []ArchiveMember
+	var symtab, longnames []byte
+	symtabWord := 4            // size of the symbol index's count and offsets
+	index := make(map[int]int) // header offset -> index in members
+	for offset := len(archiveMagic); offset < len(data); {
+		if len(data)-offset < archiveHeaderSize {
+			return nil, errors.New("truncated archive member header")
+		}
+		hdr := data[offset : offset+archiveHeaderSize]
+		if string(hdr[58:60]) != "`\n" {
+			return nil, fmt.Errorf("invalid archive member header at offset %d", offset)
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(string(hdr[48:58])))
+		if err != nil || size < 0 || size > len(data)-offset-archiveHeaderSize {
+			return nil, fmt.Errorf("invalid archive member size at offset %d", offset)
+		}
+		body := data[offset+archiveHeaderSize : offset+archiveHeaderSize+size]
+		name := strings.TrimRight(string(hdr[0:16]), " ")
+
+		switch {
+		case name == "/":
+			symtab, symtabWord = body, 4
+		case name == "/SYM64/":
+			symtab, symtabWord = body, 8
+		case name == "//":
+			longnames = body
+		case name == "__.SYMDEF" || name == "__.SYMDEF SORTED":
+			// BSD symbol index; the symbols are not recovered.
+		case strings.HasPrefix(name, "#1/"):
+			// BSD long name, stored at the start of the member's data.
+			n, err := strconv.Atoi(name[3:])
+			if err != nil || n < 0 || n > len(body) {
+				return nil, fmt.Errorf("invalid archive member name %q", name)
+			}
+			name = strings.TrimRight(string(body[:n]), "\x00")
+			if name == "__.SYMDEF" || name == "__.SYMDEF SORTED" {
+				break
+			}
+			index[offset] = len(members)
+			members = append(members, ArchiveMember{Name: name, Data: body[n:]})
+		default:
+			if strings.HasPrefix(name, "/") {
+				n, err := strconv.Atoi(name[1:])
+				if err != nil || n < 0 || n > len(longnames) {
+					return nil, fmt.Errorf("invalid archive member name %q", name)
+				}
+				name = string(longnames[n:])
+				if end := strings.Index(name, "/\n"); end >= 0 {
+					name = name[:end]
+				}
+			} else {
+				name = strings.TrimSuffix(name, "/")
+			}
+			index[offset] = len(members)
+			members = append(members, ArchiveMember{Name: name, Data: body})
+		}
+		offset += archiveHeaderSize + size + size%2
+	}
+
+	if symtab != nil {
+		if err := readArchiveSymbols(symtab, symtabWord, index, members); err != nil {
+			return nil, err
+		}
+	}
+	return members, nil
+}
+
+// readArchiveSymbols decodes a GNU symbol index, adding each symbol to the
+// member at the corresponding header offset. word is the size of the
+// index's big-endian count and offsets: 4 bytes in the "/" member, or 8 in
+// the "/SYM64/" member of an archive too large for 32-bit offsets.
+func readArchiveSymbols(symtab []byte, word int, index map[int]int, members []ArchiveMember) error {
+	get := func(b []byte) uint64 {
+		if word == 8 {
+			return binary.BigEndian.Uint64(b)
+		}
+		return uint64(binary.BigEndian.Uint32(b))
+	}
+	if len(symtab) < word {
+		return errors.New("truncated archive symbol index")
+	}
+	count := get(symtab)
+	if count > uint64((len(symtab)-word)/word) {
+		return errors.New("truncated archive symbol index")
+	}
+	n := int(count)
+	names := symtab[word+word*n:]
+	for i := 0; i < n; i++ {
+		end := bytes.IndexByte(names, 0)
+		if end < 0 {
+			return errors.New("truncated archive symbol index")
+		}
+		offset := int(get(symtab[word+word*i:]))
+		if m, ok := index[offset]; ok {
+			members[m].Symbols = append(members[m].Symbols, string(names[:end]))
+		}
+		names = names[end+1:]
+	}
+	return nil
+}
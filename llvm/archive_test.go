@@ -0,0 +1,85 @@
+package llvm
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var archiveMembers = []ArchiveMember{
+	{Name: "a.o", Data: []byte("odd"), Symbols: []string{"a", "a2"}},
+	{Name: "a_name_longer_than_fifteen.o", Data: []byte("even"), Symbols: []string{"b"}},
+	{Name: "dir/c.o", Data: []byte{0, 1, 2, 3, 4}},
+	{Name: "d.o", Data: nil, Symbols: []string{"d"}},
+}
+
+func roundTripArchive(t *testing.T, members []ArchiveMember) []byte {
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, members); err != nil {
+		t.Fatalf("WriteArchive: %v", err)
+	}
+	data := buf.Bytes()
+	got, err := ReadArchive(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadArchive: %v", err)
+	}
+	if len(got) != len(members) {
+		t.Fatalf("read %d members, want %d", len(got), len(members))
+	}
+	for i, m := range members {
+		g := got[i]
+		if g.Name != m.Name || !bytes.Equal(g.Data, m.Data) || !reflect.DeepEqual(g.Symbols, m.Symbols) {
+			t.Errorf("member %d = {%q %q %q}, want {%q %q %q}",
+				i, g.Name, g.Data, g.Symbols, m.Name, m.Data, m.Symbols)
+		}
+	}
+	return data
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	data := roundTripArchive(t, archiveMembers)
+	if !bytes.HasPrefix(data, []byte(archiveMagic+"/ ")) {
+		t.Errorf("archive does not begin with a 32-bit symbol index")
+	}
+	if len(data)%2 != 0 {
+		t.Errorf("archive has odd length %d", len(data))
+	}
+}
+
+func TestArchiveRoundTripSYM64(t *testing.T) {
+	defer func(max uint64) { archiveMaxOffset32 = max }(archiveMaxOffset32)
+	archiveMaxOffset32 = 100
+	data := roundTripArchive(t, archiveMembers)
+	if !bytes.HasPrefix(data, []byte(archiveMagic+"/SYM64/ ")) {
+		t.Errorf("archive does not begin with a 64-bit symbol index")
+	}
+}
+
+func TestArchiveWithoutSymbols(t *testing.T) {
+	data := roundTripArchive(t, []ArchiveMember{{Name: "x.o", Data: []byte("x")}})
+	if bytes.HasPrefix(data, []byte(archiveMagic+"/")) {
+		t.Errorf("archive without symbols has a symbol index")
+	}
+}
+
+func TestReadArchiveErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"not an archive",
+		archiveMagic + "short header",
+		archiveMagic + "x.o/            0           0     0     644     99        `\nabc",
+	}
+	for _, test := range tests {
+		if _, err := ReadArchive(strings.NewReader(test)); err == nil {
+			t.Errorf("ReadArchive(%q) succeeded", test)
+		}
+	}
+}
+
+func TestWriteArchiveEmptyName(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, []ArchiveMember{{Data: []byte("x")}}); err == nil {
+		t.Error("WriteArchive succeeded for a member without a name")
+	}
+}
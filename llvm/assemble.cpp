@@ -0,0 +1,85 @@
+#include <llvm/ADT/OwningPtr.h>
+#include <llvm/ADT/SmallString.h>
+#include <llvm/MC/MCAsmBackend.h>
+#include <llvm/MC/MCAsmInfo.h>
+#include <llvm/MC/MCCodeEmitter.h>
+#include <llvm/MC/MCContext.h>
+#include <llvm/MC/MCInstrInfo.h>
+#include <llvm/MC/MCObjectFileInfo.h>
+#include <llvm/MC/MCRegisterInfo.h>
+#include <llvm/MC/MCStreamer.h>
+#include <llvm/MC/MCSubtargetInfo.h>
+#include <llvm/MC/MCTargetAsmParser.h>
+#include <llvm/MC/MCParser/MCAsmParser.h>
+#include <llvm/Support/MemoryBuffer.h>
+#include <llvm/Support/SourceMgr.h>
+#include <llvm/Support/TargetRegistry.h>
+#include <llvm/Support/raw_ostream.h>
+#include <string.h>
+#include <string>
+
+static void assemblerDiagnostic(const llvm::SMDiagnostic &diag, void *ctx) {
+	llvm::raw_string_ostream os(*static_cast<std::string*>(ctx));
+	diag.print(0, os);
+}
+
+// assemble follows llvm-mc's -filetype=obj mode.
+extern "C" llvm::MemoryBuffer *assemble(const char *triple, const char *cpu,
+                                        const char *features,
+                                        const char *source, size_t len,
+                                        char **errmsg) {
+	std::string err;
+	const llvm::Target *t = llvm::TargetRegistry::lookupTarget(triple, err);
+	if (!t) {
+		*errmsg = strdup(err.c_str());
+		return 0;
+	}
+	llvm::OwningPtr<llvm::MCRegisterInfo> mri(t->createMCRegInfo(triple));
+	llvm::OwningPtr<llvm::MCAsmInfo> mai(t->createMCAsmInfo(triple));
+	llvm::OwningPtr<llvm::MCInstrInfo> mii(t->createMCInstrInfo());
+	llvm::OwningPtr<llvm::MCSubtargetInfo> sti(
+		t->createMCSubtargetInfo(triple, cpu, features));
+	if (!mri || !mai || !mii || !sti) {
+		*errmsg = strdup("target does not support the MC layer");
+		return 0;
+	}
+
+	llvm::SourceMgr srcMgr;
+	srcMgr.AddNewSourceBuffer(llvm::MemoryBuffer::getMemBufferCopy(
+		llvm::StringRef(source, len), "<assembly>"), llvm::SMLoc());
+	srcMgr.setDiagHandler(assemblerDiagnostic, &err);
+
+	llvm::OwningPtr<llvm::MCObjectFileInfo> mofi(new llvm::MCObjectFileInfo);
+	llvm::MCContext ctx(*mai, *mri, mofi.get(), &srcMgr);
+	mofi->InitMCObjectFileInfo(triple, llvm::Reloc::Default,
+	                           llvm::CodeModel::Default, ctx);
+
+	// The streamer owns the code emitter and backend.
+	llvm::MCCodeEmitter *ce = t->createMCCodeEmitter(*mii, *mri, *sti, ctx);
+	llvm::MCAsmBackend *mab = t->createMCAsmBackend(triple);
+	if (!ce || !mab) {
+		delete ce;
+		delete mab;
+		*errmsg = strdup("target cannot emit object files");
+		return 0;
+	}
+	llvm::SmallString<0> obj;
+	llvm::raw_svector_ostream os(obj);
+	llvm::OwningPtr<llvm::MCStreamer> streamer(
+		t->createMCObjectStreamer(triple, ctx, *mab, os, ce, false, false));
+	llvm::OwningPtr<llvm::MCAsmParser> parser(
+		llvm::createMCAsmParser(srcMgr, ctx, *streamer, *mai));
+	llvm::OwningPtr<llvm::MCTargetAsmParser> tap(
+		t->createMCAsmParser(*sti, *parser));
+	if (!tap) {
+		*errmsg = strdup("target has no assembly parser");
+		return 0;
+	}
+	parser->setTargetParser(*tap);
+	if (parser->Run(false)) {
+		*errmsg = strdup(err.empty() ? "assembly failed" : err.c_str());
+		return 0;
+	}
+	os.flush();
+	return llvm::MemoryBuffer::getMemBufferCopy(obj.str());
+}
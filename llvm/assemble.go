@@ -0,0 +1,42 @@
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+#include <stdlib.h>
+
+extern LLVMMemoryBufferRef assemble(const char *, const char *, const char *,
+                                    const char *, size_t, char **);
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// Assemble assembles source, in the target's assembly language, into an
+// object file for the target triple, CPU and features, without running an
+// external assembler. The target's MC layer and assembly parser must have
+// been initialized, e.g. with InitializeAllTargetMCs and
+// InitializeAllAsmParsers. Errors are reported with their source line.
+func Assemble(triple, cpu, features, source string) ([]byte, error) {
+	ctriple := C.CString(triple)
+	defer C.free(unsafe.Pointer(ctriple))
+	ccpu := C.CString(cpu)
+	defer C.free(unsafe.Pointer(ccpu))
+	cfeatures := C.CString(features)
+	defer C.free(unsafe.Pointer(cfeatures))
+	csource := C.CString(source)
+	defer C.free(unsafe.Pointer(csource))
+
+	var errmsg *C.char
+	b := C.assemble(ctriple, ccpu, cfeatures, csource, C.size_t(len(source)), &errmsg)
+	if b == nil {
+		err := errors.New(C.GoString(errmsg))
+		C.free(unsafe.Pointer(errmsg))
+		return nil, err
+	}
+	buf := MemoryBuffer{b}
+	defer buf.Dispose()
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,18 @@
+#include <llvm/Instructions.h>
+#include <llvm/IRBuilder.h>
+
+extern "C" llvm::Value *createAtomicRMW(llvm::IRBuilder<> *b, unsigned op,
+                                        llvm::Value *ptr, llvm::Value *val,
+                                        unsigned ordering, bool singleThread) {
+	return b->CreateAtomicRMW(llvm::AtomicRMWInst::BinOp(op), ptr, val,
+	                          llvm::AtomicOrdering(ordering),
+	                          singleThread ? llvm::SingleThread
+	                                       : llvm::CrossThread);
+}
+
+extern "C" void setAtomicOrdering(llvm::Instruction *i, unsigned ordering) {
+	if (llvm::LoadInst *load = llvm::dyn_cast<llvm::LoadInst>(i))
+		load->setAtomic(llvm::AtomicOrdering(ordering));
+	else
+		llvm::cast<llvm::StoreInst>(i)->setAtomic(llvm::AtomicOrdering(ordering));
+}
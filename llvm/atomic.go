@@ -0,0 +1,69 @@
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+#include <stdbool.h>
+
+extern LLVMValueRef createAtomicRMW(LLVMBuilderRef, unsigned, LLVMValueRef,
+                                    LLVMValueRef, unsigned, bool);
+extern void setAtomicOrdering(LLVMValueRef, unsigned);
+*/
+import "C"
+
+// AtomicRMWBinOp is the operation of an atomicrmw instruction.
+type AtomicRMWBinOp int
+
+// The values are those of llvm::AtomicRMWInst::BinOp.
+const (
+	AtomicRMWXchg AtomicRMWBinOp = iota
+	AtomicRMWAdd
+	AtomicRMWSub
+	AtomicRMWAnd
+	AtomicRMWNand
+	AtomicRMWOr
+	AtomicRMWXor
+	AtomicRMWMax
+	AtomicRMWMin
+	AtomicRMWUMax
+	AtomicRMWUMin
+)
+
+// AtomicOrdering is the memory ordering of an atomic instruction.
+type AtomicOrdering int
+
+// The values are those of llvm::AtomicOrdering, which lacks consume.
+const (
+	NotAtomic              AtomicOrdering = 0
+	Unordered              AtomicOrdering = 1
+	Monotonic              AtomicOrdering = 2
+	Acquire                AtomicOrdering = 4
+	Release                AtomicOrdering = 5
+	AcquireRelease         AtomicOrdering = 6
+	SequentiallyConsistent AtomicOrdering = 7
+)
+
+// CreateAtomicRMW creates an atomicrmw instruction, which atomically
+// applies op to the value pointed to by ptr and val, storing the result,
+// and returns the old value. If singleThread is true, the instruction is
+// only atomic with respect to signal handlers on the same thread.
+// See IRBuilder::CreateAtomicRMW.
+func (b Builder) CreateAtomicRMW(op AtomicRMWBinOp, ptr, val Value, ordering AtomicOrdering, singleThread bool) (v Value) {
+	v.C = C.createAtomicRMW(b.C, C.unsigned(op), ptr.C, val.C, C.unsigned(ordering), C.bool(singleThread))
+	b.trace(v)
+	return
+}
+
+// SetOrdering makes the load or store instruction v atomic, with the
+// memory ordering ordering, or not atomic if ordering is NotAtomic. An
+// atomic load or store must have an alignment, set with SetInstrAlignment,
+// of at least the size of the value. Loads may not be Release or
+// AcquireRelease, nor stores Acquire or AcquireRelease.
+// See LoadInst::setAtomic and StoreInst::setAtomic.
+func (v Value) SetOrdering(ordering AtomicOrdering) {
+	switch v.InstructionOpcode() {
+	case Load, Store:
+	default:
+		panic("SetOrdering: not a load or store instruction")
+	}
+	C.setAtomicOrdering(v.C, C.unsigned(ordering))
+}
@@ -28,6 +28,7 @@ func ParseBitcodeFile(name string) (Module, error) {
 
 	var m Module
 	if C.LLVMParseBitcode(buf, &m.C, &errmsg) == 0 {
+		registerModule(m)
 		return m, nil
 	}
 
@@ -35,3 +36,35 @@ func ParseBitcodeFile(name string) (Module, error) {
 	C.free(unsafe.Pointer(errmsg))
 	return Module{nil}, err
 }
+
+// ParseBitcodeFileLazily reads the module in the bitcode file with the
+// specified name, deferring the parsing of function bodies until they are
+// materialized, one at a time with Materialize, or with MaterializeAll, so
+// that the globals of a large module may be examined cheaply. Functions not
+// yet materialized appear to be declarations, so the module should be
+// materialized before it is transformed or written. The file, which may be
+// mapped into memory, is owned by the module.
+func ParseBitcodeFileLazily(name string) (Module, error) {
+	var buf C.LLVMMemoryBufferRef
+	var errmsg *C.char
+	cfilename := C.CString(name)
+	result := C.LLVMCreateMemoryBufferWithContentsOfFile(cfilename, &buf, &errmsg)
+	C.free(unsafe.Pointer(cfilename))
+	if result != 0 {
+		err := errors.New(C.GoString(errmsg))
+		C.free(unsafe.Pointer(errmsg))
+		return Module{}, err
+	}
+
+	var m Module
+	if C.LLVMGetBitcodeModule(buf, &m.C, &errmsg) == 0 {
+		registerModule(m)
+		return m, nil
+	}
+
+	// The buffer is only owned by the module on success.
+	C.LLVMDisposeMemoryBuffer(buf)
+	err := errors.New(C.GoString(errmsg))
+	C.free(unsafe.Pointer(errmsg))
+	return Module{nil}, err
+}
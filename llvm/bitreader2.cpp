@@ -0,0 +1,32 @@
+#include <llvm/GlobalValue.h>
+#include <llvm/Module.h>
+#include <string.h>
+#include <string>
+
+extern "C" bool materializeAll(llvm::Module *m, char **errmsg) {
+	std::string err;
+	if (m->MaterializeAll(&err)) {
+		*errmsg = strdup(err.c_str());
+		return false;
+	}
+	return true;
+}
+
+extern "C" bool isMaterializable(llvm::GlobalValue *g) {
+	return g->isMaterializable();
+}
+
+extern "C" bool materialize(llvm::GlobalValue *g, char **errmsg) {
+	std::string err;
+	if (g->Materialize(&err)) {
+		*errmsg = strdup(err.c_str());
+		return false;
+	}
+	return true;
+}
+
+extern "C" void dematerialize(llvm::GlobalValue *g) {
+	if (g->isDematerializable()) {
+		g->Dematerialize();
+	}
+}
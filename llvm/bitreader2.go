@@ -0,0 +1,61 @@
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+#include <stdbool.h>
+#include <stdlib.h>
+
+extern bool materializeAll(LLVMModuleRef, char **);
+extern bool isMaterializable(LLVMValueRef);
+extern bool materialize(LLVMValueRef, char **);
+extern void dematerialize(LLVMValueRef);
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// MaterializeAll parses the bodies of all functions of the module m not yet
+// materialized, for a module read with ParseBitcodeFileLazily; for other
+// modules it does nothing.
+// See Module::MaterializeAll.
+func (m Module) MaterializeAll() error {
+	var errmsg *C.char
+	if !C.materializeAll(m.C, &errmsg) {
+		err := errors.New(C.GoString(errmsg))
+		C.free(unsafe.Pointer(errmsg))
+		return err
+	}
+	return nil
+}
+
+// IsMaterializable reports whether the body of the function f, in a module
+// read with ParseBitcodeFileLazily, has yet to be parsed.
+// See GlobalValue::isMaterializable.
+func (f Value) IsMaterializable() bool {
+	return bool(C.isMaterializable(f.C))
+}
+
+// Materialize parses the body of the function f, in a module read with
+// ParseBitcodeFileLazily, if it has not been, so that tools need only pay
+// for the functions they examine.
+// See GlobalValue::Materialize.
+func (f Value) Materialize() error {
+	var errmsg *C.char
+	if !C.materialize(f.C, &errmsg) {
+		err := errors.New(C.GoString(errmsg))
+		C.free(unsafe.Pointer(errmsg))
+		return err
+	}
+	return nil
+}
+
+// Dematerialize releases the body of the function f, materialized with
+// Materialize, which may be materialized again later. The body must not
+// have been changed, nor be in use. It does nothing to other functions.
+// See GlobalValue::Dematerialize.
+func (f Value) Dematerialize() {
+	C.dematerialize(f.C)
+}
@@ -5,6 +5,8 @@ package llvm
 #include <stdlib.h>
 */
 import "C"
+import "io"
+import "io/ioutil"
 import "os"
 import "errors"
 
@@ -18,4 +20,34 @@ func WriteBitcodeToFile(m Module, file *os.File) error {
 	return nil
 }
 
-// TODO(nsf): Figure out way how to make it work with io.Writer
+// WriteBitcode writes the bitcode of the module m to w. It does not stream:
+// LLVM 3.2 serializes the whole module into a buffer in memory before
+// writing any of it, so writing a large module needs memory for all of its
+// bitcode. WriteBitcode only adapts that output to an io.Writer, copying it
+// through a pipe so that Go does not hold a second copy.
+func WriteBitcode(m Module, w io.Writer) error {
+	r, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	copied := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(w, r)
+		if err != nil {
+			// Keep draining the pipe, as LLVM treats write errors as
+			// fatal.
+			io.Copy(ioutil.Discard, r)
+		}
+		r.Close()
+		copied <- err
+	}()
+	fail := C.LLVMWriteBitcodeToFD(m.C, C.int(pw.Fd()), C.int(0), C.int(0))
+	pw.Close()
+	if err := <-copied; err != nil {
+		return err
+	}
+	if fail != 0 {
+		return writeBitcodeToFileErr
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+*/
+import "C"
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EmittedInstruction describes an instruction created by a Builder, as
+// passed to a BuilderHook.
+type EmittedInstruction struct {
+	Instruction Value
+	Opcode      Opcode
+	Operands    []Value
+
+	// DebugLocation is the builder's current debug location, or a nil
+	// Value if it has none.
+	DebugLocation Value
+}
+
+// BuilderHook is called with each instruction created by a Builder on
+// which it is set, after the instruction is inserted, e.g. to trace a
+// frontend's output, or to record why each instruction was emitted.
+type BuilderHook func(b Builder, inst EmittedInstruction)
+
+// builderHooks records the hooks set with SetHook. n is the number of
+// hooks, so that builders without them need not take the lock.
+var builderHooks struct {
+	n int32
+	sync.Mutex
+	m map[C.LLVMBuilderRef]BuilderHook
+}
+
+// SetHook sets the hook called with each instruction created by the
+// builder b, or removes it if hook is nil. Values folded to constants by
+// the builder are not instructions, and are not passed to the hook.
+// Tracing costs one atomic load per instruction when no hooks are
+// registered; once any builder has a hook, every builder also takes a
+// lock and looks up its hook. Dispose removes the hook.
+func (b Builder) SetHook(hook BuilderHook) {
+	builderHooks.Lock()
+	defer builderHooks.Unlock()
+	if builderHooks.m == nil {
+		builderHooks.m = make(map[C.LLVMBuilderRef]BuilderHook)
+	}
+	if hook == nil {
+		delete(builderHooks.m, b.C)
+	} else {
+		builderHooks.m[b.C] = hook
+	}
+	atomic.StoreInt32(&builderHooks.n, int32(len(builderHooks.m)))
+}
+
+// Hook returns the hook set on the builder b with SetHook, or nil.
+func (b Builder) Hook() BuilderHook {
+	if atomic.LoadInt32(&builderHooks.n) == 0 {
+		return nil
+	}
+	builderHooks.Lock()
+	defer builderHooks.Unlock()
+	return builderHooks.m[b.C]
+}
+
+// trace calls the builder's hook, if any, with v, if it is an instruction.
+func (b Builder) trace(v Value) {
+	if atomic.LoadInt32(&builderHooks.n) == 0 {
+		return
+	}
+	hook := b.Hook()
+	if hook == nil || v.IsAInstruction().IsNil() {
+		return
+	}
+	inst := EmittedInstruction{
+		Instruction:   v,
+		Opcode:        v.InstructionOpcode(),
+		Operands:      make([]Value, v.OperandsCount()),
+		DebugLocation: b.CurrentDebugLocation(),
+	}
+	for i := range inst.Operands {
+		inst.Operands[i] = v.Operand(i)
+	}
+	hook(b, inst)
+}
+
+// snapshot returns the instructions of the builder's insertion block, if it
+// has a hook, for traceNew, or nil.
+func (b Builder) snapshot() map[Value]bool {
+	if b.Hook() == nil {
+		return nil
+	}
+	before := make(map[Value]bool)
+	for i := b.GetInsertBlock().FirstInstruction(); !i.IsNil(); i = NextInstruction(i) {
+		before[i] = true
+	}
+	return before
+}
+
+// traceNew calls the builder's hook, if any, with each instruction of its
+// insertion block which is not in before, a snapshot taken before they were
+// created, in block order. It is used by methods which create more than one
+// instruction, some of which LLVM may append to the end of the block rather
+// than inserting them at the insertion point.
+func (b Builder) traceNew(before map[Value]bool) {
+	if before == nil {
+		return
+	}
+	for i := b.GetInsertBlock().FirstInstruction(); !i.IsNil(); i = NextInstruction(i) {
+		if !before[i] {
+			b.trace(i)
+		}
+	}
+}
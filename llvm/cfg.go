@@ -0,0 +1,66 @@
+package llvm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteCFG writes the control flow graph of the function f to w in the
+// Graphviz DOT language. Each basic block is a node labelled with its name,
+// followed by the text of its instructions if instructions is true. The
+// edges of a conditional branch are labelled T and F, and those of a
+// switch with the index of their case, the default being 0.
+func (f Value) WriteCFG(w io.Writer, instructions bool) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %s {\n", dotQuote("CFG for "+f.Name(), `\n`))
+	fmt.Fprintf(bw, "\tnode [shape=box, fontname=monospace];\n")
+
+	ids := make(map[BasicBlock]int)
+	for bb := f.FirstBasicBlock(); !bb.IsNil(); bb = NextBasicBlock(bb) {
+		ids[bb] = len(ids)
+	}
+	for bb := f.FirstBasicBlock(); !bb.IsNil(); bb = NextBasicBlock(bb) {
+		id := ids[bb]
+		label := bb.AsValue().Name()
+		if label == "" {
+			label = fmt.Sprintf("<block %d>", id)
+		}
+		label += ":"
+		if instructions {
+			for i := bb.FirstInstruction(); !i.IsNil(); i = NextInstruction(i) {
+				label += "\n" + strings.TrimSpace(i.IRString())
+			}
+		}
+		// Left-justify each line of the label.
+		fmt.Fprintf(bw, "\tb%d [label=%s];\n", id, dotQuote(label+"\n", `\l`))
+
+		succs := bb.Successors()
+		var opcode Opcode
+		if len(succs) > 0 {
+			opcode = bb.LastInstruction().InstructionOpcode()
+		}
+		for i, succ := range succs {
+			var attrs string
+			switch {
+			case opcode == Br && len(succs) == 2:
+				attrs = fmt.Sprintf(" [label=%q]", []string{"T", "F"}[i])
+			case opcode == Switch:
+				attrs = fmt.Sprintf(" [label=\"%d\"]", i)
+			}
+			fmt.Fprintf(bw, "\tb%d -> b%d%s;\n", id, ids[succ], attrs)
+		}
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+// dotQuote returns s as a quoted DOT string, with newlines replaced by eol,
+// one of the line break escapes \n, \l or \r.
+func dotQuote(s, eol string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	s = strings.Replace(s, "\n", eol, -1)
+	return `"` + s + `"`
+}
@@ -0,0 +1,107 @@
+package llvm
+
+import "strings"
+
+// LLVM 3.2 predates type metadata, the llvm.type.test intrinsic and the
+// LowerTypeTests and WholeProgramDevirt passes, which later versions use to
+// implement control-flow integrity. The functions below provide the same
+// scheme for indirect calls within a module: functions are made members of
+// named types, indirect calls are preceded by a test that their callee is
+// a member of the expected type, and LowerTypeTests replaces the tests with
+// comparisons against the members' addresses once the module is complete.
+// Tests are not lowered to jump tables or bit sets, so their cost grows
+// with the number of members, and there is no devirtualization.
+
+const (
+	typeMetadataName = "gollvm.type"
+	typeTestPrefix   = "gollvm.type.test."
+)
+
+// AddTypeMetadata makes the function or global variable g a member of the
+// type typeID, e.g. a mangled function signature, so that CreateTypeTest
+// accepts its address. A global may be a member of several types.
+func AddTypeMetadata(g Value, typeID string) {
+	m := g.GlobalParent()
+	c := m.Context()
+	m.AddNamedMetadataOperand(typeMetadataName, c.MDNode([]Value{c.MDString(typeID), g}))
+}
+
+// CreateTypeTest creates a test of whether the pointer ptr is the address
+// of a member of the type typeID, returning an i1. The test is a call to a
+// placeholder function until it is lowered by LowerTypeTests.
+func (b Builder) CreateTypeTest(ptr Value, typeID string, name string) Value {
+	m := b.insertModule()
+	ctx := m.Context()
+	i8ptr := PointerType(ctx.Int8Type(), 0)
+	ft := FunctionType(ctx.Int1Type(), []Type{i8ptr}, false)
+	test := getOrInsertFunction(m, typeTestPrefix+typeID, ft)
+	if ptr.Type() != i8ptr {
+		ptr = b.CreateBitCast(ptr, i8ptr, "")
+	}
+	return b.CreateCall(test, []Value{ptr}, name)
+}
+
+// CreateTypeCheck creates a type test of ptr, as CreateTypeTest does,
+// followed by a branch to a block calling llvm.trap if it fails. The
+// builder is left at the end of the block continuing after a successful
+// test, where the checked indirect call may be created.
+func (b Builder) CreateTypeCheck(ptr Value, typeID string) {
+	ok := b.CreateTypeTest(ptr, typeID, "")
+	f := b.GetInsertBlock().Parent()
+	trap := AddBasicBlock(f, "cfi.trap")
+	cont := AddBasicBlock(f, "cfi.cont")
+	b.CreateCondBr(ok, cont, trap)
+	b.SetInsertPointAtEnd(trap)
+	b.CreateTrap()
+	b.CreateUnreachable()
+	b.SetInsertPointAtEnd(cont)
+}
+
+// LowerTypeTests replaces the type tests in the module m with comparisons
+// of the tested pointer against the address of each member of the type,
+// and returns the number of tests replaced. It must be run once the module
+// is complete, since a type's members are those added by AddTypeMetadata
+// by then, and before optimization.
+func LowerTypeTests(m Module) int {
+	members := make(map[string][]Value)
+	for _, node := range m.NamedMetadataOperands(typeMetadataName) {
+		ops := node.MDNodeOperands()
+		if len(ops) != 2 || ops[1].IsNil() {
+			continue // the member has been deleted
+		}
+		id := ops[0].MDStringValue()
+		members[id] = append(members[id], ops[1])
+	}
+
+	b := m.Context().NewBuilder()
+	defer b.Dispose()
+	i8ptr := PointerType(m.Context().Int8Type(), 0)
+	n := 0
+	for f := m.FirstFunction(); !f.IsNil(); {
+		next := NextFunction(f)
+		if !strings.HasPrefix(f.Name(), typeTestPrefix) || !f.IsDeclaration() {
+			f = next
+			continue
+		}
+		id := strings.TrimPrefix(f.Name(), typeTestPrefix)
+		var calls []Value
+		for u := f.FirstUse(); !u.IsNil(); u = u.NextUse() {
+			calls = append(calls, u.User())
+		}
+		for _, call := range calls {
+			b.SetInsertPointBefore(call)
+			ptr := call.Operand(0)
+			result := ConstInt(m.Context().Int1Type(), 0, false)
+			for _, member := range members[id] {
+				eq := b.CreateICmp(IntEQ, ptr, ConstBitCast(member, i8ptr), "")
+				result = b.CreateOr(result, eq, "")
+			}
+			call.ReplaceAllUsesWith(result)
+			call.EraseFromParentAsInstruction()
+			n++
+		}
+		f.EraseFromParentAsFunction()
+		f = next
+	}
+	return n
+}
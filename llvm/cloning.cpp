@@ -0,0 +1,48 @@
+#include <llvm/Attributes.h>
+#include <llvm/Function.h>
+#include <llvm/Module.h>
+#include <llvm/ADT/SmallVector.h>
+#include <llvm/Transforms/Utils/Cloning.h>
+#include <llvm/Transforms/Utils/ValueMapper.h>
+
+extern "C" void cloneFunctionInto(llvm::Function *newFunc,
+                                  llvm::Function *oldFunc,
+                                  llvm::Value **from, llvm::Value **to,
+                                  unsigned n) {
+	llvm::ValueToValueMapTy vmap;
+	for (unsigned i = 0; i < n; i++)
+		vmap[from[i]] = to[i];
+	llvm::SmallVector<llvm::ReturnInst*, 8> returns;
+	llvm::CloneFunctionInto(newFunc, oldFunc, vmap, false, returns);
+
+	// CloneFunctionInto copies parameter attributes by position when the
+	// functions have as many parameters, so give each parameter those of
+	// the parameter it replaces instead.
+	if (newFunc->arg_size() != oldFunc->arg_size())
+		return;
+	const llvm::AttrListPtr &attrs = oldFunc->getAttributes();
+	llvm::SmallVector<llvm::Attributes, 8> params(newFunc->arg_size());
+	for (llvm::Function::arg_iterator a = oldFunc->arg_begin(); a != oldFunc->arg_end(); ++a) {
+		llvm::Argument *to = llvm::dyn_cast_or_null<llvm::Argument>(vmap.lookup(a));
+		if (to && to->getParent() == newFunc)
+			params[to->getArgNo()] = attrs.getParamAttributes(a->getArgNo() + 1);
+	}
+	llvm::SmallVector<llvm::AttributeWithIndex, 8> list;
+	if (attrs.getRetAttributes().hasAttributes())
+		list.push_back(llvm::AttributeWithIndex::get(0, attrs.getRetAttributes()));
+	for (unsigned i = 0; i < params.size(); i++) {
+		if (params[i].hasAttributes())
+			list.push_back(llvm::AttributeWithIndex::get(i + 1, params[i]));
+	}
+	if (attrs.getFnAttributes().hasAttributes())
+		list.push_back(llvm::AttributeWithIndex::get(~0U, attrs.getFnAttributes()));
+	newFunc->setAttributes(llvm::AttrListPtr::get(list));
+}
+
+extern "C" llvm::Module *cloneModule(llvm::Module *m) {
+	return llvm::CloneModule(m);
+}
+
+extern "C" void deleteFunctionBody(llvm::Function *f) {
+	f->deleteBody();
+}
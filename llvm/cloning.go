@@ -0,0 +1,142 @@
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+
+extern void cloneFunctionInto(LLVMValueRef, LLVMValueRef,
+                              LLVMValueRef*, LLVMValueRef*, unsigned);
+extern LLVMModuleRef cloneModule(LLVMModuleRef);
+extern void deleteFunctionBody(LLVMValueRef);
+*/
+import "C"
+
+import "fmt"
+
+// CloneFunctionInto clones the body of oldf into newf, which must be a
+// function declaration in the same module. vmap maps values referenced in
+// oldf to their replacements in newf; every parameter of oldf must be
+// mapped.
+// See llvm::CloneFunctionInto.
+func CloneFunctionInto(newf, oldf Value, vmap map[Value]Value) {
+	from := make([]Value, 0, len(vmap))
+	to := make([]Value, 0, len(vmap))
+	for k, v := range vmap {
+		from = append(from, k)
+		to = append(to, v)
+	}
+	fromptr, n := llvmValueRefs(from)
+	toptr, _ := llvmValueRefs(to)
+	C.cloneFunctionInto(newf.C, oldf.C, fromptr, toptr, n)
+}
+
+// CloneFunctionWithParams creates a new function named name, in the same
+// module as f, with the same return type as f and the parameter types
+// params, and clones the body of f into it.
+//
+// paramMap[i] is the index in params of the parameter replacing f's i'th
+// parameter, or -1 if the parameter has been removed, in which case uses of
+// it are replaced with undef. Parameters of the new function that do not
+// replace a parameter of f are unused by the cloned body. The attributes of
+// each parameter of f are moved with it. CloneFunctionWithParams panics if
+// paramMap does not map each parameter of f to a distinct parameter of the
+// same type.
+func CloneFunctionWithParams(f Value, name string, params []Type, paramMap []int) (nf Value) {
+	ft := f.Type().ElementType()
+	oldtypes := ft.ParamTypes()
+	if len(paramMap) != len(oldtypes) {
+		panic(fmt.Sprintf("CloneFunctionWithParams: paramMap has %d entries, but %s has %d parameters", len(paramMap), f.Name(), len(oldtypes)))
+	}
+	mapped := make([]bool, len(params))
+	for i, j := range paramMap {
+		switch {
+		case j < -1 || j >= len(params):
+			panic(fmt.Sprintf("CloneFunctionWithParams: paramMap[%d] is %d, out of range for %d parameters", i, j, len(params)))
+		case j == -1:
+			continue
+		case mapped[j]:
+			panic(fmt.Sprintf("CloneFunctionWithParams: parameter %d is mapped more than once", j))
+		case params[j] != oldtypes[i]:
+			panic(fmt.Sprintf("CloneFunctionWithParams: parameter %d of %s has type %v, but params[%d] is %v", i, f.Name(), oldtypes[i], j, params[j]))
+		}
+		mapped[j] = true
+	}
+	nft := FunctionType(ft.ReturnType(), params, ft.IsFunctionVarArg())
+	nf = AddFunction(f.GlobalParent(), name, nft)
+	nf.SetFunctionCallConv(f.FunctionCallConv())
+	nf.SetLinkage(f.Linkage())
+
+	oldparams := f.Params()
+	newparams := nf.Params()
+	vmap := make(map[Value]Value)
+	for i, p := range oldparams {
+		if j := paramMap[i]; j >= 0 {
+			newparams[j].SetName(p.Name())
+			vmap[p] = newparams[j]
+		} else {
+			vmap[p] = Undef(p.Type())
+		}
+	}
+	CloneFunctionInto(nf, f, vmap)
+	return nf
+}
+
+// CloneFunctionAddingParam clones f into a new function named name, with an
+// additional parameter of type t inserted at index i. The new parameter is
+// returned along with the function, so that the cloned body may be updated
+// to use it.
+func CloneFunctionAddingParam(f Value, name string, i int, t Type) (nf, param Value) {
+	oldtypes := f.Type().ElementType().ParamTypes()
+	params := make([]Type, 0, len(oldtypes)+1)
+	params = append(params, oldtypes[:i]...)
+	params = append(params, t)
+	params = append(params, oldtypes[i:]...)
+	paramMap := make([]int, len(oldtypes))
+	for j := range paramMap {
+		if j < i {
+			paramMap[j] = j
+		} else {
+			paramMap[j] = j + 1
+		}
+	}
+	nf = CloneFunctionWithParams(f, name, params, paramMap)
+	return nf, nf.Param(i)
+}
+
+// CloneFunctionRemovingParam clones f into a new function named name, with
+// the parameter at index i removed. Uses of the removed parameter in the
+// cloned body are replaced with undef.
+func CloneFunctionRemovingParam(f Value, name string, i int) (nf Value) {
+	oldtypes := f.Type().ElementType().ParamTypes()
+	params := make([]Type, 0, len(oldtypes))
+	params = append(params, oldtypes[:i]...)
+	params = append(params, oldtypes[i+1:]...)
+	paramMap := make([]int, len(oldtypes))
+	for j := range paramMap {
+		switch {
+		case j < i:
+			paramMap[j] = j
+		case j == i:
+			paramMap[j] = -1
+		default:
+			paramMap[j] = j - 1
+		}
+	}
+	return CloneFunctionWithParams(f, name, params, paramMap)
+}
+
+// Clone returns a copy of the module m, in the same context. Personalities
+// set with SetPersonality are set for the copies of the functions.
+// See llvm::CloneModule.
+func (m Module) Clone() (c Module) {
+	c.C = C.cloneModule(m.C)
+	registerModule(c)
+	copyPersonalities(c, m)
+	return
+}
+
+// DeleteBody removes the body of the function f, making it a declaration.
+// Its linkage should then be set to external or extern_weak.
+// See Function::deleteBody.
+func (f Value) DeleteBody() {
+	C.deleteFunctionBody(f.C)
+}
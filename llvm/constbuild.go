@@ -0,0 +1,203 @@
+package llvm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ConstBuilder builds constant initializers, such as runtime type
+// descriptors, from Go values, laying them out as the target does.
+type ConstBuilder struct {
+	Module     Module
+	TargetData TargetData
+}
+
+// Struct returns a constant struct holding fields, with explicit [N x i8]
+// padding inserted before each field as needed to align it, and at the end
+// to round the size up to a multiple of the struct's alignment. Its layout
+// is that of a struct of the same fields, but the padding is zeroed rather
+// than left undefined by the code generator.
+func (cb ConstBuilder) Struct(fields ...Value) Value {
+	ctx := cb.Module.Context()
+	var vals []Value
+	offset, maxAlign := uint64(0), uint64(1)
+	for _, f := range fields {
+		align := uint64(cb.TargetData.ABITypeAlignment(f.Type()))
+		if align > maxAlign {
+			maxAlign = align
+		}
+		vals = cb.pad(vals, offset, align)
+		offset = alignUp(offset, align)
+		vals = append(vals, f)
+		offset += cb.TargetData.TypeAllocSize(f.Type())
+	}
+	vals = cb.pad(vals, offset, maxAlign)
+	return ctx.ConstStruct(vals, false)
+}
+
+// pad appends to vals a zero [N x i8] array, if needed to align offset.
+func (cb ConstBuilder) pad(vals []Value, offset, align uint64) []Value {
+	if n := alignUp(offset, align) - offset; n > 0 {
+		t := ArrayType(cb.Module.Context().Int8Type(), int(n))
+		vals = append(vals, ConstNull(t))
+	}
+	return vals
+}
+
+func alignUp(offset, align uint64) uint64 {
+	return (offset + align - 1) / align * align
+}
+
+// Value returns a constant for the Go value x:
+//
+//	Value                    returned unchanged, e.g. a function pointer
+//	bool                     i8, 0 or 1
+//	int, uint, uintptr       integer of the target's pointer size
+//	intN, uintN              iN
+//	float32, float64         float, double
+//	string                   {i8*, intptr} pointing at a mergeable string
+//	array, slice             constant array of the element values
+//	struct                   padded struct of the exported fields' values
+//
+// Slices must not be empty, so that their element type is known, and the
+// elements of arrays and slices, e.g. of []interface{}, must convert to
+// constants of the same type. Value panics if x cannot be converted; see
+// TryValue.
+func (cb ConstBuilder) Value(x interface{}) Value {
+	v, err := cb.TryValue(x)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// TryValue returns a constant for the Go value x, as Value does, or an
+// error if x cannot be converted.
+func (cb ConstBuilder) TryValue(x interface{}) (Value, error) {
+	if v, ok := x.(Value); ok {
+		return v, nil
+	}
+	if x == nil {
+		return Value{}, errors.New("cannot convert nil to a constant")
+	}
+	return cb.value(reflect.ValueOf(x))
+}
+
+func (cb ConstBuilder) value(v reflect.Value) (Value, error) {
+	ctx := cb.Module.Context()
+	switch v.Kind() {
+	case reflect.Bool:
+		var n uint64
+		if v.Bool() {
+			n = 1
+		}
+		return ConstInt(ctx.Int8Type(), n, false), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ConstInt(cb.intType(v.Type()), uint64(v.Int()), true), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return ConstInt(cb.intType(v.Type()), v.Uint(), false), nil
+	case reflect.Float32:
+		return ConstFloat(ctx.FloatType(), v.Float()), nil
+	case reflect.Float64:
+		return ConstFloat(ctx.DoubleType(), v.Float()), nil
+	case reflect.String:
+		s := v.String()
+		i8ptr := PointerType(ctx.Int8Type(), 0)
+		ptr := ConstBitCast(cb.Module.MergeableString(s), i8ptr)
+		return cb.Struct(ptr, ConstInt(cb.uintptrType(), uint64(len(s)), false)), nil
+	case reflect.Array, reflect.Slice:
+		if v.Len() == 0 {
+			if v.Kind() == reflect.Slice {
+				return Value{}, errors.New("cannot determine element type of empty slice")
+			}
+			return ConstArray(cb.Module.Context().Int8Type(), nil), nil
+		}
+		elems := make([]Value, v.Len())
+		for i := range elems {
+			elem, err := cb.TryValue(v.Index(i).Interface())
+			if err != nil {
+				return Value{}, err
+			}
+			if i > 0 && elem.Type() != elems[0].Type() {
+				return Value{}, fmt.Errorf("element %d of %v has type %v, not %v",
+					i, v.Type(), elem.Type(), elems[0].Type())
+			}
+			elems[i] = elem
+		}
+		return ConstArray(elems[0].Type(), elems), nil
+	case reflect.Struct:
+		var fields []Value
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue // unexported
+			}
+			field, err := cb.TryValue(v.Field(i).Interface())
+			if err != nil {
+				return Value{}, err
+			}
+			fields = append(fields, field)
+		}
+		return cb.Struct(fields...), nil
+	}
+	return Value{}, fmt.Errorf("cannot convert %v to a constant", v.Type())
+}
+
+func (cb ConstBuilder) uintptrType() Type {
+	return cb.Module.Context().IntType(cb.TargetData.PointerSize() * 8)
+}
+
+func (cb ConstBuilder) intType(t reflect.Type) Type {
+	switch t.Kind() {
+	case reflect.Int, reflect.Uint, reflect.Uintptr:
+		return cb.uintptrType()
+	}
+	return cb.Module.Context().IntType(t.Bits())
+}
+
+// Table adds to the module a constant global variable named name, whose
+// initializer is an array with an element for each element of rows, a
+// slice, converted with Value. Each row must convert to the same type, so
+// fields holding relocations, such as function pointers of differing types,
+// should be Values bitcast to a common pointer type. The global is aligned
+// as its element type. Table panics if rows cannot be converted; see
+// TryTable.
+func (cb ConstBuilder) Table(name string, rows interface{}) Value {
+	g, err := cb.TryTable(name, rows)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// TryTable adds a table to the module, as Table does, or returns an error,
+// leaving the module unchanged, if rows cannot be converted.
+func (cb ConstBuilder) TryTable(name string, rows interface{}) (Value, error) {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice {
+		return Value{}, fmt.Errorf("table %s rows must be a slice, not %T", name, rows)
+	}
+	elems := make([]Value, v.Len())
+	var t Type
+	for i := range elems {
+		elem, err := cb.TryValue(v.Index(i).Interface())
+		if err != nil {
+			return Value{}, fmt.Errorf("table %s row %d: %v", name, i, err)
+		}
+		elems[i] = elem
+		if i == 0 {
+			t = elem.Type()
+		} else if elem.Type() != t {
+			return Value{}, fmt.Errorf("table %s row %d has type %v, not %v", name, i, elem.Type(), t)
+		}
+	}
+	if t.IsNil() {
+		t = cb.Module.Context().Int8Type()
+	}
+	init := ConstArray(t, elems)
+	g := AddGlobal(cb.Module, init.Type(), name)
+	g.SetInitializer(init)
+	g.SetGlobalConstant(true)
+	g.SetAlignment(cb.TargetData.ABITypeAlignment(t))
+	return g, nil
+}
@@ -0,0 +1,41 @@
+#include <llvm/Constants.h>
+#include <llvm/DataLayout.h>
+#include <llvm/Instruction.h>
+#include <llvm/ADT/ArrayRef.h>
+#include <llvm/Analysis/ConstantFolding.h>
+#include <llvm/Support/ErrorHandling.h>
+#include <llvm-c/Core.h>
+
+extern "C" llvm::Constant *constantFoldInstruction(llvm::Instruction *inst,
+                                                   llvm::DataLayout *td) {
+	return llvm::ConstantFoldInstruction(inst, td);
+}
+
+// instructionOpcode maps the C API's opcode to the Instruction opcode, as
+// Core.cpp's map_from_llvmopcode does; the enumerations differ from Trunc
+// onwards.
+static unsigned instructionOpcode(LLVMOpcode opcode) {
+	switch (opcode) {
+#define HANDLE_INST(num, opc, clas) case LLVM##opc: return num;
+#include <llvm/Instruction.def>
+#undef HANDLE_INST
+	}
+	llvm_unreachable("unhandled opcode");
+}
+
+extern "C" llvm::Constant *constantFoldInstOperands(LLVMOpcode opcode,
+                                                    llvm::Type *destTy,
+                                                    llvm::Constant **ops,
+                                                    unsigned n,
+                                                    llvm::DataLayout *td) {
+	llvm::ArrayRef<llvm::Constant*> opsRef(ops, n);
+	return llvm::ConstantFoldInstOperands(instructionOpcode(opcode), destTy,
+	                                      opsRef, td);
+}
+
+extern "C" llvm::Constant *constantFoldCompare(unsigned predicate,
+                                               llvm::Constant *lhs,
+                                               llvm::Constant *rhs,
+                                               llvm::DataLayout *td) {
+	return llvm::ConstantFoldCompareInstOperands(predicate, lhs, rhs, td);
+}
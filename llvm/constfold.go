@@ -0,0 +1,62 @@
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+#include <llvm-c/Target.h>
+
+extern LLVMValueRef constantFoldInstruction(LLVMValueRef, LLVMTargetDataRef);
+extern LLVMValueRef constantFoldInstOperands(LLVMOpcode, LLVMTypeRef,
+                                             LLVMValueRef*, unsigned,
+                                             LLVMTargetDataRef);
+extern LLVMValueRef constantFoldCompare(unsigned, LLVMValueRef, LLVMValueRef,
+                                        LLVMTargetDataRef);
+*/
+import "C"
+
+// The functions in this file fold instructions using LLVM's constant folder,
+// so that the results, including floating point rounding, match those of
+// the optimizer. If td is non-nil, it is used to fold target dependent
+// expressions such as loads from constant globals and pointer arithmetic;
+// pass a zero TargetData to fold target independently.
+
+// ConstantFoldInstruction attempts to fold the instruction inst, whose
+// operands must be constants, into a constant. If inst cannot be folded,
+// the result is nil. inst is not modified.
+// See llvm::ConstantFoldInstruction.
+func ConstantFoldInstruction(inst Value, td TargetData) (v Value) {
+	v.C = C.constantFoldInstruction(inst.C, td.C)
+	return
+}
+
+// ConstantFoldInstOperands attempts to fold an instruction with the opcode
+// op, result type t and the constant operands ops, without the instruction
+// having to be created. Compares must be folded with ConstantFoldICmp or
+// ConstantFoldFCmp instead. If the instruction cannot be folded, the result
+// is nil.
+// See llvm::ConstantFoldInstOperands.
+func ConstantFoldInstOperands(op Opcode, t Type, ops []Value, td TargetData) (v Value) {
+	if op == ICmp || op == FCmp {
+		panic("ConstantFoldInstOperands: compares must use ConstantFoldICmp or ConstantFoldFCmp")
+	}
+	ptr, n := llvmValueRefs(ops)
+	v.C = C.constantFoldInstOperands(C.LLVMOpcode(op), t.C, ptr, n, td.C)
+	return
+}
+
+// ConstantFoldICmp attempts to fold an integer or pointer comparison of the
+// constants lhs and rhs. If the comparison cannot be folded, the result is
+// nil.
+// See llvm::ConstantFoldCompareInstOperands.
+func ConstantFoldICmp(pred IntPredicate, lhs, rhs Value, td TargetData) (v Value) {
+	v.C = C.constantFoldCompare(C.unsigned(pred), lhs.C, rhs.C, td.C)
+	return
+}
+
+// ConstantFoldFCmp attempts to fold a floating point comparison of the
+// constants lhs and rhs. If the comparison cannot be folded, the result is
+// nil.
+// See llvm::ConstantFoldCompareInstOperands.
+func ConstantFoldFCmp(pred FloatPredicate, lhs, rhs Value, td TargetData) (v Value) {
+	v.C = C.constantFoldCompare(C.unsigned(pred), lhs.C, rhs.C, td.C)
+	return
+}
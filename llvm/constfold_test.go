@@ -0,0 +1,32 @@
+package llvm
+
+import "testing"
+
+func TestConstantFoldInstOperands(t *testing.T) {
+	i8, i32 := Int8Type(), Int32Type()
+	tests := []struct {
+		op   Opcode
+		t    Type
+		ops  []Value
+		want uint64
+	}{
+		{Trunc, i8, []Value{ConstInt(i32, 0x1234, false)}, 0x34},
+		{ZExt, i32, []Value{ConstInt(i8, 0xff, false)}, 0xff},
+		{SExt, i32, []Value{ConstInt(i8, 0xff, false)}, 0xffffffff},
+		{BitCast, i32, []Value{ConstInt(i32, 7, false)}, 7},
+		{Select, i32, []Value{ConstInt(Int1Type(), 1, false), ConstInt(i32, 1, false), ConstInt(i32, 2, false)}, 1},
+		{Select, i32, []Value{ConstInt(Int1Type(), 0, false), ConstInt(i32, 1, false), ConstInt(i32, 2, false)}, 2},
+	}
+	for _, test := range tests {
+		v := ConstantFoldInstOperands(test.op, test.t, test.ops, TargetData{})
+		if v.IsNil() {
+			t.Errorf("opcode %d: not folded", test.op)
+			continue
+		}
+		if v.Type() != test.t {
+			t.Errorf("opcode %d: folded to a value of the wrong type", test.op)
+		} else if got := v.ZExtValue(); got != test.want {
+			t.Errorf("opcode %d: folded to %#x, want %#x", test.op, got, test.want)
+		}
+	}
+}
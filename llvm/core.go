@@ -7,6 +7,7 @@ package llvm
 import "C"
 import "unsafe"
 import "errors"
+import "sync"
 
 // TODO: Add comments
 // TODO: Use Go's reflection in order to simplify bindings?
@@ -200,6 +201,7 @@ const (
 
 const (
 	VoidTypeKind      TypeKind = C.LLVMVoidTypeKind
+	HalfTypeKind      TypeKind = C.LLVMHalfTypeKind
 	FloatTypeKind     TypeKind = C.LLVMFloatTypeKind
 	DoubleTypeKind    TypeKind = C.LLVMDoubleTypeKind
 	X86_FP80TypeKind  TypeKind = C.LLVMX86_FP80TypeKind
@@ -213,6 +215,7 @@ const (
 	PointerTypeKind   TypeKind = C.LLVMPointerTypeKind
 	VectorTypeKind    TypeKind = C.LLVMVectorTypeKind
 	MetadataTypeKind  TypeKind = C.LLVMMetadataTypeKind
+	X86_MMXTypeKind   TypeKind = C.LLVMX86_MMXTypeKind
 )
 
 //-------------------------------------------------------------------------
@@ -259,6 +262,16 @@ const (
 	ColdCallConv        CallConv = C.LLVMColdCallConv
 	X86StdcallCallConv  CallConv = C.LLVMX86StdcallCallConv
 	X86FastcallCallConv CallConv = C.LLVMX86FastcallCallConv
+
+	// The following calling conventions are not enumerated by the C API.
+	// See llvm::CallingConv::ID.
+	GHCCallConv         CallConv = 10
+	ARMAPCSCallConv     CallConv = 66
+	ARMAAPCSCallConv    CallConv = 67
+	ARMAAPCSVFPCallConv CallConv = 68
+	X86ThisCallCallConv CallConv = 70
+	PTXKernelCallConv   CallConv = 71
+	PTXDeviceCallConv   CallConv = 72
 )
 
 //-------------------------------------------------------------------------
@@ -316,7 +329,13 @@ const (
 
 func NewContext() Context    { return Context{C.LLVMContextCreate()} }
 func GlobalContext() Context { return Context{C.LLVMGetGlobalContext()} }
-func (c Context) Dispose()   { C.LLVMContextDispose(c.C) }
+
+// Dispose destroys the context, and everything in it.
+func (c Context) Dispose() {
+	forgetMDStrings(c)
+	forgetDiagnostics(c)
+	C.LLVMContextDispose(c.C)
+}
 
 func (c Context) MDKindID(name string) (id int) {
 	cname := C.CString(name)
@@ -342,6 +361,7 @@ func NewModule(name string) (m Module) {
 	cname := C.CString(name)
 	m.C = C.LLVMModuleCreateWithName(cname)
 	C.free(unsafe.Pointer(cname))
+	registerModule(m)
 	return
 }
 
@@ -349,11 +369,37 @@ func (c Context) NewModule(name string) (m Module) {
 	cname := C.CString(name)
 	m.C = C.LLVMModuleCreateWithNameInContext(cname, c.C)
 	C.free(unsafe.Pointer(cname))
+	registerModule(m)
 	return
 }
 
-// See llvm::Module::~Module
-func (m Module) Dispose() { C.LLVMDisposeModule(m.C) }
+// See llvm::Module::~Module.
+func (m Module) Dispose() {
+	m.forceDisposed()
+	forgetPersonalities(m)
+	C.LLVMDisposeModule(m.C)
+}
+
+// TryDispose disposes the module, as Dispose does, unless ownership of it
+// has been transferred (see TakenBy) or it has already been disposed, in
+// which case an error is returned and the module is left untouched. The
+// check is best-effort: modules created outside the bindings are not
+// tracked, so a module at the address of one disposed earlier may be
+// reported as disposed.
+func (m Module) TryDispose() error {
+	if err := m.markDisposed(); err != nil {
+		return err
+	}
+	forgetPersonalities(m)
+	C.LLVMDisposeModule(m.C)
+	return nil
+}
+
+// See Module::getContext.
+func (m Module) Context() (c Context) {
+	c.C = C.LLVMGetModuleContext(m.C)
+	return
+}
 
 // Data layout. See Module::getDataLayout.
 func (m Module) DataLayout() string {
@@ -403,6 +449,16 @@ func (m Module) AddNamedMetadataOperand(name string, operand Value) {
 	C.free(unsafe.Pointer(cname))
 }
 
+func (m Module) NamedMetadataOperands(name string) []Value {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	out := make([]Value, C.LLVMGetNamedMetadataNumOperands(m.C, cname))
+	if len(out) > 0 {
+		C.LLVMGetNamedMetadataOperands(m.C, cname, llvmValueRefPtr(&out[0]))
+	}
+	return out
+}
+
 //-------------------------------------------------------------------------
 // llvm.Type
 //-------------------------------------------------------------------------
@@ -424,6 +480,11 @@ func (m Module) AddNamedMetadataOperand(name string, operand Value) {
 // See llvm::LLVMTypeKind::getTypeID.
 func (t Type) TypeKind() TypeKind { return TypeKind(C.LLVMGetTypeKind(t.C)) }
 
+// Equal reports whether t and u are the same type. Types are uniqued within a
+// context, so structurally identical types are equal, except for named
+// struct types, which are only equal to themselves.
+func (t Type) Equal(u Type) bool { return t.C == u.C }
+
 // See llvm::LLVMType::getContext.
 func (t Type) Context() (c Context) {
 	c.C = C.LLVMGetTypeContext(t.C)
@@ -436,7 +497,7 @@ func (c Context) Int8Type() (t Type)  { t.C = C.LLVMInt8TypeInContext(c.C); retu
 func (c Context) Int16Type() (t Type) { t.C = C.LLVMInt16TypeInContext(c.C); return }
 func (c Context) Int32Type() (t Type) { t.C = C.LLVMInt32TypeInContext(c.C); return }
 func (c Context) Int64Type() (t Type) { t.C = C.LLVMInt64TypeInContext(c.C); return }
-func (c Context) IntType() (t Type, numbits int) {
+func (c Context) IntType(numbits int) (t Type) {
 	t.C = C.LLVMIntTypeInContext(c.C, C.unsigned(numbits))
 	return
 }
@@ -457,18 +518,23 @@ func (t Type) IntTypeWidth() int {
 }
 
 // Operations on real types
+func (c Context) HalfType() (t Type)     { t.C = C.LLVMHalfTypeInContext(c.C); return }
 func (c Context) FloatType() (t Type)    { t.C = C.LLVMFloatTypeInContext(c.C); return }
 func (c Context) DoubleType() (t Type)   { t.C = C.LLVMDoubleTypeInContext(c.C); return }
 func (c Context) X86FP80Type() (t Type)  { t.C = C.LLVMX86FP80TypeInContext(c.C); return }
 func (c Context) FP128Type() (t Type)    { t.C = C.LLVMFP128TypeInContext(c.C); return }
 func (c Context) PPCFP128Type() (t Type) { t.C = C.LLVMPPCFP128TypeInContext(c.C); return }
 
+func HalfType() (t Type)     { t.C = C.LLVMHalfType(); return }
 func FloatType() (t Type)    { t.C = C.LLVMFloatType(); return }
 func DoubleType() (t Type)   { t.C = C.LLVMDoubleType(); return }
 func X86FP80Type() (t Type)  { t.C = C.LLVMX86FP80Type(); return }
 func FP128Type() (t Type)    { t.C = C.LLVMFP128Type(); return }
 func PPCFP128Type() (t Type) { t.C = C.LLVMPPCFP128Type(); return }
 
+func (c Context) X86MMXType() (t Type) { t.C = C.LLVMX86MMXTypeInContext(c.C); return }
+func X86MMXType() (t Type)             { t.C = C.LLVMX86MMXType(); return }
+
 // Operations on function types
 func FunctionType(returnType Type, paramTypes []Type, isVarArg bool) (t Type) {
 	var pt *C.LLVMTypeRef
@@ -540,6 +606,8 @@ func (t Type) StructSetBody(elementTypes []Type, packed bool) {
 	C.LLVMStructSetBody(t.C, pt, ptlen, boolToLLVMBool(packed))
 }
 
+func (t Type) StructName() string           { return C.GoString(C.LLVMGetStructName(t.C)) }
+func (t Type) IsStructOpaque() bool         { return C.LLVMIsOpaqueStruct(t.C) != 0 }
 func (t Type) IsStructPacked() bool         { return C.LLVMIsPackedStruct(t.C) != 0 }
 func (t Type) StructElementTypesCount() int { return int(C.LLVMCountStructElementTypes(t.C)) }
 func (t Type) StructElementTypes() []Type {
@@ -675,11 +743,14 @@ func (v Value) SetMetadata(kind int, node Value) {
 // Conversion functions. Generated using preprocess statements above. Return
 // the input value if it is an instance of the specified class, otherwise NULL.
 // See llvm::dyn_cast_or_null<>.
-func (v Value) IsAArgument() (rv Value)   { rv.C = C.LLVMIsAArgument(v.C); return }
-func (v Value) IsABasicBlock() (rv Value) { rv.C = C.LLVMIsABasicBlock(v.C); return }
-func (v Value) IsAInlineAsm() (rv Value)  { rv.C = C.LLVMIsAInlineAsm(v.C); return }
-func (v Value) IsAUser() (rv Value)       { rv.C = C.LLVMIsAUser(v.C); return }
-func (v Value) IsAConstant() (rv Value)   { rv.C = C.LLVMIsAConstant(v.C); return }
+func (v Value) IsAArgument() (rv Value)     { rv.C = C.LLVMIsAArgument(v.C); return }
+func (v Value) IsABasicBlock() (rv Value)   { rv.C = C.LLVMIsABasicBlock(v.C); return }
+func (v Value) IsAInlineAsm() (rv Value)    { rv.C = C.LLVMIsAInlineAsm(v.C); return }
+func (v Value) IsAMDNode() (rv Value)       { rv.C = C.LLVMIsAMDNode(v.C); return }
+func (v Value) IsAMDString() (rv Value)     { rv.C = C.LLVMIsAMDString(v.C); return }
+func (v Value) IsAUser() (rv Value)         { rv.C = C.LLVMIsAUser(v.C); return }
+func (v Value) IsAConstant() (rv Value)     { rv.C = C.LLVMIsAConstant(v.C); return }
+func (v Value) IsABlockAddress() (rv Value) { rv.C = C.LLVMIsABlockAddress(v.C); return }
 func (v Value) IsAConstantAggregateZero() (rv Value) {
 	rv.C = C.LLVMIsAConstantAggregateZero(v.C)
 	return
@@ -713,16 +784,19 @@ func (v Value) IsAExtractElementInst() (rv Value)  { rv.C = C.LLVMIsAExtractElem
 func (v Value) IsAGetElementPtrInst() (rv Value)   { rv.C = C.LLVMIsAGetElementPtrInst(v.C); return }
 func (v Value) IsAInsertElementInst() (rv Value)   { rv.C = C.LLVMIsAInsertElementInst(v.C); return }
 func (v Value) IsAInsertValueInst() (rv Value)     { rv.C = C.LLVMIsAInsertValueInst(v.C); return }
+func (v Value) IsALandingPadInst() (rv Value)      { rv.C = C.LLVMIsALandingPadInst(v.C); return }
 func (v Value) IsAPHINode() (rv Value)             { rv.C = C.LLVMIsAPHINode(v.C); return }
 func (v Value) IsASelectInst() (rv Value)          { rv.C = C.LLVMIsASelectInst(v.C); return }
 func (v Value) IsAShuffleVectorInst() (rv Value)   { rv.C = C.LLVMIsAShuffleVectorInst(v.C); return }
 func (v Value) IsAStoreInst() (rv Value)           { rv.C = C.LLVMIsAStoreInst(v.C); return }
 func (v Value) IsATerminatorInst() (rv Value)      { rv.C = C.LLVMIsATerminatorInst(v.C); return }
 func (v Value) IsABranchInst() (rv Value)          { rv.C = C.LLVMIsABranchInst(v.C); return }
+func (v Value) IsAIndirectBrInst() (rv Value)      { rv.C = C.LLVMIsAIndirectBrInst(v.C); return }
 func (v Value) IsAInvokeInst() (rv Value)          { rv.C = C.LLVMIsAInvokeInst(v.C); return }
 func (v Value) IsAReturnInst() (rv Value)          { rv.C = C.LLVMIsAReturnInst(v.C); return }
 func (v Value) IsASwitchInst() (rv Value)          { rv.C = C.LLVMIsASwitchInst(v.C); return }
 func (v Value) IsAUnreachableInst() (rv Value)     { rv.C = C.LLVMIsAUnreachableInst(v.C); return }
+func (v Value) IsAResumeInst() (rv Value)          { rv.C = C.LLVMIsAResumeInst(v.C); return }
 func (v Value) IsAUnaryInstruction() (rv Value)    { rv.C = C.LLVMIsAUnaryInstruction(v.C); return }
 func (v Value) IsAAllocaInst() (rv Value)          { rv.C = C.LLVMIsAAllocaInst(v.C); return }
 func (v Value) IsACastInst() (rv Value)            { rv.C = C.LLVMIsACastInst(v.C); return }
@@ -763,18 +837,20 @@ func (v Value) IsUndef() bool           { return C.LLVMIsUndef(v.C) != 0 }
 func ConstPointerNull(t Type) (v Value) { v.C = C.LLVMConstPointerNull(t.C); return }
 
 // Operations on metadata
+
+// MDString returns the metadata string str, from the cache described at
+// SetMDStringCacheLimit if it is enabled and the string is there.
 func (c Context) MDString(str string) (v Value) {
+	if v = cachedMDString(c, str); !v.IsNil() {
+		return
+	}
 	cstr := C.CString(str)
 	v.C = C.LLVMMDStringInContext(c.C, cstr, C.unsigned(len(str)))
 	C.free(unsafe.Pointer(cstr))
+	cacheMDString(c, str, v)
 	return
 }
-func MDString(str string) (v Value) {
-	cstr := C.CString(str)
-	v.C = C.LLVMMDString(cstr, C.unsigned(len(str)))
-	C.free(unsafe.Pointer(cstr))
-	return
-}
+func MDString(str string) (v Value) { return GlobalContext().MDString(str) }
 func (c Context) MDNode(vals []Value) (v Value) {
 	ptr, nvals := llvmValueRefs(vals)
 	v.C = C.LLVMMDNodeInContext(c.C, ptr, nvals)
@@ -786,6 +862,21 @@ func MDNode(vals []Value) (v Value) {
 	return
 }
 
+// MDStringValue returns the string held by an MDString.
+func (v Value) MDStringValue() string {
+	var n C.unsigned
+	cstr := C.LLVMGetMDString(v.C, &n)
+	return C.GoStringN(cstr, C.int(n))
+}
+func (v Value) MDNodeOperandsCount() int { return int(C.LLVMGetMDNodeNumOperands(v.C)) }
+func (v Value) MDNodeOperands() []Value {
+	out := make([]Value, v.MDNodeOperandsCount())
+	if len(out) > 0 {
+		C.LLVMGetMDNodeOperands(v.C, llvmValueRefPtr(&out[0]))
+	}
+	return out
+}
+
 // Operations on scalar constants
 func ConstInt(t Type, n uint64, signExtend bool) (v Value) {
 	v.C = C.LLVMConstInt(t.C,
@@ -793,6 +884,23 @@ func ConstInt(t Type, n uint64, signExtend bool) (v Value) {
 		boolToLLVMBool(signExtend))
 	return
 }
+
+// ConstIntOfArbitraryPrecision returns an integer constant of type t, which
+// may be wider than 64 bits, from the words of its value, least significant
+// first.
+// See ConstantInt::get(Type*, const APInt&).
+func ConstIntOfArbitraryPrecision(t Type, words []uint64) (v Value) {
+	var pw *C.uint64_t
+	if len(words) > 0 {
+		pw = (*C.uint64_t)(unsafe.Pointer(&words[0]))
+	}
+	v.C = C.LLVMConstIntOfArbitraryPrecision(t.C, C.unsigned(len(words)), pw)
+	return
+}
+
+// ConstIntFromString parses str, in the given radix, as an integer constant
+// of type t, which may be wider than 64 bits. The string may begin with a
+// minus sign.
 func ConstIntFromString(t Type, str string, radix int) (v Value) {
 	cstr := C.CString(str)
 	v.C = C.LLVMConstIntOfString(t.C, cstr, C.uint8_t(radix))
@@ -803,6 +911,10 @@ func ConstFloat(t Type, n float64) (v Value) {
 	v.C = C.LLVMConstReal(t.C, C.double(n))
 	return
 }
+
+// ConstFloatFromString parses the decimal or hexadecimal floating point
+// literal str as a constant of the floating point type t, rounding it
+// directly to the precision of t rather than via float64.
 func ConstFloatFromString(t Type, str string) (v Value) {
 	cstr := C.CString(str)
 	v.C = C.LLVMConstRealOfString(t.C, cstr)
@@ -853,6 +965,7 @@ func ConstVector(scalarConstVals []Value, packed bool) (v Value) {
 // Constant expressions
 func (v Value) Opcode() Opcode                { return Opcode(C.LLVMGetConstOpcode(v.C)) }
 func (v Value) InstructionOpcode() Opcode     { return Opcode(C.LLVMGetInstructionOpcode(v.C)) }
+func (v Value) IntPredicate() IntPredicate    { return IntPredicate(C.LLVMGetICmpPredicate(v.C)) }
 func AlignOf(t Type) (v Value)                { v.C = C.LLVMAlignOf(t.C); return }
 func SizeOf(t Type) (v Value)                 { v.C = C.LLVMSizeOf(t.C); return }
 func ConstNeg(v Value) (rv Value)             { rv.C = C.LLVMConstNeg(v.C); return }
@@ -925,6 +1038,11 @@ func ConstTruncOrBitCast(v Value, t Type) (rv Value) {
 	return
 }
 func ConstPointerCast(v Value, t Type) (rv Value) { rv.C = C.LLVMConstPointerCast(v.C, t.C); return }
+
+// ConstAddrSpaceCast converts the pointer constant v to the pointer type t,
+// which may be in a different address space. The supported LLVM versions
+// have no addrspacecast, and represent this as a bitcast.
+func ConstAddrSpaceCast(v Value, t Type) (rv Value) { return ConstBitCast(v, t) }
 func ConstIntCast(v Value, t Type, signed bool) (rv Value) {
 	rv.C = C.LLVMConstIntCast(v.C, t.C, boolToLLVMBool(signed))
 	return
@@ -971,6 +1089,22 @@ func ConstInsertValue(agg, val Value, indices []uint32) (rv Value) {
 	return
 }
 
+// InlineAsm returns an inline assembly expression of the function type t,
+// which may be called like a function.
+// See InlineAsm::get.
+func InlineAsm(t Type, asm, constraints string, hasSideEffects, isAlignStack bool) (rv Value) {
+	casm := C.CString(asm)
+	defer C.free(unsafe.Pointer(casm))
+	cconstraints := C.CString(constraints)
+	defer C.free(unsafe.Pointer(cconstraints))
+	rv.C = C.LLVMConstInlineAsm(t.C, casm, cconstraints,
+		boolToLLVMBool(hasSideEffects), boolToLLVMBool(isAlignStack))
+	return
+}
+
+// BlockAddress returns a constant holding the address of the basic block bb
+// in the function f, which may be used as the target of an indirectbr
+// instruction (see Builder.CreateIndirectBr).
 func BlockAddress(f Value, bb BasicBlock) (v Value) {
 	v.C = C.LLVMBlockAddress(f.C, bb.C)
 	return
@@ -1051,7 +1185,7 @@ func (m Module) FirstFunction() (v Value)  { v.C = C.LLVMGetFirstFunction(m.C);
 func (m Module) LastFunction() (v Value)   { v.C = C.LLVMGetLastFunction(m.C); return }
 func NextFunction(v Value) (rv Value)      { rv.C = C.LLVMGetNextFunction(v.C); return }
 func PrevFunction(v Value) (rv Value)      { rv.C = C.LLVMGetPreviousFunction(v.C); return }
-func (v Value) EraseFromParentAsFunction() { C.LLVMDeleteFunction(v.C) }
+func (v Value) EraseFromParentAsFunction() { v.forgetPersonality(); C.LLVMDeleteFunction(v.C) }
 func (v Value) IntrinsicID() int           { return int(C.LLVMGetIntrinsicID(v.C)) }
 func (v Value) FunctionCallConv() CallConv {
 	return CallConv(C.LLVMCallConv(C.LLVMGetFunctionCallConv(v.C)))
@@ -1087,6 +1221,23 @@ func (v Value) RemoveAttribute(a Attribute) { C.LLVMRemoveAttribute(v.C, C.LLVMA
 func (v Value) Attribute() Attribute        { return Attribute(C.LLVMGetAttribute(v.C)) }
 func (v Value) SetParamAlignment(align int) { C.LLVMSetParamAlignment(v.C, C.unsigned(align)) }
 
+// ParamIndex returns the zero-based position of the parameter v in its
+// function's parameter list, or -1 if v is not a parameter.
+// LocalVariableDescriptor.Argument expects the position plus one.
+func (v Value) ParamIndex() int {
+	if v.IsAArgument().IsNil() {
+		return -1
+	}
+	i := 0
+	for p := v.ParamParent().FirstParam(); !p.IsNil(); p = NextParam(p) {
+		if p == v {
+			return i
+		}
+		i++
+	}
+	return -1
+}
+
 // Operations on basic blocks
 func (bb BasicBlock) AsValue() (v Value)      { v.C = C.LLVMBasicBlockAsValue(bb.C); return }
 func (v Value) IsBasicBlock() bool            { return C.LLVMValueIsBasicBlock(v.C) != 0 }
@@ -1137,6 +1288,7 @@ func (bb BasicBlock) FirstInstruction() (v Value)  { v.C = C.LLVMGetFirstInstruc
 func (bb BasicBlock) LastInstruction() (v Value)   { v.C = C.LLVMGetLastInstruction(bb.C); return }
 func NextInstruction(v Value) (rv Value)           { rv.C = C.LLVMGetNextInstruction(v.C); return }
 func PrevInstruction(v Value) (rv Value)           { rv.C = C.LLVMGetPreviousInstruction(v.C); return }
+func (v Value) EraseFromParentAsInstruction()      { C.LLVMInstructionEraseFromParent(v.C) }
 
 // Operations on call sites
 func (v Value) SetInstructionCallConv(cc CallConv) {
@@ -1196,7 +1348,12 @@ func (b Builder) InsertWithName(instr Value, name string) {
 	C.LLVMInsertIntoBuilderWithName(b.C, instr.C, cname)
 	C.free(unsafe.Pointer(cname))
 }
-func (b Builder) Dispose() { C.LLVMDisposeBuilder(b.C) }
+func (b Builder) Dispose() {
+	if b.Hook() != nil {
+		b.SetHook(nil)
+	}
+	C.LLVMDisposeBuilder(b.C)
+}
 
 // Metadata
 func (b Builder) SetCurrentDebugLocation(v Value) { C.LLVMSetCurrentDebugLocation(b.C, v.C) }
@@ -1204,34 +1361,71 @@ func (b Builder) CurrentDebugLocation() (v Value) { v.C = C.LLVMGetCurrentDebugL
 func (b Builder) SetInstDebugLocation(v Value)    { C.LLVMSetInstDebugLocation(b.C, v.C) }
 
 // Terminators
-func (b Builder) CreateRetVoid() (rv Value)    { rv.C = C.LLVMBuildRetVoid(b.C); return }
-func (b Builder) CreateRet(v Value) (rv Value) { rv.C = C.LLVMBuildRet(b.C, v.C); return }
+func (b Builder) CreateRetVoid() (rv Value) {
+	rv.C = C.LLVMBuildRetVoid(b.C)
+	b.trace(rv)
+	return
+}
+func (b Builder) CreateRet(v Value) (rv Value) {
+	rv.C = C.LLVMBuildRet(b.C, v.C)
+	b.trace(rv)
+	return
+}
 func (b Builder) CreateAggregateRet(vs []Value) (rv Value) {
 	ptr, nvals := llvmValueRefs(vs)
+	before := b.snapshot()
 	rv.C = C.LLVMBuildAggregateRet(b.C, ptr, nvals)
+	b.traceNew(before)
+	return
+}
+func (b Builder) CreateBr(bb BasicBlock) (rv Value) {
+	rv.C = C.LLVMBuildBr(b.C, bb.C)
+	b.trace(rv)
 	return
 }
-func (b Builder) CreateBr(bb BasicBlock) (rv Value) { rv.C = C.LLVMBuildBr(b.C, bb.C); return }
 func (b Builder) CreateCondBr(ifv Value, thenb, elseb BasicBlock) (rv Value) {
 	rv.C = C.LLVMBuildCondBr(b.C, ifv.C, thenb.C, elseb.C)
+	b.trace(rv)
 	return
 }
 func (b Builder) CreateSwitch(v Value, elseb BasicBlock, numCases int) (rv Value) {
 	rv.C = C.LLVMBuildSwitch(b.C, v.C, elseb.C, C.unsigned(numCases))
+	b.trace(rv)
 	return
 }
 func (b Builder) CreateIndirectBr(addr Value, numDests int) (rv Value) {
 	rv.C = C.LLVMBuildIndirectBr(b.C, addr.C, C.unsigned(numDests))
+	b.trace(rv)
+	return
+}
+
+// CreateIndirectBrWithDests creates an indirectbr instruction that jumps to
+// addr, which must be the BlockAddress of one of the blocks in dests.
+func (b Builder) CreateIndirectBrWithDests(addr Value, dests []BasicBlock) (rv Value) {
+	rv = b.CreateIndirectBr(addr, len(dests))
+	for _, dest := range dests {
+		rv.AddDest(dest)
+	}
 	return
 }
 func (b Builder) CreateInvoke(fn Value, args []Value, then, catch BasicBlock, name string) (rv Value) {
 	cname := C.CString(name)
 	ptr, nvals := llvmValueRefs(args)
 	rv.C = C.LLVMBuildInvoke(b.C, fn.C, ptr, nvals, then.C, catch.C, cname)
+	b.trace(rv)
 	C.free(unsafe.Pointer(cname))
 	return
 }
-func (b Builder) CreateUnreachable() (rv Value) { rv.C = C.LLVMBuildUnreachable(b.C); return }
+func (b Builder) CreateUnreachable() (rv Value) {
+	rv.C = C.LLVMBuildUnreachable(b.C)
+	b.trace(rv)
+	return
+}
+func (b Builder) CreateResume(ex Value) (rv Value) {
+	rv.C = C.LLVMBuildResume(b.C, ex.C)
+	b.trace(rv)
+	return
+}
 
 // Add a case to the switch instruction
 func (v Value) AddCase(on Value, dest BasicBlock) { C.LLVMAddCase(v.C, on.C, dest.C) }
@@ -1243,186 +1437,217 @@ func (v Value) AddDest(dest BasicBlock) { C.LLVMAddDestination(v.C, dest.C) }
 func (b Builder) CreateAdd(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildAdd(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateNSWAdd(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildNSWAdd(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateNUWAdd(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildNUWAdd(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateFAdd(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildFAdd(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateSub(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildSub(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateNSWSub(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildNSWSub(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateNUWSub(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildNUWSub(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateFSub(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildFSub(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateMul(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildMul(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateNSWMul(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildNSWMul(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateNUWMul(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildNUWMul(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateFMul(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildFMul(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateUDiv(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildUDiv(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateSDiv(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildSDiv(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateExactSDiv(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildExactSDiv(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateFDiv(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildFDiv(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateURem(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildURem(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateSRem(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildSRem(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateFRem(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildFRem(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateShl(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildShl(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateLShr(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildLShr(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateAShr(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildAShr(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateAnd(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildAnd(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateOr(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildOr(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateXor(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildXor(b.C, lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateBinOp(op Opcode, lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildBinOp(b.C, C.LLVMOpcode(op), lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateNeg(v Value, name string) (rv Value) {
 	cname := C.CString(name)
 	rv.C = C.LLVMBuildNeg(b.C, v.C, cname)
+	b.trace(rv)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateNSWNeg(v Value, name string) (rv Value) {
 	cname := C.CString(name)
 	rv.C = C.LLVMBuildNSWNeg(b.C, v.C, cname)
+	b.trace(rv)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateNUWNeg(v Value, name string) (rv Value) {
 	cname := C.CString(name)
 	rv.C = C.LLVMBuildNUWNeg(b.C, v.C, cname)
+	b.trace(rv)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateFNeg(v Value, name string) (rv Value) {
 	cname := C.CString(name)
 	rv.C = C.LLVMBuildFNeg(b.C, v.C, cname)
+	b.trace(rv)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateNot(v Value, name string) (rv Value) {
 	cname := C.CString(name)
 	rv.C = C.LLVMBuildNot(b.C, v.C, cname)
+	b.trace(rv)
 	C.free(unsafe.Pointer(cname))
 	return
 }
@@ -1431,46 +1656,57 @@ func (b Builder) CreateNot(v Value, name string) (rv Value) {
 
 func (b Builder) CreateMalloc(t Type, name string) (v Value) {
 	cname := C.CString(name)
+	before := b.snapshot()
 	v.C = C.LLVMBuildMalloc(b.C, t.C, cname)
+	b.traceNew(before)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateArrayMalloc(t Type, val Value, name string) (v Value) {
 	cname := C.CString(name)
+	before := b.snapshot()
 	v.C = C.LLVMBuildArrayMalloc(b.C, t.C, val.C, cname)
+	b.traceNew(before)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateAlloca(t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildAlloca(b.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateArrayAlloca(t Type, val Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildArrayAlloca(b.C, t.C, val.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateFree(p Value) (v Value) {
+	before := b.snapshot()
 	v.C = C.LLVMBuildFree(b.C, p.C)
+	b.traceNew(before)
 	return
 }
 func (b Builder) CreateLoad(p Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildLoad(b.C, p.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateStore(val Value, p Value) (v Value) {
 	v.C = C.LLVMBuildStore(b.C, val.C, p.C)
+	b.trace(v)
 	return
 }
 func (b Builder) CreateGEP(p Value, indices []Value, name string) (v Value) {
 	cname := C.CString(name)
 	ptr, nvals := llvmValueRefs(indices)
 	v.C = C.LLVMBuildGEP(b.C, p.C, ptr, nvals, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
@@ -1478,12 +1714,14 @@ func (b Builder) CreateInBoundsGEP(p Value, indices []Value, name string) (v Val
 	cname := C.CString(name)
 	ptr, nvals := llvmValueRefs(indices)
 	v.C = C.LLVMBuildInBoundsGEP(b.C, p.C, ptr, nvals, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateStructGEP(p Value, i int, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildStructGEP(b.C, p.C, C.unsigned(i), cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
@@ -1491,6 +1729,7 @@ func (b Builder) CreateGlobalString(str, name string) (v Value) {
 	cstr := C.CString(str)
 	cname := C.CString(name)
 	v.C = C.LLVMBuildGlobalString(b.C, cstr, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	C.free(unsafe.Pointer(cstr))
 	return
@@ -1499,6 +1738,7 @@ func (b Builder) CreateGlobalStringPtr(str, name string) (v Value) {
 	cstr := C.CString(str)
 	cname := C.CString(name)
 	v.C = C.LLVMBuildGlobalStringPtr(b.C, cstr, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	C.free(unsafe.Pointer(cstr))
 	return
@@ -1508,114 +1748,141 @@ func (b Builder) CreateGlobalStringPtr(str, name string) (v Value) {
 func (b Builder) CreateTrunc(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildTrunc(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateZExt(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildZExt(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateSExt(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildSExt(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateFPToUI(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildFPToUI(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateFPToSI(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildFPToSI(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateUIToFP(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildUIToFP(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateSIToFP(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildSIToFP(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateFPTrunc(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildFPTrunc(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateFPExt(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildFPExt(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreatePtrToInt(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildPtrToInt(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateIntToPtr(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildIntToPtr(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateBitCast(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildBitCast(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateZExtOrBitCast(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildZExtOrBitCast(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateSExtOrBitCast(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildSExtOrBitCast(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateTruncOrBitCast(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildTruncOrBitCast(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateCast(val Value, op Opcode, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildCast(b.C, C.LLVMOpcode(op), val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 } //
+
+// CreateAddrSpaceCast converts the pointer val to the pointer type t, which
+// may be in a different address space. The supported LLVM versions have no
+// addrspacecast instruction, and represent this as a bitcast.
+func (b Builder) CreateAddrSpaceCast(val Value, t Type, name string) (v Value) {
+	return b.CreateBitCast(val, t, name)
+}
+
 func (b Builder) CreatePointerCast(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildPointerCast(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateIntCast(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildIntCast(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateFPCast(val Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildFPCast(b.C, val.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
@@ -1624,12 +1891,14 @@ func (b Builder) CreateFPCast(val Value, t Type, name string) (v Value) {
 func (b Builder) CreateICmp(pred IntPredicate, lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildICmp(b.C, C.LLVMIntPredicate(pred), lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateFCmp(pred FloatPredicate, lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildFCmp(b.C, C.LLVMRealPredicate(pred), lhs.C, rhs.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
@@ -1638,6 +1907,7 @@ func (b Builder) CreateFCmp(pred FloatPredicate, lhs, rhs Value, name string) (v
 func (b Builder) CreatePHI(t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildPhi(b.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
@@ -1645,6 +1915,7 @@ func (b Builder) CreateCall(fn Value, args []Value, name string) (v Value) {
 	cname := C.CString(name)
 	ptr, nvals := llvmValueRefs(args)
 	v.C = C.LLVMBuildCall(b.C, fn.C, ptr, nvals, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
@@ -1652,6 +1923,7 @@ func (b Builder) CreateCall(fn Value, args []Value, name string) (v Value) {
 func (b Builder) CreateSelect(ifv, thenv, elsev Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildSelect(b.C, ifv.C, thenv.C, elsev.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
@@ -1659,36 +1931,42 @@ func (b Builder) CreateSelect(ifv, thenv, elsev Value, name string) (v Value) {
 func (b Builder) CreateVAArg(list Value, t Type, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildVAArg(b.C, list.C, t.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateExtractElement(vec, i Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildExtractElement(b.C, vec.C, i.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateInsertElement(vec, elt, i Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildInsertElement(b.C, vec.C, elt.C, i.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateShuffleVector(v1, v2, mask Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildShuffleVector(b.C, v1.C, v2.C, mask.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateExtractValue(agg Value, i int, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildExtractValue(b.C, agg.C, C.unsigned(i), cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateInsertValue(agg, elt Value, i int, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildInsertValue(b.C, agg.C, elt.C, C.unsigned(i), cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
@@ -1696,18 +1974,22 @@ func (b Builder) CreateInsertValue(agg, elt Value, i int, name string) (v Value)
 func (b Builder) CreateIsNull(val Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildIsNull(b.C, val.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreateIsNotNull(val Value, name string) (v Value) {
 	cname := C.CString(name)
 	v.C = C.LLVMBuildIsNotNull(b.C, val.C, cname)
+	b.trace(v)
 	C.free(unsafe.Pointer(cname))
 	return
 }
 func (b Builder) CreatePtrDiff(lhs, rhs Value, name string) (v Value) {
 	cname := C.CString(name)
+	before := b.snapshot()
 	v.C = C.LLVMBuildPtrDiff(b.C, lhs.C, rhs.C, cname)
+	b.traceNew(before)
 	C.free(unsafe.Pointer(cname))
 	return
 }
@@ -1718,10 +2000,114 @@ func (b Builder) CreatePtrDiff(lhs, rhs Value, name string) (v Value) {
 
 type LandingPad Value
 
+// personalities records the personality routines set with SetPersonality,
+// by module, so that they are forgotten when the module is disposed, and by
+// function, so that they are forgotten when it is erased with
+// EraseFromParentAsFunction.
+var personalities struct {
+	sync.Mutex
+	m map[Module]map[Value]Value
+}
+
+// SetPersonality sets the personality routine used for landing pads in the
+// function f. In the supported LLVM versions the personality is an operand
+// of each landingpad instruction rather than a property of the function, so
+// it is recorded by the bindings and used by CreateLandingPad whenever no
+// personality is specified. The record is keyed by the function's address:
+// passes which delete functions, such as global DCE and the inliner,
+// invalidate it, since a function later created at the same address
+// would inherit the personality.
+func (f Value) SetPersonality(personality Value) {
+	m := f.GlobalParent()
+	personalities.Lock()
+	if personalities.m == nil {
+		personalities.m = make(map[Module]map[Value]Value)
+	}
+	if personalities.m[m] == nil {
+		personalities.m[m] = make(map[Value]Value)
+	}
+	personalities.m[m][f] = personality
+	personalities.Unlock()
+}
+
+// Personality returns the personality routine set with SetPersonality, or
+// a nil Value if none has been set.
+func (f Value) Personality() Value {
+	m := f.GlobalParent()
+	personalities.Lock()
+	defer personalities.Unlock()
+	return personalities.m[m][f]
+}
+
+// forgetPersonalities forgets the personalities set for the functions of
+// the module m, which is being disposed.
+func forgetPersonalities(m Module) {
+	personalities.Lock()
+	delete(personalities.m, m)
+	personalities.Unlock()
+}
+
+// forgetPersonality forgets the personality set for the function f, which
+// is being erased.
+func (f Value) forgetPersonality() {
+	m := f.GlobalParent()
+	personalities.Lock()
+	delete(personalities.m[m], f)
+	personalities.Unlock()
+}
+
+// copyPersonalities records for the functions of the module dst the
+// personalities set for the functions of the same names in the module src,
+// of which dst is a clone, or into which src has been linked. Functions
+// whose personality has already been set are unchanged.
+func copyPersonalities(dst, src Module) {
+	personalities.Lock()
+	defer personalities.Unlock()
+	for f, personality := range personalities.m[src] {
+		df := dst.NamedFunction(f.Name())
+		if f.Name() == "" || df.IsNil() || !personalities.m[dst][df].IsNil() {
+			continue
+		}
+		personality = remapPersonality(personality, dst)
+		if personality.IsNil() {
+			continue
+		}
+		if personalities.m[dst] == nil {
+			personalities.m[dst] = make(map[Value]Value)
+		}
+		personalities.m[dst][df] = personality
+	}
+}
+
+// remapPersonality returns the personality routine of the module dst
+// corresponding to personality, a global of another module or a bitcast of
+// one, or nil if dst has no global of its name.
+func remapPersonality(personality Value, dst Module) Value {
+	switch {
+	case !personality.IsAGlobalValue().IsNil():
+		if f := dst.NamedFunction(personality.Name()); !f.IsNil() {
+			return f
+		}
+		return dst.NamedGlobal(personality.Name())
+	case !personality.IsAConstantExpr().IsNil() && personality.Opcode() == BitCast:
+		if g := remapPersonality(personality.Operand(0), dst); !g.IsNil() {
+			return ConstBitCast(g, personality.Type())
+		}
+		return Value{}
+	}
+	return personality
+}
+
+// CreateLandingPad creates a landingpad instruction. If personality is nil,
+// the personality set for the enclosing function with SetPersonality is used.
 func (b Builder) CreateLandingPad(t Type, personality Value, nclauses int, name string) LandingPad {
+	if personality.IsNil() {
+		personality = b.GetInsertBlock().Parent().Personality()
+	}
 	cname := C.CString(name)
 	lp := LandingPad{C: C.LLVMBuildLandingPad(b.C, t.C, personality.C, C.unsigned(nclauses), cname)}
 	C.free(unsafe.Pointer(cname))
+	b.trace(Value(lp))
 	return lp
 }
 
@@ -1738,14 +2124,31 @@ func (l LandingPad) SetCleanup(cleanup bool) {
 //-------------------------------------------------------------------------
 
 // Changes the type of M so it can be passed to FunctionPassManagers and the
-// JIT. They take ModuleProviders for historical reasons.
+// JIT. They take ModuleProviders for historical reasons. The module provider
+// takes ownership of the module.
 func NewModuleProviderForModule(m Module) (mp ModuleProvider) {
 	mp.C = C.LLVMCreateModuleProviderForExistingModule(m.C)
+	m.setOwner(mp)
+	return
+}
+
+// TryNewModuleProviderForModule creates a module provider for the module
+// m, as NewModuleProviderForModule does, unless m is already owned by
+// another object or has been disposed, in which case an error is returned.
+func TryNewModuleProviderForModule(m Module) (mp ModuleProvider, err error) {
+	if err = m.CheckOwnership(); err != nil {
+		return
+	}
+	mp.C = C.LLVMCreateModuleProviderForExistingModule(m.C)
+	err = m.TakenBy(mp)
 	return
 }
 
 // Destroys the module M.
-func (mp ModuleProvider) Dispose() { C.LLVMDisposeModuleProvider(mp.C) }
+func (mp ModuleProvider) Dispose() {
+	C.LLVMDisposeModuleProvider(mp.C)
+	disposeOwnedModules(mp)
+}
 
 //-------------------------------------------------------------------------
 // llvm.MemoryBuffer
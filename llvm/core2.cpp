@@ -0,0 +1,138 @@
+#include <llvm/Attributes.h>
+#include <llvm/Constants.h>
+#include <llvm/Function.h>
+#include <llvm/Assembly/Writer.h>
+#include <llvm/GlobalValue.h>
+#include <llvm/InstrTypes.h>
+#include <llvm/Instructions.h>
+#include <llvm/IRBuilder.h>
+#include <llvm/Module.h>
+#include <llvm/Support/CallSite.h>
+#include <llvm/Support/raw_ostream.h>
+#include <string.h>
+#include <string>
+
+extern "C" void appendModuleInlineAsm(llvm::Module *m, const char *asm_) {
+	m->appendModuleInlineAsm(asm_);
+}
+
+extern "C" const char *getModuleInlineAsm(llvm::Module *m) {
+	return m->getModuleInlineAsm().c_str();
+}
+
+extern "C" const uint64_t *getConstIntWords(llvm::ConstantInt *c,
+                                            unsigned *n) {
+	const llvm::APInt &v = c->getValue();
+	*n = v.getNumWords();
+	return v.getRawData();
+}
+
+extern "C" double getConstFPDouble(llvm::ConstantFP *c, bool *losesInfo) {
+	llvm::APFloat f = c->getValueAPF();
+	f.convert(llvm::APFloat::IEEEdouble,
+	          llvm::APFloat::rmNearestTiesToEven, losesInfo);
+	return f.convertToDouble();
+}
+
+extern "C" const char *getConstDataString(llvm::Value *v, size_t *len) {
+	llvm::ConstantDataSequential *c =
+		llvm::dyn_cast<llvm::ConstantDataSequential>(v);
+	if (!c || !c->isString())
+		return 0;
+	llvm::StringRef s = c->getAsString();
+	*len = s.size();
+	return s.data();
+}
+
+extern "C" llvm::Instruction *getBuilderInsertPoint(llvm::IRBuilder<> *b) {
+	llvm::BasicBlock::iterator it = b->GetInsertPoint();
+	if (!b->GetInsertBlock() || it == b->GetInsertBlock()->end())
+		return 0;
+	return &*it;
+}
+
+extern "C" void setUnnamedAddr(llvm::GlobalValue *gv, bool unnamed) {
+	gv->setUnnamedAddr(unnamed);
+}
+
+extern "C" bool hasUnnamedAddr(llvm::GlobalValue *gv) {
+	return gv->hasUnnamedAddr();
+}
+
+extern "C" void setInstrAlignment(llvm::Instruction *i, unsigned align) {
+	if (llvm::LoadInst *load = llvm::dyn_cast<llvm::LoadInst>(i))
+		load->setAlignment(align);
+	else if (llvm::StoreInst *store = llvm::dyn_cast<llvm::StoreInst>(i))
+		store->setAlignment(align);
+	else if (llvm::AllocaInst *alloca = llvm::dyn_cast<llvm::AllocaInst>(i))
+		alloca->setAlignment(align);
+}
+
+extern "C" unsigned getNumSuccessors(llvm::TerminatorInst *term) {
+	return term->getNumSuccessors();
+}
+
+extern "C" llvm::BasicBlock *getSuccessor(llvm::TerminatorInst *term, unsigned i) {
+	return term->getSuccessor(i);
+}
+
+extern "C" void setSuccessor(llvm::TerminatorInst *term, unsigned i,
+                             llvm::BasicBlock *bb) {
+	term->setSuccessor(i, bb);
+}
+
+extern "C" void setIncomingBlock(llvm::PHINode *phi, unsigned i,
+                                 llvm::BasicBlock *bb) {
+	phi->setIncomingBlock(i, bb);
+}
+
+extern "C" char *printValueToString(llvm::Value *v) {
+	std::string s;
+	llvm::raw_string_ostream os(s);
+	v->print(os);
+	return strdup(os.str().c_str());
+}
+
+extern "C" char *printValueAsOperand(llvm::Value *v) {
+	std::string s;
+	llvm::raw_string_ostream os(s);
+	llvm::WriteAsOperand(os, v, false);
+	return strdup(os.str().c_str());
+}
+
+extern "C" char *printTypeToString(llvm::Type *t) {
+	std::string s;
+	llvm::raw_string_ostream os(s);
+	t->print(os);
+	return strdup(os.str().c_str());
+}
+
+extern "C" unsigned getThreadLocalMode(llvm::GlobalVariable *g) {
+	return g->getThreadLocalMode();
+}
+
+extern "C" void setThreadLocalMode(llvm::GlobalVariable *g, unsigned mode) {
+	g->setThreadLocalMode((llvm::GlobalVariable::ThreadLocalMode)mode);
+}
+
+extern "C" void copyAttributes(llvm::Value *v, llvm::Function *f) {
+	if (llvm::Function *g = llvm::dyn_cast<llvm::Function>(v))
+		g->setAttributes(f->getAttributes());
+	else
+		llvm::CallSite(v).setAttributes(f->getAttributes());
+}
+
+extern "C" uint64_t getReturnAttr(llvm::Function *f) {
+	return f->getAttributes().getRetAttributes().Raw();
+}
+
+extern "C" void addReturnAttr(llvm::Function *f, uint64_t a) {
+	llvm::AttrBuilder b(a);
+	llvm::LLVMContext &ctx = f->getContext();
+	f->setAttributes(f->getAttributes().addAttr(ctx,
+		llvm::AttrListPtr::ReturnIndex, llvm::Attributes::get(ctx, b)));
+}
+
+extern "C" unsigned getAliasCount(llvm::Module *m) {
+	return m->alias_size();
+}
@@ -0,0 +1,350 @@
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+#include <stdbool.h>
+#include <stdlib.h>
+
+extern void appendModuleInlineAsm(LLVMModuleRef, const char*);
+extern const char *getModuleInlineAsm(LLVMModuleRef);
+extern const uint64_t *getConstIntWords(LLVMValueRef, unsigned*);
+extern double getConstFPDouble(LLVMValueRef, bool*);
+extern const char *getConstDataString(LLVMValueRef, size_t*);
+extern LLVMValueRef getBuilderInsertPoint(LLVMBuilderRef);
+extern void setUnnamedAddr(LLVMValueRef, bool);
+extern bool hasUnnamedAddr(LLVMValueRef);
+extern void setInstrAlignment(LLVMValueRef, unsigned);
+extern unsigned getNumSuccessors(LLVMValueRef);
+extern LLVMBasicBlockRef getSuccessor(LLVMValueRef, unsigned);
+extern void setSuccessor(LLVMValueRef, unsigned, LLVMBasicBlockRef);
+extern void setIncomingBlock(LLVMValueRef, unsigned, LLVMBasicBlockRef);
+extern char *printValueToString(LLVMValueRef);
+extern char *printValueAsOperand(LLVMValueRef);
+extern char *printTypeToString(LLVMTypeRef);
+extern unsigned getThreadLocalMode(LLVMValueRef);
+extern void setThreadLocalMode(LLVMValueRef, unsigned);
+extern void copyAttributes(LLVMValueRef, LLVMValueRef);
+extern uint64_t getReturnAttr(LLVMValueRef);
+extern void addReturnAttr(LLVMValueRef, uint64_t);
+extern unsigned getAliasCount(LLVMModuleRef);
+*/
+import "C"
+import "crypto/sha1"
+import "fmt"
+import "math/big"
+import "unsafe"
+
+// See Module::getModuleInlineAsm.
+func (m Module) InlineAsm() string {
+	return C.GoString(C.getModuleInlineAsm(m.C))
+}
+
+// aliasCount returns the number of global aliases in the module m, which
+// the C API cannot enumerate.
+func (m Module) aliasCount() int { return int(C.getAliasCount(m.C)) }
+
+// See Module::appendModuleInlineAsm.
+func (m Module) AppendInlineAsm(asm string) {
+	casm := C.CString(asm)
+	C.appendModuleInlineAsm(m.C, casm)
+	C.free(unsafe.Pointer(casm))
+}
+
+// AddGlobalIFunc declares a function named name, with the function type ft,
+// whose address is determined at load time by calling resolver; resolver
+// takes no arguments and returns the address of the implementation to use.
+//
+// The supported LLVM versions have no native ifunc support, so the symbol is
+// defined with module inline assembly as a GNU indirect function. This is
+// only supported on ELF targets. The resolver must not be removed by
+// optimisation, e.g. by giving it external linkage.
+func AddGlobalIFunc(m Module, name string, ft Type, resolver Value) Value {
+	f := AddFunction(m, name, ft)
+	m.AppendInlineAsm(fmt.Sprintf(
+		".globl %s\n.type %s, @gnu_indirect_function\n.set %s, %s",
+		name, name, name, resolver.Name()))
+	return f
+}
+
+// ConstIntValue returns the value of the ConstantInt v, of any width,
+// interpreting it as a two's complement signed integer if signed is true.
+// See ConstantInt::getValue.
+func (v Value) ConstIntValue(signed bool) *big.Int {
+	var n C.unsigned
+	cwords := C.getConstIntWords(v.C, &n)
+	words := (*[1 << 24]uint64)(unsafe.Pointer(cwords))[:n:n]
+	return wordsToBig(words, v.Type().IntTypeWidth(), signed)
+}
+
+// ConstIntFromBig returns an integer constant of type t, of any width, with
+// the value x truncated to the width of t in two's complement.
+func ConstIntFromBig(t Type, x *big.Int) Value {
+	return ConstIntOfArbitraryPrecision(t, bigToWords(x, t.IntTypeWidth()))
+}
+
+// wordsToBig converts the words of an APInt of the given width, least
+// significant first, to a big.Int, interpreting it as a two's complement
+// signed integer if signed is true.
+func wordsToBig(words []uint64, width int, signed bool) *big.Int {
+	x := new(big.Int)
+	for i := len(words) - 1; i >= 0; i-- {
+		x.Lsh(x, 64)
+		x.Or(x, new(big.Int).SetUint64(words[i]))
+	}
+	if signed && x.Bit(width-1) == 1 {
+		x.Sub(x, new(big.Int).Lsh(big.NewInt(1), uint(width)))
+	}
+	return x
+}
+
+// bigToWords converts x, truncated to width bits in two's complement, to
+// the words of an APInt, least significant first.
+func bigToWords(x *big.Int, width int) []uint64 {
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(width)), big.NewInt(1))
+	// And with a positive mask yields the two's complement of negative x.
+	u := new(big.Int).And(x, mask)
+	word := new(big.Int).SetUint64(^uint64(0))
+	words := make([]uint64, (width+63)/64)
+	for i := range words {
+		words[i] = new(big.Int).And(u, word).Uint64()
+		u.Rsh(u, 64)
+	}
+	return words
+}
+
+// CreateSplitInt splits the integer v, whose width must be even, into its
+// low and high halves, e.g. an i128 into two i64s.
+func (b Builder) CreateSplitInt(v Value, name string) (lo, hi Value) {
+	t := v.Type()
+	width := t.IntTypeWidth()
+	ht := t.Context().IntType(width / 2)
+	lo = b.CreateTrunc(v, ht, name+".lo")
+	hi = b.CreateLShr(v, ConstInt(t, uint64(width/2), false), "")
+	hi = b.CreateTrunc(hi, ht, name+".hi")
+	return
+}
+
+// CreateCombineInt combines the integers lo and hi, which must be of the
+// same type, into an integer of twice their width, e.g. two i64s into an
+// i128. It is the inverse of CreateSplitInt.
+func (b Builder) CreateCombineInt(lo, hi Value, name string) Value {
+	width := lo.Type().IntTypeWidth()
+	t := lo.Type().Context().IntType(width * 2)
+	hi = b.CreateShl(b.CreateZExt(hi, t, ""), ConstInt(t, uint64(width), false), "")
+	return b.CreateOr(b.CreateZExt(lo, t, ""), hi, name)
+}
+
+// ConstFloatValue returns the value of the ConstantFP v, converted to a
+// float64. losesInfo reports whether the conversion was inexact.
+// See ConstantFP::getValueAPF.
+func (v Value) ConstFloatValue() (f float64, losesInfo bool) {
+	var closesInfo C.bool
+	f = float64(C.getConstFPDouble(v.C, &closesInfo))
+	return f, bool(closesInfo)
+}
+
+// ConstDataString returns the contents of v if it is a constant array of
+// i8, such as that created by ConstString.
+// See ConstantDataSequential::getAsString.
+func (v Value) ConstDataString() (s string, ok bool) {
+	var n C.size_t
+	cstr := C.getConstDataString(v.C, &n)
+	if cstr == nil {
+		return "", false
+	}
+	return C.GoStringN(cstr, C.int(n)), true
+}
+
+// InsertPoint records a builder's insertion point: new instructions are
+// inserted before Point, or at the end of Block if Point is nil. If Block
+// is nil, the builder has no insertion point.
+type InsertPoint struct {
+	Block BasicBlock
+	Point Value
+}
+
+// SaveIP returns the builder's current insertion point, so that it can be
+// restored with RestoreIP after generating code elsewhere.
+// See IRBuilderBase::saveIP.
+func (b Builder) SaveIP() (ip InsertPoint) {
+	ip.Block = b.GetInsertBlock()
+	if !ip.Block.IsNil() {
+		ip.Point.C = C.getBuilderInsertPoint(b.C)
+	}
+	return
+}
+
+// RestoreIP positions the builder at the insertion point ip.
+// See IRBuilderBase::restoreIP.
+func (b Builder) RestoreIP(ip InsertPoint) {
+	switch {
+	case ip.Block.IsNil():
+		b.ClearInsertionPoint()
+	case ip.Point.IsNil():
+		b.SetInsertPointAtEnd(ip.Block)
+	default:
+		b.SetInsertPointBefore(ip.Point)
+	}
+}
+
+// SetUnnamedAddr sets whether the address of the global value v is
+// insignificant, allowing it to be merged with identical constants. LLVM
+// 3.x has no equivalent of the later local_unnamed_addr.
+// See GlobalValue::setUnnamedAddr.
+func (v Value) SetUnnamedAddr(unnamed bool) { C.setUnnamedAddr(v.C, C.bool(unnamed)) }
+
+// See GlobalValue::hasUnnamedAddr.
+func (v Value) HasUnnamedAddr() bool { return bool(C.hasUnnamedAddr(v.C)) }
+
+// SetInstrAlignment sets the alignment, in bytes, of the load, store or
+// alloca instruction v; it panics if v is any other value. In LLVM 3.2,
+// Value.SetAlignment applies only to global values.
+func (v Value) SetInstrAlignment(align int) {
+	switch v.InstructionOpcode() {
+	case Load, Store, Alloca:
+	default:
+		panic("SetInstrAlignment: not a load, store or alloca instruction")
+	}
+	C.setInstrAlignment(v.C, C.unsigned(align))
+}
+
+// MarkMergeable marks the global variable g as a constant whose address is
+// insignificant, so that code generators may place it in a mergeable
+// section and linkers may deduplicate it.
+func MarkMergeable(g Value) {
+	g.SetGlobalConstant(true)
+	g.SetUnnamedAddr(true)
+}
+
+// MergeableString returns a null terminated, mergeable constant global
+// containing s. Globals are named after a hash of their contents and given
+// linkonce_odr linkage, so identical strings are shared within the module
+// and deduplicated by the linker across modules.
+func (m Module) MergeableString(s string) Value {
+	name := fmt.Sprintf("str.%x", sha1.Sum([]byte(s)))
+	if g := m.NamedGlobal(name); !g.IsNil() {
+		return g
+	}
+	init := m.Context().ConstString(s, true)
+	g := AddGlobal(m, init.Type(), name)
+	g.SetInitializer(init)
+	g.SetLinkage(LinkOnceODRLinkage)
+	MarkMergeable(g)
+	return g
+}
+
+// Successors returns the successors of the basic block bb, in the order of
+// its terminator's operands; for a conditional branch, the block taken if
+// the condition is true comes first. It returns nil if bb has no
+// terminator.
+// See TerminatorInst::getSuccessor.
+func (bb BasicBlock) Successors() []BasicBlock {
+	term := bb.LastInstruction()
+	if term.IsNil() || term.IsATerminatorInst().IsNil() {
+		return nil
+	}
+	succs := make([]BasicBlock, C.getNumSuccessors(term.C))
+	for i := range succs {
+		succs[i].C = C.getSuccessor(term.C, C.unsigned(i))
+	}
+	return succs
+}
+
+// SetSuccessor replaces successor i of the terminator instruction term,
+// numbered as by BasicBlock.Successors, with bb. PHI nodes in the old and
+// new successors are not updated.
+// See TerminatorInst::setSuccessor.
+func (term Value) SetSuccessor(i int, bb BasicBlock) {
+	C.setSuccessor(term.C, C.unsigned(i), bb.C)
+}
+
+// SetIncomingBlock replaces the block of incoming value i of the PHI node
+// phi with bb.
+// See PHINode::setIncomingBlock.
+func (phi Value) SetIncomingBlock(i int, bb BasicBlock) {
+	C.setIncomingBlock(phi.C, C.unsigned(i), bb.C)
+}
+
+// IRString returns the textual IR of the value v, e.g. the text of an
+// instruction.
+// See Value::print.
+func (v Value) IRString() string {
+	cstr := C.printValueToString(v.C)
+	defer C.free(unsafe.Pointer(cstr))
+	return C.GoString(cstr)
+}
+
+// OperandIRString returns the textual IR of the value v as an operand,
+// without its type, e.g. "@f" for a function or "42" for a constant.
+// See llvm::WriteAsOperand.
+func (v Value) OperandIRString() string {
+	cstr := C.printValueAsOperand(v.C)
+	defer C.free(unsafe.Pointer(cstr))
+	return C.GoString(cstr)
+}
+
+// IRString returns the textual IR of the type t, e.g. "{ i32, i8* }".
+// See Type::print.
+func (t Type) IRString() string {
+	cstr := C.printTypeToString(t.C)
+	defer C.free(unsafe.Pointer(cstr))
+	return C.GoString(cstr)
+}
+
+// ThreadLocalMode is the TLS model of a thread-local global variable, which
+// determines the relocations used to access it.
+type ThreadLocalMode int
+
+const (
+	NotThreadLocal ThreadLocalMode = iota
+
+	// GeneralDynamicTLSModel may be used in any code, including shared
+	// libraries loaded with dlopen.
+	GeneralDynamicTLSModel
+
+	// LocalDynamicTLSModel may be used for variables defined in the same
+	// shared library or executable.
+	LocalDynamicTLSModel
+
+	// InitialExecTLSModel may be used for variables defined in the
+	// executable or a shared library loaded at startup.
+	InitialExecTLSModel
+
+	// LocalExecTLSModel may be used for variables defined in the
+	// executable itself.
+	LocalExecTLSModel
+)
+
+// ThreadLocalMode returns the TLS model of the global variable v, or
+// NotThreadLocal if it is not thread-local.
+// See GlobalVariable::getThreadLocalMode.
+func (v Value) ThreadLocalMode() ThreadLocalMode {
+	return ThreadLocalMode(C.getThreadLocalMode(v.C))
+}
+
+// SetThreadLocalMode makes the global variable v thread-local with the TLS
+// model mode, or not thread-local if mode is NotThreadLocal. SetThreadLocal
+// uses GeneralDynamicTLSModel, which the code generator may relax for
+// variables it can see are local to the executable.
+// See GlobalVariable::setThreadLocalMode.
+func (v Value) SetThreadLocalMode(mode ThreadLocalMode) {
+	C.setThreadLocalMode(v.C, C.unsigned(mode))
+}
+
+// CopyAttributes replaces the attributes of v, a function or a call or
+// invoke instruction, for its return value, parameters and itself, with
+// those of the function f, e.g. so that a call through a pointer passes
+// byval, sret and extended arguments as f expects them.
+// See Function::setAttributes and CallSite::setAttributes.
+func (v Value) CopyAttributes(f Value) { C.copyAttributes(v.C, f.C) }
+
+// ReturnAttribute returns the attributes of the return value of the
+// function v, such as ZExtAttribute or NoAliasAttribute.
+// See AttrListPtr::getRetAttributes.
+func (v Value) ReturnAttribute() Attribute { return Attribute(C.getReturnAttr(v.C)) }
+
+// AddReturnAttribute adds the attributes a to the return value of the
+// function v. Unlike CopyAttributes, it may be used to copy attributes
+// between functions in different contexts.
+// See AttrListPtr::addAttr.
+func (v Value) AddReturnAttribute(a Attribute) { C.addReturnAttr(v.C, C.uint64_t(a)) }
@@ -0,0 +1,93 @@
+// +build llvm3.2
+
+#include <llvm/Instructions.h>
+#include <llvm/TargetTransformInfo.h>
+#include <llvm/Target/TargetMachine.h>
+
+extern "C" const llvm::VectorTargetTransformInfo *
+getVectorTargetTransformInfo(llvm::TargetMachine *tm) {
+	return tm->getVectorTargetTransformInfo();
+}
+
+extern "C" unsigned getNumberOfParts(const llvm::VectorTargetTransformInfo *vtti,
+                                     llvm::Type *t) {
+	return vtti->getNumberOfParts(t);
+}
+
+static int vectorIndex(llvm::Value *v) {
+	if (llvm::ConstantInt *c = llvm::dyn_cast<llvm::ConstantInt>(v))
+		return c->getZExtValue();
+	return -1;
+}
+
+// getInstructionCost follows the CostModel analysis.
+extern "C" int getInstructionCost(const llvm::VectorTargetTransformInfo *vtti,
+                                  llvm::Instruction *i) {
+	unsigned op = i->getOpcode();
+	switch (op) {
+	case llvm::Instruction::Ret:
+	case llvm::Instruction::PHI:
+	case llvm::Instruction::Br:
+		return vtti->getCFInstrCost(op);
+	case llvm::Instruction::Add:
+	case llvm::Instruction::FAdd:
+	case llvm::Instruction::Sub:
+	case llvm::Instruction::FSub:
+	case llvm::Instruction::Mul:
+	case llvm::Instruction::FMul:
+	case llvm::Instruction::UDiv:
+	case llvm::Instruction::SDiv:
+	case llvm::Instruction::FDiv:
+	case llvm::Instruction::URem:
+	case llvm::Instruction::SRem:
+	case llvm::Instruction::FRem:
+	case llvm::Instruction::Shl:
+	case llvm::Instruction::LShr:
+	case llvm::Instruction::AShr:
+	case llvm::Instruction::And:
+	case llvm::Instruction::Or:
+	case llvm::Instruction::Xor:
+		return vtti->getArithmeticInstrCost(op, i->getType());
+	case llvm::Instruction::Select: {
+		llvm::SelectInst *si = llvm::cast<llvm::SelectInst>(i);
+		return vtti->getCmpSelInstrCost(op, si->getType(),
+		                                si->getCondition()->getType());
+	}
+	case llvm::Instruction::ICmp:
+	case llvm::Instruction::FCmp:
+		return vtti->getCmpSelInstrCost(op, i->getOperand(0)->getType());
+	case llvm::Instruction::Store: {
+		llvm::StoreInst *si = llvm::cast<llvm::StoreInst>(i);
+		return vtti->getMemoryOpCost(op, si->getValueOperand()->getType(),
+		                             si->getAlignment(),
+		                             si->getPointerAddressSpace());
+	}
+	case llvm::Instruction::Load: {
+		llvm::LoadInst *li = llvm::cast<llvm::LoadInst>(i);
+		return vtti->getMemoryOpCost(op, li->getType(), li->getAlignment(),
+		                             li->getPointerAddressSpace());
+	}
+	case llvm::Instruction::ZExt:
+	case llvm::Instruction::SExt:
+	case llvm::Instruction::FPToUI:
+	case llvm::Instruction::FPToSI:
+	case llvm::Instruction::FPExt:
+	case llvm::Instruction::PtrToInt:
+	case llvm::Instruction::IntToPtr:
+	case llvm::Instruction::SIToFP:
+	case llvm::Instruction::UIToFP:
+	case llvm::Instruction::Trunc:
+	case llvm::Instruction::FPTrunc:
+	case llvm::Instruction::BitCast:
+		return vtti->getCastInstrCost(op, i->getType(),
+		                              i->getOperand(0)->getType());
+	case llvm::Instruction::ExtractElement:
+		return vtti->getVectorInstrCost(op, i->getOperand(0)->getType(),
+		                                vectorIndex(i->getOperand(1)));
+	case llvm::Instruction::InsertElement:
+		return vtti->getVectorInstrCost(op, i->getType(),
+		                                vectorIndex(i->getOperand(2)));
+	default:
+		return -1;
+	}
+}
@@ -0,0 +1,57 @@
+// +build llvm3.2
+
+package llvm
+
+/*
+#include <llvm-c/Target.h>
+#include <llvm-c/TargetMachine.h>
+
+typedef struct vectorTargetTransformInfo vectorTargetTransformInfo;
+extern const vectorTargetTransformInfo *getVectorTargetTransformInfo(LLVMTargetMachineRef);
+extern unsigned getNumberOfParts(const vectorTargetTransformInfo *, LLVMTypeRef);
+extern int getInstructionCost(const vectorTargetTransformInfo *, LLVMValueRef);
+*/
+import "C"
+
+// CostModel estimates the cost of instructions on a target, in units of
+// the cost of a typical simple instruction, for use by inlining and
+// vectorization heuristics. Only LLVM 3.2 has VectorTargetTransformInfo;
+// with other versions, every target's cost model is nil.
+// See llvm::VectorTargetTransformInfo.
+type CostModel struct {
+	C *C.vectorTargetTransformInfo
+}
+
+// CostModel returns the cost model of the target machine, which is valid
+// until the target machine is disposed. It is nil if the target has none.
+func (tm TargetMachine) CostModel() CostModel {
+	return CostModel{C.getVectorTargetTransformInfo(tm.C)}
+}
+
+func (c CostModel) IsNil() bool { return c.C == nil }
+
+// InstructionCost returns the estimated cost of the instruction i, or -1 if
+// it is not known, e.g. for calls.
+func (c CostModel) InstructionCost(i Value) int {
+	return int(C.getInstructionCost(c.C, i.C))
+}
+
+// NumberOfParts returns the number of registers needed to hold a value of
+// type t once legalized, or 0 if t must be scalarized.
+func (c CostModel) NumberOfParts(t Type) int {
+	return int(C.getNumberOfParts(c.C, t.C))
+}
+
+// FunctionCost returns the sum of the known costs of the instructions of
+// the function f.
+func (c CostModel) FunctionCost(f Value) int {
+	cost := 0
+	for bb := f.FirstBasicBlock(); !bb.IsNil(); bb = NextBasicBlock(bb) {
+		for i := bb.FirstInstruction(); !i.IsNil(); i = NextInstruction(i) {
+			if n := c.InstructionCost(i); n > 0 {
+				cost += n
+			}
+		}
+	}
+	return cost
+}
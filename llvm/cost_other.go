@@ -0,0 +1,29 @@
+// +build !llvm3.2
+
+package llvm
+
+import "unsafe"
+
+// CostModel estimates the cost of instructions on a target. LLVM versions
+// other than 3.2 have no VectorTargetTransformInfo, so every target's cost
+// model is nil, and knows the cost of no instructions.
+type CostModel struct {
+	C unsafe.Pointer
+}
+
+// CostModel returns the cost model of the target machine, which is always
+// nil.
+func (tm TargetMachine) CostModel() CostModel { return CostModel{} }
+
+func (c CostModel) IsNil() bool { return c.C == nil }
+
+// InstructionCost returns -1, as the cost of the instruction i is not
+// known.
+func (c CostModel) InstructionCost(i Value) int { return -1 }
+
+// NumberOfParts returns 0, as the legalization of t is not known.
+func (c CostModel) NumberOfParts(t Type) int { return 0 }
+
+// FunctionCost returns 0, as the costs of the instructions of the function
+// f are not known.
+func (c CostModel) FunctionCost(f Value) int { return 0 }
@@ -0,0 +1,203 @@
+package llvm
+
+import "fmt"
+
+// CounterMode selects what InsertCounters counts.
+type CounterMode int
+
+const (
+	// CountBlocks counts the executions of each basic block.
+	CountBlocks CounterMode = iota
+
+	// CountEdges counts the traversals of each edge of the control flow
+	// graph.
+	CountEdges
+)
+
+// Counters describes the counters inserted into a module by InsertCounters.
+type Counters struct {
+	// Global is the [n x i64] array of counters.
+	Global Value
+
+	// Dump is a function, void ()*, which prints the label and value of
+	// each counter to the standard output with printf, one per line,
+	// separated by a tab.
+	Dump Value
+
+	// Labels are the labels of the counters, f:block for a block and
+	// f:from->to for an edge, where unnamed blocks are written #i, i being
+	// the block's index in the function.
+	Labels []string
+}
+
+// counterSite is where a counter is incremented: at the start of block,
+// after any PHI nodes and landingpad, or before its terminator.
+type counterSite struct {
+	label string
+	block BasicBlock
+	atEnd bool
+}
+
+// InsertCounters instruments each function defined in the module m with
+// atomic increments of 64-bit counters, one per basic block or per edge,
+// for profiling and coverage without LLVM's profiling runtime. The counters
+// are the global array name, and are printed by the function name.dump,
+// which the program may call, e.g. when it exits. If m already declares
+// printf with another type, the dump function calls it bitcast to
+// i32 (i8*, ...).
+//
+// An edge whose source has a single successor is counted in the source;
+// others are split by a block in which they are counted. Edges which
+// cannot be split, those to landing pads and from indirectbr instructions,
+// are not counted.
+func InsertCounters(m Module, name string, mode CounterMode) Counters {
+	var sites []counterSite
+	for f := m.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		if f.IsDeclaration() {
+			continue
+		}
+		labels := make(map[BasicBlock]string)
+		for i, bb := range f.BasicBlocks() {
+			label := bb.AsValue().Name()
+			if label == "" {
+				label = fmt.Sprintf("#%d", i)
+			}
+			labels[bb] = label
+		}
+		for _, bb := range f.BasicBlocks() {
+			if mode == CountBlocks {
+				sites = append(sites, counterSite{f.Name() + ":" + labels[bb], bb, false})
+				continue
+			}
+			succs := bb.Successors()
+			if len(succs) == 1 {
+				label := f.Name() + ":" + labels[bb] + "->" + labels[succs[0]]
+				sites = append(sites, counterSite{label, bb, true})
+				continue
+			}
+			term := bb.LastInstruction()
+			if len(succs) == 0 || !term.IsAIndirectBrInst().IsNil() {
+				continue
+			}
+			for i, succ := range succs {
+				if !firstNonPHI(succ).IsALandingPadInst().IsNil() {
+					continue
+				}
+				label := f.Name() + ":" + labels[bb] + "->" + labels[succ]
+				sites = append(sites, counterSite{label, splitEdge(term, i), true})
+			}
+		}
+	}
+
+	ctx := m.Context()
+	i64 := ctx.Int64Type()
+	arrayType := ArrayType(i64, len(sites))
+	counters := AddGlobal(m, arrayType, name)
+	counters.SetInitializer(ConstNull(arrayType))
+
+	b := ctx.NewBuilder()
+	defer b.Dispose()
+	i32 := ctx.Int32Type()
+	zero := ConstInt(i32, 0, false)
+	one := ConstInt(i64, 1, false)
+	labels := make([]string, len(sites))
+	for i, site := range sites {
+		if site.atEnd {
+			b.SetInsertPointBefore(site.block.LastInstruction())
+		} else if first := firstNonPHI(site.block); first.IsALandingPadInst().IsNil() {
+			b.SetInsertPointBefore(first)
+		} else {
+			b.SetInsertPointBefore(NextInstruction(first))
+		}
+		counter := ConstGEP(counters, []Value{zero, ConstInt(i32, uint64(i), false)})
+		b.CreateAtomicRMW(AtomicRMWAdd, counter, one, Monotonic, false)
+		labels[i] = site.label
+	}
+	return Counters{
+		Global: counters,
+		Dump:   counterDump(m, name+".dump", counters, labels),
+		Labels: labels,
+	}
+}
+
+// firstNonPHI returns the first instruction of bb which is not a PHI node.
+func firstNonPHI(bb BasicBlock) Value {
+	i := bb.FirstInstruction()
+	for !i.IsNil() && !i.IsAPHINode().IsNil() {
+		i = NextInstruction(i)
+	}
+	return i
+}
+
+// splitEdge inserts a block on the edge from the block of the terminator
+// term to its successor i, returning the block.
+func splitEdge(term Value, i int) BasicBlock {
+	from := term.InstructionParent()
+	to := from.Successors()[i]
+	f := from.Parent()
+	bb := f.Type().Context().AddBasicBlock(f, "")
+	bb.MoveAfter(from)
+	b := f.Type().Context().NewBuilder()
+	defer b.Dispose()
+	b.SetInsertPointAtEnd(bb)
+	b.CreateBr(to)
+	term.SetSuccessor(i, bb)
+
+	// Each PHI node in to has an incoming value per edge from from, so
+	// only the first not yet updated is for this edge.
+	for phi := to.FirstInstruction(); !phi.IsNil() && !phi.IsAPHINode().IsNil(); phi = NextInstruction(phi) {
+		for j := 0; j < phi.IncomingCount(); j++ {
+			if phi.IncomingBlock(j) == from {
+				phi.SetIncomingBlock(j, bb)
+				break
+			}
+		}
+	}
+	return bb
+}
+
+// counterDump defines the function name, which prints the counters with
+// their labels.
+func counterDump(m Module, name string, counters Value, labels []string) Value {
+	ctx := m.Context()
+	i32 := ctx.Int32Type()
+	i8ptr := PointerType(ctx.Int8Type(), 0)
+	printf := getOrInsertFunction(m, "printf", FunctionType(i32, []Type{i8ptr}, true))
+
+	f := AddFunction(m, name, FunctionType(ctx.VoidType(), nil, false))
+	b := ctx.NewBuilder()
+	defer b.Dispose()
+	entry := AddBasicBlock(f, "entry")
+	b.SetInsertPointAtEnd(entry)
+	if len(labels) == 0 {
+		b.CreateRetVoid()
+		return f
+	}
+
+	strs := make([]Value, len(labels))
+	for i, label := range labels {
+		strs[i] = b.CreateGlobalStringPtr(label, "")
+	}
+	labelsType := ArrayType(i8ptr, len(labels))
+	labelsGlobal := AddGlobal(m, labelsType, name+".labels")
+	labelsGlobal.SetLinkage(InternalLinkage)
+	labelsGlobal.SetGlobalConstant(true)
+	labelsGlobal.SetInitializer(ConstArray(i8ptr, strs))
+	format := b.CreateGlobalStringPtr("%s\t%llu\n", "")
+
+	loop := AddBasicBlock(f, "loop")
+	done := AddBasicBlock(f, "done")
+	zero := ConstInt(i32, 0, false)
+	b.CreateBr(loop)
+	b.SetInsertPointAtEnd(loop)
+	i := b.CreatePHI(i32, "i")
+	label := b.CreateLoad(b.CreateInBoundsGEP(labelsGlobal, []Value{zero, i}, ""), "")
+	count := b.CreateLoad(b.CreateInBoundsGEP(counters, []Value{zero, i}, ""), "")
+	b.CreateCall(printf, []Value{format, label, count}, "")
+	next := b.CreateAdd(i, ConstInt(i32, 1, false), "")
+	b.CreateCondBr(b.CreateICmp(IntULT, next, ConstInt(i32, uint64(len(labels)), false), ""), loop, done)
+	i.AddIncoming([]Value{zero, next}, []BasicBlock{entry, loop})
+	b.SetInsertPointAtEnd(done)
+	b.CreateRetVoid()
+	return f
+}
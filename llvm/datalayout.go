@@ -0,0 +1,66 @@
+package llvm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dataLayoutPresets maps the architecture component of a target triple to
+// the data layout the target's ABI requires. The layouts spell out the
+// alignment of every type whose default differs, in the syntax of LLVM
+// 3.2, which predates the mangling component, so that they may be used
+// for targets LLVM 3.2 cannot generate code for, e.g. to produce IR for a
+// later version of LLVM. The type sizes and alignments of the presets for
+// targets LLVM 3.2 has, MIPS and PowerPC, are tested against those of its
+// TargetMachines; native integer widths and stack alignment, which are not
+// part of the ABI, may differ.
+var dataLayoutPresets = map[string]string{
+	"mips":      "E-p:32:32:32-i8:8:32-i16:16:32-i64:64:64-f32:32:32-f64:64:64-v64:64:64-n32-S64",
+	"mipsel":    "e-p:32:32:32-i8:8:32-i16:16:32-i64:64:64-f32:32:32-f64:64:64-v64:64:64-n32-S64",
+	"mips64":    "E-p:64:64:64-i8:8:32-i16:16:32-i64:64:64-f32:32:32-f64:64:64-f128:128:128-v64:64:64-n32:64-S128",
+	"mips64el":  "e-p:64:64:64-i8:8:32-i16:16:32-i64:64:64-f32:32:32-f64:64:64-f128:128:128-v64:64:64-n32:64-S128",
+	"powerpc64": "E-p:64:64:64-i64:64:64-f64:64:64-f128:64:128-v128:128:128-n32:64",
+	"s390x":     "E-p:64:64:64-i1:8:16-i8:8:16-i16:16:16-i32:32:32-i64:64:64-f32:32:32-f64:64:64-f128:64:64-a0:8:16-n32:64",
+	"riscv64":   "e-p:64:64:64-i64:64:64-i128:128:128-n64-S128",
+	"wasm32":    "e-p:32:32:32-i64:64:64-n32:64-S128",
+}
+
+// dataLayoutArchAliases maps other spellings of the architectures above,
+// accepted in target triples, to those used in dataLayoutPresets.
+var dataLayoutArchAliases = map[string]string{
+	"ppc64":   "powerpc64",
+	"systemz": "s390x",
+}
+
+// DataLayoutForTriple returns the data layout preset for the architecture
+// of the target triple, for targets whose layouts are easily gotten wrong:
+// big-endian MIPS, PowerPC and SystemZ, and RISC-V and WebAssembly, whose
+// 64-bit integers are 64-bit aligned on 32-bit targets. An error is
+// returned for other architectures, whose layouts should be taken from a
+// TargetMachine.
+func DataLayoutForTriple(triple string) (string, error) {
+	arch := triple
+	if i := strings.Index(arch, "-"); i >= 0 {
+		arch = arch[:i]
+	}
+	if alias, ok := dataLayoutArchAliases[arch]; ok {
+		arch = alias
+	}
+	layout, ok := dataLayoutPresets[arch]
+	if !ok {
+		return "", fmt.Errorf("no data layout preset for %s", triple)
+	}
+	return layout, nil
+}
+
+// SetTargetPreset sets the target triple of the module m, and its data
+// layout to the preset for the triple. See DataLayoutForTriple.
+func (m Module) SetTargetPreset(triple string) error {
+	layout, err := DataLayoutForTriple(triple)
+	if err != nil {
+		return err
+	}
+	m.SetTarget(triple)
+	m.SetDataLayout(layout)
+	return nil
+}
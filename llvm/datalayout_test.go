@@ -0,0 +1,59 @@
+package llvm
+
+import "testing"
+
+// dataLayoutTestTargets maps each architecture in dataLayoutPresets to a
+// triple for it, and a CPU implementing the ABI of the preset; LLVM 3.2
+// defaults to the 32-bit O32 ABI for 64-bit MIPS triples.
+var dataLayoutTestTargets = map[string]struct{ triple, cpu string }{
+	"mips":      {"mips-unknown-linux-gnu", ""},
+	"mipsel":    {"mipsel-unknown-linux-gnu", ""},
+	"mips64":    {"mips64-unknown-linux-gnu", "mips64"},
+	"mips64el":  {"mips64el-unknown-linux-gnu", "mips64"},
+	"powerpc64": {"powerpc64-unknown-linux-gnu", ""},
+	"s390x":     {"s390x-unknown-linux-gnu", ""},
+	"riscv64":   {"riscv64-unknown-linux-gnu", ""},
+	"wasm32":    {"wasm32-unknown-unknown", ""},
+}
+
+func TestDataLayoutPresets(t *testing.T) {
+	InitializeAllTargetInfos()
+	InitializeAllTargets()
+	InitializeAllTargetMCs()
+
+	types := []Type{
+		Int1Type(), Int8Type(), Int16Type(), Int32Type(), Int64Type(),
+		FloatType(), DoubleType(), FP128Type(), PPCFP128Type(),
+		PointerType(Int8Type(), 0), VectorType(Int32Type(), 4),
+	}
+	for arch, layout := range dataLayoutPresets {
+		test, ok := dataLayoutTestTargets[arch]
+		if !ok {
+			t.Errorf("no test triple for %s", arch)
+			continue
+		}
+		triple := test.triple
+		if got, err := DataLayoutForTriple(triple); err != nil || got != layout {
+			t.Errorf("DataLayoutForTriple(%s) = %q, %v", triple, got, err)
+		}
+		target, err := GetTargetFromTriple(triple)
+		if err != nil {
+			continue // the target is not registered, e.g. not in LLVM 3.2
+		}
+		tm := target.CreateTargetMachine(triple, test.cpu, "", CodeGenLevelDefault, RelocDefault, CodeModelDefault)
+		want := tm.TargetData()
+		got := NewTargetData(layout)
+		if got.ByteOrder() != want.ByteOrder() || got.PointerSize() != want.PointerSize() {
+			t.Errorf("%s: preset %q has a different byte order or pointer size from %q", arch, layout, want)
+		}
+		for _, ty := range types {
+			if got.TypeAllocSize(ty) != want.TypeAllocSize(ty) ||
+				got.ABITypeAlignment(ty) != want.ABITypeAlignment(ty) ||
+				got.PrefTypeAlignment(ty) != want.PrefTypeAlignment(ty) {
+				t.Errorf("%s: preset %q lays out %v differently from %q", arch, layout, ty, want)
+			}
+		}
+		got.Dispose()
+		tm.Dispose()
+	}
+}
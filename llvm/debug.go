@@ -25,6 +25,7 @@ package llvm
 import (
 	"path"
 	"reflect"
+	"strings"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -37,17 +38,22 @@ const (
 type DwarfTag uint32
 
 const (
-	DW_TAG_lexical_block   DwarfTag = 0x0b
-	DW_TAG_compile_unit    DwarfTag = 0x11
-	DW_TAG_variable        DwarfTag = 0x34
-	DW_TAG_base_type       DwarfTag = 0x24
-	DW_TAG_pointer_type    DwarfTag = 0x0F
-	DW_TAG_structure_type  DwarfTag = 0x13
-	DW_TAG_subroutine_type DwarfTag = 0x15
-	DW_TAG_file_type       DwarfTag = 0x29
-	DW_TAG_subprogram      DwarfTag = 0x2E
-	DW_TAG_auto_variable   DwarfTag = 0x100
-	DW_TAG_arg_variable    DwarfTag = 0x101
+	DW_TAG_array_type       DwarfTag = 0x01
+	DW_TAG_enumeration_type DwarfTag = 0x04
+	DW_TAG_lexical_block    DwarfTag = 0x0b
+	DW_TAG_member           DwarfTag = 0x0d
+	DW_TAG_compile_unit     DwarfTag = 0x11
+	DW_TAG_variable         DwarfTag = 0x34
+	DW_TAG_base_type        DwarfTag = 0x24
+	DW_TAG_pointer_type     DwarfTag = 0x0F
+	DW_TAG_structure_type   DwarfTag = 0x13
+	DW_TAG_subroutine_type  DwarfTag = 0x15
+	DW_TAG_union_type       DwarfTag = 0x17
+	DW_TAG_subrange_type    DwarfTag = 0x21
+	DW_TAG_file_type        DwarfTag = 0x29
+	DW_TAG_subprogram       DwarfTag = 0x2E
+	DW_TAG_auto_variable    DwarfTag = 0x100
+	DW_TAG_arg_variable     DwarfTag = 0x101
 )
 
 const (
@@ -99,6 +105,57 @@ const (
 
 type DebugInfo struct {
 	cache map[DebugDescriptor]Value
+
+	// emitted lists the descriptors in cache in the order their metadata
+	// nodes were created.
+	emitted []DebugDescriptor
+
+	// listed records the descriptors in the lists of listedCU, whose
+	// lengths were listedLen, so that register need not search them. It
+	// is rebuilt if the compile unit or the lengths change otherwise.
+	listed    map[DebugDescriptor]bool
+	listedCU  *CompileUnitDescriptor
+	listedLen [3]int
+
+	// cuLists holds the placeholders for the RetainedTypes, Subprograms
+	// and GlobalVariables operands of the metadata of CompileUnit, until
+	// Finalize replaces them.
+	cuLists [3]Value
+
+	// CompileUnit, if non-nil, has each subprogram, global variable and
+	// named struct, union or enum descriptor appended to its Subprograms,
+	// GlobalVariables or RetainedTypes respectively when its metadata is
+	// created, unless already listed. The lists in the compile unit's own
+	// metadata are placeholders until Finalize is called, so descriptors
+	// may be created after it, e.g. those whose Context is the compile
+	// unit. If output is deterministic (see SetDeterministic), the lists
+	// are emitted sorted.
+	CompileUnit *CompileUnitDescriptor
+
+	// PathPrefixMap rewrites the directories recorded for source files,
+	// as with GCC's -fdebug-prefix-map, so that output does not depend on
+	// the directory in which it was built. The first entry whose Old
+	// prefix matches a directory is applied.
+	PathPrefixMap []PathPrefix
+}
+
+// PathPrefix is an entry in DebugInfo.PathPrefixMap.
+type PathPrefix struct {
+	Old, New string
+}
+
+// remapPath applies the PathPrefixMap to the directory dir.
+func (info *DebugInfo) remapPath(dir string) string {
+	if info == nil {
+		return dir
+	}
+	for _, p := range info.PathPrefixMap {
+		old := strings.TrimSuffix(p.Old, "/")
+		if dir == old || strings.HasPrefix(dir, old+"/") {
+			return p.New + dir[len(old):]
+		}
+	}
+	return dir
 }
 
 type DebugDescriptor interface {
@@ -133,10 +190,113 @@ func (info *DebugInfo) MDNode(d DebugDescriptor) Value {
 	if !exists {
 		value = d.mdNode(info)
 		info.cache[d] = value
+		info.emitted = append(info.emitted, d)
+		info.register(d)
 	}
 	return value
 }
 
+// register adds d to the appropriate list of info.CompileUnit, if any.
+func (info *DebugInfo) register(d DebugDescriptor) {
+	cu := info.CompileUnit
+	if cu == nil {
+		return
+	}
+	var list *[]DebugDescriptor
+	switch d := d.(type) {
+	case *SubprogramDescriptor:
+		list = &cu.Subprograms
+	case *GlobalVariableDescriptor:
+		list = &cu.GlobalVariables
+	case *CompositeTypeDescriptor:
+		// Only named types are retained; others, such as subroutine and
+		// array types, are emitted where they are used.
+		switch d.tag {
+		case DW_TAG_structure_type, DW_TAG_union_type, DW_TAG_enumeration_type:
+			if d.Name != "" {
+				list = &cu.RetainedTypes
+			}
+		}
+	}
+	if list == nil {
+		return
+	}
+	lists := [3]*[]DebugDescriptor{&cu.Subprograms, &cu.GlobalVariables, &cu.RetainedTypes}
+	var lens [3]int
+	for i, l := range lists {
+		lens[i] = len(*l)
+	}
+	if info.listedCU != cu || info.listedLen != lens {
+		info.listed = make(map[DebugDescriptor]bool)
+		for _, l := range lists {
+			for _, e := range *l {
+				info.listed[e] = true
+			}
+		}
+		info.listedCU = cu
+	}
+	if !info.listed[d] {
+		info.listed[d] = true
+		*list = append(*list, d)
+		lens = [3]int{len(cu.Subprograms), len(cu.GlobalVariables), len(cu.RetainedTypes)}
+	}
+	info.listedLen = lens
+}
+
+// Finalize completes the metadata of the CompileUnit, creating it if
+// necessary, by replacing the placeholders for its lists of subprograms,
+// global variables and retained types with the descriptors registered with
+// it. It must be called after the metadata of all other descriptors has
+// been created, and before the module is verified or emitted. Descriptors
+// created later are not listed.
+func (info *DebugInfo) Finalize() {
+	cu := info.CompileUnit
+	if cu == nil {
+		return
+	}
+	info.MDNode(cu)
+	if info.cuLists[0].IsNil() {
+		return
+	}
+	// Creating the metadata of a listed descriptor may register others,
+	// such as the named types of a subprogram's signature.
+	for {
+		n := len(cu.RetainedTypes) + len(cu.Subprograms) + len(cu.GlobalVariables)
+		info.MDNodes(cu.Subprograms)
+		info.MDNodes(cu.GlobalVariables)
+		info.MDNodes(cu.RetainedTypes)
+		if len(cu.RetainedTypes)+len(cu.Subprograms)+len(cu.GlobalVariables) == n {
+			break
+		}
+	}
+	lists := [3][]DebugDescriptor{cu.RetainedTypes, cu.Subprograms, cu.GlobalVariables}
+	for i, temp := range info.cuLists {
+		replaceTemporaryMDNode(temp, MDNode(info.MDNodes(orderDescriptors(lists[i]))))
+	}
+	info.cuLists = [3]Value{}
+}
+
+// Descriptors returns the descriptors for which metadata nodes have been
+// created, in the order they were created. A descriptor's metadata is
+// created after that of the descriptors it refers to.
+func (info *DebugInfo) Descriptors() []DebugDescriptor {
+	return append([]DebugDescriptor(nil), info.emitted...)
+}
+
+// DescriptorsWithTag returns the descriptors for which metadata nodes have
+// been created with the DWARF tag tag, in the order they were created, e.g.
+// DW_TAG_subprogram for CompileUnitDescriptor.Subprograms, or
+// DW_TAG_variable for CompileUnitDescriptor.GlobalVariables.
+func (info *DebugInfo) DescriptorsWithTag(tag DwarfTag) []DebugDescriptor {
+	var ds []DebugDescriptor
+	for _, d := range info.emitted {
+		if d.Tag() == tag {
+			ds = append(ds, d)
+		}
+	}
+	return ds
+}
+
 func (info *DebugInfo) MDNodes(d []DebugDescriptor) []Value {
 	if n := len(d); n > 0 {
 		v := make([]Value, n)
@@ -181,6 +341,198 @@ func (d *BasicTypeDescriptor) mdNode(info *DebugInfo) Value {
 		ConstInt(Int32Type(), uint64(d.TypeEncoding), false)})
 }
 
+// goMember describes a member of a Go runtime struct for newGoStruct.
+type goMember struct {
+	name      string
+	base      DebugDescriptor
+	size      uint64 // Size in bits.
+	alignment uint64 // Alignment in bits.
+}
+
+// newGoStruct returns a descriptor for a struct named name with the given
+// members, laid out with natural alignment as the Go compilers do. The
+// members are marked artificial.
+func newGoStruct(name string, members []goMember) *CompositeTypeDescriptor {
+	var offset, alignment uint64 = 0, 8
+	descriptors := make([]DebugDescriptor, len(members))
+	for i, m := range members {
+		offset = (offset + m.alignment - 1) / m.alignment * m.alignment
+		descriptors[i] = NewMemberDerivedType(m.name, m.base, m.size, m.alignment, offset, FlagArtificial)
+		offset += m.size
+		if m.alignment > alignment {
+			alignment = m.alignment
+		}
+	}
+	d := NewStructCompositeType(descriptors)
+	d.Name = name
+	d.Size = (offset + alignment - 1) / alignment * alignment
+	d.Alignment = alignment
+	return d
+}
+
+func newGoBasicType(name string, size uint64, encoding DwarfTypeEncoding) *BasicTypeDescriptor {
+	return &BasicTypeDescriptor{
+		Name:         name,
+		Size:         size,
+		Alignment:    size,
+		TypeEncoding: encoding,
+	}
+}
+
+func newGoPointerType(name string, base DebugDescriptor, ptrSize uint64) *DerivedTypeDescriptor {
+	d := NewPointerDerivedType(base)
+	d.Name = name
+	d.Size = ptrSize
+	d.Alignment = ptrSize
+	return d
+}
+
+// NewGoStringType returns a descriptor for the Go string type, laid out as
+// the runtime does: a struct named "string" with the members str, a pointer
+// to the UTF-8 encoded data, and len, an int. ptrSize is the size of a
+// pointer and of int, in bits. The members are marked artificial, and are
+// named as expected by the Go runtime's gdb extension, so that values are
+// printed as strings.
+func NewGoStringType(ptrSize uint64) *CompositeTypeDescriptor {
+	byteType := newGoBasicType("uint8", 8, DW_ATE_UTF)
+	intType := newGoBasicType("int", ptrSize, DW_ATE_signed)
+	return newGoStruct("string", []goMember{
+		{"str", newGoPointerType("", byteType, ptrSize), ptrSize, ptrSize},
+		{"len", intType, ptrSize, ptrSize},
+	})
+}
+
+// NewGoSliceType returns a descriptor for the Go slice type []elemName,
+// whose elements are described by elem: a struct with the members array,
+// len and cap. ptrSize is as for NewGoStringType.
+func NewGoSliceType(elemName string, elem DebugDescriptor, ptrSize uint64) *CompositeTypeDescriptor {
+	intType := newGoBasicType("int", ptrSize, DW_ATE_signed)
+	return newGoStruct("[]"+elemName, []goMember{
+		{"array", newGoPointerType("", elem, ptrSize), ptrSize, ptrSize},
+		{"len", intType, ptrSize, ptrSize},
+		{"cap", intType, ptrSize, ptrSize},
+	})
+}
+
+// descriptorSize returns the size and alignment, in bits, of the type
+// described by d, as recorded in the descriptor, or 0 and 8 if it has none.
+func descriptorSize(d DebugDescriptor) (size, alignment uint64) {
+	switch d := d.(type) {
+	case *BasicTypeDescriptor:
+		size, alignment = d.Size, d.Alignment
+	case *CompositeTypeDescriptor:
+		size, alignment = d.Size, d.Alignment
+	case *DerivedTypeDescriptor:
+		size, alignment = d.Size, d.Alignment
+	}
+	if alignment == 0 {
+		alignment = 8
+	}
+	return size, alignment
+}
+
+// newGoArrayType returns a descriptor for an array of n elements described
+// by elem.
+func newGoArrayType(elem DebugDescriptor, n int64) *CompositeTypeDescriptor {
+	size, alignment := descriptorSize(elem)
+	return &CompositeTypeDescriptor{
+		tag:       DW_TAG_array_type,
+		Size:      size * uint64(n),
+		Alignment: alignment,
+		Members:   []DebugDescriptor{&subrangeDescriptor{Count: n}},
+		elem:      elem,
+	}
+}
+
+// Go 1.1's hash tables have buckets of 8 entries, whose keys and values are
+// stored indirectly if they are larger than 128 bytes.
+const (
+	goMapBucketSize   = 8
+	goMapMaxEntrySize = 128 * 8 // in bits
+)
+
+// NewGoMapType returns a descriptor for the Go map type map[keyName]valName,
+// whose keys and values are described by key and val: a pointer to the
+// runtime's hash table header, laid out as in Go 1.1, whose buckets are
+// described with arrays of keys and values as the Go runtime's gdb
+// extension expects. ptrSize is as for NewGoStringType.
+func NewGoMapType(keyName string, key DebugDescriptor, valName string, val DebugDescriptor, ptrSize uint64) *DerivedTypeDescriptor {
+	intType := newGoBasicType("int", ptrSize, DW_ATE_signed)
+	uintptrType := newGoBasicType("uintptr", ptrSize, DW_ATE_unsigned)
+	uint8Type := newGoBasicType("uint8", 8, DW_ATE_unsigned)
+	uint16Type := newGoBasicType("uint16", 16, DW_ATE_unsigned)
+	uint32Type := newGoBasicType("uint32", 32, DW_ATE_unsigned)
+	entries := func(name string, d DebugDescriptor) goMember {
+		if size, _ := descriptorSize(d); size > goMapMaxEntrySize {
+			d = newGoPointerType("", d, ptrSize)
+		}
+		array := newGoArrayType(d, goMapBucketSize)
+		return goMember{name, array, array.Size, array.Alignment}
+	}
+	tophash := newGoArrayType(uint8Type, goMapBucketSize)
+	bucket := newGoStruct("bucket<"+keyName+","+valName+">", []goMember{
+		{"tophash", tophash, tophash.Size, 8},
+		{"overflow", newGoPointerType("", nil, ptrSize), ptrSize, ptrSize},
+		entries("keys", key),
+		entries("values", val),
+	})
+	bucketPtrType := newGoPointerType("", bucket, ptrSize)
+	hmap := newGoStruct("hash<"+keyName+","+valName+">", []goMember{
+		{"count", intType, ptrSize, ptrSize},
+		{"flags", uint32Type, 32, 32},
+		{"hash0", uint32Type, 32, 32},
+		{"B", uint8Type, 8, 8},
+		{"keysize", uint8Type, 8, 8},
+		{"valuesize", uint8Type, 8, 8},
+		{"bucketsize", uint16Type, 16, 16},
+		{"buckets", bucketPtrType, ptrSize, ptrSize},
+		{"oldbuckets", bucketPtrType, ptrSize, ptrSize},
+		{"nevacuate", uintptrType, ptrSize, ptrSize},
+	})
+	return newGoPointerType("map["+keyName+"]"+valName, hmap, ptrSize)
+}
+
+// NewGoChanType returns a descriptor for the Go channel type chan elemName,
+// whose elements are described by elem: a pointer to the runtime's channel
+// header, laid out as in Go 1.1, whose queues of waiting goroutines point
+// to their elements. The buffered elements, which follow the header, are
+// not described. ptrSize is as for NewGoStringType.
+func NewGoChanType(elemName string, elem DebugDescriptor, ptrSize uint64) *DerivedTypeDescriptor {
+	intType := newGoBasicType("int", ptrSize, DW_ATE_signed)
+	int64Type := newGoBasicType("int64", 64, DW_ATE_signed)
+	uintptrType := newGoBasicType("uintptr", ptrSize, DW_ATE_unsigned)
+	uint16Type := newGoBasicType("uint16", 16, DW_ATE_unsigned)
+	uint32Type := newGoBasicType("uint32", 32, DW_ATE_unsigned)
+	boolType := newGoBasicType("bool", 8, DW_ATE_boolean)
+	voidPtrType := newGoPointerType("", nil, ptrSize)
+	sudog := newGoStruct("sudog<"+elemName+">", []goMember{
+		{"g", voidPtrType, ptrSize, ptrSize},
+		{"selgen", uint32Type, 32, 32},
+		{"link", voidPtrType, ptrSize, ptrSize},
+		{"releasetime", int64Type, 64, 64},
+		{"elem", newGoPointerType("", elem, ptrSize), ptrSize, ptrSize},
+	})
+	sudogPtrType := newGoPointerType("", sudog, ptrSize)
+	waitq := newGoStruct("waitq<"+elemName+">", []goMember{
+		{"first", sudogPtrType, ptrSize, ptrSize},
+		{"last", sudogPtrType, ptrSize, ptrSize},
+	})
+	hchan := newGoStruct("hchan<"+elemName+">", []goMember{
+		{"qcount", intType, ptrSize, ptrSize},
+		{"dataqsiz", intType, ptrSize, ptrSize},
+		{"elemsize", uint16Type, 16, 16},
+		{"pad", uint16Type, 16, 16},
+		{"closed", boolType, 8, 8},
+		{"elemalg", voidPtrType, ptrSize, ptrSize},
+		{"sendx", intType, ptrSize, ptrSize},
+		{"recvx", intType, ptrSize, ptrSize},
+		{"recvq", waitq, waitq.Size, ptrSize},
+		{"sendq", waitq, waitq.Size, ptrSize},
+		{"lock", uintptrType, ptrSize, ptrSize},
+	})
+	return newGoPointerType("chan "+elemName, hchan, ptrSize)
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // Composite Types
 
@@ -195,6 +547,8 @@ type CompositeTypeDescriptor struct {
 	Offset    uint64 // Offset in bits
 	Flags     uint32
 	Members   []DebugDescriptor
+
+	elem DebugDescriptor // element type of an array
 }
 
 func (d *CompositeTypeDescriptor) Tag() DwarfTag {
@@ -212,7 +566,7 @@ func (d *CompositeTypeDescriptor) mdNode(info *DebugInfo) Value {
 		ConstInt(Int64Type(), d.Alignment, false),
 		ConstInt(Int64Type(), d.Offset, false),
 		ConstInt(Int32Type(), uint64(d.Flags), false),
-		info.MDNode(nil), // reference type derived from
+		info.MDNode(d.elem), // reference type derived from
 		MDNode(info.MDNodes(d.Members)),
 		ConstInt(Int32Type(), uint64(0), false), // Runtime language
 		ConstInt(Int32Type(), uint64(0), false), // Base type containing the vtable pointer for this type
@@ -238,6 +592,23 @@ func NewSubroutineCompositeType(
 	return d
 }
 
+// subrangeDescriptor describes the range of indices of an array type.
+type subrangeDescriptor struct {
+	Lo, Count int64
+}
+
+func (d *subrangeDescriptor) Tag() DwarfTag {
+	return DW_TAG_subrange_type
+}
+
+func (d *subrangeDescriptor) mdNode(info *DebugInfo) Value {
+	return MDNode([]Value{
+		ConstInt(Int32Type(), LLVMDebugVersion+uint64(d.Tag()), false),
+		ConstInt(Int64Type(), uint64(d.Lo), true),
+		ConstInt(Int64Type(), uint64(d.Count), true),
+	})
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // Compilation Unit
 
@@ -259,18 +630,32 @@ func (d *CompileUnitDescriptor) Tag() DwarfTag {
 }
 
 func (d *CompileUnitDescriptor) mdNode(info *DebugInfo) Value {
+	// The lists of the DebugInfo's CompileUnit are created by Finalize.
+	var lists [3]Value
+	if d == info.CompileUnit {
+		for i := range lists {
+			lists[i] = GlobalContext().temporaryMDNode()
+		}
+		info.cuLists = lists
+	} else {
+		lists = [3]Value{
+			MDNode(info.MDNodes(orderDescriptors(d.RetainedTypes))),
+			MDNode(info.MDNodes(orderDescriptors(d.Subprograms))),
+			MDNode(info.MDNodes(orderDescriptors(d.GlobalVariables))),
+		}
+	}
 	return MDNode([]Value{
 		ConstInt(Int32Type(), uint64(d.Tag())+LLVMDebugVersion, false),
-		d.Path.mdNode(nil),
+		d.Path.mdNode(info),
 		ConstInt(Int32Type(), uint64(d.Language), false),
 		MDString(d.Producer),
 		constInt1(d.Optimized),
 		MDString(d.CompilerFlags),
 		ConstInt(Int32Type(), uint64(d.Runtime), false),
 		MDNode(info.MDNodes(d.EnumTypes)),
-		MDNode(info.MDNodes(d.RetainedTypes)),
-		MDNode(info.MDNodes(d.Subprograms)),
-		MDNode(info.MDNodes(d.GlobalVariables)),
+		lists[0],     // Retained types
+		lists[1],     // Subprograms
+		lists[2],     // Global variables
 		MDNode(nil),  // List of imported entities
 		MDString(""), // Split debug filename
 	})
@@ -317,6 +702,24 @@ func NewPointerDerivedType(Base DebugDescriptor) *DerivedTypeDescriptor {
 	return d
 }
 
+// NewMemberDerivedType returns a descriptor for a struct member named Name,
+// of the type Base, with the given size, alignment and offset in bits.
+func NewMemberDerivedType(
+	Name string,
+	Base DebugDescriptor,
+	Size, Alignment, Offset uint64,
+	Flags uint32) *DerivedTypeDescriptor {
+	d := new(DerivedTypeDescriptor)
+	d.tag = DW_TAG_member
+	d.Name = Name
+	d.Base = Base
+	d.Size = Size
+	d.Alignment = Alignment
+	d.Offset = Offset
+	d.Flags = Flags
+	return d
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // Subprograms.
 
@@ -324,6 +727,7 @@ type SubprogramDescriptor struct {
 	Context     DebugDescriptor
 	Name        string
 	DisplayName string
+	LinkageName string // Symbol name; if empty, that of Function.
 	Type        DebugDescriptor
 	Line        uint32
 	Function    Value
@@ -337,14 +741,24 @@ func (d *SubprogramDescriptor) Tag() DwarfTag {
 	return DW_TAG_subprogram
 }
 
+// linkageName returns the symbol name of the subprogram: LinkageName, or
+// else the name of Function, which AddGoFunction names with the mangle
+// package.
+func (d *SubprogramDescriptor) linkageName() string {
+	if d.LinkageName == "" && !d.Function.IsNil() {
+		return d.Function.Name()
+	}
+	return d.LinkageName
+}
+
 func (d *SubprogramDescriptor) mdNode(info *DebugInfo) Value {
 	return MDNode([]Value{
 		ConstInt(Int32Type(), LLVMDebugVersion+uint64(d.Tag()), false),
-		d.Path.mdNode(nil),
+		d.Path.mdNode(info),
 		info.MDNode(d.Context),
 		MDString(d.Name),
 		MDString(d.DisplayName),
-		MDString(""), // mips linkage name
+		MDString(d.linkageName()),
 		ConstInt(Int32Type(), uint64(d.Line), false),
 		info.MDNode(d.Type),
 		ConstNull(Int1Type()),                        // not static
@@ -445,7 +859,7 @@ func (d *FileDescriptor) mdNode(info *DebugInfo) Value {
 	if l := len(dirname); l > 0 && dirname[l-1] == '/' {
 		dirname = dirname[:l-1]
 	}
-	return MDNode([]Value{MDString(filename), MDString(dirname)})
+	return MDNode([]Value{MDString(filename), MDString(info.remapPath(dirname))})
 }
 
 ///////////////////////////////////////////////////////////////////////////////
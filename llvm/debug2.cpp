@@ -1,11 +1,24 @@
-#include <llvm/DebugInfo.h>
+#include <llvm/Function.h>
 #include <llvm/Intrinsics.h>
+#include <llvm/ADT/ArrayRef.h>
+#include <cstring>
 
-typedef void* LLVMValueRef;
-extern "C" LLVMValueRef getDbgDeclare(llvm::Module* module) {
-	llvm::Intrinsic::ID id = llvm::Intrinsic::dbg_declare;
-	// TODO: why on earth is the +1 needed??
-	id++;
-	llvm::Function* DeclareFn = llvm::Intrinsic::getDeclaration(module, id);
-	return DeclareFn;
+// intrinsicID returns the ID of the intrinsic with the given base name,
+// e.g. "llvm.dbg.declare", or 0 (not_intrinsic) if there is none.
+extern "C" unsigned intrinsicID(const char *name) {
+	for (unsigned id = 1; id < llvm::Intrinsic::num_intrinsics; id++) {
+		if (llvm::Intrinsic::getName(llvm::Intrinsic::ID(id)) == name)
+			return id;
+	}
+	return 0;
+}
+
+// getIntrinsicDeclaration returns the declaration of the intrinsic id,
+// overloaded on the given types.
+extern "C" llvm::Function *getIntrinsicDeclaration(llvm::Module *module,
+                                                   unsigned id,
+                                                   llvm::Type **types,
+                                                   unsigned n) {
+	llvm::ArrayRef<llvm::Type*> tys(types, n);
+	return llvm::Intrinsic::getDeclaration(module, llvm::Intrinsic::ID(id), tys);
 }
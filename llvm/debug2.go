@@ -2,17 +2,101 @@ package llvm
 
 /*
 #include <llvm-c/Core.h>
+#include <stdlib.h>
 
-extern LLVMValueRef getDbgDeclare(LLVMModuleRef);
+extern unsigned intrinsicID(const char*);
+extern LLVMValueRef getIntrinsicDeclaration(LLVMModuleRef, unsigned,
+                                            LLVMTypeRef*, unsigned);
 */
 import "C"
 
-import "fmt"
+import (
+	"sync"
+	"unsafe"
+)
 
-func (b Builder) InsertDeclare(module Module, storage Value, md Value) Value {
-	nf := Value{C.getDbgDeclare(module.C)}
-	if nf.IsAFunction().IsNil() || nf.Name() != "llvm.dbg.declare" {
-		panic(fmt.Sprintf("Wanted llvm.dbg.declare but got: %s", nf.Name()))
+// intrinsicIDs caches the IDs of intrinsics by name, since LLVM can only
+// find them by searching all intrinsics.
+var intrinsicIDs struct {
+	sync.Mutex
+	m map[string]C.unsigned
+}
+
+// lookupIntrinsicID returns the ID of the intrinsic name, or 0 if there is
+// no such intrinsic.
+func lookupIntrinsicID(name string) C.unsigned {
+	intrinsicIDs.Lock()
+	defer intrinsicIDs.Unlock()
+	id, ok := intrinsicIDs.m[name]
+	if !ok {
+		cname := C.CString(name)
+		id = C.intrinsicID(cname)
+		C.free(unsafe.Pointer(cname))
+		if intrinsicIDs.m == nil {
+			intrinsicIDs.m = make(map[string]C.unsigned)
+		}
+		intrinsicIDs.m[name] = id
+	}
+	return id
+}
+
+// GetOrInsertIntrinsic returns the declaration of the intrinsic with the
+// base name name, such as "llvm.dbg.declare" or "llvm.dbg.value", in the
+// module, inserting it if necessary. types gives the types an overloaded
+// intrinsic is instantiated with, and must be empty otherwise. If LLVM has
+// no intrinsic with the name, the result is nil.
+// See llvm::Intrinsic::getDeclaration.
+func GetOrInsertIntrinsic(module Module, name string, types []Type) (v Value) {
+	id := lookupIntrinsicID(name)
+	if id == 0 {
+		return
+	}
+	var pt *C.LLVMTypeRef
+	if len(types) > 0 {
+		pt = llvmTypeRefPtr(&types[0])
+	}
+	v.C = C.getIntrinsicDeclaration(module.C, id, pt, C.unsigned(len(types)))
+	return
+}
+
+func dbgDeclare(module Module) Value {
+	nf := GetOrInsertIntrinsic(module, "llvm.dbg.declare", nil)
+	if nf.IsNil() {
+		panic("llvm.dbg.declare intrinsic not found")
 	}
-	return b.CreateCall(nf, []Value{storage, md}, "")
+	return nf
+}
+
+func (b Builder) InsertDeclare(module Module, storage Value, md Value) Value {
+	return b.CreateCall(dbgDeclare(module), []Value{storage, md}, "")
+}
+
+// insertDeclareWith inserts a call to llvm.dbg.declare using a temporary
+// builder positioned by position, with the builder's current debug
+// location, leaving the builder's own insertion point unchanged.
+func (b Builder) insertDeclareWith(module Module, storage, md Value, position func(Builder)) Value {
+	tmp := storage.Type().Context().NewBuilder()
+	defer tmp.Dispose()
+	position(tmp)
+	tmp.SetCurrentDebugLocation(b.CurrentDebugLocation())
+	return tmp.InsertDeclare(module, storage, md)
+}
+
+// InsertDeclareAtEnd is like InsertDeclare, but appends the declaration to
+// the end of block rather than at the builder's insertion point, which is
+// left unchanged.
+func (b Builder) InsertDeclareAtEnd(module Module, storage, md Value, block BasicBlock) Value {
+	return b.insertDeclareWith(module, storage, md, func(tmp Builder) {
+		tmp.SetInsertPointAtEnd(block)
+	})
+}
+
+// InsertDeclareBefore is like InsertDeclare, but inserts the declaration
+// before the instruction instr rather than at the builder's insertion point,
+// which is left unchanged. This allows declarations to be placed in the
+// entry block, e.g. after the allocas, as LLVM recommends.
+func (b Builder) InsertDeclareBefore(module Module, storage, md Value, instr Value) Value {
+	return b.insertDeclareWith(module, storage, md, func(tmp Builder) {
+		tmp.SetInsertPointBefore(instr)
+	})
 }
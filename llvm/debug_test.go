@@ -0,0 +1,64 @@
+package llvm
+
+import "testing"
+
+func TestDebugInfoFinalize(t *testing.T) {
+	m := NewModule("debug")
+	defer m.Dispose()
+	f := AddFunction(m, "f", FunctionType(VoidType(), nil, false))
+	g := AddGlobal(m, Int32Type(), "g")
+
+	path := FileDescriptor("x.go")
+	cu := &CompileUnitDescriptor{Path: path, Language: DW_LANG_Go, Producer: "test"}
+	info := &DebugInfo{CompileUnit: cu}
+	intType := &BasicTypeDescriptor{Name: "int", Size: 32, Alignment: 32, TypeEncoding: DW_ATE_signed}
+	point := NewStructCompositeType([]DebugDescriptor{
+		NewMemberDerivedType("x", intType, 32, 32, 0, 0),
+	})
+	point.Name = "point"
+	sp := &SubprogramDescriptor{
+		Context:  cu,
+		Name:     "f",
+		Type:     NewSubroutineCompositeType(nil, []DebugDescriptor{NewPointerDerivedType(point)}),
+		Line:     1,
+		Function: f,
+		Path:     path,
+	}
+	gv := &GlobalVariableDescriptor{Context: cu, Name: "g", File: &path, Line: 2, Type: intType, Value: g}
+
+	// The subprogram's context, the compile unit, is created first.
+	spNode := info.MDNode(sp)
+	gvNode := info.MDNode(gv)
+	info.Finalize()
+	cuNode := info.MDNode(cu)
+	if got := spNode.MDNodeOperands()[2]; got != cuNode {
+		t.Errorf("subprogram context is not the compile unit")
+	}
+
+	ops := cuNode.MDNodeOperands()
+	lists := []struct {
+		name string
+		list Value
+		want []Value
+	}{
+		{"retained types", ops[8], []Value{info.MDNode(point)}},
+		{"subprograms", ops[9], []Value{spNode}},
+		{"global variables", ops[10], []Value{gvNode}},
+	}
+	for _, l := range lists {
+		if l.list.IsAMDNode().IsNil() {
+			t.Errorf("%s: not a metadata node", l.name)
+			continue
+		}
+		got := l.list.MDNodeOperands()
+		if len(got) != len(l.want) {
+			t.Errorf("%s: %d descriptors, want %d", l.name, len(got), len(l.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != l.want[i] {
+				t.Errorf("%s: descriptor %d is not the registered descriptor", l.name, i)
+			}
+		}
+	}
+}
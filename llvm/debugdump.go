@@ -0,0 +1,177 @@
+package llvm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The functions in this file decode debug metadata in the layout produced
+// by the descriptors in debug.go.
+
+func mdOperands(node Value) []Value {
+	if node.IsNil() || node.IsAMDNode().IsNil() {
+		return nil
+	}
+	return node.MDNodeOperands()
+}
+
+func mdOperand(node Value, i int) Value {
+	if ops := mdOperands(node); i < len(ops) {
+		return ops[i]
+	}
+	return Value{}
+}
+
+func mdString(v Value) string {
+	if v.IsNil() || v.IsAMDString().IsNil() {
+		return ""
+	}
+	return v.MDStringValue()
+}
+
+func mdInt(v Value) uint64 {
+	if v.IsNil() || v.IsAConstantInt().IsNil() {
+		return 0
+	}
+	return v.ZExtValue()
+}
+
+// mdTag returns the DWARF tag of the debug descriptor node, or 0 if node is
+// not a descriptor.
+func mdTag(node Value) DwarfTag {
+	if node.IsNil() || node.IsAMDNode().IsNil() {
+		return 0
+	}
+	v := mdInt(mdOperand(node, 0))
+	if v < LLVMDebugVersion {
+		return 0
+	}
+	return DwarfTag(v - LLVMDebugVersion)
+}
+
+// mdFile returns the path of the file node (filename, directory).
+func mdFile(node Value) string {
+	name, dir := mdString(mdOperand(node, 0)), mdString(mdOperand(node, 1))
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
+
+// debugTypeName renders the type descriptor node in a Go-like syntax.
+func debugTypeName(node Value, depth int) string {
+	if node.IsNil() {
+		return "void"
+	}
+	name := mdString(mdOperand(node, 3))
+	switch tag := mdTag(node); tag {
+	case DW_TAG_base_type:
+		return name
+	case DW_TAG_pointer_type:
+		if name != "" {
+			return name
+		}
+		return "*" + debugTypeName(mdOperand(node, 9), depth+1)
+	case DW_TAG_member:
+		return name + " " + debugTypeName(mdOperand(node, 9), depth+1)
+	case DW_TAG_structure_type, DW_TAG_subroutine_type:
+		if name != "" || depth > 4 {
+			return name
+		}
+		members := mdOperand(node, 10)
+		var s []string
+		for _, m := range mdOperands(members) {
+			s = append(s, debugTypeName(m, depth+1))
+		}
+		if tag == DW_TAG_subroutine_type {
+			if len(s) == 0 {
+				return "func()"
+			}
+			return fmt.Sprintf("func(%s) %s", strings.Join(s[1:], ", "), s[0])
+		}
+		return "struct{" + strings.Join(s, "; ") + "}"
+	default:
+		return fmt.Sprintf("<tag %#x>", uint32(tag))
+	}
+}
+
+// functionVariables returns the variable descriptors referred to by calls
+// to llvm.dbg.declare and llvm.dbg.value in the function f.
+func functionVariables(f Value) []Value {
+	var vars []Value
+	for bb := f.FirstBasicBlock(); !bb.IsNil(); bb = NextBasicBlock(bb) {
+		for i := bb.FirstInstruction(); !i.IsNil(); i = NextInstruction(i) {
+			if i.IsACallInst().IsNil() {
+				continue
+			}
+			callee := i.Operand(i.OperandsCount() - 1)
+			switch callee.Name() {
+			case "llvm.dbg.declare":
+				vars = append(vars, i.Operand(1))
+			case "llvm.dbg.value":
+				vars = append(vars, i.Operand(2))
+			}
+		}
+	}
+	return vars
+}
+
+// DumpDebugInfo writes a human-readable tree of the debug metadata of the
+// module m, as listed in its llvm.dbg.cu named metadata, to w: each compile
+// unit, with its subprograms and their variables, and its global variables.
+func DumpDebugInfo(w io.Writer, m Module) error {
+	for _, cu := range m.NamedMetadataOperands("llvm.dbg.cu") {
+		if mdTag(cu) != DW_TAG_compile_unit {
+			continue
+		}
+		_, err := fmt.Fprintf(w, "compile unit %s (language %#x, producer %q)\n",
+			mdFile(mdOperand(cu, 1)), mdInt(mdOperand(cu, 2)), mdString(mdOperand(cu, 3)))
+		if err != nil {
+			return err
+		}
+		for _, sp := range mdOperands(mdOperand(cu, 9)) {
+			if mdTag(sp) != DW_TAG_subprogram {
+				continue
+			}
+			fn := mdOperand(sp, 15)
+			fname := "<none>"
+			if !fn.IsNil() {
+				fname = fn.Name()
+			}
+			_, err := fmt.Fprintf(w, "  subprogram %s: %s, line %d, function %s\n",
+				mdString(mdOperand(sp, 3)), debugTypeName(mdOperand(sp, 7), 0),
+				mdInt(mdOperand(sp, 6)), fname)
+			if err != nil {
+				return err
+			}
+			if fn.IsNil() || fn.IsAFunction().IsNil() {
+				continue
+			}
+			for _, v := range functionVariables(fn) {
+				kind := "variable"
+				if mdTag(v) == DW_TAG_arg_variable {
+					kind = "argument"
+				}
+				_, err := fmt.Fprintf(w, "    %s %s %s, line %d\n", kind,
+					mdString(mdOperand(v, 2)), debugTypeName(mdOperand(v, 5), 0),
+					mdInt(mdOperand(v, 4))&(1<<24-1))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		for _, gv := range mdOperands(mdOperand(cu, 10)) {
+			if mdTag(gv) != DW_TAG_variable {
+				continue
+			}
+			_, err := fmt.Fprintf(w, "  global %s %s, line %d\n",
+				mdString(mdOperand(gv, 3)), debugTypeName(mdOperand(gv, 8), 0),
+				mdInt(mdOperand(gv, 7)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package llvm
+
+import "fmt"
+
+// CheckSubprogram reports whether the subprogram descriptor d is consistent
+// with the function it describes: d.Function must be a function, and if
+// d.Type is a subroutine type, it must have a member for the result and for
+// each of the function's parameters.
+func CheckSubprogram(d *SubprogramDescriptor) error {
+	f := d.Function
+	if f.IsNil() || f.IsAFunction().IsNil() {
+		return fmt.Errorf("subprogram %q does not refer to a function", d.Name)
+	}
+	if t, ok := d.Type.(*CompositeTypeDescriptor); ok && t != nil && t.Tag() == DW_TAG_subroutine_type {
+		if params := len(t.Members) - 1; params != f.ParamsCount() {
+			return fmt.Errorf("subprogram %q has %d parameters, but function %s has %d",
+				d.Name, params, f.Name(), f.ParamsCount())
+		}
+	}
+	return nil
+}
+
+// ScopeStack tracks the nesting of subprogram and lexical block scopes while
+// generating a function body, so that each BlockDescriptor's Context refers
+// to its enclosing scope and each block has a unique Id.
+type ScopeStack struct {
+	scopes  []DebugDescriptor
+	blockId uint32
+}
+
+// PushSubprogram checks d with CheckSubprogram, and if it is consistent,
+// makes it the current scope.
+func (s *ScopeStack) PushSubprogram(d *SubprogramDescriptor) error {
+	if err := CheckSubprogram(d); err != nil {
+		return err
+	}
+	s.scopes = append(s.scopes, d)
+	return nil
+}
+
+// PushBlock creates a lexical block nested in the current scope, beginning
+// at the given line and column of file, and makes it the current scope. It
+// panics if there is no enclosing subprogram.
+func (s *ScopeStack) PushBlock(file *FileDescriptor, line, column uint32) *BlockDescriptor {
+	if s.Subprogram() == nil {
+		panic("lexical block outside of a subprogram")
+	}
+	s.blockId++
+	d := &BlockDescriptor{
+		File:    file,
+		Context: s.Current(),
+		Line:    line,
+		Column:  column,
+		Id:      s.blockId,
+	}
+	s.scopes = append(s.scopes, d)
+	return d
+}
+
+// Pop removes and returns the current scope. It panics if the stack is
+// empty.
+func (s *ScopeStack) Pop() DebugDescriptor {
+	n := len(s.scopes)
+	if n == 0 {
+		panic("pop of empty scope stack")
+	}
+	d := s.scopes[n-1]
+	s.scopes = s.scopes[:n-1]
+	return d
+}
+
+// Current returns the current scope, or nil if the stack is empty.
+func (s *ScopeStack) Current() DebugDescriptor {
+	if n := len(s.scopes); n > 0 {
+		return s.scopes[n-1]
+	}
+	return nil
+}
+
+// Subprogram returns the innermost subprogram on the stack, or nil if there
+// is none.
+func (s *ScopeStack) Subprogram() *SubprogramDescriptor {
+	for i := len(s.scopes) - 1; i >= 0; i-- {
+		if d, ok := s.scopes[i].(*SubprogramDescriptor); ok {
+			return d
+		}
+	}
+	return nil
+}
+
+// Depth returns the number of scopes on the stack.
+func (s *ScopeStack) Depth() int { return len(s.scopes) }
@@ -0,0 +1,100 @@
+package llvm
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync/atomic"
+)
+
+// deterministic is non-zero if deterministic output has been enabled with
+// SetDeterministic.
+var deterministic int32
+
+// SetDeterministic enables or disables deterministic output, for
+// reproducible builds, where identical inputs must produce byte-identical
+// objects, e.g. for build caching and attestation. While it is enabled:
+//
+//   - the subprograms, global variables and retained types of a compile
+//     unit are listed in the order of their names and lines, rather than in
+//     the order in which their metadata was created by DebugInfo;
+//   - IncrementalBuild names unnamed globals after their contents, rather
+//     than numbering them in the order they are found, so that a rebuild
+//     names them as a fresh build would;
+//   - Link runs the linker in the temporary directory of its objects, and
+//     names them relative to it, since some linkers record the paths of
+//     objects in their output.
+//
+// LLVM numbers unnamed values within a function by their position, so the
+// numbering is deterministic already. See also DebugInfo.PathPrefixMap,
+// which removes the build directory from debug information.
+func SetDeterministic(on bool) {
+	var n int32
+	if on {
+		n = 1
+	}
+	atomic.StoreInt32(&deterministic, n)
+}
+
+// Deterministic reports whether deterministic output has been enabled with
+// SetDeterministic.
+func Deterministic() bool {
+	return atomic.LoadInt32(&deterministic) != 0
+}
+
+// descriptorKey returns the key by which the descriptor d is ordered in the
+// lists of a compile unit when output is deterministic.
+func descriptorKey(d DebugDescriptor) string {
+	switch d := d.(type) {
+	case *SubprogramDescriptor:
+		return fmt.Sprintf("%s\x00%s\x00%s\x00%010d", d.Path, d.linkageName(), d.Name, d.Line)
+	case *GlobalVariableDescriptor:
+		var file FileDescriptor
+		if d.File != nil {
+			file = *d.File
+		}
+		return fmt.Sprintf("%s\x00%s\x00%s\x00%010d", file, d.DisplayName, d.Name, d.Line)
+	case *CompositeTypeDescriptor:
+		var file FileDescriptor
+		if d.File != nil {
+			file = *d.File
+		}
+		return fmt.Sprintf("%s\x00%s\x00%010d\x00%d", file, d.Name, d.Line, d.tag)
+	}
+	return ""
+}
+
+type descriptorsByKey []DebugDescriptor
+
+func (l descriptorsByKey) Len() int           { return len(l) }
+func (l descriptorsByKey) Less(i, j int) bool { return descriptorKey(l[i]) < descriptorKey(l[j]) }
+func (l descriptorsByKey) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// orderDescriptors returns the list of descriptors of a compile unit in the
+// order in which they are to be emitted: as given, or, when output is
+// deterministic, sorted by descriptorKey. Descriptors with equal keys keep
+// their order.
+func orderDescriptors(list []DebugDescriptor) []DebugDescriptor {
+	if !Deterministic() || len(list) < 2 {
+		return list
+	}
+	sorted := append([]DebugDescriptor(nil), list...)
+	sort.Stable(descriptorsByKey(sorted))
+	return sorted
+}
+
+// globalSlot matches the slot numbers of unnamed globals in IR text.
+var globalSlot = regexp.MustCompile(`@[0-9]+\b`)
+
+// contentName returns a name for the unnamed global g derived from its
+// text: its linkage, type and initializer or body, without its own slot
+// number or those of the unnamed globals it refers to, which depend on
+// their position in the module. Slot numbers of values local to a function
+// are numbered within the function, and so depend only on its body.
+// Unnamed globals with the same text are told apart by LLVM, which numbers
+// the names after the first in module order.
+func contentName(g Value) string {
+	text := globalSlot.ReplaceAllString(g.IRString(), "@")
+	return fmt.Sprintf("gollvm.anon.%x", sha1.Sum([]byte(text)))
+}
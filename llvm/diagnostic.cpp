@@ -0,0 +1,24 @@
+#include <llvm/LLVMContext.h>
+#include <llvm/Support/SourceMgr.h>
+#include <string>
+
+// Exported by diagnostic.go, which copies the message.
+extern "C" void goDiagnostic(llvm::LLVMContext *, int, const char *, unsigned);
+
+static void diagnosticHandler(const llvm::SMDiagnostic &diag, void *ctx,
+                              unsigned cookie) {
+	std::string msg = diag.getMessage();
+	goDiagnostic(static_cast<llvm::LLVMContext *>(ctx), diag.getKind(),
+	             msg.c_str(), cookie);
+}
+
+// setDiagnosticHandler sets or clears the handler to which LLVMContext
+// reports errors in inline assembly, and other errors the code generator
+// attributes to instructions, with their srcloc cookies.
+extern "C" void setDiagnosticHandler(llvm::LLVMContext *c, bool set) {
+	if (set) {
+		c->setInlineAsmDiagnosticHandler(diagnosticHandler, c);
+	} else {
+		c->setInlineAsmDiagnosticHandler(0, 0);
+	}
+}
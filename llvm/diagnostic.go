@@ -0,0 +1,232 @@
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+#include <stdbool.h>
+
+extern void setDiagnosticHandler(LLVMContextRef, bool);
+*/
+import "C"
+
+import (
+	"go/token"
+	"path"
+	"sync"
+)
+
+// DiagnosticSeverity is the severity of a Diagnostic.
+type DiagnosticSeverity int
+
+// The values are those of llvm::SourceMgr::DiagKind.
+const (
+	DiagnosticError DiagnosticSeverity = iota
+	DiagnosticWarning
+	DiagnosticNote
+)
+
+func (s DiagnosticSeverity) String() string {
+	switch s {
+	case DiagnosticError:
+		return "error"
+	case DiagnosticWarning:
+		return "warning"
+	case DiagnosticNote:
+		return "note"
+	}
+	return "unknown"
+}
+
+// Diagnostic is an error or warning reported by the code generator.
+type Diagnostic struct {
+	Severity DiagnosticSeverity
+	Message  string
+
+	// Pos is the source position of the instruction which caused the
+	// diagnostic, if it is known; see AttachSourcePositions.
+	Pos token.Position
+}
+
+// String formats the diagnostic as the Go tools do, e.g.
+// "x.go:12:3: invalid operand for instruction".
+func (d Diagnostic) String() string {
+	msg := d.Message
+	if d.Severity != DiagnosticError {
+		msg = d.Severity.String() + ": " + msg
+	}
+	if !d.Pos.IsValid() {
+		return msg
+	}
+	return d.Pos.String() + ": " + msg
+}
+
+// diagnostics records the handlers set with SetDiagnosticHandler, and the
+// positions numbered by AttachSourcePositions, by context.
+var diagnostics struct {
+	sync.Mutex
+	handlers  map[C.LLVMContextRef]func(Diagnostic)
+	positions map[C.LLVMContextRef]*sourcePositions
+}
+
+// sourcePositions numbers the distinct source positions given srclocs in a
+// context, so that instructions at the same position share a cookie.
+type sourcePositions struct {
+	list   []token.Position // cookie i identifies list[i-1]
+	cookie map[token.Position]int
+}
+
+// SetDiagnosticHandler sets the function called with the diagnostics the
+// code generator reports for modules in the context c, or, if handler is
+// nil, restores the default, printing them to standard error, or, for
+// errors, exiting. LLVM 3.2 has no general diagnostic or remark
+// interface: the code generator reports through this handler errors in
+// inline assembly, and errors in lowering other instructions, such as
+// unsupported intrinsics. The handler is called on the thread generating
+// code, and must not use the context.
+func (c Context) SetDiagnosticHandler(handler func(Diagnostic)) {
+	diagnostics.Lock()
+	if diagnostics.handlers == nil {
+		diagnostics.handlers = make(map[C.LLVMContextRef]func(Diagnostic))
+	}
+	if handler == nil {
+		delete(diagnostics.handlers, c.C)
+	} else {
+		diagnostics.handlers[c.C] = handler
+	}
+	diagnostics.Unlock()
+	C.setDiagnosticHandler(c.C, C.bool(handler != nil))
+}
+
+//export goDiagnostic
+func goDiagnostic(c C.LLVMContextRef, kind C.int, msg *C.char, cookie C.unsigned) {
+	diagnostics.Lock()
+	handler := diagnostics.handlers[c]
+	var pos token.Position
+	if sp := diagnostics.positions[c]; sp != nil && cookie > 0 && int(cookie) <= len(sp.list) {
+		pos = sp.list[cookie-1]
+	}
+	diagnostics.Unlock()
+	if handler != nil {
+		handler(Diagnostic{DiagnosticSeverity(kind), C.GoString(msg), pos})
+	}
+}
+
+// forgetDiagnostics removes the handler and positions of the context c,
+// which is being disposed.
+func forgetDiagnostics(c Context) {
+	diagnostics.Lock()
+	delete(diagnostics.handlers, c.C)
+	delete(diagnostics.positions, c.C)
+	diagnostics.Unlock()
+}
+
+// AttachSourcePositions gives each call instruction in the module m with a
+// debug location, and without a srcloc, a srcloc identifying the
+// location's source position, which the code generator passes to the
+// diagnostic handler when reporting an error for the instruction, such as
+// an error in inline assembly. It returns the number of instructions
+// given a srcloc.
+//
+// The positions are recorded in the module's context, each distinct
+// position once, so that the memory used grows with the number of source
+// positions compiled rather than the number of modules. A long-lived
+// context which compiles many different sources should be reset with
+// ResetSourcePositions once their code has been generated.
+func AttachSourcePositions(m Module) int {
+	ctx := m.Context()
+	dbg := ctx.MDKindID("dbg")
+	srcloc := ctx.MDKindID("srcloc")
+	i32 := ctx.Int32Type()
+	diagnostics.Lock()
+	defer diagnostics.Unlock()
+	if diagnostics.positions == nil {
+		diagnostics.positions = make(map[C.LLVMContextRef]*sourcePositions)
+	}
+	sp := diagnostics.positions[ctx.C]
+	if sp == nil {
+		sp = &sourcePositions{cookie: make(map[token.Position]int)}
+		diagnostics.positions[ctx.C] = sp
+	}
+	n := 0
+	for f := m.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		for bb := f.FirstBasicBlock(); !bb.IsNil(); bb = NextBasicBlock(bb) {
+			for i := bb.FirstInstruction(); !i.IsNil(); i = NextInstruction(i) {
+				if i.IsACallInst().IsNil() || !i.Metadata(srcloc).IsNil() {
+					continue
+				}
+				pos, ok := DebugLocPosition(i.Metadata(dbg))
+				if !ok {
+					continue
+				}
+				cookie, ok := sp.cookie[pos]
+				if !ok {
+					sp.list = append(sp.list, pos)
+					cookie = len(sp.list)
+					sp.cookie[pos] = cookie
+				}
+				i.SetMetadata(srcloc, ctx.MDNode([]Value{ConstInt(i32, uint64(cookie), false)}))
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// ResetSourcePositions forgets the source positions recorded in the
+// context c by AttachSourcePositions. Diagnostics for instructions given
+// srclocs before the reset, in modules whose code has yet to be generated,
+// may then report the wrong position, or none.
+func (c Context) ResetSourcePositions() {
+	diagnostics.Lock()
+	delete(diagnostics.positions, c.C)
+	diagnostics.Unlock()
+}
+
+// Position returns the source position of the instruction v, from its
+// debug location. See DebugLocPosition.
+func (v Value) Position() (token.Position, bool) {
+	return DebugLocPosition(v.Metadata(v.Type().Context().MDKindID("dbg")))
+}
+
+// DebugLocPosition returns the source position of the debug location loc,
+// as created from a LineDescriptor: its line and column, and the file of
+// its innermost enclosing scope which has one, joined to the file's
+// directory unless it is absolute, so that frontends may report problems
+// found by the code generator in Go sources as the Go tools do. The
+// position of an inlined instruction is that of the inlined code. ok is
+// false if loc is not a debug location.
+func DebugLocPosition(loc Value) (pos token.Position, ok bool) {
+	if loc.IsNil() || loc.IsAMDNode().IsNil() || loc.MDNodeOperandsCount() < 3 {
+		return pos, false
+	}
+	ops := loc.MDNodeOperands()
+	if ops[0].IsAConstantInt().IsNil() || ops[1].IsAConstantInt().IsNil() {
+		return pos, false
+	}
+	pos.Line = int(ops[0].ZExtValue())
+	pos.Column = int(ops[1].ZExtValue())
+
+	// Each scope has its file, a (filename, directory) pair, as operand 1,
+	// and its enclosing scope as operand 2; a compile unit has no
+	// enclosing scope.
+	for scope, depth := ops[2], 0; !scope.IsNil() && !scope.IsAMDNode().IsNil() && depth < 100; depth++ {
+		sops := scope.MDNodeOperands()
+		if len(sops) < 2 {
+			break
+		}
+		if file := sops[1]; !file.IsNil() && !file.IsAMDNode().IsNil() && file.MDNodeOperandsCount() == 2 {
+			fops := file.MDNodeOperands()
+			if !fops[0].IsAMDString().IsNil() && !fops[1].IsAMDString().IsNil() {
+				pos.Filename = fops[0].MDStringValue()
+				if !path.IsAbs(pos.Filename) {
+					pos.Filename = path.Join(fops[1].MDStringValue(), pos.Filename)
+				}
+				break
+			}
+		}
+		if len(sops) < 3 {
+			break
+		}
+		scope = sops[2]
+	}
+	return pos, true
+}
@@ -0,0 +1,315 @@
+package llvm
+
+import "sort"
+
+// DominatorTree is the dominator tree of the reachable basic blocks of a
+// function. It must be recomputed after the function's control flow
+// changes.
+type DominatorTree struct {
+	blocks   []BasicBlock // reachable blocks in reverse postorder
+	order    map[BasicBlock]int
+	idom     []int // index in blocks of each block's immediate dominator
+	children [][]BasicBlock
+	preds    map[BasicBlock][]BasicBlock
+
+	// in and out number a preorder walk of the tree, so that a dominates b
+	// iff in[a] <= in[b] && out[b] <= out[a].
+	in, out []int
+}
+
+// NewDominatorTree computes the dominator tree of the function f, which
+// must have a body, using the algorithm of Cooper, Harvey and Kennedy.
+func NewDominatorTree(f Value) *DominatorTree {
+	dt := &DominatorTree{
+		order: make(map[BasicBlock]int),
+		preds: make(map[BasicBlock][]BasicBlock),
+	}
+
+	// Number the reachable blocks in reverse postorder.
+	visited := make(map[BasicBlock]bool)
+	var postorder []BasicBlock
+	var visit func(bb BasicBlock)
+	visit = func(bb BasicBlock) {
+		visited[bb] = true
+		for _, succ := range bb.Successors() {
+			// A terminator, e.g. a switch, may branch to a block more than
+			// once; each predecessor is recorded once.
+			if !containsBlock(dt.preds[succ], bb) {
+				dt.preds[succ] = append(dt.preds[succ], bb)
+			}
+			if !visited[succ] {
+				visit(succ)
+			}
+		}
+		postorder = append(postorder, bb)
+	}
+	visit(f.EntryBasicBlock())
+	for i := len(postorder) - 1; i >= 0; i-- {
+		dt.order[postorder[i]] = len(dt.blocks)
+		dt.blocks = append(dt.blocks, postorder[i])
+	}
+
+	dt.idom = make([]int, len(dt.blocks))
+	for i := range dt.idom {
+		dt.idom[i] = -1
+	}
+	dt.idom[0] = 0
+	for changed := true; changed; {
+		changed = false
+		for b := 1; b < len(dt.blocks); b++ {
+			idom := -1
+			for _, pred := range dt.preds[dt.blocks[b]] {
+				p, ok := dt.order[pred]
+				if !ok || dt.idom[p] == -1 {
+					continue
+				}
+				if idom == -1 {
+					idom = p
+				} else {
+					idom = dt.intersect(p, idom)
+				}
+			}
+			if dt.idom[b] != idom {
+				dt.idom[b] = idom
+				changed = true
+			}
+		}
+	}
+
+	dt.children = make([][]BasicBlock, len(dt.blocks))
+	for b := 1; b < len(dt.blocks); b++ {
+		dt.children[dt.idom[b]] = append(dt.children[dt.idom[b]], dt.blocks[b])
+	}
+	dt.in = make([]int, len(dt.blocks))
+	dt.out = make([]int, len(dt.blocks))
+	n := 0
+	var number func(b int)
+	number = func(b int) {
+		dt.in[b] = n
+		n++
+		for _, child := range dt.children[b] {
+			number(dt.order[child])
+		}
+		dt.out[b] = n
+		n++
+	}
+	number(0)
+	return dt
+}
+
+func containsBlock(blocks []BasicBlock, bb BasicBlock) bool {
+	for _, b := range blocks {
+		if b == bb {
+			return true
+		}
+	}
+	return false
+}
+
+func (dt *DominatorTree) intersect(a, b int) int {
+	for a != b {
+		for a > b {
+			a = dt.idom[a]
+		}
+		for b > a {
+			b = dt.idom[b]
+		}
+	}
+	return a
+}
+
+// Root returns the function's entry block.
+func (dt *DominatorTree) Root() BasicBlock { return dt.blocks[0] }
+
+// Reachable reports whether bb is reachable from the entry block.
+func (dt *DominatorTree) Reachable(bb BasicBlock) bool {
+	_, ok := dt.order[bb]
+	return ok
+}
+
+// Blocks returns the reachable blocks in reverse postorder, so that each
+// block precedes the blocks it dominates.
+func (dt *DominatorTree) Blocks() []BasicBlock {
+	return append([]BasicBlock(nil), dt.blocks...)
+}
+
+// Predecessors returns the reachable predecessors of bb, each once, however
+// many of its terminator's successors are bb.
+func (dt *DominatorTree) Predecessors(bb BasicBlock) []BasicBlock {
+	var preds []BasicBlock
+	for _, pred := range dt.preds[bb] {
+		if dt.Reachable(pred) {
+			preds = append(preds, pred)
+		}
+	}
+	return preds
+}
+
+// IDom returns the immediate dominator of bb, or a nil block if bb is the
+// entry block or unreachable.
+func (dt *DominatorTree) IDom(bb BasicBlock) BasicBlock {
+	b, ok := dt.order[bb]
+	if !ok || b == 0 {
+		return BasicBlock{}
+	}
+	return dt.blocks[dt.idom[b]]
+}
+
+// Children returns the blocks immediately dominated by bb.
+func (dt *DominatorTree) Children(bb BasicBlock) []BasicBlock {
+	b, ok := dt.order[bb]
+	if !ok {
+		return nil
+	}
+	return append([]BasicBlock(nil), dt.children[b]...)
+}
+
+// Dominates reports whether every path from the entry block to b passes
+// through a. A block dominates itself. Unreachable blocks are dominated by
+// every block, and dominate none but themselves.
+func (dt *DominatorTree) Dominates(a, b BasicBlock) bool {
+	ib, ok := dt.order[b]
+	if !ok || a == b {
+		return true
+	}
+	ia, ok := dt.order[a]
+	if !ok {
+		return false
+	}
+	return dt.in[ia] <= dt.in[ib] && dt.out[ib] <= dt.out[ia]
+}
+
+// InstrDominates reports whether the instruction a dominates the
+// instruction b, i.e. a's block dominates b's, and if they are in the same
+// block, a precedes b.
+func (dt *DominatorTree) InstrDominates(a, b Value) bool {
+	ba, bb := a.InstructionParent(), b.InstructionParent()
+	if ba != bb {
+		return dt.Dominates(ba, bb)
+	}
+	for i := a; !i.IsNil(); i = NextInstruction(i) {
+		if i == b {
+			return true
+		}
+	}
+	return false
+}
+
+// Loop is a natural loop: a header block which dominates the loop's other
+// blocks, and the blocks from which the header is reached by back edges.
+type Loop struct {
+	Header   BasicBlock
+	Latches  []BasicBlock // sources of the back edges to Header
+	Blocks   []BasicBlock // the loop's blocks, Header first
+	Parent   *Loop        // the innermost enclosing loop, if any
+	Children []*Loop      // loops nested directly within the loop
+	Depth    int          // 1 for outermost loops
+
+	blocks map[BasicBlock]bool
+}
+
+// Contains reports whether bb is in the loop, including its nested loops.
+func (l *Loop) Contains(bb BasicBlock) bool { return l.blocks[bb] }
+
+// LoopInfo describes the natural loops of a function.
+type LoopInfo struct {
+	dt        *DominatorTree
+	loops     []*Loop // outermost loops
+	innermost map[BasicBlock]*Loop
+}
+
+// NewLoopInfo finds the natural loops of the function whose dominator tree
+// is dt.
+func NewLoopInfo(dt *DominatorTree) *LoopInfo {
+	li := &LoopInfo{dt: dt, innermost: make(map[BasicBlock]*Loop)}
+
+	// Find the back edges to each header, and the blocks from which the
+	// latches are reached without passing through the header.
+	var all []*Loop
+	for _, header := range dt.blocks {
+		var latches []BasicBlock
+		for _, pred := range dt.Predecessors(header) {
+			if dt.Dominates(header, pred) {
+				latches = append(latches, pred)
+			}
+		}
+		if len(latches) == 0 {
+			continue
+		}
+		l := &Loop{
+			Header:  header,
+			Latches: latches,
+			Blocks:  []BasicBlock{header},
+			blocks:  map[BasicBlock]bool{header: true},
+		}
+		work := append([]BasicBlock(nil), latches...)
+		for len(work) > 0 {
+			bb := work[len(work)-1]
+			work = work[:len(work)-1]
+			if l.blocks[bb] {
+				continue
+			}
+			l.blocks[bb] = true
+			l.Blocks = append(l.Blocks, bb)
+			work = append(work, dt.Predecessors(bb)...)
+		}
+		all = append(all, l)
+	}
+
+	// Natural loops with distinct headers are disjoint or nested, so the
+	// parent of a loop is the smallest other loop containing its header.
+	sort.Sort(loopsBySize(all))
+	for i, l := range all {
+		for _, outer := range all[i+1:] {
+			if outer.blocks[l.Header] {
+				l.Parent = outer
+				outer.Children = append(outer.Children, l)
+				break
+			}
+		}
+		for _, bb := range l.Blocks {
+			if li.innermost[bb] == nil {
+				li.innermost[bb] = l
+			}
+		}
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		l := all[i]
+		if l.Parent == nil {
+			l.Depth = 1
+			li.loops = append(li.loops, l)
+		} else {
+			l.Depth = l.Parent.Depth + 1
+		}
+	}
+	return li
+}
+
+type loopsBySize []*Loop
+
+func (s loopsBySize) Len() int           { return len(s) }
+func (s loopsBySize) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s loopsBySize) Less(i, j int) bool { return len(s[i].Blocks) < len(s[j].Blocks) }
+
+// Loops returns the outermost loops of the function.
+func (li *LoopInfo) Loops() []*Loop {
+	return append([]*Loop(nil), li.loops...)
+}
+
+// LoopFor returns the innermost loop containing bb, or nil if bb is not in
+// a loop.
+func (li *LoopInfo) LoopFor(bb BasicBlock) *Loop { return li.innermost[bb] }
+
+// Depth returns the number of loops containing bb.
+func (li *LoopInfo) Depth(bb BasicBlock) int {
+	if l := li.innermost[bb]; l != nil {
+		return l.Depth
+	}
+	return 0
+}
+
+// IsBackEdge reports whether the edge from the block from to the block to
+// is a back edge, i.e. to is a loop header which dominates from.
+func (li *LoopInfo) IsBackEdge(from, to BasicBlock) bool {
+	return li.dt.Reachable(from) && li.dt.Dominates(to, from)
+}
@@ -0,0 +1,174 @@
+package llvm
+
+import "testing"
+
+// cfgEdge lists the successors of a block of a test function.
+type cfgEdge struct {
+	block string
+	succs []string
+}
+
+// buildCFG defines a function whose blocks, in order, have the given
+// successors: none returns, one branches, two branch on the function's
+// parameter, and more switch on it. The first block is the entry block.
+func buildCFG(m Module, edges []cfgEdge) (Value, map[string]BasicBlock) {
+	i8 := Int8Type()
+	f := AddFunction(m, "f", FunctionType(VoidType(), []Type{i8}, false))
+	blocks := make(map[string]BasicBlock)
+	for _, e := range edges {
+		blocks[e.block] = AddBasicBlock(f, e.block)
+	}
+	b := NewBuilder()
+	defer b.Dispose()
+	for _, e := range edges {
+		b.SetInsertPointAtEnd(blocks[e.block])
+		switch len(e.succs) {
+		case 0:
+			b.CreateRetVoid()
+		case 1:
+			b.CreateBr(blocks[e.succs[0]])
+		case 2:
+			cond := b.CreateICmp(IntEQ, f.Param(0), ConstInt(i8, 0, false), "")
+			b.CreateCondBr(cond, blocks[e.succs[0]], blocks[e.succs[1]])
+		default:
+			sw := b.CreateSwitch(f.Param(0), blocks[e.succs[0]], len(e.succs)-1)
+			for i, succ := range e.succs[1:] {
+				sw.AddCase(ConstInt(i8, uint64(i), false), blocks[succ])
+			}
+		}
+	}
+	return f, blocks
+}
+
+func TestDominatorTree(t *testing.T) {
+	m := NewModule("dominators")
+	defer m.Dispose()
+	f, bb := buildCFG(m, []cfgEdge{
+		{"entry", []string{"a"}},
+		{"a", []string{"b", "c"}},
+		{"b", []string{"d"}},
+		{"c", []string{"d"}},
+		{"d", []string{"a", "exit"}},
+		{"exit", nil},
+		{"dead", []string{"a"}},
+	})
+	dt := NewDominatorTree(f)
+
+	if dt.Root() != bb["entry"] {
+		t.Errorf("root is not the entry block")
+	}
+	idoms := map[string]string{"a": "entry", "b": "a", "c": "a", "d": "a", "exit": "d"}
+	for block, idom := range idoms {
+		if got := dt.IDom(bb[block]); got != bb[idom] {
+			t.Errorf("IDom(%s) = %s, want %s", block, got.AsValue().Name(), idom)
+		}
+	}
+	if !dt.IDom(bb["entry"]).IsNil() {
+		t.Errorf("entry block has an immediate dominator")
+	}
+	if dt.Reachable(bb["dead"]) {
+		t.Errorf("unreachable block is reachable")
+	}
+	if n := len(dt.Blocks()); n != 6 {
+		t.Errorf("%d reachable blocks, want 6", n)
+	}
+	if n := len(dt.Children(bb["a"])); n != 3 {
+		t.Errorf("a has %d children, want 3", n)
+	}
+	if n := len(dt.Predecessors(bb["a"])); n != 2 {
+		t.Errorf("a has %d reachable predecessors, want 2", n)
+	}
+
+	dominates := []struct {
+		a, b string
+		want bool
+	}{
+		{"entry", "exit", true},
+		{"a", "d", true},
+		{"d", "exit", true},
+		{"b", "d", false},
+		{"c", "d", false},
+		{"d", "a", false},
+		{"b", "b", true},
+		{"exit", "dead", true},
+		{"dead", "a", false},
+	}
+	for _, test := range dominates {
+		if got := dt.Dominates(bb[test.a], bb[test.b]); got != test.want {
+			t.Errorf("Dominates(%s, %s) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestDominatorTreeSwitchPredecessors(t *testing.T) {
+	m := NewModule("dominators")
+	defer m.Dispose()
+	f, bb := buildCFG(m, []cfgEdge{
+		{"entry", []string{"x", "x", "x"}},
+		{"x", nil},
+	})
+	dt := NewDominatorTree(f)
+	if n := len(dt.Predecessors(bb["x"])); n != 1 {
+		t.Errorf("x has %d predecessors, want 1", n)
+	}
+	if dt.IDom(bb["x"]) != bb["entry"] {
+		t.Errorf("entry does not immediately dominate x")
+	}
+}
+
+func TestLoopInfo(t *testing.T) {
+	m := NewModule("loops")
+	defer m.Dispose()
+	f, bb := buildCFG(m, []cfgEdge{
+		{"entry", []string{"outer"}},
+		{"outer", []string{"inner", "exit"}},
+		{"inner", []string{"inner", "latch"}},
+		{"latch", []string{"outer"}},
+		{"exit", nil},
+	})
+	li := NewLoopInfo(NewDominatorTree(f))
+
+	loops := li.Loops()
+	if len(loops) != 1 {
+		t.Fatalf("%d outermost loops, want 1", len(loops))
+	}
+	outer := loops[0]
+	if outer.Header != bb["outer"] || outer.Depth != 1 || outer.Parent != nil {
+		t.Errorf("outer loop has header %s, depth %d", outer.Header.AsValue().Name(), outer.Depth)
+	}
+	if len(outer.Latches) != 1 || outer.Latches[0] != bb["latch"] {
+		t.Errorf("outer loop has latches %v, want latch", outer.Latches)
+	}
+	if len(outer.Blocks) != 3 || outer.Blocks[0] != bb["outer"] {
+		t.Errorf("outer loop has %d blocks, want 3 beginning with its header", len(outer.Blocks))
+	}
+	if len(outer.Children) != 1 {
+		t.Fatalf("outer loop has %d children, want 1", len(outer.Children))
+	}
+	inner := outer.Children[0]
+	if inner.Header != bb["inner"] || inner.Depth != 2 || inner.Parent != outer {
+		t.Errorf("inner loop has header %s, depth %d", inner.Header.AsValue().Name(), inner.Depth)
+	}
+	if len(inner.Latches) != 1 || inner.Latches[0] != bb["inner"] {
+		t.Errorf("inner loop is not its own latch")
+	}
+
+	depths := map[string]int{"entry": 0, "outer": 1, "inner": 2, "latch": 1, "exit": 0}
+	for block, depth := range depths {
+		if got := li.Depth(bb[block]); got != depth {
+			t.Errorf("Depth(%s) = %d, want %d", block, got, depth)
+		}
+	}
+	if li.LoopFor(bb["inner"]) != inner || li.LoopFor(bb["latch"]) != outer || li.LoopFor(bb["exit"]) != nil {
+		t.Errorf("LoopFor does not return the innermost loops")
+	}
+	if !outer.Contains(bb["inner"]) || outer.Contains(bb["exit"]) {
+		t.Errorf("outer loop contains the wrong blocks")
+	}
+	if !li.IsBackEdge(bb["latch"], bb["outer"]) || !li.IsBackEdge(bb["inner"], bb["inner"]) {
+		t.Errorf("back edges not found")
+	}
+	if li.IsBackEdge(bb["outer"], bb["inner"]) {
+		t.Errorf("forward edge reported as a back edge")
+	}
+}
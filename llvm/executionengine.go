@@ -3,10 +3,14 @@ package llvm
 /*
 #include <llvm-c/ExecutionEngine.h>
 #include <stdlib.h>
+#include <string.h>
 */
 import "C"
 import "unsafe"
 import "errors"
+import "fmt"
+import "reflect"
+import "sync"
 
 func LinkInJIT()         { C.LLVMLinkInJIT() }
 func LinkInInterpreter() { C.LLVMLinkInInterpreter() }
@@ -51,13 +55,106 @@ func (g GenericValue) Float(t Type) float64 {
 func (g GenericValue) Pointer() unsafe.Pointer {
 	return C.LLVMGenericValueToPointer(g.C)
 }
-func (g GenericValue) Dispose() { C.LLVMDisposeGenericValue(g.C) }
+
+// Dispose destroys the GenericValue, and frees the copy of the []byte it
+// was created from, if any.
+func (g GenericValue) Dispose() {
+	genericValueMemory.Lock()
+	p, ok := genericValueMemory.m[g.C]
+	delete(genericValueMemory.m, g.C)
+	genericValueMemory.Unlock()
+	if ok {
+		C.free(p)
+	}
+	C.LLVMDisposeGenericValue(g.C)
+}
+
+// genericValueMemory records the C copies of the []byte values from which
+// GenericValues were created with NewGenericValue, to be freed by Dispose.
+var genericValueMemory struct {
+	sync.Mutex
+	m map[C.LLVMGenericValueRef]unsafe.Pointer
+}
+
+// NewGenericValue creates a GenericValue of the LLVM type t from the Go value
+// x. Integers, bools, floats, unsafe.Pointer and []byte are supported. Go
+// memory may not be passed to C, so a []byte is passed as a pointer to a
+// copy of its contents, which lives until the GenericValue is disposed and
+// may be read through Pointer. Functions made by MakeFunc copy it back into
+// the slice after each call.
+func NewGenericValue(t Type, x interface{}) (g GenericValue, err error) {
+	v := reflect.ValueOf(x)
+	switch v.Kind() {
+	case reflect.Bool:
+		var n uint64
+		if v.Bool() {
+			n = 1
+		}
+		g = NewGenericValueFromInt(t, n, false)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		g = NewGenericValueFromInt(t, uint64(v.Int()), true)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		g = NewGenericValueFromInt(t, v.Uint(), false)
+	case reflect.Float32, reflect.Float64:
+		g = NewGenericValueFromFloat(t, v.Float())
+	case reflect.UnsafePointer:
+		g = NewGenericValueFromPointer(unsafe.Pointer(v.Pointer()))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			err = fmt.Errorf("unsupported slice type: %s", v.Type())
+			break
+		}
+		var p unsafe.Pointer
+		if n := v.Len(); n > 0 {
+			p = C.malloc(C.size_t(n))
+			C.memcpy(p, unsafe.Pointer(&v.Bytes()[0]), C.size_t(n))
+		}
+		g = NewGenericValueFromPointer(p)
+		if p != nil {
+			genericValueMemory.Lock()
+			if genericValueMemory.m == nil {
+				genericValueMemory.m = make(map[C.LLVMGenericValueRef]unsafe.Pointer)
+			}
+			genericValueMemory.m[g.C] = p
+			genericValueMemory.Unlock()
+		}
+	default:
+		err = fmt.Errorf("unsupported type: %s", v.Type())
+	}
+	return
+}
+
+// GoValue converts the GenericValue, which holds a value of the LLVM type t,
+// to a Go value of the type rt.
+func (g GenericValue) GoValue(t Type, rt reflect.Type) (v reflect.Value, err error) {
+	v = reflect.New(rt).Elem()
+	switch rt.Kind() {
+	case reflect.Bool:
+		v.SetBool(g.Int(false) != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(g.Int(true)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		v.SetUint(g.Int(false))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(g.Float(t))
+	case reflect.UnsafePointer:
+		v.SetPointer(g.Pointer())
+	default:
+		err = fmt.Errorf("unsupported type: %s", rt)
+	}
+	return
+}
 
 //-------------------------------------------------------------------------
 // llvm.ExecutionEngine
 //-------------------------------------------------------------------------
 
 func NewExecutionEngine(m Module) (ee ExecutionEngine, err error) {
+	if err = m.CheckOwnership(); err != nil {
+		return
+	}
 	var cmsg *C.char
 	fail := C.LLVMCreateExecutionEngineForModule(&ee.C, m.C, &cmsg)
 	if fail != 0 {
@@ -65,12 +162,15 @@ func NewExecutionEngine(m Module) (ee ExecutionEngine, err error) {
 		err = errors.New(C.GoString(cmsg))
 		C.LLVMDisposeMessage(cmsg)
 	} else {
-		err = nil
+		err = m.TakenBy(ee)
 	}
 	return
 }
 
 func NewInterpreter(m Module) (ee ExecutionEngine, err error) {
+	if err = m.CheckOwnership(); err != nil {
+		return
+	}
 	var cmsg *C.char
 	fail := C.LLVMCreateInterpreterForModule(&ee.C, m.C, &cmsg)
 	if fail != 0 {
@@ -78,11 +178,14 @@ func NewInterpreter(m Module) (ee ExecutionEngine, err error) {
 		err = errors.New(C.GoString(cmsg))
 		C.LLVMDisposeMessage(cmsg)
 	} else {
-		err = nil
+		err = m.TakenBy(ee)
 	}
 	return
 }
 func NewJITCompiler(m Module, optLevel int) (ee ExecutionEngine, err error) {
+	if err = m.CheckOwnership(); err != nil {
+		return
+	}
 	var cmsg *C.char
 	fail := C.LLVMCreateJITCompilerForModule(&ee.C, m.C, C.unsigned(optLevel), &cmsg)
 	if fail != 0 {
@@ -90,7 +193,23 @@ func NewJITCompiler(m Module, optLevel int) (ee ExecutionEngine, err error) {
 		err = errors.New(C.GoString(cmsg))
 		C.LLVMDisposeMessage(cmsg)
 	} else {
-		err = nil
+		err = m.TakenBy(ee)
+	}
+	return
+}
+
+// NewJITCompilerOrInterpreter creates a JIT compiler for the module, falling
+// back to an interpreter if the JIT cannot be created (e.g. the host target
+// has no JIT support, or the process may not map executable memory).
+// LinkInInterpreter must have been called for the fallback to succeed.
+func NewJITCompilerOrInterpreter(m Module, optLevel int) (ee ExecutionEngine, err error) {
+	ee, err = NewJITCompiler(m, optLevel)
+	if err != nil {
+		var ierr error
+		ee, ierr = NewInterpreter(m)
+		if ierr == nil {
+			err = nil
+		}
 	}
 	return
 }
@@ -112,7 +231,12 @@ func NewJITCompiler(m Module, optLevel int) (ee ExecutionEngine, err error) {
 //                               unsigned OptLevel,
 //                               char **OutError);
 
-func (ee ExecutionEngine) Dispose()               { C.LLVMDisposeExecutionEngine(ee.C) }
+// Dispose destroys the execution engine, along with all modules it owns.
+func (ee ExecutionEngine) Dispose() {
+	C.LLVMDisposeExecutionEngine(ee.C)
+	disposeOwnedModules(ee)
+}
+
 func (ee ExecutionEngine) RunStaticConstructors() { C.LLVMRunStaticConstructors(ee.C) }
 func (ee ExecutionEngine) RunStaticDestructors()  { C.LLVMRunStaticDestructors(ee.C) }
 
@@ -132,18 +256,128 @@ func (ee ExecutionEngine) RunFunction(f Value, args []GenericValue) (g GenericVa
 	return
 }
 
+// genericKindError returns an error if values of the Go type rt cannot be
+// converted to or, if result is true, from GenericValues of the LLVM type t.
+func genericKindError(rt reflect.Type, t Type, result bool) error {
+	kind := t.TypeKind()
+	ok := false
+	switch rt.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		ok = kind == IntegerTypeKind
+	case reflect.Float32, reflect.Float64:
+		ok = kind == FloatTypeKind || kind == DoubleTypeKind
+	case reflect.UnsafePointer:
+		ok = kind == PointerTypeKind
+	case reflect.Slice:
+		ok = !result && rt.Elem().Kind() == reflect.Uint8 && kind == PointerTypeKind
+	}
+	if !ok {
+		return fmt.Errorf("cannot convert %s to or from %s", rt, t.IRString())
+	}
+	return nil
+}
+
+// MakeFunc sets fptr, which must be a pointer to a variable of function type,
+// to a Go function that runs f through the execution engine. Arguments and
+// results are converted with NewGenericValue and GenericValue.GoValue, using
+// the parameter and return types of f; an error is returned if the Go and
+// LLVM types do not correspond, e.g. if a Go integer is given for an LLVM
+// pointer. []byte arguments are copied back from C after each call.
+func (ee ExecutionEngine) MakeFunc(f Value, fptr interface{}) error {
+	fn := reflect.ValueOf(fptr)
+	if fn.Kind() != reflect.Ptr || fn.Elem().Kind() != reflect.Func {
+		return fmt.Errorf("expected pointer to function, got %s", fn.Type())
+	}
+	fn = fn.Elem()
+	ft := f.Type().ElementType()
+	paramTypes := ft.ParamTypes()
+	if fn.Type().NumIn() != len(paramTypes) {
+		return fmt.Errorf("parameter count mismatch: %d != %d",
+			fn.Type().NumIn(), len(paramTypes))
+	}
+	returnType := ft.ReturnType()
+	nresults := 1
+	if returnType.TypeKind() == VoidTypeKind {
+		nresults = 0
+	}
+	if fn.Type().NumOut() != nresults {
+		return fmt.Errorf("result count mismatch: %d != %d",
+			fn.Type().NumOut(), nresults)
+	}
+	for i, t := range paramTypes {
+		if err := genericKindError(fn.Type().In(i), t, false); err != nil {
+			return fmt.Errorf("parameter %d: %v", i, err)
+		}
+	}
+	if nresults != 0 {
+		if err := genericKindError(fn.Type().Out(0), returnType, true); err != nil {
+			return fmt.Errorf("result: %v", err)
+		}
+	}
+	impl := func(in []reflect.Value) []reflect.Value {
+		args := make([]GenericValue, len(in))
+		for i, arg := range in {
+			g, err := NewGenericValue(paramTypes[i], arg.Interface())
+			if err != nil {
+				panic(err)
+			}
+			args[i] = g
+			defer g.Dispose()
+		}
+		result := ee.RunFunction(f, args)
+		defer result.Dispose()
+		for i, arg := range in {
+			if arg.Kind() == reflect.Slice && arg.Len() > 0 {
+				copy(arg.Bytes(), C.GoBytes(args[i].Pointer(), C.int(arg.Len())))
+			}
+		}
+		if nresults == 0 {
+			return nil
+		}
+		v, err := result.GoValue(returnType, fn.Type().Out(0))
+		if err != nil {
+			panic(err)
+		}
+		return []reflect.Value{v}
+	}
+	fn.Set(reflect.MakeFunc(fn.Type(), impl))
+	return nil
+}
+
 func (ee ExecutionEngine) FreeMachineCodeForFunction(f Value) {
 	C.LLVMFreeMachineCodeForFunction(ee.C, f.C)
 }
-func (ee ExecutionEngine) AddModule(m Module) { C.LLVMAddModule(ee.C, m.C) }
+
+// AddModule adds a module to the execution engine, which takes ownership of
+// it.
+func (ee ExecutionEngine) AddModule(m Module) {
+	m.setOwner(ee)
+	C.LLVMAddModule(ee.C, m.C)
+}
+
+// TryAddModule adds a module to the execution engine, as AddModule does,
+// unless the module is already owned by another object or has been
+// disposed, in which case an error is returned.
+func (ee ExecutionEngine) TryAddModule(m Module) error {
+	if err := m.TakenBy(ee); err != nil {
+		return err
+	}
+	C.LLVMAddModule(ee.C, m.C)
+	return nil
+}
 
 // XXX(nsf): Don't port deprecated
 // Deprecated: Use LLVMAddModule instead.
 //void LLVMAddModuleProvider(LLVMExecutionEngineRef EE, LLVMModuleProviderRef MP);
 
+// RemoveModule removes a module from the execution engine, returning
+// ownership of it to the caller.
 func (ee ExecutionEngine) RemoveModule(m Module) {
 	var modtmp C.LLVMModuleRef
 	C.LLVMRemoveModule(ee.C, m.C, &modtmp, nil)
+	m.release(ee)
 }
 
 // XXX(nsf): Don't port deprecated
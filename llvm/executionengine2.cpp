@@ -0,0 +1,56 @@
+#include <llvm/DerivedTypes.h>
+#include <llvm/ADT/APInt.h>
+#include <llvm/ADT/ArrayRef.h>
+#include <llvm/ExecutionEngine/ExecutionEngine.h>
+#include <llvm/ExecutionEngine/GenericValue.h>
+#include <llvm/Target/TargetOptions.h>
+#include <string.h>
+#include <string>
+
+extern "C" llvm::GenericValue *createGenericValueOfWords(llvm::Type *t,
+                                                         const uint64_t *words,
+                                                         unsigned n) {
+	llvm::GenericValue *gv = new llvm::GenericValue;
+	unsigned width = llvm::cast<llvm::IntegerType>(t)->getBitWidth();
+	gv->IntVal = llvm::APInt(width, llvm::ArrayRef<uint64_t>(words, n));
+	return gv;
+}
+
+extern "C" const uint64_t *getGenericValueWords(llvm::GenericValue *gv,
+                                                unsigned *n) {
+	*n = gv->IntVal.getNumWords();
+	return gv->IntVal.getRawData();
+}
+
+extern "C" llvm::ExecutionEngine *createJITWithExceptions(llvm::Module *m,
+                                                         unsigned optLevel,
+                                                         char **errmsg) {
+	std::string err;
+	llvm::TargetOptions options;
+	options.JITExceptionHandling = true;
+	llvm::ExecutionEngine *ee = llvm::EngineBuilder(m)
+		.setEngineKind(llvm::EngineKind::JIT)
+		.setOptLevel((llvm::CodeGenOpt::Level)optLevel)
+		.setTargetOptions(options)
+		.setErrorStr(&err)
+		.create();
+	if (!ee)
+		*errmsg = strdup(err.c_str());
+	return ee;
+}
+
+extern "C" void installExceptionTableRegister(llvm::ExecutionEngine *ee,
+                                              void (*reg)(void *),
+                                              void (*dereg)(void *)) {
+	ee->InstallExceptionTableRegister(reg);
+	ee->InstallExceptionTableDeregister(dereg);
+}
+
+extern "C" void deregisterAllExceptionTables(llvm::ExecutionEngine *ee) {
+	ee->DeregisterAllTables();
+}
+
+extern "C" void clearGlobalMappingsFromModule(llvm::ExecutionEngine *ee,
+                                              llvm::Module *m) {
+	ee->clearGlobalMappingsFromModule(m);
+}
@@ -0,0 +1,93 @@
+package llvm
+
+/*
+#include <llvm-c/ExecutionEngine.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+extern LLVMGenericValueRef createGenericValueOfWords(LLVMTypeRef,
+                                                     const uint64_t*,
+                                                     unsigned);
+extern const uint64_t *getGenericValueWords(LLVMGenericValueRef, unsigned*);
+extern LLVMExecutionEngineRef createJITWithExceptions(LLVMModuleRef, unsigned,
+                                                      char **);
+extern void installExceptionTableRegister(LLVMExecutionEngineRef,
+                                          void (*)(void *), void (*)(void *));
+extern void deregisterAllExceptionTables(LLVMExecutionEngineRef);
+extern void clearGlobalMappingsFromModule(LLVMExecutionEngineRef,
+                                          LLVMModuleRef);
+*/
+import "C"
+import "errors"
+import "math/big"
+import "unsafe"
+
+// NewGenericValueFromBig creates a GenericValue of the integer type t, which
+// may be wider than 64 bits, with the value x truncated to the width of t.
+func NewGenericValueFromBig(t Type, x *big.Int) (g GenericValue) {
+	words := bigToWords(x, t.IntTypeWidth())
+	g.C = C.createGenericValueOfWords(t.C, (*C.uint64_t)(unsafe.Pointer(&words[0])), C.unsigned(len(words)))
+	return
+}
+
+// BigInt returns the integer value of g, of any width, interpreting it as a
+// two's complement signed integer if signed is true.
+func (g GenericValue) BigInt(signed bool) *big.Int {
+	var n C.unsigned
+	cwords := C.getGenericValueWords(g.C, &n)
+	words := (*[1 << 24]uint64)(unsafe.Pointer(cwords))[:n:n]
+	return wordsToBig(words, g.IntWidth(), signed)
+}
+
+// NewJITCompilerWithExceptions creates a JIT compiler for the module, like
+// NewJITCompiler, which also emits exception handling tables for the
+// functions it compiles, so that exceptions thrown by resume, or by the
+// unwinder, propagate through JIT-compiled frames to their landing pads.
+//
+// Where the host has __register_frame, the tables are registered with it
+// as functions are compiled, and deregistered when their machine code is
+// freed or the execution engine is disposed. Other unwinders may be
+// supported with SetExceptionTableRegistration.
+func NewJITCompilerWithExceptions(m Module, optLevel int) (ee ExecutionEngine, err error) {
+	if err = m.CheckOwnership(); err != nil {
+		return
+	}
+	var cmsg *C.char
+	ee.C = C.createJITWithExceptions(m.C, C.unsigned(optLevel), &cmsg)
+	if ee.C == nil {
+		err = errors.New(C.GoString(cmsg))
+		C.free(unsafe.Pointer(cmsg))
+	} else if err = m.TakenBy(ee); err != nil {
+		// The module was taken by another object since checkOwner, so
+		// it is detached before the engine is disposed.
+		ee.RemoveModule(m)
+		ee.Dispose()
+		ee = ExecutionEngine{}
+	}
+	return
+}
+
+// SetExceptionTableRegistration sets the C functions, of type
+// void (*)(void *), called with the address of each exception handling
+// table the JIT compiler emits and frees, e.g. __register_frame and
+// __deregister_frame.
+// See ExecutionEngine::InstallExceptionTableRegister.
+func (ee ExecutionEngine) SetExceptionTableRegistration(register, deregister unsafe.Pointer) {
+	C.installExceptionTableRegister(ee.C, (*[0]byte)(register), (*[0]byte)(deregister))
+}
+
+// DeregisterExceptionTables deregisters all exception handling tables the
+// execution engine has registered, e.g. before replacing the registration
+// functions.
+// See ExecutionEngine::DeregisterAllTables.
+func (ee ExecutionEngine) DeregisterExceptionTables() {
+	C.deregisterAllExceptionTables(ee.C)
+}
+
+// ClearGlobalMappingsFromModule forgets the addresses of all globals in the
+// module m, whether compiled or mapped with AddGlobalMapping, so that stale
+// addresses are not returned for globals later allocated at the same place.
+// See ExecutionEngine::clearGlobalMappingsFromModule.
+func (ee ExecutionEngine) ClearGlobalMappingsFromModule(m Module) {
+	C.clearGlobalMappingsFromModule(ee.C, m.C)
+}
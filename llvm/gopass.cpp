@@ -0,0 +1,72 @@
+#include <llvm/Function.h>
+#include <llvm/Module.h>
+#include <llvm/Pass.h>
+#include <llvm/PassManager.h>
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+
+// Exported by gopass.go.
+extern "C" int goRunFunctionPass(uintptr_t, llvm::Function *);
+extern "C" int goRunModulePass(uintptr_t, llvm::Module *);
+extern "C" void goReleasePass(uintptr_t);
+
+namespace {
+
+// goFunctionPass and goModulePass call the Go function registered with the
+// handle, which is released when the pass manager deletes the pass.
+struct goFunctionPass : public llvm::FunctionPass {
+	static char ID;
+	uintptr_t handle;
+	char *name;
+
+	goFunctionPass(uintptr_t handle, const char *name)
+		: llvm::FunctionPass(ID), handle(handle), name(strdup(name)) {}
+
+	~goFunctionPass() {
+		goReleasePass(handle);
+		free(name);
+	}
+
+	virtual const char *getPassName() const { return name; }
+
+	virtual bool runOnFunction(llvm::Function &F) {
+		return goRunFunctionPass(handle, &F) != 0;
+	}
+};
+
+char goFunctionPass::ID = 0;
+
+struct goModulePass : public llvm::ModulePass {
+	static char ID;
+	uintptr_t handle;
+	char *name;
+
+	goModulePass(uintptr_t handle, const char *name)
+		: llvm::ModulePass(ID), handle(handle), name(strdup(name)) {}
+
+	~goModulePass() {
+		goReleasePass(handle);
+		free(name);
+	}
+
+	virtual const char *getPassName() const { return name; }
+
+	virtual bool runOnModule(llvm::Module &M) {
+		return goRunModulePass(handle, &M) != 0;
+	}
+};
+
+char goModulePass::ID = 0;
+
+} // namespace
+
+extern "C" void addGoFunctionPass(llvm::PassManagerBase *pm, uintptr_t handle,
+                                  const char *name) {
+	pm->add(new goFunctionPass(handle, name));
+}
+
+extern "C" void addGoModulePass(llvm::PassManagerBase *pm, uintptr_t handle,
+                                const char *name) {
+	pm->add(new goModulePass(handle, name));
+}
@@ -0,0 +1,85 @@
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+extern void addGoFunctionPass(LLVMPassManagerRef, uintptr_t, const char *);
+extern void addGoModulePass(LLVMPassManagerRef, uintptr_t, const char *);
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// goPasses records the functions run by the passes added with
+// AddGoFunctionPass and AddGoModulePass, by the handle given to the pass.
+var goPasses struct {
+	sync.Mutex
+	next uintptr
+	m    map[uintptr]interface{}
+}
+
+func registerGoPass(run interface{}) uintptr {
+	goPasses.Lock()
+	defer goPasses.Unlock()
+	if goPasses.m == nil {
+		goPasses.m = make(map[uintptr]interface{})
+	}
+	goPasses.next++
+	goPasses.m[goPasses.next] = run
+	return goPasses.next
+}
+
+func goPass(handle C.uintptr_t) interface{} {
+	goPasses.Lock()
+	defer goPasses.Unlock()
+	return goPasses.m[uintptr(handle)]
+}
+
+// AddGoFunctionPass adds a function pass, named name, which calls run with
+// each function defined in the module, in the pipeline's order, so that
+// analyses and transformations written in Go can be interleaved with
+// LLVM's passes. run reports whether it changed the function, and, like
+// any function pass, must not change other functions or globals. As
+// LLVM's cleanups do not run if run panics, the pass manager must not be
+// used after a panic.
+func (pm PassManager) AddGoFunctionPass(name string, run func(f Value) bool) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	C.addGoFunctionPass(pm.C, C.uintptr_t(registerGoPass(run)), cname)
+}
+
+// AddGoModulePass adds a module pass, named name, which calls run with the
+// module. run reports whether it changed the module. See AddGoFunctionPass.
+func (pm PassManager) AddGoModulePass(name string, run func(m Module) bool) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	C.addGoModulePass(pm.C, C.uintptr_t(registerGoPass(run)), cname)
+}
+
+//export goRunFunctionPass
+func goRunFunctionPass(handle C.uintptr_t, f C.LLVMValueRef) C.int {
+	if goPass(handle).(func(Value) bool)(Value{f}) {
+		return 1
+	}
+	return 0
+}
+
+//export goRunModulePass
+func goRunModulePass(handle C.uintptr_t, m C.LLVMModuleRef) C.int {
+	if goPass(handle).(func(Module) bool)(Module{m}) {
+		return 1
+	}
+	return 0
+}
+
+//export goReleasePass
+func goReleasePass(handle C.uintptr_t) {
+	goPasses.Lock()
+	delete(goPasses.m, uintptr(handle))
+	goPasses.Unlock()
+}
@@ -0,0 +1,17 @@
+package llvm
+
+import "github.com/axw/gollvm/mangle"
+
+// AddGoFunction declares the function sym, a Go function or method, in the
+// module m, with the function type ft, naming it with the symbol name given
+// by the mangle package, as the gc toolchain would.
+func AddGoFunction(m Module, sym mangle.Symbol, ft Type) Value {
+	return AddFunction(m, sym.Mangle(), ft)
+}
+
+// AddGoGlobal declares the global variable sym, a Go package-level
+// variable, in the module m, with the type t, naming it with the symbol
+// name given by the mangle package.
+func AddGoGlobal(m Module, sym mangle.Symbol, t Type) Value {
+	return AddGlobal(m, t, sym.Mangle())
+}
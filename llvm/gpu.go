@@ -0,0 +1,50 @@
+package llvm
+
+// nvvmAnnotate adds an entry for f to the module's nvvm.annotations named
+// metadata, which the NVPTX backend uses to identify kernels and their
+// properties.
+func nvvmAnnotate(f Value, key string, value int) {
+	ctx := f.Type().Context()
+	f.GlobalParent().AddNamedMetadataOperand("nvvm.annotations", ctx.MDNode([]Value{
+		f,
+		ctx.MDString(key),
+		ConstInt(ctx.Int32Type(), uint64(value), false),
+	}))
+}
+
+// MarkNVVMKernel marks the function f as an NVPTX kernel entry point, by
+// annotating it in the module's nvvm.annotations metadata.
+func MarkNVVMKernel(f Value) {
+	nvvmAnnotate(f, "kernel", 1)
+}
+
+// MarkPTXKernel marks the function f as a PTX kernel entry point, by setting
+// its calling convention. This is what the PTX backend expects; the NVPTX
+// backend accepts either this or MarkNVVMKernel.
+func MarkPTXKernel(f Value) {
+	f.SetFunctionCallConv(PTXKernelCallConv)
+}
+
+// SetNVVMLaunchBounds annotates the NVPTX kernel f with launch bounds: the
+// maximum number of threads per block in each dimension (x, y, z), and the
+// minimum number of blocks per multiprocessor. Zero values are omitted.
+func SetNVVMLaunchBounds(f Value, maxThreads [3]int, minBlocksPerSM int) {
+	for i, key := range []string{"maxntidx", "maxntidy", "maxntidz"} {
+		if maxThreads[i] > 0 {
+			nvvmAnnotate(f, key, maxThreads[i])
+		}
+	}
+	if minBlocksPerSM > 0 {
+		nvvmAnnotate(f, "minctasm", minBlocksPerSM)
+	}
+}
+
+// SetNVVMRequiredThreads annotates the NVPTX kernel f with the exact number
+// of threads per block it must be launched with, in each dimension (x, y, z).
+func SetNVVMRequiredThreads(f Value, threads [3]int) {
+	for i, key := range []string{"reqntidx", "reqntidy", "reqntidz"} {
+		if threads[i] > 0 {
+			nvvmAnnotate(f, key, threads[i])
+		}
+	}
+}
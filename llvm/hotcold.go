@@ -0,0 +1,55 @@
+package llvm
+
+import "strings"
+
+// LLVM 3.2 has neither the "hot" and "cold" function attributes, nor
+// section prefix metadata, nor a hot/cold splitting pass. A frontend can
+// still keep rarely executed code, such as panics and error formatting,
+// off hot text pages by generating it in separate functions and marking
+// them cold; on ELF targets, the system linker clusters the sections used
+// below. Mach-O section names are limited to 16 characters and COFF has no
+// equivalent convention, so there the functions are left in their default
+// sections and only their attributes are changed.
+
+// MarkCold marks the function f as rarely executed: it is optimized for
+// size and never inlined into its callers and, if its module's target is
+// ELF, placed in a .text.unlikely section.
+func MarkCold(f Value) {
+	if isELFTriple(f.GlobalParent().Target()) {
+		f.SetSection(".text.unlikely." + f.Name())
+	}
+	f.AddFunctionAttr(OptimizeForSizeAttribute | NoInlineAttribute)
+}
+
+// MarkHot marks the function f as frequently executed: inlining into its
+// callers is encouraged and, if its module's target is ELF, it is placed
+// in a .text.hot section.
+func MarkHot(f Value) {
+	if isELFTriple(f.GlobalParent().Target()) {
+		f.SetSection(".text.hot." + f.Name())
+	}
+	f.AddFunctionAttr(InlineHintAttribute)
+}
+
+// isELFTriple reports whether the target triple, or DefaultTargetTriple if
+// it is empty, produces ELF objects: that is, whether it is neither a
+// Darwin (Mach-O) nor a Windows (COFF) triple, unless its environment is
+// "elf", as in i686-pc-win32-elf.
+func isELFTriple(triple string) bool {
+	if triple == "" {
+		triple = DefaultTargetTriple
+	}
+	parts := strings.Split(triple, "-")
+	if len(parts) >= 4 && parts[3] == "elf" {
+		return true
+	}
+	if len(parts) < 3 {
+		return true
+	}
+	for _, prefix := range []string{"darwin", "macosx", "ios", "win32", "windows", "mingw", "cygwin"} {
+		if strings.HasPrefix(parts[2], prefix) {
+			return false
+		}
+	}
+	return true
+}
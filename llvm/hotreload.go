@@ -0,0 +1,141 @@
+package llvm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// HotFunction is a function in a JITSession whose body may be replaced
+// while the program runs. Callers in the session and in the host call a
+// stub, which loads the address of the current body from a slot and tail
+// calls it; replacing the body stores the new address in the slot
+// atomically, so each call runs either the old or the new body.
+type HotFunction struct {
+	mu         sync.Mutex // serializes Reload
+	s          *JITSession
+	name       string
+	ft         Type
+	slot       *unsafe.Pointer
+	generation int
+	impl       Module // the module defining the current body
+}
+
+// AddHotFunction adds the module m to the session, as AddModule does,
+// making the function name defined in m replaceable with Reload. The
+// function is renamed, and the name is defined instead by a stub in a
+// module of its own, so that calls to it, including those within m and
+// within modules passed to Reload, and the address returned by Lookup, use
+// the current body.
+func (s *JITSession) AddHotFunction(m Module, name string) (*HotFunction, error) {
+	f := m.NamedFunction(name)
+	if f.IsNil() || f.IsDeclaration() {
+		return nil, fmt.Errorf("module does not define function %s", name)
+	}
+	ft := f.Type().ElementType()
+	if ft.IsFunctionVarArg() {
+		return nil, errors.New("variadic functions cannot be reloaded")
+	}
+
+	var stub Module
+	s.Build(func(ctx Context) {
+		stub = ctx.NewModule(name + ".stub")
+		fptr := PointerType(ft, 0)
+		slot := AddGlobal(stub, fptr, name+".slot")
+		slot.SetInitializer(ConstNull(fptr))
+		align := s.TargetData().ABITypeAlignment(fptr)
+		slot.SetAlignment(align)
+		cc := f.FunctionCallConv()
+		sf := DefineThunk(stub, name, ft, func(b Builder, params []Value) (Value, []Value) {
+			// Reload publishes each body with an atomic store; the
+			// acquire load pairs with it, so that the stub never
+			// calls a body whose code it cannot yet see.
+			impl := b.CreateLoad(slot, "")
+			impl.SetInstrAlignment(align)
+			impl.SetOrdering(Acquire)
+			return impl, params
+		})
+		sf.SetFunctionCallConv(cc)
+		PrevInstruction(sf.EntryBasicBlock().LastInstruction()).SetInstructionCallConv(cc)
+	})
+	if err := s.AddModule(stub); err != nil {
+		stub.Dispose()
+		return nil, err
+	}
+
+	h := &HotFunction{
+		s:    s,
+		name: name,
+		ft:   ft,
+		slot: (*unsafe.Pointer)(s.Lookup(name + ".slot")),
+	}
+	if _, err := h.Reload(m); err != nil {
+		s.RemoveModule(stub)
+		return nil, err
+	}
+	return h, nil
+}
+
+// Reload adds the module m, which must define the function with the same
+// name and type as the hot function, to the session and makes it the
+// function's body, returning the module which defined the previous body,
+// if any. Calls in progress continue in the previous body, so its module
+// should only be removed from the session once they are known to have
+// returned.
+//
+// The previous body's module stays in the session, so m must not define
+// any other symbol that module defines, e.g. a helper function or global
+// variable of the same name; AddModule returns an error for such
+// duplicates. Such definitions should be given internal linkage or unique
+// names.
+func (h *HotFunction) Reload(m Module) (prev Module, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := m.NamedFunction(h.name)
+	if f.IsNil() || f.IsDeclaration() {
+		return Module{}, fmt.Errorf("module does not define function %s", h.name)
+	}
+	if f.Type().ElementType() != h.ft {
+		return Module{}, fmt.Errorf("function %s has type %v, not %v", h.name, f.Type().ElementType(), h.ft)
+	}
+	// Uses of the function within m, such as recursive calls, are
+	// redirected to a declaration of the name, which the session links to
+	// the stub, so that they too call the current body.
+	implName := fmt.Sprintf("%s.hot%d", h.name, h.generation+1)
+	linkage := f.Linkage()
+	var decl Value
+	h.s.Build(func(Context) {
+		f.SetName(implName)
+		f.SetLinkage(ExternalLinkage)
+		decl = AddFunction(m, h.name, h.ft)
+		decl.SetFunctionCallConv(f.FunctionCallConv())
+		decl.CopyAttributes(f)
+		f.ReplaceAllUsesWith(decl)
+	})
+	if err := h.s.AddModule(m); err != nil {
+		h.s.Build(func(Context) {
+			decl.ReplaceAllUsesWith(f)
+			decl.EraseFromParentAsFunction()
+			f.SetName(h.name)
+			f.SetLinkage(linkage)
+		})
+		return Module{}, err
+	}
+	h.generation++
+	atomic.StorePointer(h.slot, h.s.Lookup(implName))
+	prev, h.impl = h.impl, m
+	return prev, nil
+}
+
+// Module returns the module defining the function's current body.
+func (h *HotFunction) Module() Module {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.impl
+}
+
+// Address returns the address of the function's stub, which calls the
+// current body.
+func (h *HotFunction) Address() unsafe.Pointer { return h.s.Lookup(h.name) }
@@ -0,0 +1,214 @@
+package llvm
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// IncrementalBuild generates object code for a module function by
+// function, so that when functions change, only their object code need be
+// generated again, e.g. for an editor-integrated compiler to rebuild a
+// large module quickly after an edit. Each function defined in the module
+// is compiled into an object of its own, from a copy of the module in which
+// the other functions are declarations, and the module's global variables,
+// inline assembly and static constructors into another, the data object.
+// Symbols with local linkage are given hidden visibility and external
+// linkage, so that the objects can refer to each other, and are renamed
+// with a prefix unique to the module, so that they do not collide with
+// symbols of the same name in other objects and libraries.
+//
+// Changing a function's type, or a global's, makes the objects which refer
+// to it stale; these must be marked dirty as well. Global aliases, which
+// the bindings cannot enumerate, are not supported, and Rebuild returns an
+// error if the module has any.
+type IncrementalBuild struct {
+	m       Module
+	tm      TargetMachine
+	dir     string
+	prefix  string // of the names of symbols with local linkage
+	anon    int
+	dirty   map[string]bool
+	objects map[string]string
+}
+
+// NewIncrementalBuild returns an IncrementalBuild of the module m, which
+// generates object code with the target machine tm into files in the
+// directory dir. Everything is dirty to begin with.
+func NewIncrementalBuild(m Module, tm TargetMachine, dir string) *IncrementalBuild {
+	return &IncrementalBuild{
+		m:       m,
+		tm:      tm,
+		dir:     dir,
+		prefix:  localPrefix(m),
+		dirty:   map[string]bool{"": true},
+		objects: make(map[string]string),
+	}
+}
+
+// localPrefix returns the prefix given to the names of symbols with local
+// linkage in the objects of the module m, derived from the names of the
+// external symbols m defines, which no other module linked with it may
+// define, so that it is unique to the module and stable across builds.
+func localPrefix(m Module) string {
+	h := sha1.New()
+	add := func(v Value) {
+		if !v.IsDeclaration() && !isLocalLinkage(v.Linkage()) {
+			fmt.Fprintf(h, "%s\x00", v.Name())
+		}
+	}
+	for f := m.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		add(f)
+	}
+	for g := m.FirstGlobal(); !g.IsNil(); g = NextGlobal(g) {
+		add(g)
+	}
+	return fmt.Sprintf("gollvm.%x.", h.Sum(nil)[:8])
+}
+
+// MarkDirty records that the function name has changed, and its object
+// must be generated again, or, if name is empty, that global variables,
+// inline assembly or static constructors have. Functions which have been
+// added are dirty, and those which have been removed need not be marked.
+func (ib *IncrementalBuild) MarkDirty(name string) {
+	ib.dirty[name] = true
+}
+
+// Rebuild generates the objects of the dirty functions, and the data
+// object if it is dirty, returning the names of those generated, the data
+// object's being empty. If an error occurs, those not generated remain
+// dirty.
+//
+// Rebuild names the unnamed functions and global variables of the module
+// in place, since objects can only refer to each other's symbols by name;
+// the names, which begin with "gollvm.anon." or, if output is
+// deterministic, are derived from the globals' contents, remain after
+// Rebuild returns.
+func (ib *IncrementalBuild) Rebuild() ([]string, error) {
+	if ib.m.aliasCount() > 0 {
+		return nil, errors.New("incremental build of a module with global aliases is not supported")
+	}
+	// Unnamed globals cannot be referred to from other objects.
+	defined := make(map[string]bool)
+	for f := ib.m.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		ib.nameAnonymous(f)
+		if !f.IsDeclaration() {
+			defined[f.Name()] = true
+			if _, ok := ib.objects[f.Name()]; !ok {
+				ib.dirty[f.Name()] = true
+			}
+		}
+	}
+	for g := ib.m.FirstGlobal(); !g.IsNil(); g = NextGlobal(g) {
+		ib.nameAnonymous(g)
+	}
+	for name, obj := range ib.objects {
+		if name != "" && !defined[name] {
+			os.Remove(obj)
+			delete(ib.objects, name)
+		}
+	}
+
+	var names []string
+	for name := range ib.dirty {
+		if name == "" || defined[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var rebuilt []string
+	for _, name := range names {
+		obj := filepath.Join(ib.dir, fmt.Sprintf("%x.o", sha1.Sum([]byte(name))))
+		c := ib.m.Clone()
+		splitPartition(c, name, ib.prefix)
+		err := ib.tm.EmitToFile(c, obj, ObjectFile)
+		c.Dispose()
+		if err != nil {
+			return rebuilt, err
+		}
+		ib.objects[name] = obj
+		delete(ib.dirty, name)
+		rebuilt = append(rebuilt, name)
+	}
+	for name := range ib.dirty {
+		delete(ib.dirty, name)
+	}
+	return rebuilt, nil
+}
+
+// nameAnonymous gives the global g a name, if it has none: one derived
+// from its contents if output is deterministic (see SetDeterministic), or
+// else a number.
+func (ib *IncrementalBuild) nameAnonymous(g Value) {
+	if g.Name() == "" {
+		if Deterministic() {
+			g.SetName(contentName(g))
+			return
+		}
+		ib.anon++
+		g.SetName(fmt.Sprintf("gollvm.anon.%d", ib.anon))
+	}
+}
+
+// Objects returns the paths of the object files generated by Rebuild, the
+// data object first, then those of the functions in order of their names.
+func (ib *IncrementalBuild) Objects() []string {
+	var names []string
+	for name := range ib.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	objects := make([]string, len(names))
+	for i, name := range names {
+		objects[i] = ib.objects[name]
+	}
+	return objects
+}
+
+// Link links the objects generated by Rebuild as Link does.
+func (ib *IncrementalBuild) Link(opts LinkOptions) error {
+	return linkObjects(ib.Objects(), "", opts)
+}
+
+// splitPartition reduces the module c, a copy of the module being built, to
+// the definition of the function keep, or, if keep is empty, to the
+// definitions of the module's global variables, and declarations of
+// everything else. Symbols with local linkage are renamed with prefix.
+func splitPartition(c Module, keep, prefix string) {
+	if keep != "" {
+		c.SetInlineAsm("")
+	}
+	for f := c.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		local := isLocalLinkage(f.Linkage())
+		if !f.IsDeclaration() && f.Name() != keep {
+			f.DeleteBody()
+		}
+		if local {
+			f.SetName(prefix + f.Name())
+			f.SetLinkage(ExternalLinkage)
+			f.SetVisibility(HiddenVisibility)
+		}
+	}
+	for g := c.FirstGlobal(); !g.IsNil(); {
+		next := NextGlobal(g)
+		if keep != "" && g.Linkage() == AppendingLinkage {
+			// llvm.global_ctors and the like belong to the data object.
+			g.EraseFromParentAsGlobal()
+			g = next
+			continue
+		}
+		if isLocalLinkage(g.Linkage()) {
+			g.SetName(prefix + g.Name())
+			g.SetLinkage(ExternalLinkage)
+			g.SetVisibility(HiddenVisibility)
+		}
+		if keep != "" && !g.IsDeclaration() {
+			g.SetInitializer(Value{})
+			g.SetLinkage(ExternalLinkage)
+		}
+		g = next
+	}
+}
@@ -0,0 +1,130 @@
+package llvm
+
+// LLVM 3.2 has no instrument-function-entry and instrument-function-exit
+// attributes; clang implements -finstrument-functions in its frontend. The
+// functions below insert the same calls into the IR, which should be done
+// before optimization, so that the hooks see functions as written rather
+// than as inlined.
+
+// Names of the hooks called by code compiled with -finstrument-functions.
+const (
+	ProfileFuncEnter = "__cyg_profile_func_enter"
+	ProfileFuncExit  = "__cyg_profile_func_exit"
+)
+
+// noInstrumentName is the named metadata listing the functions excluded
+// from instrumentation with SetNoInstrument.
+const noInstrumentName = "gollvm.no_instrument_function"
+
+// SetNoInstrument excludes the function f from InstrumentFunctions, like
+// GCC's no_instrument_function attribute, e.g. for functions called by the
+// hooks, which would otherwise recurse. LLVM 3.2 has no string attributes,
+// so f is listed in named metadata of its module.
+func SetNoInstrument(f Value) {
+	m := f.GlobalParent()
+	c := m.Context()
+	m.AddNamedMetadataOperand(noInstrumentName, c.MDNode([]Value{f}))
+}
+
+// noInstrumentFunctions returns the set of functions of the module m
+// excluded with SetNoInstrument.
+func noInstrumentFunctions(m Module) map[Value]bool {
+	excluded := make(map[Value]bool)
+	for _, node := range m.NamedMetadataOperands(noInstrumentName) {
+		if ops := node.MDNodeOperands(); len(ops) == 1 && !ops[0].IsNil() {
+			excluded[ops[0]] = true
+		}
+	}
+	return excluded
+}
+
+// InstrumentFunction inserts into the function f, which must have a body,
+// a call to enter on entry and a call to exit before each return. Both
+// hooks are passed the address of f and the return address of f, the call
+// site, as i8*, i.e. they are called as void (i8*, i8*); hooks of another
+// type are bitcast to it. Exits by unwinding are not instrumented.
+func InstrumentFunction(f, enter, exit Value) {
+	ctx := f.Type().Context()
+	b := ctx.NewBuilder()
+	defer b.Dispose()
+	i8ptr := PointerType(ctx.Int8Type(), 0)
+	hookPtr := PointerType(FunctionType(ctx.VoidType(), []Type{i8ptr, i8ptr}, false), 0)
+	if enter.Type() != hookPtr {
+		enter = ConstBitCast(enter, hookPtr)
+	}
+	if exit.Type() != hookPtr {
+		exit = ConstBitCast(exit, hookPtr)
+	}
+
+	entry := f.EntryBasicBlock()
+	first := entry.FirstInstruction()
+	for !first.IsNil() && !first.IsAAllocaInst().IsNil() {
+		first = NextInstruction(first)
+	}
+	b.SetInsertPointBefore(first)
+	fn := ConstBitCast(f, i8ptr)
+	callSite := b.CreateReturnAddress(0, "callsite")
+	b.CreateCall(enter, []Value{fn, callSite}, "")
+
+	for bb := f.FirstBasicBlock(); !bb.IsNil(); bb = NextBasicBlock(bb) {
+		if ret := bb.LastInstruction(); !ret.IsAReturnInst().IsNil() {
+			b.SetInsertPointBefore(ret)
+			b.CreateCall(exit, []Value{fn, callSite}, "")
+		}
+	}
+}
+
+// InstrumentFunctions instruments each function with a body in the module
+// m for which filter, if not nil, returns true, with calls to
+// __cyg_profile_func_enter and __cyg_profile_func_exit, as clang's
+// -finstrument-functions does. Functions marked always_inline, those
+// excluded with SetNoInstrument, and the hooks themselves, if the module
+// defines them, are not instrumented. Hooks the module already declares
+// with another type are called bitcast to void (i8*, i8*). It returns the
+// number of functions instrumented.
+func InstrumentFunctions(m Module, filter func(f Value) bool) int {
+	ctx := m.Context()
+	i8ptr := PointerType(ctx.Int8Type(), 0)
+	hookType := FunctionType(ctx.VoidType(), []Type{i8ptr, i8ptr}, false)
+	enter := getOrInsertFunction(m, ProfileFuncEnter, hookType)
+	exit := getOrInsertFunction(m, ProfileFuncExit, hookType)
+	excluded := noInstrumentFunctions(m)
+	n := 0
+	for f := m.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		if f.IsDeclaration() || f.FunctionAttr()&AlwaysInlineAttribute != 0 {
+			continue
+		}
+		if f.Name() == ProfileFuncEnter || f.Name() == ProfileFuncExit || excluded[f] {
+			continue
+		}
+		if filter != nil && !filter(f) {
+			continue
+		}
+		InstrumentFunction(f, enter, exit)
+		n++
+	}
+	return n
+}
+
+// MinInstructions returns a filter for InstrumentFunctions selecting the
+// functions of at least n instructions, like XRay's
+// xray-instruction-threshold, so that small functions, whose hooks would
+// cost more than their bodies, are not instrumented.
+//
+// LLVM 3.2 predates XRay: it cannot emit patchable sleds, nor the
+// xray_instr_map section the XRay runtime patches, so hooks inserted with
+// InstrumentFunctions are always called. Tracing that must be cheap when
+// disabled should test a global flag in the hooks themselves.
+func MinInstructions(n int) func(f Value) bool {
+	return func(f Value) bool {
+		count := 0
+		for bb := f.FirstBasicBlock(); !bb.IsNil(); bb = NextBasicBlock(bb) {
+			for i := bb.FirstInstruction(); !i.IsNil(); i = NextInstruction(i) {
+				if count++; count >= n {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
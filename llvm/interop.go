@@ -0,0 +1,156 @@
+package llvm
+
+import "fmt"
+
+// typeImporter translates types from one context into another.
+type typeImporter struct {
+	dst   Module
+	ctx   Context
+	cache map[Type]Type
+}
+
+func (ti *typeImporter) importType(t Type) Type {
+	if t.Context() == ti.ctx {
+		return t
+	}
+	if it, ok := ti.cache[t]; ok {
+		return it
+	}
+	var it Type
+	switch t.TypeKind() {
+	case VoidTypeKind:
+		it = ti.ctx.VoidType()
+	case HalfTypeKind:
+		it = ti.ctx.HalfType()
+	case FloatTypeKind:
+		it = ti.ctx.FloatType()
+	case DoubleTypeKind:
+		it = ti.ctx.DoubleType()
+	case X86_FP80TypeKind:
+		it = ti.ctx.X86FP80Type()
+	case FP128TypeKind:
+		it = ti.ctx.FP128Type()
+	case PPC_FP128TypeKind:
+		it = ti.ctx.PPCFP128Type()
+	case X86_MMXTypeKind:
+		it = ti.ctx.X86MMXType()
+	case LabelTypeKind:
+		it = ti.ctx.LabelType()
+	case IntegerTypeKind:
+		it = ti.ctx.IntType(t.IntTypeWidth())
+	case PointerTypeKind:
+		it = PointerType(ti.importType(t.ElementType()), t.PointerAddressSpace())
+	case ArrayTypeKind:
+		it = ArrayType(ti.importType(t.ElementType()), t.ArrayLength())
+	case VectorTypeKind:
+		it = VectorType(ti.importType(t.ElementType()), t.VectorSize())
+	case FunctionTypeKind:
+		params := t.ParamTypes()
+		for i, p := range params {
+			params[i] = ti.importType(p)
+		}
+		it = FunctionType(ti.importType(t.ReturnType()), params, t.IsFunctionVarArg())
+	case StructTypeKind:
+		name := t.StructName()
+		if name == "" {
+			it = ti.ctx.StructType(ti.importTypes(t.StructElementTypes()), t.IsStructPacked())
+			break
+		}
+		// Named structs are reused if already defined in the destination,
+		// and are cached before their bodies are imported, as they may be
+		// recursive.
+		if it = ti.dst.GetTypeByName(name); !it.IsNil() && !it.IsStructOpaque() {
+			break
+		}
+		if it.IsNil() {
+			it = ti.ctx.StructCreateNamed(name)
+		}
+		ti.cache[t] = it
+		if !t.IsStructOpaque() {
+			it.StructSetBody(ti.importTypes(t.StructElementTypes()), t.IsStructPacked())
+		}
+	default:
+		panic(fmt.Sprintf("cannot import type: %v", t))
+	}
+	ti.cache[t] = it
+	return it
+}
+
+func (ti *typeImporter) importTypes(types []Type) []Type {
+	for i, t := range types {
+		types[i] = ti.importType(t)
+	}
+	return types
+}
+
+// ImportDeclarations declares, in the module dst, each function and global
+// variable defined or declared in the module src, along with the types they
+// refer to, with external linkage. Calling conventions and the attributes
+// of functions, their parameters and their return values, such as zeroext
+// and noalias, are copied. src is typically compiled from a C header by
+// clang, e.g. from a file which calls or takes the address of each function
+// of interest, and dst may be in a different context.
+//
+// Declarations already present in dst are kept; an error is returned if
+// one has a different type from that in src. Named struct types already
+// defined in dst are assumed to match those of the same name in src.
+func ImportDeclarations(dst, src Module) error {
+	ti := &typeImporter{dst: dst, ctx: dst.Context(), cache: make(map[Type]Type)}
+	for f := src.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		if f.Linkage() == PrivateLinkage || f.Linkage() == InternalLinkage {
+			continue
+		}
+		name := f.Name()
+		ft := ti.importType(f.Type().ElementType())
+		if existing := dst.NamedFunction(name); !existing.IsNil() {
+			if existing.Type().ElementType() != ft {
+				return fmt.Errorf("conflicting declarations of function %s: %v and %v",
+					name, existing.Type().ElementType(), ft)
+			}
+			continue
+		}
+		nf := AddFunction(dst, name, ft)
+		nf.SetFunctionCallConv(f.FunctionCallConv())
+		if a := f.FunctionAttr(); a != 0 {
+			nf.AddFunctionAttr(a)
+		}
+		if a := f.ReturnAttribute(); a != 0 {
+			nf.AddReturnAttribute(a)
+		}
+		for i, p := range f.Params() {
+			if a := p.Attribute(); a != 0 {
+				nf.Param(i).AddAttribute(a)
+			}
+		}
+	}
+	for g := src.FirstGlobal(); !g.IsNil(); g = NextGlobal(g) {
+		if g.Linkage() == PrivateLinkage || g.Linkage() == InternalLinkage {
+			continue
+		}
+		name := g.Name()
+		t := ti.importType(g.Type().ElementType())
+		if existing := dst.NamedGlobal(name); !existing.IsNil() {
+			if existing.Type().ElementType() != t {
+				return fmt.Errorf("conflicting declarations of global %s: %v and %v",
+					name, existing.Type().ElementType(), t)
+			}
+			continue
+		}
+		ng := AddGlobalInAddressSpace(dst, t, name, g.Type().PointerAddressSpace())
+		ng.SetGlobalConstant(g.IsGlobalConstant())
+		ng.SetThreadLocal(g.IsThreadLocal())
+	}
+	return nil
+}
+
+// ImportHeaderBitcode parses the bitcode file at path, typically compiled
+// from a C header by clang, and imports its declarations into dst with
+// ImportDeclarations.
+func ImportHeaderBitcode(dst Module, path string) error {
+	src, err := ParseBitcodeFile(path)
+	if err != nil {
+		return err
+	}
+	defer src.Dispose()
+	return ImportDeclarations(dst, src)
+}
@@ -0,0 +1,571 @@
+package llvm
+
+import "fmt"
+
+// getOrInsertFunction returns the function with the specified name in the
+// module, declaring it with the function type ft if it does not exist. If
+// the module already declares it with another type, the function is
+// returned bitcast to a pointer to ft, as Module::getOrInsertFunction does,
+// so that it may be called with arguments of the types in ft.
+func getOrInsertFunction(m Module, name string, ft Type) Value {
+	f := m.NamedFunction(name)
+	if f.IsNil() {
+		return AddFunction(m, name, ft)
+	}
+	if pt := PointerType(ft, 0); f.Type() != pt {
+		return ConstBitCast(f, pt)
+	}
+	return f
+}
+
+// intrinsicTypeSuffix returns the suffix used to mangle the type t into the
+// name of an overloaded intrinsic, e.g. "i32" or "v4f32".
+func intrinsicTypeSuffix(t Type) string {
+	switch k := t.TypeKind(); k {
+	case IntegerTypeKind:
+		return fmt.Sprintf("i%d", t.IntTypeWidth())
+	case HalfTypeKind:
+		return "f16"
+	case FloatTypeKind:
+		return "f32"
+	case DoubleTypeKind:
+		return "f64"
+	case X86_FP80TypeKind:
+		return "f80"
+	case FP128TypeKind:
+		return "f128"
+	case PPC_FP128TypeKind:
+		return "ppcf128"
+	case VectorTypeKind:
+		return fmt.Sprintf("v%d%s", t.VectorSize(), intrinsicTypeSuffix(t.ElementType()))
+	case PointerTypeKind:
+		return fmt.Sprintf("p%d%s", t.PointerAddressSpace(), intrinsicTypeSuffix(t.ElementType()))
+	default:
+		panic(fmt.Sprintf("unsupported intrinsic overload type: %v", k))
+	}
+}
+
+// insertModule returns the module containing the builder's insertion point.
+func (b Builder) insertModule() Module {
+	return b.GetInsertBlock().Parent().GlobalParent()
+}
+
+// CreatePrefetch creates a call to llvm.prefetch, hinting that the memory at
+// addr will be accessed soon. write specifies whether the access is a write
+// or a read, locality ranges from 0 (no temporal locality) to 3 (keep in
+// cache), and data specifies whether the data or instruction cache should
+// be prefetched into.
+func (b Builder) CreatePrefetch(addr Value, write bool, locality int, data bool) Value {
+	ctx := addr.Type().Context()
+	i8ptr := PointerType(ctx.Int8Type(), 0)
+	i32 := ctx.Int32Type()
+	ft := FunctionType(ctx.VoidType(), []Type{i8ptr, i32, i32, i32}, false)
+	fn := getOrInsertFunction(b.insertModule(), "llvm.prefetch", ft)
+	if addr.Type() != i8ptr {
+		addr = b.CreateBitCast(addr, i8ptr, "")
+	}
+	var rw, cachetype uint64
+	if write {
+		rw = 1
+	}
+	if data {
+		cachetype = 1
+	}
+	args := []Value{
+		addr,
+		ConstInt(i32, rw, false),
+		ConstInt(i32, uint64(locality), false),
+		ConstInt(i32, cachetype, false),
+	}
+	return b.CreateCall(fn, args, "")
+}
+
+// CreateExpect creates a call to llvm.expect, which returns v and informs
+// the optimizer that v is most likely equal to the constant expected. v must
+// be of integer type.
+func (b Builder) CreateExpect(v, expected Value, name string) Value {
+	t := v.Type()
+	ft := FunctionType(t, []Type{t, t}, false)
+	fn := getOrInsertFunction(b.insertModule(), "llvm.expect."+intrinsicTypeSuffix(t), ft)
+	return b.CreateCall(fn, []Value{v, expected}, name)
+}
+
+// splat returns a constant of type t with every element equal to the scalar
+// constant c; if t is not a vector type, c is returned.
+func splat(t Type, c Value) Value {
+	if t.TypeKind() != VectorTypeKind {
+		return c
+	}
+	elems := make([]Value, t.VectorSize())
+	for i := range elems {
+		elems[i] = c
+	}
+	return ConstVector(elems, false)
+}
+
+// scalarType returns the element type of t if it is a vector, or t otherwise.
+func scalarType(t Type) Type {
+	if t.TypeKind() == VectorTypeKind {
+		return t.ElementType()
+	}
+	return t
+}
+
+// ReduceOp identifies the operation performed by CreateVectorReduce.
+type ReduceOp int
+
+const (
+	ReduceAdd ReduceOp = iota
+	ReduceMul
+	ReduceAnd
+	ReduceOr
+	ReduceXor
+	ReduceSMax
+	ReduceSMin
+	ReduceUMax
+	ReduceUMin
+	ReduceFAdd
+	ReduceFMul
+	ReduceFMax
+	ReduceFMin
+)
+
+func (b Builder) createReduceOp(op ReduceOp, lhs, rhs Value) Value {
+	switch op {
+	case ReduceAdd:
+		return b.CreateAdd(lhs, rhs, "")
+	case ReduceMul:
+		return b.CreateMul(lhs, rhs, "")
+	case ReduceAnd:
+		return b.CreateAnd(lhs, rhs, "")
+	case ReduceOr:
+		return b.CreateOr(lhs, rhs, "")
+	case ReduceXor:
+		return b.CreateXor(lhs, rhs, "")
+	case ReduceSMax:
+		return b.CreateSelect(b.CreateICmp(IntSGT, lhs, rhs, ""), lhs, rhs, "")
+	case ReduceSMin:
+		return b.CreateSelect(b.CreateICmp(IntSLT, lhs, rhs, ""), lhs, rhs, "")
+	case ReduceUMax:
+		return b.CreateSelect(b.CreateICmp(IntUGT, lhs, rhs, ""), lhs, rhs, "")
+	case ReduceUMin:
+		return b.CreateSelect(b.CreateICmp(IntULT, lhs, rhs, ""), lhs, rhs, "")
+	case ReduceFAdd:
+		return b.CreateFAdd(lhs, rhs, "")
+	case ReduceFMul:
+		return b.CreateFMul(lhs, rhs, "")
+	case ReduceFMax:
+		return b.CreateSelect(b.CreateFCmp(FloatOGT, lhs, rhs, ""), lhs, rhs, "")
+	case ReduceFMin:
+		return b.CreateSelect(b.CreateFCmp(FloatOLT, lhs, rhs, ""), lhs, rhs, "")
+	}
+	panic(fmt.Sprintf("invalid reduction operation: %d", op))
+}
+
+// CreateVectorReduce reduces the elements of the vector vec to a scalar
+// using the operation op, equivalent to the llvm.vector.reduce.* intrinsics
+// of later LLVM versions. Vectors with a power-of-two number of elements are
+// reduced pairwise with shuffles; floating point reductions are therefore
+// not performed in element order.
+func (b Builder) CreateVectorReduce(op ReduceOp, vec Value, name string) Value {
+	t := vec.Type()
+	n := t.VectorSize()
+	i32 := t.Context().Int32Type()
+	if n&(n-1) != 0 {
+		result := b.CreateExtractElement(vec, ConstInt(i32, 0, false), "")
+		for i := 1; i < n; i++ {
+			elem := b.CreateExtractElement(vec, ConstInt(i32, uint64(i), false), "")
+			result = b.createReduceOp(op, result, elem)
+		}
+		result.SetName(name)
+		return result
+	}
+	undef := Undef(t)
+	for half := n / 2; half > 0; half /= 2 {
+		mask := make([]Value, n)
+		for i := range mask {
+			if i < half {
+				mask[i] = ConstInt(i32, uint64(i+half), false)
+			} else {
+				mask[i] = Undef(i32)
+			}
+		}
+		shuffled := b.CreateShuffleVector(vec, undef, ConstVector(mask, false), "")
+		vec = b.createReduceOp(op, vec, shuffled)
+	}
+	return b.CreateExtractElement(vec, ConstInt(i32, 0, false), name)
+}
+
+// signedMinMax returns the minimum and maximum signed values of the integer
+// or integer vector type t.
+func signedMinMax(t Type) (min, max Value) {
+	et := scalarType(t)
+	emax := ConstLShr(ConstAllOnes(et), ConstInt(et, 1, false))
+	return splat(t, ConstNot(emax)), splat(t, emax)
+}
+
+// CreateSAddSat adds two signed integers (or integer vectors), clamping the
+// result to the range of the type rather than wrapping, equivalent to the
+// llvm.sadd.sat intrinsic of later LLVM versions.
+func (b Builder) CreateSAddSat(lhs, rhs Value, name string) Value {
+	t := lhs.Type()
+	zero := ConstNull(t)
+	min, max := signedMinMax(t)
+	sum := b.CreateAdd(lhs, rhs, "")
+	// Overflow occurred iff both operands' signs differ from the result's.
+	signs := b.CreateAnd(b.CreateXor(lhs, sum, ""), b.CreateXor(rhs, sum, ""), "")
+	overflow := b.CreateICmp(IntSLT, signs, zero, "")
+	sat := b.CreateSelect(b.CreateICmp(IntSLT, lhs, zero, ""), min, max, "")
+	return b.CreateSelect(overflow, sat, sum, name)
+}
+
+// CreateSSubSat subtracts two signed integers (or integer vectors), clamping
+// the result to the range of the type rather than wrapping, equivalent to
+// the llvm.ssub.sat intrinsic of later LLVM versions.
+func (b Builder) CreateSSubSat(lhs, rhs Value, name string) Value {
+	t := lhs.Type()
+	zero := ConstNull(t)
+	min, max := signedMinMax(t)
+	diff := b.CreateSub(lhs, rhs, "")
+	// Overflow occurred iff the operands' signs differ, and the result's
+	// sign differs from lhs's.
+	signs := b.CreateAnd(b.CreateXor(lhs, rhs, ""), b.CreateXor(lhs, diff, ""), "")
+	overflow := b.CreateICmp(IntSLT, signs, zero, "")
+	sat := b.CreateSelect(b.CreateICmp(IntSLT, lhs, zero, ""), min, max, "")
+	return b.CreateSelect(overflow, sat, diff, name)
+}
+
+// CreateUAddSat adds two unsigned integers (or integer vectors), clamping
+// the result to the maximum value of the type rather than wrapping,
+// equivalent to the llvm.uadd.sat intrinsic of later LLVM versions.
+func (b Builder) CreateUAddSat(lhs, rhs Value, name string) Value {
+	sum := b.CreateAdd(lhs, rhs, "")
+	overflow := b.CreateICmp(IntULT, sum, lhs, "")
+	return b.CreateSelect(overflow, ConstAllOnes(lhs.Type()), sum, name)
+}
+
+// CreateUSubSat subtracts two unsigned integers (or integer vectors),
+// clamping the result to zero rather than wrapping, equivalent to the
+// llvm.usub.sat intrinsic of later LLVM versions.
+func (b Builder) CreateUSubSat(lhs, rhs Value, name string) Value {
+	diff := b.CreateSub(lhs, rhs, "")
+	overflow := b.CreateICmp(IntULT, lhs, rhs, "")
+	return b.CreateSelect(overflow, ConstNull(lhs.Type()), diff, name)
+}
+
+// createWithOverflow creates a call to the llvm.<op>.with.overflow intrinsic
+// for the integer type of lhs and rhs.
+func (b Builder) createWithOverflow(op string, lhs, rhs Value, name string) Value {
+	t := lhs.Type()
+	ctx := t.Context()
+	rt := ctx.StructType([]Type{t, ctx.Int1Type()}, false)
+	ft := FunctionType(rt, []Type{t, t}, false)
+	fname := "llvm." + op + ".with.overflow." + intrinsicTypeSuffix(t)
+	fn := getOrInsertFunction(b.insertModule(), fname, ft)
+	return b.CreateCall(fn, []Value{lhs, rhs}, name)
+}
+
+// CreateSAddWithOverflow creates a call to llvm.sadd.with.overflow, which
+// returns a {result, overflow} aggregate: the wrapped sum of lhs and rhs,
+// and an i1 that is set if signed overflow occurred. Use CreateExtractValue
+// to retrieve the members.
+func (b Builder) CreateSAddWithOverflow(lhs, rhs Value, name string) Value {
+	return b.createWithOverflow("sadd", lhs, rhs, name)
+}
+
+// CreateUAddWithOverflow creates a call to llvm.uadd.with.overflow. See
+// CreateSAddWithOverflow.
+func (b Builder) CreateUAddWithOverflow(lhs, rhs Value, name string) Value {
+	return b.createWithOverflow("uadd", lhs, rhs, name)
+}
+
+// CreateSSubWithOverflow creates a call to llvm.ssub.with.overflow. See
+// CreateSAddWithOverflow.
+func (b Builder) CreateSSubWithOverflow(lhs, rhs Value, name string) Value {
+	return b.createWithOverflow("ssub", lhs, rhs, name)
+}
+
+// CreateUSubWithOverflow creates a call to llvm.usub.with.overflow. See
+// CreateSAddWithOverflow.
+func (b Builder) CreateUSubWithOverflow(lhs, rhs Value, name string) Value {
+	return b.createWithOverflow("usub", lhs, rhs, name)
+}
+
+// CreateSMulWithOverflow creates a call to llvm.smul.with.overflow. See
+// CreateSAddWithOverflow.
+func (b Builder) CreateSMulWithOverflow(lhs, rhs Value, name string) Value {
+	return b.createWithOverflow("smul", lhs, rhs, name)
+}
+
+// CreateUMulWithOverflow creates a call to llvm.umul.with.overflow. See
+// CreateSAddWithOverflow.
+func (b Builder) CreateUMulWithOverflow(lhs, rhs Value, name string) Value {
+	return b.createWithOverflow("umul", lhs, rhs, name)
+}
+
+// forEachMaskedLane emits a conditional block for each lane of the vector
+// mask, in which lane is called to emit the operation for that lane. If
+// result is not nil, the value returned by lane is merged with result, and
+// the merged value passed to the next lane.
+//
+// The builder must be positioned at the end of a block without a
+// terminator; on return, it is positioned at the end of a new block.
+func (b Builder) forEachMaskedLane(mask, result Value, lane func(idx, result Value) Value) Value {
+	ctx := mask.Type().Context()
+	i32 := ctx.Int32Type()
+	fn := b.GetInsertBlock().Parent()
+	for i := 0; i < mask.Type().VectorSize(); i++ {
+		idx := ConstInt(i32, uint64(i), false)
+		cond := b.CreateExtractElement(mask, idx, "")
+		pred := b.GetInsertBlock()
+		thenbb := ctx.AddBasicBlock(fn, "masked.lane")
+		nextbb := ctx.AddBasicBlock(fn, "masked.next")
+		b.CreateCondBr(cond, thenbb, nextbb)
+		b.SetInsertPointAtEnd(thenbb)
+		laneResult := lane(idx, result)
+		thenend := b.GetInsertBlock()
+		b.CreateBr(nextbb)
+		b.SetInsertPointAtEnd(nextbb)
+		if !result.IsNil() {
+			phi := b.CreatePHI(result.Type(), "")
+			phi.AddIncoming([]Value{result, laneResult}, []BasicBlock{pred, thenend})
+			result = phi
+		}
+	}
+	return result
+}
+
+// vectorElementPointer converts ptr, a pointer to a vector, to a pointer to
+// the vector's first element.
+func (b Builder) vectorElementPointer(ptr Value) Value {
+	pt := ptr.Type()
+	et := pt.ElementType().ElementType()
+	return b.CreateBitCast(ptr, PointerType(et, pt.PointerAddressSpace()), "")
+}
+
+// CreateMaskedLoad loads the lanes of the vector pointed to by ptr for which
+// the corresponding element of the i1 vector mask is set; the remaining
+// lanes are taken from passthru. Memory is only accessed for the selected
+// lanes. This is equivalent to the llvm.masked.load intrinsic of later LLVM
+// versions, and is emitted as a sequence of conditional scalar loads; the
+// builder must be positioned at the end of a block without a terminator.
+func (b Builder) CreateMaskedLoad(ptr, mask, passthru Value, name string) Value {
+	eptr := b.vectorElementPointer(ptr)
+	result := b.forEachMaskedLane(mask, passthru, func(idx, result Value) Value {
+		elem := b.CreateLoad(b.CreateGEP(eptr, []Value{idx}, ""), "")
+		return b.CreateInsertElement(result, elem, idx, "")
+	})
+	result.SetName(name)
+	return result
+}
+
+// CreateMaskedStore stores the lanes of the vector val for which the
+// corresponding element of the i1 vector mask is set, to the vector pointed
+// to by ptr. This is equivalent to the llvm.masked.store intrinsic of later
+// LLVM versions; see CreateMaskedLoad.
+func (b Builder) CreateMaskedStore(val, ptr, mask Value) {
+	eptr := b.vectorElementPointer(ptr)
+	b.forEachMaskedLane(mask, Value{}, func(idx, _ Value) Value {
+		elem := b.CreateExtractElement(val, idx, "")
+		b.CreateStore(elem, b.CreateGEP(eptr, []Value{idx}, ""))
+		return Value{}
+	})
+}
+
+// CreateMaskedGather loads, for each lane for which the corresponding
+// element of the i1 vector mask is set, the value pointed to by that lane of
+// the pointer vector ptrs; the remaining lanes are taken from passthru. This
+// is equivalent to the llvm.masked.gather intrinsic of later LLVM versions;
+// see CreateMaskedLoad.
+func (b Builder) CreateMaskedGather(ptrs, mask, passthru Value, name string) Value {
+	result := b.forEachMaskedLane(mask, passthru, func(idx, result Value) Value {
+		elem := b.CreateLoad(b.CreateExtractElement(ptrs, idx, ""), "")
+		return b.CreateInsertElement(result, elem, idx, "")
+	})
+	result.SetName(name)
+	return result
+}
+
+// CreateMaskedScatter stores, for each lane for which the corresponding
+// element of the i1 vector mask is set, that lane of val to the address in
+// the same lane of the pointer vector ptrs. This is equivalent to the
+// llvm.masked.scatter intrinsic of later LLVM versions; see
+// CreateMaskedLoad.
+func (b Builder) CreateMaskedScatter(val, ptrs, mask Value) {
+	b.forEachMaskedLane(mask, Value{}, func(idx, _ Value) Value {
+		elem := b.CreateExtractElement(val, idx, "")
+		b.CreateStore(elem, b.CreateExtractElement(ptrs, idx, ""))
+		return Value{}
+	})
+}
+
+// CreateConvertFromFP16 creates a call to llvm.convert.from.fp16, which
+// converts the IEEE half precision value stored in the i16 v to a float.
+// Targets without native half precision arithmetic should store half values
+// as i16 and compute on float.
+func (b Builder) CreateConvertFromFP16(v Value, name string) Value {
+	ctx := v.Type().Context()
+	ft := FunctionType(ctx.FloatType(), []Type{ctx.Int16Type()}, false)
+	fn := getOrInsertFunction(b.insertModule(), "llvm.convert.from.fp16", ft)
+	return b.CreateCall(fn, []Value{v}, name)
+}
+
+// CreateConvertToFP16 creates a call to llvm.convert.to.fp16, which converts
+// the float v to IEEE half precision, returned as an i16.
+func (b Builder) CreateConvertToFP16(v Value, name string) Value {
+	ctx := v.Type().Context()
+	ft := FunctionType(ctx.Int16Type(), []Type{ctx.FloatType()}, false)
+	fn := getOrInsertFunction(b.insertModule(), "llvm.convert.to.fp16", ft)
+	return b.CreateCall(fn, []Value{v}, name)
+}
+
+// bfloat16 values are the upper 16 bits of a float, and are represented as
+// i16, as LLVM versions before 11 have no bfloat type.
+
+// CreateBFloat16ToFloat converts the bfloat16 v, an i16 or i16 vector, to
+// float or a float vector. The conversion is exact.
+func (b Builder) CreateBFloat16ToFloat(v Value, name string) Value {
+	t := v.Type()
+	ctx := t.Context()
+	i32, f32 := ctx.Int32Type(), ctx.FloatType()
+	if t.TypeKind() == VectorTypeKind {
+		i32, f32 = VectorType(i32, t.VectorSize()), VectorType(f32, t.VectorSize())
+	}
+	bits := b.CreateZExt(v, i32, "")
+	bits = b.CreateShl(bits, splat(i32, ConstInt(scalarType(i32), 16, false)), "")
+	return b.CreateBitCast(bits, f32, name)
+}
+
+// CreateFloatToBFloat16 converts the float v, or a float vector, to
+// bfloat16, rounding to nearest even. NaNs are converted to quiet NaNs.
+func (b Builder) CreateFloatToBFloat16(v Value, name string) Value {
+	t := v.Type()
+	ctx := t.Context()
+	i32, i16 := ctx.Int32Type(), ctx.Int16Type()
+	if t.TypeKind() == VectorTypeKind {
+		i32, i16 = VectorType(i32, t.VectorSize()), VectorType(i16, t.VectorSize())
+	}
+	c := func(n uint64) Value { return splat(i32, ConstInt(scalarType(i32), n, false)) }
+	bits := b.CreateBitCast(v, i32, "")
+	// Adding 0x7fff plus the lowest retained bit rounds to nearest even.
+	lsb := b.CreateAnd(b.CreateLShr(bits, c(16), ""), c(1), "")
+	rounded := b.CreateAdd(b.CreateAdd(bits, c(0x7fff), ""), lsb, "")
+	// Rounding could turn a NaN into infinity, so NaNs are truncated and
+	// their quiet bit set instead.
+	nan := b.CreateOr(bits, c(0x400000), "")
+	isnan := b.CreateFCmp(FloatUNO, v, v, "")
+	bits = b.CreateSelect(isnan, nan, rounded, "")
+	return b.CreateTrunc(b.CreateLShr(bits, c(16), ""), i16, name)
+}
+
+// CreateInvariantStart creates a call to llvm.invariant.start, marking the
+// size bytes at ptr as unchanging until a matching llvm.invariant.end. size
+// is -1 if the extent is unknown. The result is passed to
+// CreateInvariantEnd.
+func (b Builder) CreateInvariantStart(size int64, ptr Value) Value {
+	ctx := ptr.Type().Context()
+	i8ptr := PointerType(ctx.Int8Type(), 0)
+	i64 := ctx.Int64Type()
+	desc := PointerType(ctx.StructType(nil, false), 0)
+	ft := FunctionType(desc, []Type{i64, i8ptr}, false)
+	fn := getOrInsertFunction(b.insertModule(), "llvm.invariant.start", ft)
+	if ptr.Type() != i8ptr {
+		ptr = b.CreateBitCast(ptr, i8ptr, "")
+	}
+	return b.CreateCall(fn, []Value{ConstInt(i64, uint64(size), true), ptr}, "")
+}
+
+// CreateInvariantEnd creates a call to llvm.invariant.end, ending the
+// invariant region begun by start, the result of CreateInvariantStart with
+// the same size and ptr.
+func (b Builder) CreateInvariantEnd(start Value, size int64, ptr Value) Value {
+	ctx := ptr.Type().Context()
+	i8ptr := PointerType(ctx.Int8Type(), 0)
+	i64 := ctx.Int64Type()
+	ft := FunctionType(ctx.VoidType(), []Type{start.Type(), i64, i8ptr}, false)
+	fn := getOrInsertFunction(b.insertModule(), "llvm.invariant.end", ft)
+	if ptr.Type() != i8ptr {
+		ptr = b.CreateBitCast(ptr, i8ptr, "")
+	}
+	return b.CreateCall(fn, []Value{start, ConstInt(i64, uint64(size), true), ptr}, "")
+}
+
+// CreateTrap creates a call to llvm.trap, which stops the program, e.g.
+// with an illegal instruction.
+func (b Builder) CreateTrap() Value {
+	ft := FunctionType(b.insertModule().Context().VoidType(), nil, false)
+	fn := getOrInsertFunction(b.insertModule(), "llvm.trap", ft)
+	return b.CreateCall(fn, nil, "")
+}
+
+// CreateReturnAddress creates a call to llvm.returnaddress, returning the
+// return address of the current function, or of its caller at the given
+// depth, as an i8*.
+func (b Builder) CreateReturnAddress(depth int, name string) Value {
+	ctx := b.insertModule().Context()
+	i8ptr := PointerType(ctx.Int8Type(), 0)
+	ft := FunctionType(i8ptr, []Type{ctx.Int32Type()}, false)
+	fn := getOrInsertFunction(b.insertModule(), "llvm.returnaddress", ft)
+	return b.CreateCall(fn, []Value{ConstInt(ctx.Int32Type(), uint64(depth), false)}, name)
+}
+
+// createUnaryIntrinsic creates a call to the intrinsic name overloaded on
+// the type of v, taking v and the extra arguments, and returning a value of
+// the same type as v.
+func (b Builder) createUnaryIntrinsic(intrinsic string, v Value, extra []Value, name string) Value {
+	t := v.Type()
+	params := []Type{t}
+	for _, e := range extra {
+		params = append(params, e.Type())
+	}
+	ft := FunctionType(t, params, false)
+	fn := getOrInsertFunction(b.insertModule(), intrinsic+"."+intrinsicTypeSuffix(t), ft)
+	return b.CreateCall(fn, append([]Value{v}, extra...), name)
+}
+
+// CreatePopCount creates a call to llvm.ctpop, counting the bits set in
+// the integer or integer vector v.
+func (b Builder) CreatePopCount(v Value, name string) Value {
+	return b.createUnaryIntrinsic("llvm.ctpop", v, nil, name)
+}
+
+// CreateCountLeadingZeros creates a call to llvm.ctlz, counting the leading
+// zero bits of the integer or integer vector v. If zeroUndef is true, the
+// result is undefined if v is zero.
+func (b Builder) CreateCountLeadingZeros(v Value, zeroUndef bool, name string) Value {
+	flag := ConstInt(v.Type().Context().Int1Type(), boolToUint64(zeroUndef), false)
+	return b.createUnaryIntrinsic("llvm.ctlz", v, []Value{flag}, name)
+}
+
+// CreateCountTrailingZeros creates a call to llvm.cttz, counting the
+// trailing zero bits of the integer or integer vector v. If zeroUndef is
+// true, the result is undefined if v is zero.
+func (b Builder) CreateCountTrailingZeros(v Value, zeroUndef bool, name string) Value {
+	flag := ConstInt(v.Type().Context().Int1Type(), boolToUint64(zeroUndef), false)
+	return b.createUnaryIntrinsic("llvm.cttz", v, []Value{flag}, name)
+}
+
+// CreateByteSwap creates a call to llvm.bswap, reversing the bytes of v, an
+// integer or integer vector with a whole, even number of bytes.
+func (b Builder) CreateByteSwap(v Value, name string) Value {
+	return b.createUnaryIntrinsic("llvm.bswap", v, nil, name)
+}
+
+// CreateSqrt creates a call to llvm.sqrt, the square root of the floating
+// point or floating point vector v.
+func (b Builder) CreateSqrt(v Value, name string) Value {
+	return b.createUnaryIntrinsic("llvm.sqrt", v, nil, name)
+}
+
+// CreateFMA creates a call to llvm.fma, computing x*y+z with a single
+// rounding.
+func (b Builder) CreateFMA(x, y, z Value, name string) Value {
+	return b.createUnaryIntrinsic("llvm.fma", x, []Value{y, z}, name)
+}
+
+func boolToUint64(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
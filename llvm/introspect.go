@@ -0,0 +1,176 @@
+package llvm
+
+import "math/big"
+
+// ValueKind identifies the class of a Value in LLVM's value hierarchy.
+type ValueKind int
+
+const (
+	UnknownValueKind ValueKind = iota
+	ArgumentValueKind
+	BasicBlockValueKind
+	InlineAsmValueKind
+	MDNodeValueKind
+	MDStringValueKind
+	BlockAddressValueKind
+	ConstantAggregateZeroValueKind
+	ConstantArrayValueKind
+	ConstantDataValueKind
+	ConstantExprValueKind
+	ConstantFPValueKind
+	ConstantIntValueKind
+	ConstantPointerNullValueKind
+	ConstantStructValueKind
+	ConstantVectorValueKind
+	FunctionValueKind
+	GlobalAliasValueKind
+	GlobalVariableValueKind
+	UndefValueKind
+	InstructionValueKind
+)
+
+var valueKindNames = [...]string{
+	UnknownValueKind:               "Unknown",
+	ArgumentValueKind:              "Argument",
+	BasicBlockValueKind:            "BasicBlock",
+	InlineAsmValueKind:             "InlineAsm",
+	MDNodeValueKind:                "MDNode",
+	MDStringValueKind:              "MDString",
+	BlockAddressValueKind:          "BlockAddress",
+	ConstantAggregateZeroValueKind: "ConstantAggregateZero",
+	ConstantArrayValueKind:         "ConstantArray",
+	ConstantDataValueKind:          "ConstantData",
+	ConstantExprValueKind:          "ConstantExpr",
+	ConstantFPValueKind:            "ConstantFP",
+	ConstantIntValueKind:           "ConstantInt",
+	ConstantPointerNullValueKind:   "ConstantPointerNull",
+	ConstantStructValueKind:        "ConstantStruct",
+	ConstantVectorValueKind:        "ConstantVector",
+	FunctionValueKind:              "Function",
+	GlobalAliasValueKind:           "GlobalAlias",
+	GlobalVariableValueKind:        "GlobalVariable",
+	UndefValueKind:                 "UndefValue",
+	InstructionValueKind:           "Instruction",
+}
+
+func (k ValueKind) String() string {
+	if k < 0 || int(k) >= len(valueKindNames) {
+		return "Unknown"
+	}
+	return valueKindNames[k]
+}
+
+// Kind classifies the value v. The kind of a nil Value is
+// UnknownValueKind.
+func (v Value) Kind() ValueKind {
+	switch {
+	case v.IsNil():
+		return UnknownValueKind
+	case !v.IsAArgument().IsNil():
+		return ArgumentValueKind
+	case !v.IsABasicBlock().IsNil():
+		return BasicBlockValueKind
+	case !v.IsAInlineAsm().IsNil():
+		return InlineAsmValueKind
+	case !v.IsAMDNode().IsNil():
+		return MDNodeValueKind
+	case !v.IsAMDString().IsNil():
+		return MDStringValueKind
+	case !v.IsAInstruction().IsNil():
+		return InstructionValueKind
+	case v.IsAConstant().IsNil():
+		return UnknownValueKind
+	case !v.IsABlockAddress().IsNil():
+		return BlockAddressValueKind
+	case !v.IsAConstantAggregateZero().IsNil():
+		return ConstantAggregateZeroValueKind
+	case !v.IsAConstantArray().IsNil():
+		return ConstantArrayValueKind
+	case !v.IsAConstantExpr().IsNil():
+		return ConstantExprValueKind
+	case !v.IsAConstantFP().IsNil():
+		return ConstantFPValueKind
+	case !v.IsAConstantInt().IsNil():
+		return ConstantIntValueKind
+	case !v.IsAConstantPointerNull().IsNil():
+		return ConstantPointerNullValueKind
+	case !v.IsAConstantStruct().IsNil():
+		return ConstantStructValueKind
+	case !v.IsAConstantVector().IsNil():
+		return ConstantVectorValueKind
+	case !v.IsAFunction().IsNil():
+		return FunctionValueKind
+	case !v.IsAGlobalAlias().IsNil():
+		return GlobalAliasValueKind
+	case !v.IsAGlobalVariable().IsNil():
+		return GlobalVariableValueKind
+	case !v.IsAUndefValue().IsNil():
+		return UndefValueKind
+	}
+	// The remaining constants are ConstantDataArray and ConstantDataVector,
+	// which have no C API predicate.
+	return ConstantDataValueKind
+}
+
+// ValueInfo is a structured description of a Value, as returned by
+// Value.Describe.
+type ValueInfo struct {
+	Kind ValueKind
+	Type Type
+	Name string
+
+	// Opcode is the opcode of an instruction or constant expression.
+	Opcode Opcode
+
+	// Operands holds the operands of a User (instructions and constants),
+	// or of an MDNode.
+	Operands []Value
+
+	// Int holds the signed value of a ConstantInt.
+	Int *big.Int
+
+	// Float holds the value of a ConstantFP, converted to float64.
+	Float float64
+
+	// String holds the contents of an MDString, or of a constant i8
+	// array.
+	String string
+}
+
+// Describe decodes the value v into a ValueInfo. A nil Value is
+// described by a zero ValueInfo.
+func (v Value) Describe() (info ValueInfo) {
+	if v.IsNil() {
+		return
+	}
+	info.Kind = v.Kind()
+	if info.Kind != MDStringValueKind && info.Kind != MDNodeValueKind {
+		info.Type = v.Type()
+	}
+	info.Name = v.Name()
+	switch info.Kind {
+	case InstructionValueKind:
+		info.Opcode = v.InstructionOpcode()
+	case ConstantExprValueKind:
+		info.Opcode = v.Opcode()
+	case ConstantIntValueKind:
+		info.Int = v.ConstIntValue(true)
+	case ConstantFPValueKind:
+		info.Float, _ = v.ConstFloatValue()
+	case ConstantDataValueKind:
+		info.String, _ = v.ConstDataString()
+	case MDStringValueKind:
+		info.String = v.MDStringValue()
+	}
+	if info.Kind == MDNodeValueKind {
+		info.Operands = v.MDNodeOperands()
+	} else if !v.IsAUser().IsNil() {
+		if n := v.OperandsCount(); n > 0 {
+			info.Operands = make([]Value, n)
+			for i := range info.Operands {
+				info.Operands[i] = v.Operand(i)
+			}
+		}
+	}
+	return
+}
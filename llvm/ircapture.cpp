@@ -0,0 +1,87 @@
+#include <llvm/Pass.h>
+#include <llvm/PassManager.h>
+#include <llvm/Assembly/PrintModulePass.h>
+#include <llvm/Support/raw_ostream.h>
+#include <stdint.h>
+#include <string>
+#include <vector>
+
+// Exported by ircapture.go.
+extern "C" void goIRCaptureWrite(uintptr_t, const char *, size_t);
+
+namespace {
+
+// goWriterStream writes to the io.Writer registered with the handle.
+class goWriterStream : public llvm::raw_ostream {
+	uintptr_t handle;
+	uint64_t pos;
+
+	virtual void write_impl(const char *ptr, size_t size) {
+		goIRCaptureWrite(handle, ptr, size);
+		pos += size;
+	}
+
+	virtual uint64_t current_pos() const { return pos; }
+
+public:
+	goWriterStream(uintptr_t handle) : handle(handle), pos(0) {}
+	~goWriterStream() { flush(); }
+};
+
+// capturingPassManager adds each pass to pm followed by a printer of the IR
+// the pass ran on, as -print-after-all does, so that passes added by a
+// PassManagerBuilder, which calls add, are captured too. Immutable passes
+// do not change the IR, and are not followed by printers.
+class capturingPassManager : public llvm::PassManagerBase {
+	llvm::PassManagerBase *pm;
+	llvm::raw_ostream &os;
+	std::string banner;
+
+public:
+	capturingPassManager(llvm::PassManagerBase *pm, llvm::raw_ostream &os,
+	                     const char *banner)
+		: pm(pm), os(os), banner(banner) {}
+
+	virtual void add(llvm::Pass *p) {
+		pm->add(p);
+		if (!p->getAsImmutablePass())
+			pm->add(p->createPrinterPass(os, banner + p->getPassName() + "\n"));
+	}
+};
+
+} // namespace
+
+struct irCapture {
+	goWriterStream os;
+	std::vector<capturingPassManager*> wrappers;
+	irCapture(uintptr_t handle) : os(handle) {}
+	~irCapture() {
+		for (size_t i = 0; i < wrappers.size(); i++)
+			delete wrappers[i];
+	}
+};
+
+extern "C" irCapture *createIRCapture(uintptr_t handle) {
+	return new irCapture(handle);
+}
+
+extern "C" void disposeIRCapture(irCapture *c) {
+	delete c;
+}
+
+extern "C" llvm::PassManagerBase *wrapIRCapturePassManager(irCapture *c,
+                                                           llvm::PassManagerBase *pm,
+                                                           const char *banner) {
+	capturingPassManager *wrapper = new capturingPassManager(pm, c->os, banner);
+	c->wrappers.push_back(wrapper);
+	return wrapper;
+}
+
+extern "C" void addIRCapturePass(llvm::PassManagerBase *pm, irCapture *c,
+                                 const char *banner) {
+	pm->add(llvm::createPrintModulePass(&c->os, false, banner));
+}
+
+extern "C" void flushIRCapture(irCapture *c) {
+	c->os.flush();
+}
@@ -0,0 +1,138 @@
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+typedef struct irCapture irCapture;
+extern irCapture *createIRCapture(uintptr_t);
+extern void disposeIRCapture(irCapture *);
+extern LLVMPassManagerRef wrapIRCapturePassManager(irCapture *, LLVMPassManagerRef, const char *);
+extern void addIRCapturePass(LLVMPassManagerRef, irCapture *, const char *);
+extern void flushIRCapture(irCapture *);
+*/
+import "C"
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// irCaptureBanner precedes each snapshot written by an IRCapture.
+const irCaptureBanner = "; gollvm IR after pass: "
+
+// IRSnapshot is the IR after a pass was run: that of the module, or of a
+// single function, loop or call graph node for passes run on those.
+type IRSnapshot struct {
+	Pass string
+	IR   string
+}
+
+// irCaptureWriter is the destination of an IRCapture, and the first error
+// writing to it.
+type irCaptureWriter struct {
+	w   io.Writer
+	err error
+}
+
+// irCaptures records the writers of the IRCaptures, by the handle given to
+// their streams.
+var irCaptures struct {
+	sync.Mutex
+	next uintptr
+	m    map[uintptr]*irCaptureWriter
+}
+
+// IRCapture writes the IR after each pass of a pass manager to an
+// io.Writer, as -print-after-all does to standard error. The IR is
+// streamed through a buffer as the passes run; Flush writes what remains.
+// The IRCapture must not be disposed before the pass managers it was added
+// to have finished running.
+type IRCapture struct {
+	C      *C.irCapture
+	handle uintptr
+}
+
+// NewIRCapture creates an IRCapture writing to w.
+func NewIRCapture(w io.Writer) IRCapture {
+	irCaptures.Lock()
+	defer irCaptures.Unlock()
+	if irCaptures.m == nil {
+		irCaptures.m = make(map[uintptr]*irCaptureWriter)
+	}
+	irCaptures.next++
+	h := irCaptures.next
+	irCaptures.m[h] = &irCaptureWriter{w: w}
+	return IRCapture{C.createIRCapture(C.uintptr_t(h)), h}
+}
+
+// Dispose flushes the IRCapture, and releases it and the pass managers
+// returned by Wrap.
+func (c IRCapture) Dispose() {
+	C.disposeIRCapture(c.C)
+	irCaptures.Lock()
+	delete(irCaptures.m, c.handle)
+	irCaptures.Unlock()
+}
+
+// Wrap returns a pass manager which adds each pass to pm followed by a pass
+// writing the IR after it, labelled with the pass's name. Passes added by
+// a PassManagerBuilder, e.g. with Populate or PopulateFunc, are captured
+// too; analyses run only as the dependencies of other passes are not.
+// Function passes write the IR of each function they run on, and loop
+// passes that of each loop.
+//
+// The returned pass manager may only be used to add passes: pm must be run
+// instead, and it must not be disposed, as it is owned by the IRCapture.
+func (c IRCapture) Wrap(pm PassManager) PassManager {
+	banner := C.CString(irCaptureBanner)
+	defer C.free(unsafe.Pointer(banner))
+	return PassManager{C.wrapIRCapturePassManager(c.C, pm.C, banner)}
+}
+
+// AddSnapshotPass adds a pass to the module pass manager pm which writes
+// the module's IR, labelled with pass, e.g. to capture the IR before the
+// first pass.
+func (c IRCapture) AddSnapshotPass(pm PassManager, pass string) {
+	banner := C.CString(irCaptureBanner + pass + "\n")
+	defer C.free(unsafe.Pointer(banner))
+	C.addIRCapturePass(pm.C, c.C, banner)
+}
+
+// Flush writes the IR buffered by the IRCapture to its writer, and returns
+// the first error the writer returned, if any. IR is not written after an
+// error.
+func (c IRCapture) Flush() error {
+	C.flushIRCapture(c.C)
+	irCaptures.Lock()
+	defer irCaptures.Unlock()
+	return irCaptures.m[c.handle].err
+}
+
+//export goIRCaptureWrite
+func goIRCaptureWrite(handle C.uintptr_t, data *C.char, n C.size_t) {
+	irCaptures.Lock()
+	cw := irCaptures.m[uintptr(handle)]
+	irCaptures.Unlock()
+	if cw == nil || cw.err != nil {
+		return
+	}
+	_, cw.err = cw.w.Write(C.GoBytes(unsafe.Pointer(data), C.int(n)))
+}
+
+// ParseIRSnapshots splits the output of an IRCapture into snapshots, in
+// the order the passes ran.
+func ParseIRSnapshots(s string) []IRSnapshot {
+	var snapshots []IRSnapshot
+	for _, s := range strings.Split(s, irCaptureBanner)[1:] {
+		var snapshot IRSnapshot
+		if i := strings.Index(s, "\n"); i >= 0 {
+			snapshot.Pass, snapshot.IR = s[:i], s[i+1:]
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
@@ -0,0 +1,248 @@
+package llvm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// JITSession is a JIT compiler for a set of modules which may be loaded and
+// unloaded independently. It owns a context, in which its modules must be
+// created, and the modules added to it, and links each module's
+// declarations to the definitions of previously added modules.
+//
+// A JITSession is safe for concurrent use: compilation and changes to the
+// set of modules are serialized by a lock. Its context is not, since LLVM
+// contexts are not thread-safe, so while other goroutines may be using the
+// session, modules must be created and changed in it within Build. The
+// addresses it returns remain valid until the module defining them is
+// removed.
+type JITSession struct {
+	mu      sync.Mutex
+	ctx     Context
+	ee      ExecutionEngine
+	modules map[Module]*sessionModule
+	order   []Module          // the modules in the order they were added
+	symbols map[string]Module // the module defining each exported symbol
+	limits  ResourceLimits
+}
+
+type sessionModule struct {
+	exports []string
+	deps    map[Module]bool // modules whose definitions this module uses
+}
+
+// NewJITSession creates a JIT session with a new context, compiling at the
+// given optimization level as NewJITCompiler does.
+func NewJITSession(optLevel int) (*JITSession, error) {
+	s := &JITSession{
+		ctx:     NewContext(),
+		modules: make(map[Module]*sessionModule),
+		symbols: make(map[string]Module),
+	}
+	// The execution engine must be created with a module, so it is given
+	// an empty one which is never removed.
+	ee, err := NewJITCompiler(s.ctx.NewModule("jitsession"), optLevel)
+	if err != nil {
+		s.ctx.Dispose()
+		return nil, err
+	}
+	s.ee = ee
+	return s, nil
+}
+
+// Context returns the context in which modules added to the session must be
+// created. It may only be used outside Build while no other goroutine is
+// using the session.
+func (s *JITSession) Context() Context { return s.ctx }
+
+// Build calls f with the session's context while holding the session's
+// lock, so that f may create and change modules in the context while other
+// goroutines compile in it. f must not call the session's methods.
+func (s *JITSession) Build(f func(ctx Context)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s.ctx)
+}
+
+// SetResourceLimits sets the limits checked against each module added to
+// the session; AddModule fails with a *ResourceLimitError if one is
+// exceeded.
+func (s *JITSession) SetResourceLimits(l ResourceLimits) {
+	s.mu.Lock()
+	s.limits = l
+	s.mu.Unlock()
+}
+
+// TargetData returns the layout of data in the session's compiled code.
+func (s *JITSession) TargetData() TargetData { return s.ee.TargetData() }
+
+// exported returns the names of the functions and global variables defined
+// in m which are visible to other modules.
+func exported(m Module) []string {
+	var names []string
+	add := func(v Value) {
+		switch v.Linkage() {
+		case InternalLinkage, PrivateLinkage, LinkerPrivateLinkage, LinkerPrivateWeakLinkage, AvailableExternallyLinkage:
+			return
+		}
+		if !v.IsDeclaration() && v.Name() != "" {
+			names = append(names, v.Name())
+		}
+	}
+	for f := m.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		if f.IntrinsicID() == 0 {
+			add(f)
+		}
+	}
+	for g := m.FirstGlobal(); !g.IsNil(); g = NextGlobal(g) {
+		add(g)
+	}
+	return names
+}
+
+// AddModule adds the module m, which must have been created in the
+// session's context, to the session, which takes ownership of it. Each
+// declaration in m of a symbol defined by a module already in the session
+// is linked to that definition; other declarations are resolved in the
+// host process. An error is returned if m defines a symbol already defined
+// by another module, so modules which refer to each other's symbols must
+// be linked into one before they are added.
+func (s *JITSession) AddModule(m Module) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m.Context() != s.ctx {
+		return errors.New("module was not created in the session's context")
+	}
+	if err := s.limits.Check(m); err != nil {
+		return err
+	}
+	exports := exported(m)
+	for _, name := range exports {
+		if _, dup := s.symbols[name]; dup {
+			return fmt.Errorf("symbol %s is already defined", name)
+		}
+	}
+	if err := s.ee.TryAddModule(m); err != nil {
+		return err
+	}
+	sm := &sessionModule{exports: exports, deps: make(map[Module]bool)}
+	link := func(decl Value) {
+		owner, ok := s.symbols[decl.Name()]
+		if !decl.IsDeclaration() || !ok {
+			return
+		}
+		var def Value
+		if decl.IsAFunction().IsNil() {
+			def = owner.NamedGlobal(decl.Name())
+		} else {
+			def = owner.NamedFunction(decl.Name())
+		}
+		s.ee.AddGlobalMapping(decl, s.ee.PointerToGlobal(def))
+		sm.deps[owner] = true
+	}
+	for f := m.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		link(f)
+	}
+	for g := m.FirstGlobal(); !g.IsNil(); g = NextGlobal(g) {
+		link(g)
+	}
+	for _, name := range exports {
+		s.symbols[name] = m
+	}
+	s.modules[m] = sm
+	s.order = append(s.order, m)
+	return nil
+}
+
+// RemoveModule unloads the module m from the session and disposes it. The
+// machine code of its functions is freed, and the addresses of its globals
+// forgotten; the memory of global variables is not reclaimed by LLVM 3.2's
+// JIT until the session is disposed. An error is returned if another module
+// in the session uses one of m's definitions.
+func (s *JITSession) RemoveModule(m Module) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sm, ok := s.modules[m]
+	if !ok {
+		return errors.New("module is not in the session")
+	}
+	for _, other := range s.modules {
+		if other.deps[m] {
+			return errors.New("module is used by another module in the session")
+		}
+	}
+	for f := m.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		if !f.IsDeclaration() {
+			s.ee.FreeMachineCodeForFunction(f)
+		}
+	}
+	s.ee.ClearGlobalMappingsFromModule(m)
+	s.ee.RemoveModule(m)
+	for _, name := range sm.exports {
+		delete(s.symbols, name)
+	}
+	delete(s.modules, m)
+	for i, other := range s.order {
+		if other == m {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	return m.TryDispose()
+}
+
+// Modules returns the modules in the session, in the order in which they
+// were added.
+func (s *JITSession) Modules() []Module {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Module(nil), s.order...)
+}
+
+// Owner returns the module defining the symbol name, or false if no module
+// in the session exports it.
+func (s *JITSession) Owner(name string) (Module, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.symbols[name]
+	return m, ok
+}
+
+// Lookup returns the address of the function or global variable name
+// exported by a module in the session, compiling it if necessary, or nil
+// if no module exports it.
+func (s *JITSession) Lookup(name string) unsafe.Pointer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.symbols[name]
+	if !ok {
+		return nil
+	}
+	v := m.NamedFunction(name)
+	if v.IsNil() {
+		v = m.NamedGlobal(name)
+	}
+	return s.ee.PointerToGlobal(v)
+}
+
+// RunFunction runs the function f, which must be defined by a module in
+// the session, with the given arguments, holding the session's lock, so f
+// must not call back into the session. See ExecutionEngine.RunFunction.
+func (s *JITSession) RunFunction(f Value, args []GenericValue) GenericValue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ee.RunFunction(f, args)
+}
+
+// Dispose destroys the session, along with its modules and context.
+func (s *JITSession) Dispose() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ee.Dispose()
+	s.ctx.Dispose()
+	s.modules = nil
+	s.order = nil
+	s.symbols = nil
+}
@@ -0,0 +1,107 @@
+package llvm
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ResourceLimits bounds the size of a module, for services which compile
+// untrusted IR. A zero limit is not enforced.
+type ResourceLimits struct {
+	MaxGlobals              int // Global variables.
+	MaxFunctions            int // Functions, including declarations.
+	MaxBasicBlocks          int // Basic blocks in the module.
+	MaxInstructions         int // Instructions in the module.
+	MaxFunctionInstructions int // Instructions in any one function.
+}
+
+// ResourceLimitError is returned by ResourceLimits.Check when a module
+// exceeds a limit.
+type ResourceLimitError struct {
+	Resource string // e.g. "functions", or "instructions in function f".
+	Limit    int
+}
+
+func (e *ResourceLimitError) Error() string {
+	return fmt.Sprintf("module exceeds limit of %d %s", e.Limit, e.Resource)
+}
+
+func exceeds(count, limit int) bool { return limit > 0 && count > limit }
+
+// Check returns a *ResourceLimitError if the module m exceeds any of the
+// limits. It stops counting as soon as a limit is exceeded, so the cost of
+// checking an oversized module is bounded by the limits.
+//
+// Limits are enforced at emission time by target machines given them with
+// SetResourceLimits, in EmitToFile and EmitToMemoryBuffer and so in
+// EmitParallel and Link, which also checks LinkOptions.Limits, and by
+// JITSession.AddModule for sessions given them with SetResourceLimits. The
+// execution engine constructors take a module which is compiled lazily,
+// with no later point at which to check it, so callers executing untrusted
+// IR with an ExecutionEngine must call Check before creating the engine.
+func (l ResourceLimits) Check(m Module) error {
+	globals := 0
+	for g := m.FirstGlobal(); !g.IsNil(); g = NextGlobal(g) {
+		if globals++; exceeds(globals, l.MaxGlobals) {
+			return &ResourceLimitError{"globals", l.MaxGlobals}
+		}
+	}
+	functions, blocks, instructions := 0, 0, 0
+	for f := m.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		if functions++; exceeds(functions, l.MaxFunctions) {
+			return &ResourceLimitError{"functions", l.MaxFunctions}
+		}
+		finstructions := 0
+		for bb := f.FirstBasicBlock(); !bb.IsNil(); bb = NextBasicBlock(bb) {
+			if blocks++; exceeds(blocks, l.MaxBasicBlocks) {
+				return &ResourceLimitError{"basic blocks", l.MaxBasicBlocks}
+			}
+			for i := bb.FirstInstruction(); !i.IsNil(); i = NextInstruction(i) {
+				if instructions++; exceeds(instructions, l.MaxInstructions) {
+					return &ResourceLimitError{"instructions", l.MaxInstructions}
+				}
+				if finstructions++; exceeds(finstructions, l.MaxFunctionInstructions) {
+					return &ResourceLimitError{"instructions in function " + f.Name(), l.MaxFunctionInstructions}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// targetMachineLimits records the limits set for each target machine with
+// SetResourceLimits.
+var targetMachineLimits struct {
+	sync.Mutex
+	m map[TargetMachine]ResourceLimits
+}
+
+// SetResourceLimits sets the limits checked against each module before the
+// target machine generates code for it, with EmitToFile or
+// EmitToMemoryBuffer, which fail with a *ResourceLimitError if one is
+// exceeded. The limits are forgotten when the target machine is disposed.
+func (tm TargetMachine) SetResourceLimits(l ResourceLimits) {
+	targetMachineLimits.Lock()
+	if targetMachineLimits.m == nil {
+		targetMachineLimits.m = make(map[TargetMachine]ResourceLimits)
+	}
+	targetMachineLimits.m[tm] = l
+	targetMachineLimits.Unlock()
+}
+
+// checkLimits checks the module m against the limits set for the target
+// machine.
+func (tm TargetMachine) checkLimits(m Module) error {
+	targetMachineLimits.Lock()
+	l := targetMachineLimits.m[tm]
+	targetMachineLimits.Unlock()
+	return l.Check(m)
+}
+
+// forgetLimits forgets the limits set for the target machine, which is
+// being disposed.
+func (tm TargetMachine) forgetLimits() {
+	targetMachineLimits.Lock()
+	delete(targetMachineLimits.m, tm)
+	targetMachineLimits.Unlock()
+}
@@ -0,0 +1,327 @@
+package llvm
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+)
+
+// LineEntry maps a machine code address to a source position.
+type LineEntry struct {
+	// Section is the name of the section containing the code in a
+	// relocatable object, in which Address is then an offset; it is empty
+	// in executables and shared libraries.
+	Section string
+
+	Address uint64
+	File    string
+	Line    int
+	Column  int
+
+	// EndSequence marks the first address after a sequence of
+	// instructions; its position is not meaningful.
+	EndSequence bool
+}
+
+// LineTable is the DWARF line table of an object file, which maps
+// addresses to source positions.
+type LineTable struct {
+	entries  []LineEntry // sorted by section and address
+	sections int         // the number of sections of code, if relocatable
+}
+
+// ReadLineTable parses the .debug_line section of an ELF, Mach-O or COFF
+// object file, such as one emitted by TargetMachine.EmitToMemoryBuffer for a
+// module with debug information. In a relocatable ELF or COFF object, each
+// entry records the section containing its code, and its address is an
+// offset in that section, to which the load address of the section must be
+// added. Objects have several sections of code when they contain comdat or
+// linkonce functions, are emitted with function sections (see
+// SetFunctionSections), or have code moved by MarkCold.
+func ReadLineTable(obj []byte) (*LineTable, error) {
+	d, sections, err := objectDWARF(obj)
+	if err != nil {
+		return nil, err
+	}
+	t := &LineTable{sections: len(sections)}
+	r := d.Reader()
+	for {
+		cu, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if cu == nil {
+			break
+		}
+		if cu.Tag != dwarf.TagCompileUnit {
+			r.SkipChildren()
+			continue
+		}
+		lr, err := d.LineReader(cu)
+		if err != nil {
+			return nil, err
+		}
+		r.SkipChildren()
+		if lr == nil {
+			continue
+		}
+		var e dwarf.LineEntry
+		var seq *codeSection // the section of the current sequence
+		for {
+			if err := lr.Next(&e); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			entry := LineEntry{Address: e.Address, Line: e.Line, Column: e.Column, EndSequence: e.EndSequence}
+			if e.File != nil {
+				entry.File = e.File.Name
+			}
+			if seq == nil {
+				seq = sectionAt(sections, e.Address)
+			}
+			if seq != nil {
+				entry.Section = seq.name
+				entry.Address -= seq.base
+			}
+			if e.EndSequence {
+				seq = nil
+			}
+			t.entries = append(t.entries, entry)
+		}
+	}
+	sort.Stable(lineEntriesByAddress(t.entries))
+	return t, nil
+}
+
+// codeSection is a section of code in a relocatable object. Its offsets
+// are resolved by objectDWARF to addresses from base, so that sequences
+// of the line table in different sections may be told apart.
+type codeSection struct {
+	name       string
+	base, size uint64
+}
+
+// sectionAt returns the section containing the address addr, or nil.
+func sectionAt(sections []codeSection, addr uint64) *codeSection {
+	for i := range sections {
+		if s := &sections[i]; addr >= s.base && addr < s.base+s.size {
+			return s
+		}
+	}
+	return nil
+}
+
+// objectDWARF returns the DWARF data of an object file in any of the
+// formats supported by the standard library, along with the sections of
+// code of an ELF or COFF relocatable object, which are placed one after
+// the other.
+func objectDWARF(obj []byte) (*dwarf.Data, []codeSection, error) {
+	r := bytes.NewReader(obj)
+	if f, err := elf.NewFile(r); err == nil {
+		if f.Type != elf.ET_REL {
+			d, err := f.DWARF()
+			return d, nil, err
+		}
+		return relocatableELFDWARF(obj, f)
+	}
+	if f, err := macho.NewFile(r); err == nil {
+		d, err := f.DWARF()
+		return d, nil, err
+	}
+	if f, err := pe.NewFile(r); err == nil {
+		if f.Characteristics&pe.IMAGE_FILE_EXECUTABLE_IMAGE != 0 {
+			d, err := f.DWARF()
+			return d, nil, err
+		}
+		return relocatableCOFFDWARF(obj, f)
+	}
+	return nil, nil, errors.New("unrecognized object file format")
+}
+
+var errMalformedObject = errors.New("malformed object file")
+
+// relocatableELFDWARF returns the DWARF data of the relocatable ELF object
+// obj, parsed as f. The standard library resolves the relocations of the
+// debug sections to the values of their symbols, which are offsets in
+// their sections, so the values of symbols in sections of code are first
+// offset by the sections' bases.
+func relocatableELFDWARF(obj []byte, f *elf.File) (*dwarf.Data, []codeSection, error) {
+	var sections []codeSection
+	bases := make(map[elf.SectionIndex]uint64)
+	var next uint64
+	for i, s := range f.Sections {
+		if s.Flags&elf.SHF_EXECINSTR != 0 && s.Size > 0 {
+			bases[elf.SectionIndex(i)] = next
+			sections = append(sections, codeSection{s.Name, next, s.Size})
+			next += s.Size
+		}
+	}
+	if len(sections) < 2 {
+		d, err := f.DWARF()
+		return d, sections, err
+	}
+	obj = append([]byte(nil), obj...)
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_SYMTAB {
+			continue
+		}
+		if s.Offset+s.Size > uint64(len(obj)) {
+			return nil, nil, errMalformedObject
+		}
+		symtab := obj[s.Offset : s.Offset+s.Size]
+		bo := f.ByteOrder
+		if f.Class == elf.ELFCLASS64 {
+			for ; len(symtab) >= elf.Sym64Size; symtab = symtab[elf.Sym64Size:] {
+				if base, ok := bases[elf.SectionIndex(bo.Uint16(symtab[6:]))]; ok {
+					bo.PutUint64(symtab[8:], bo.Uint64(symtab[8:])+base)
+				}
+			}
+		} else {
+			for ; len(symtab) >= elf.Sym32Size; symtab = symtab[elf.Sym32Size:] {
+				if base, ok := bases[elf.SectionIndex(bo.Uint16(symtab[14:]))]; ok {
+					bo.PutUint32(symtab[4:], bo.Uint32(symtab[4:])+uint32(base))
+				}
+			}
+		}
+	}
+	f, err := elf.NewFile(bytes.NewReader(obj))
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := f.DWARF()
+	return d, sections, err
+}
+
+// COFF relocation types of addresses in debug sections.
+const (
+	coffRelocI386Dir32   = 0x6
+	coffRelocAMD64Addr64 = 0x1
+	coffRelocAMD64Addr32 = 0x2
+)
+
+// relocatableCOFFDWARF returns the DWARF data of the relocatable COFF
+// object obj, parsed as f. The standard library does not apply the
+// relocations of debug sections, whose addresses are then offsets in
+// their sections, so those of the line table are applied first, resolving
+// symbols in sections of code from the sections' bases.
+func relocatableCOFFDWARF(obj []byte, f *pe.File) (*dwarf.Data, []codeSection, error) {
+	var sections []codeSection
+	bases := make(map[int16]uint64) // by section number, from 1
+	var next uint64
+	for i, s := range f.Sections {
+		if s.Characteristics&pe.IMAGE_SCN_CNT_CODE != 0 && s.Size > 0 {
+			bases[int16(i+1)] = next
+			sections = append(sections, codeSection{s.Name, next, uint64(s.Size)})
+			next += uint64(s.Size)
+		}
+	}
+	if len(sections) < 2 {
+		d, err := f.DWARF()
+		return d, sections, err
+	}
+	obj = append([]byte(nil), obj...)
+	for _, s := range f.Sections {
+		if s.Name != ".debug_line" {
+			continue
+		}
+		if uint64(s.Offset)+uint64(s.Size) > uint64(len(obj)) {
+			return nil, nil, errMalformedObject
+		}
+		data := obj[s.Offset : s.Offset+s.Size]
+		for _, rel := range s.Relocs {
+			if int(rel.SymbolTableIndex) >= len(f.COFFSymbols) {
+				return nil, nil, errMalformedObject
+			}
+			sym := f.COFFSymbols[rel.SymbolTableIndex]
+			base, ok := bases[sym.SectionNumber]
+			if !ok {
+				continue
+			}
+			addr := base + uint64(sym.Value)
+			width := 4
+			switch {
+			case f.Machine == pe.IMAGE_FILE_MACHINE_AMD64 && rel.Type == coffRelocAMD64Addr64:
+				width = 8
+			case f.Machine == pe.IMAGE_FILE_MACHINE_AMD64 && rel.Type == coffRelocAMD64Addr32:
+			case f.Machine == pe.IMAGE_FILE_MACHINE_I386 && rel.Type == coffRelocI386Dir32:
+			default:
+				continue
+			}
+			off := uint64(rel.VirtualAddress)
+			if off+uint64(width) > uint64(len(data)) {
+				return nil, nil, errMalformedObject
+			}
+			if width == 8 {
+				binary.LittleEndian.PutUint64(data[off:], binary.LittleEndian.Uint64(data[off:])+addr)
+			} else {
+				binary.LittleEndian.PutUint32(data[off:], binary.LittleEndian.Uint32(data[off:])+uint32(addr))
+			}
+		}
+	}
+	f, err := pe.NewFile(bytes.NewReader(obj))
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := f.DWARF()
+	return d, sections, err
+}
+
+type lineEntriesByAddress []LineEntry
+
+func (s lineEntriesByAddress) Len() int      { return len(s) }
+func (s lineEntriesByAddress) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// Less orders entries by section, then address, and the end of a sequence
+// before the start of another at the same address.
+func (s lineEntriesByAddress) Less(i, j int) bool {
+	if s[i].Section != s[j].Section {
+		return s[i].Section < s[j].Section
+	}
+	if s[i].Address != s[j].Address {
+		return s[i].Address < s[j].Address
+	}
+	return s[i].EndSequence && !s[j].EndSequence
+}
+
+// Entries returns the rows of the line table, in order of section and
+// address.
+func (t *LineTable) Entries() []LineEntry {
+	return append([]LineEntry(nil), t.entries...)
+}
+
+// Lookup returns the source position of the instruction at addr, or false
+// if addr is not covered by the table. In a relocatable object, addr is an
+// offset in its section of code; if it has several, Lookup returns false,
+// and LookupSection must be used instead.
+func (t *LineTable) Lookup(addr uint64) (LineEntry, bool) {
+	if t.sections > 1 {
+		return LineEntry{}, false
+	}
+	return lookupLineEntry(t.entries, addr)
+}
+
+// LookupSection returns the source position of the instruction at offset
+// in the named section of code of a relocatable object, or false if it is
+// not covered by the table.
+func (t *LineTable) LookupSection(section string, offset uint64) (LineEntry, bool) {
+	i := sort.Search(len(t.entries), func(i int) bool { return t.entries[i].Section >= section })
+	j := sort.Search(len(t.entries), func(i int) bool { return t.entries[i].Section > section })
+	return lookupLineEntry(t.entries[i:j], offset)
+}
+
+// lookupLineEntry returns the entry of entries, sorted by address, which
+// covers addr.
+func lookupLineEntry(entries []LineEntry, addr uint64) (LineEntry, bool) {
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].Address > addr })
+	if i == 0 || entries[i-1].EndSequence {
+		return LineEntry{}, false
+	}
+	return entries[i-1], true
+}
@@ -0,0 +1,103 @@
+package llvm
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestLineTableLookup(t *testing.T) {
+	entries := []LineEntry{
+		{Address: 0x20, File: "b.go", Line: 7},
+		{Address: 0x28, EndSequence: true},
+		{Address: 0x10, File: "a.go", Line: 3},
+		{Address: 0x14, File: "a.go", Line: 4},
+		{Address: 0x20, EndSequence: true},
+	}
+	sort.Stable(lineEntriesByAddress(entries))
+	lt := &LineTable{entries: entries, sections: 1}
+
+	tests := []struct {
+		addr uint64
+		line int
+		ok   bool
+	}{
+		{0x0f, 0, false},
+		{0x10, 3, true},
+		{0x13, 3, true},
+		{0x14, 4, true},
+		{0x1f, 4, true},
+		{0x20, 7, true}, // the end of one sequence and the start of another
+		{0x27, 7, true},
+		{0x28, 0, false},
+	}
+	for _, test := range tests {
+		e, ok := lt.Lookup(test.addr)
+		if ok != test.ok || e.Line != test.line {
+			t.Errorf("Lookup(%#x) = line %d, %v; want line %d, %v", test.addr, e.Line, ok, test.line, test.ok)
+		}
+	}
+}
+
+func TestLineTableLookupSection(t *testing.T) {
+	entries := []LineEntry{
+		{Section: ".text.g", Address: 0, File: "g.go", Line: 20},
+		{Section: ".text.g", Address: 8, EndSequence: true},
+		{Section: ".text.f", Address: 0, File: "f.go", Line: 10},
+		{Section: ".text.f", Address: 4, File: "f.go", Line: 11},
+		{Section: ".text.f", Address: 8, EndSequence: true},
+	}
+	sort.Stable(lineEntriesByAddress(entries))
+	lt := &LineTable{entries: entries, sections: 2}
+
+	if _, ok := lt.Lookup(0); ok {
+		t.Errorf("Lookup succeeded in an object with two sections of code")
+	}
+	tests := []struct {
+		section string
+		offset  uint64
+		line    int
+		ok      bool
+	}{
+		{".text.f", 0, 10, true},
+		{".text.f", 5, 11, true},
+		{".text.f", 8, 0, false},
+		{".text.g", 3, 20, true},
+		{".text.h", 0, 0, false},
+	}
+	for _, test := range tests {
+		e, ok := lt.LookupSection(test.section, test.offset)
+		if ok != test.ok || e.Line != test.line {
+			t.Errorf("LookupSection(%s, %d) = line %d, %v; want line %d, %v",
+				test.section, test.offset, e.Line, ok, test.line, test.ok)
+		}
+	}
+}
+
+func TestSectionAt(t *testing.T) {
+	sections := []codeSection{{".text", 0, 0x10}, {".text.cold", 0x10, 0x8}}
+	tests := []struct {
+		addr uint64
+		want string
+	}{
+		{0, ".text"},
+		{0xf, ".text"},
+		{0x10, ".text.cold"},
+		{0x17, ".text.cold"},
+		{0x18, ""},
+	}
+	for _, test := range tests {
+		got := ""
+		if s := sectionAt(sections, test.addr); s != nil {
+			got = s.name
+		}
+		if got != test.want {
+			t.Errorf("sectionAt(%#x) = %q, want %q", test.addr, got, test.want)
+		}
+	}
+}
+
+func TestReadLineTableInvalid(t *testing.T) {
+	if _, err := ReadLineTable([]byte("not an object file")); err == nil {
+		t.Error("ReadLineTable succeeded for data that is not an object file")
+	}
+}
@@ -0,0 +1,139 @@
+package llvm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// LinkOptions controls how Link produces an executable or shared library.
+type LinkOptions struct {
+	// Output is the path of the file to produce.
+	Output string
+
+	// Shared produces a shared library rather than an executable. The
+	// TargetMachine must have been created with RelocPIC.
+	Shared bool
+
+	// PIE produces a position independent executable. The TargetMachine
+	// must have been created with RelocPIC.
+	PIE bool
+
+	// Entry, if not empty, is the name of the entry point symbol.
+	Entry string
+
+	// Libraries are linked with -l, searching LibraryPaths and the
+	// system's default directories.
+	Libraries    []string
+	LibraryPaths []string
+
+	// Linker is the compiler driver used to link, "cc" if empty. Args are
+	// passed to it after the objects and libraries.
+	Linker string
+	Args   []string
+
+	// Limits, if set, are checked against each module before any code is
+	// generated, and Link fails with a *ResourceLimitError if one is
+	// exceeded.
+	Limits ResourceLimits
+
+	// Cancelled, if not nil, is called before generating code for each
+	// module and before running the linker; if it returns true, Link
+	// stops and returns ErrCancelled.
+	Cancelled func() bool
+}
+
+// Link generates object code for each of the modules with the target
+// machine tm, and links the objects with the system's compiler driver into
+// an executable or shared library as described by opts. The objects are
+// written into a temporary directory. If output is deterministic (see
+// SetDeterministic), the linker is run in that directory and given the
+// objects' names relative to it, so that paths recorded by the linker do
+// not depend on the directory's random name; Output and LibraryPaths are
+// made absolute, but relative paths in Args are then resolved in the
+// temporary directory.
+func Link(tm TargetMachine, modules []Module, opts LinkOptions) error {
+	for _, m := range modules {
+		if err := opts.Limits.Check(m); err != nil {
+			return err
+		}
+	}
+	dir, err := ioutil.TempDir("", "gollvm")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	var objects []string
+	cancelled := opts.Cancelled
+	if cancelled == nil {
+		cancelled = func() bool { return false }
+	}
+	for i, m := range modules {
+		if cancelled() {
+			return ErrCancelled
+		}
+		obj := fmt.Sprintf("%d.o", i)
+		if err := tm.EmitToFile(m, filepath.Join(dir, obj), ObjectFile); err != nil {
+			return err
+		}
+		objects = append(objects, obj)
+	}
+	if cancelled() {
+		return ErrCancelled
+	}
+	if !Deterministic() {
+		for i, obj := range objects {
+			objects[i] = filepath.Join(dir, obj)
+		}
+		return linkObjects(objects, "", opts)
+	}
+	if opts.Output, err = filepath.Abs(opts.Output); err != nil {
+		return err
+	}
+	paths := make([]string, len(opts.LibraryPaths))
+	for i, path := range opts.LibraryPaths {
+		if paths[i], err = filepath.Abs(path); err != nil {
+			return err
+		}
+	}
+	opts.LibraryPaths = paths
+	return linkObjects(objects, dir, opts)
+}
+
+// linkObjects links the object files with the system's compiler driver, as
+// described by opts, running it in the directory dir, or the current
+// directory if dir is empty.
+func linkObjects(objects []string, dir string, opts LinkOptions) error {
+	args := append([]string{"-o", opts.Output}, objects...)
+	if opts.Shared {
+		args = append(args, "-shared")
+	}
+	if opts.PIE {
+		args = append(args, "-pie")
+	}
+	if opts.Entry != "" {
+		args = append(args, "-Wl,-e,"+opts.Entry)
+	}
+	for _, path := range opts.LibraryPaths {
+		args = append(args, "-L"+path)
+	}
+	for _, lib := range opts.Libraries {
+		args = append(args, "-l"+lib)
+	}
+	args = append(args, opts.Args...)
+
+	linker := opts.Linker
+	if linker == "" {
+		linker = "cc"
+	}
+	cmd := exec.Command(linker, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %v\n%s", linker, err, out)
+	}
+	return nil
+}
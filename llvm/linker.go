@@ -16,7 +16,19 @@ const (
 	LinkerPreserveSource = C.LLVMLinkerPreserveSource
 )
 
+// LinkModules links Src into Dest. If Mode is LinkerDestroySource, the
+// linker may move parts of Src into Dest rather than copying them, leaving
+// Src unusable; an error is returned if Src is owned by another object.
+// In either mode the linker does not delete Src: it is still owned by the
+// caller, who must dispose it. Personalities set with SetPersonality for
+// functions of Src are set for the functions of the same names in Dest
+// which have none.
 func LinkModules(Dest, Src Module, Mode LinkerMode) error {
+	if Mode == LinkerDestroySource {
+		if err := Src.CheckOwnership(); err != nil {
+			return err
+		}
+	}
 	var cmsg *C.char
 	failed := C.LLVMLinkModules(Dest.C, Src.C, C.LLVMLinkerMode(Mode), &cmsg)
 	if failed != 0 {
@@ -24,5 +36,6 @@ func LinkModules(Dest, Src Module, Mode LinkerMode) error {
 		C.LLVMDisposeMessage(cmsg)
 		return err
 	}
+	copyPersonalities(Dest, Src)
 	return nil
 }
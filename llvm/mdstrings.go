@@ -0,0 +1,117 @@
+package llvm
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// MDStringStats describes the use of the metadata string cache while it is
+// enabled.
+type MDStringStats struct {
+	Hits    uint64 // calls to MDString answered from the cache
+	Misses  uint64 // calls to MDString which went to LLVM
+	Strings int    // strings in the cache
+	Bytes   int    // total length of the strings in the cache
+}
+
+// DefaultMDStringCacheLimit is a limit on the number of strings in the
+// metadata string cache suitable for most frontends.
+const DefaultMDStringCacheLimit = 1 << 16
+
+// mdStringCacheEnabled is non-zero if the metadata string cache has been
+// enabled, so that MDString need not take the cache's lock otherwise.
+var mdStringCacheEnabled int32
+
+// mdStrings caches the metadata strings created with MDString, by context.
+var mdStrings struct {
+	sync.Mutex
+	limit int
+	m     map[Context]map[string]Value
+	stats MDStringStats
+}
+
+// SetMDStringCacheLimit enables the metadata string cache, limiting it to
+// n strings in all contexts; if n is zero, the cache is emptied and
+// disabled, as it is initially. LLVM keeps a single copy of each metadata
+// string in a context, but each call to MDString must copy the string to C
+// and look it up, which debug-heavy frontends, creating the same type names
+// and file names repeatedly, may do millions of times. The cache returns
+// repeated strings without either, at the cost of a Go copy of each string
+// it holds, and of a lock shared by all contexts, which serializes calls
+// to MDString from goroutines using different contexts, e.g. those of
+// EmitParallel. Once full, strings not in the cache are no longer added.
+func SetMDStringCacheLimit(n int) {
+	mdStrings.Lock()
+	defer mdStrings.Unlock()
+	mdStrings.limit = n
+	if n == 0 {
+		atomic.StoreInt32(&mdStringCacheEnabled, 0)
+		mdStrings.m = nil
+		mdStrings.stats.Strings = 0
+		mdStrings.stats.Bytes = 0
+	} else {
+		atomic.StoreInt32(&mdStringCacheEnabled, 1)
+	}
+}
+
+// MDStringCacheStats returns the statistics of the metadata string cache.
+func MDStringCacheStats() MDStringStats {
+	mdStrings.Lock()
+	defer mdStrings.Unlock()
+	return mdStrings.stats
+}
+
+// cachedMDString returns the metadata string str in the context c from the
+// cache, or a nil Value if it is not there.
+func cachedMDString(c Context, str string) Value {
+	if atomic.LoadInt32(&mdStringCacheEnabled) == 0 {
+		return Value{}
+	}
+	mdStrings.Lock()
+	defer mdStrings.Unlock()
+	v, ok := mdStrings.m[c][str]
+	if ok {
+		mdStrings.stats.Hits++
+	} else {
+		mdStrings.stats.Misses++
+	}
+	return v
+}
+
+// cacheMDString adds the metadata string v, holding str, in the context c to
+// the cache, if it is not full.
+func cacheMDString(c Context, str string, v Value) {
+	if atomic.LoadInt32(&mdStringCacheEnabled) == 0 {
+		return
+	}
+	mdStrings.Lock()
+	defer mdStrings.Unlock()
+	if mdStrings.stats.Strings >= mdStrings.limit {
+		return
+	}
+	if mdStrings.m == nil {
+		mdStrings.m = make(map[Context]map[string]Value)
+	}
+	strs := mdStrings.m[c]
+	if strs == nil {
+		strs = make(map[string]Value)
+		mdStrings.m[c] = strs
+	}
+	if _, ok := strs[str]; !ok {
+		strs[str] = v
+		mdStrings.stats.Strings++
+		mdStrings.stats.Bytes += len(str)
+	}
+}
+
+// forgetMDStrings removes the strings of the context c, which is being
+// disposed, from the cache.
+func forgetMDStrings(c Context) {
+	mdStrings.Lock()
+	defer mdStrings.Unlock()
+	for str := range mdStrings.m[c] {
+		mdStrings.stats.Strings--
+		mdStrings.stats.Bytes -= len(str)
+	}
+	delete(mdStrings.m, c)
+}
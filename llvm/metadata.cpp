@@ -0,0 +1,26 @@
+#include <llvm/LLVMContext.h>
+#include <llvm/Metadata.h>
+#include <llvm/ADT/ArrayRef.h>
+#include <vector>
+
+extern "C" llvm::MDNode *createSelfReferentialMDNode(llvm::LLVMContext *ctx,
+                                                     llvm::Value **ops,
+                                                     unsigned n) {
+	llvm::MDNode *temp = llvm::MDNode::getTemporary(*ctx, llvm::ArrayRef<llvm::Value*>());
+	std::vector<llvm::Value*> vals;
+	vals.push_back(temp);
+	vals.insert(vals.end(), ops, ops + n);
+	llvm::MDNode *node = llvm::MDNode::get(*ctx, vals);
+	temp->replaceAllUsesWith(node);
+	llvm::MDNode::deleteTemporary(temp);
+	return node;
+}
+
+extern "C" llvm::MDNode *createTemporaryMDNode(llvm::LLVMContext *ctx) {
+	return llvm::MDNode::getTemporary(*ctx, llvm::ArrayRef<llvm::Value*>());
+}
+
+extern "C" void replaceTemporaryMDNode(llvm::MDNode *temp, llvm::Value *node) {
+	temp->replaceAllUsesWith(node);
+	llvm::MDNode::deleteTemporary(temp);
+}
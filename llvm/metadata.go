@@ -0,0 +1,168 @@
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+
+extern LLVMValueRef createSelfReferentialMDNode(LLVMContextRef, LLVMValueRef *, unsigned);
+extern LLVMValueRef createTemporaryMDNode(LLVMContextRef);
+extern void replaceTemporaryMDNode(LLVMValueRef, LLVMValueRef);
+*/
+import "C"
+import (
+	"fmt"
+	"sync"
+)
+
+// BranchWeights creates !prof metadata giving the relative likelihood of
+// each successor of a conditional branch or switch instruction, in order.
+func (c Context) BranchWeights(weights ...uint32) Value {
+	vals := make([]Value, len(weights)+1)
+	vals[0] = c.MDString("branch_weights")
+	for i, w := range weights {
+		vals[i+1] = ConstInt(c.Int32Type(), uint64(w), false)
+	}
+	return c.MDNode(vals)
+}
+
+// SetBranchWeights attaches branch weights to the branch or switch
+// instruction br. See Context.BranchWeights.
+func SetBranchWeights(br Value, weights ...uint32) {
+	c := br.Type().Context()
+	br.SetMetadata(c.MDKindID("prof"), c.BranchWeights(weights...))
+}
+
+// Names of loop hints, for Context.LoopHint.
+const (
+	LoopUnrollDisable   = "llvm.loop.unroll.disable"
+	LoopUnrollEnable    = "llvm.loop.unroll.enable"
+	LoopUnrollCount     = "llvm.loop.unroll.count"     // i32 count
+	LoopVectorizeEnable = "llvm.loop.vectorize.enable" // i1 enable
+	LoopVectorizeWidth  = "llvm.loop.vectorize.width"  // i32 width
+)
+
+// LoopHint creates a loop hint with the given name and arguments, to be
+// included in a loop ID created with Context.LoopID.
+func (c Context) LoopHint(name string, args ...Value) Value {
+	return c.MDNode(append([]Value{c.MDString(name)}, args...))
+}
+
+// LoopID creates a distinct, self-referential !llvm.loop node holding the
+// given hints.
+func (c Context) LoopID(hints ...Value) Value {
+	var ptr *C.LLVMValueRef
+	if len(hints) > 0 {
+		ptr = llvmValueRefPtr(&hints[0])
+	}
+	return Value{C.createSelfReferentialMDNode(c.C, ptr, C.unsigned(len(hints)))}
+}
+
+// temporaryMDNode returns a placeholder metadata node, which may be used as
+// an operand of other nodes until it is replaced with replaceTemporaryMDNode.
+// See MDNode::getTemporary.
+func (c Context) temporaryMDNode() Value {
+	return Value{C.createTemporaryMDNode(c.C)}
+}
+
+// replaceTemporaryMDNode replaces each use of the node temp, created by
+// temporaryMDNode, with node, and deletes temp.
+func replaceTemporaryMDNode(temp, node Value) {
+	C.replaceTemporaryMDNode(temp.C, node.C)
+}
+
+// SetLoopID attaches the loop ID created by Context.LoopID to backedge, the
+// branch instruction at the end of the loop's latch block.
+//
+// The loop optimizations of LLVM 3.2 do not read loop hints. They are
+// preserved in the IR and bitcode, to be honoured by later versions of
+// LLVM, but until then unrolling and vectorization can only be controlled
+// for the module as a whole.
+func SetLoopID(backedge, loopID Value) {
+	c := backedge.Type().Context()
+	backedge.SetMetadata(c.MDKindID("llvm.loop"), loopID)
+}
+
+// AliasDomain creates scopes for memory accesses which are known not to
+// alias one another, such as accesses to the distinct backing arrays of
+// two slices.
+//
+// LLVM 3.2 has no alias.scope or noalias metadata, so scopes are encoded as
+// sibling nodes in a type-based alias analysis (TBAA) tree of their own:
+// accesses in different scopes of a domain are assumed not to alias, while
+// untagged accesses may alias anything. An instruction has at most one TBAA
+// tag, so an access can be placed in only one scope, not a list of them,
+// and scopes cannot be combined with type-based tags on the same
+// instruction. The TBAA pass must be run (see
+// PassManager.AddTypeBasedAliasAnalysisPass) for the scopes to be used; the
+// standard pipelines include it.
+type AliasDomain struct {
+	c      Context
+	root   Value
+	label  string // the name of the root node
+	scopes int    // the number of scopes created
+}
+
+// aliasDomains counts the alias domains created, to give each a distinct
+// root node.
+var aliasDomains struct {
+	sync.Mutex
+	n int
+}
+
+// NewAliasDomain creates a new alias domain, distinct from all others,
+// including those with the same name; name only labels its metadata.
+func (c Context) NewAliasDomain(name string) *AliasDomain {
+	aliasDomains.Lock()
+	aliasDomains.n++
+	id := aliasDomains.n
+	aliasDomains.Unlock()
+	label := fmt.Sprintf("%s.domain%d", name, id)
+	return &AliasDomain{c: c, root: c.MDNode([]Value{c.MDString(label)}), label: label}
+}
+
+// Scope returns a new scope in the domain d, distinct from all other
+// scopes, so that accesses tagged with it are assumed not to alias those in
+// the domain's other scopes. Each call creates a new scope, even for the
+// same name, which only labels its metadata; a frontend should create the
+// scopes of each function afresh, since reusing a scope in another function
+// would assert that unrelated accesses there do not alias.
+func (d *AliasDomain) Scope(name string) Value {
+	d.scopes++
+	label := fmt.Sprintf("%s.%s.scope%d", d.label, name, d.scopes)
+	return d.c.MDNode([]Value{d.c.MDString(label), d.root})
+}
+
+// SetAliasScope places the load or store instruction inst in scope, which
+// was created by AliasDomain.Scope.
+func SetAliasScope(inst, scope Value) {
+	inst.SetMetadata(inst.Type().Context().MDKindID("tbaa"), scope)
+}
+
+// SetInvariantLoad attaches !invariant.load metadata to the load
+// instruction load, stating that the memory it reads never changes while
+// the program can observe it.
+//
+// LLVM 3.2 does not read !invariant.load, which is preserved for later
+// versions, nor does it have the dereferenceable attributes. Within a
+// function, immutable memory can be described to LLVM 3.2 with
+// Builder.CreateInvariantStart.
+func SetInvariantLoad(load Value) {
+	c := load.Type().Context()
+	load.SetMetadata(c.MDKindID("invariant.load"), c.MDNode(nil))
+}
+
+// SetRange attaches !range metadata to the load instruction load, stating
+// that the loaded integer lies in one of the half-open ranges [lo, hi),
+// given as pairs of constants of the loaded type in bounds. For example, a
+// length may be described as non-negative with the bounds 0 and the
+// minimum signed value, the range wrapping around to exclude it.
+//
+// LLVM 3.2 has neither !nonnull metadata nor the nonnull attribute. The
+// alignment of pointer parameters is set with Value.SetParamAlignment, and
+// that of loads with Value.SetInstrAlignment.
+func SetRange(load Value, bounds ...Value) {
+	if len(bounds) == 0 || len(bounds)%2 != 0 {
+		panic("range bounds must be non-empty pairs")
+	}
+	c := load.Type().Context()
+	load.SetMetadata(c.MDKindID("range"), c.MDNode(bounds))
+}
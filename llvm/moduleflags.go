@@ -0,0 +1,109 @@
+package llvm
+
+import "fmt"
+
+// ModuleFlagBehavior specifies how the linker merges a module flag with
+// the flag of the same key in another module.
+// See Module::ModFlagBehavior.
+type ModuleFlagBehavior int
+
+const (
+	// ModuleFlagError causes linking to fail if the values differ.
+	ModuleFlagError ModuleFlagBehavior = iota + 1
+
+	// ModuleFlagWarning causes a warning if the values differ, and the
+	// value of the first module to be used.
+	ModuleFlagWarning
+
+	// ModuleFlagRequire requires the linked module to have a flag whose
+	// key and value are those of the metadata pair value.
+	ModuleFlagRequire
+
+	// ModuleFlagOverride uses the value of this flag, whatever that of
+	// the other module.
+	ModuleFlagOverride
+)
+
+// Keys of the module flags set by the helpers below, as clang sets them.
+// The code generator of LLVM 3.2 reads none of them; they are recorded for
+// later versions of LLVM, which do, and are checked when modules are
+// linked.
+const (
+	PICLevelKey         = "PIC Level"
+	PIELevelKey         = "PIE Level"
+	WcharSizeKey        = "wchar_size"
+	DwarfVersionKey     = "Dwarf Version"
+	DebugInfoVersionKey = "Debug Info Version"
+)
+
+const moduleFlagsName = "llvm.module.flags"
+
+// ModuleFlag returns the behavior and value of the module flag key, or
+// false if the module has no such flag.
+func (m Module) ModuleFlag(key string) (ModuleFlagBehavior, Value, bool) {
+	for _, flag := range m.NamedMetadataOperands(moduleFlagsName) {
+		ops := flag.MDNodeOperands()
+		if len(ops) == 3 && ops[1].MDStringValue() == key {
+			return ModuleFlagBehavior(ops[0].ZExtValue()), ops[2], true
+		}
+	}
+	return 0, Value{}, false
+}
+
+// AddModuleFlag adds to llvm.module.flags the flag key, whose value is a
+// constant or metadata node, to be merged with other modules' flags as
+// specified by behavior. The verifier rejects a module with two flags of
+// the same key, so an error is returned if the module already has the flag
+// with a different behavior or value; adding an identical flag again has
+// no effect.
+func (m Module) AddModuleFlag(behavior ModuleFlagBehavior, key string, value Value) error {
+	if b, v, ok := m.ModuleFlag(key); ok {
+		if b != behavior || v != value {
+			return fmt.Errorf("module flag %q is already set", key)
+		}
+		return nil
+	}
+	c := m.Context()
+	flag := c.MDNode([]Value{
+		ConstInt(c.Int32Type(), uint64(behavior), false),
+		c.MDString(key),
+		value,
+	})
+	m.AddNamedMetadataOperand(moduleFlagsName, flag)
+	return nil
+}
+
+func (m Module) addModuleFlagInt(behavior ModuleFlagBehavior, key string, value int) error {
+	return m.AddModuleFlag(behavior, key, ConstInt(m.Context().Int32Type(), uint64(value), false))
+}
+
+// SetPICLevel records that the module is compiled as position independent
+// code of the given level, 1 for -fpic and 2 for -fPIC.
+func (m Module) SetPICLevel(level int) error {
+	return m.addModuleFlagInt(ModuleFlagError, PICLevelKey, level)
+}
+
+// SetPIELevel records that the module is compiled for a position
+// independent executable of the given level, 1 for -fpie and 2 for -fPIE.
+func (m Module) SetPIELevel(level int) error {
+	return m.addModuleFlagInt(ModuleFlagError, PIELevelKey, level)
+}
+
+// SetWcharSize records the size in bytes of wchar_t in the module, which
+// ARM EABI objects record in their build attributes.
+func (m Module) SetWcharSize(size int) error {
+	return m.addModuleFlagInt(ModuleFlagError, WcharSizeKey, size)
+}
+
+// SetDwarfVersion records the version of DWARF debug information to emit
+// for the module.
+func (m Module) SetDwarfVersion(version int) error {
+	return m.addModuleFlagInt(ModuleFlagWarning, DwarfVersionKey, version)
+}
+
+// SetDebugInfoVersion records the version of the debug information
+// metadata in the module, so that later versions of LLVM can discard
+// debug information in a format they do not understand.
+func (m Module) SetDebugInfoVersion(version int) error {
+	return m.addModuleFlagInt(ModuleFlagWarning, DebugInfoVersionKey, version)
+}
@@ -0,0 +1,325 @@
+package llvm
+
+import "fmt"
+
+// X86Feature is a set of x86 CPU features, as detected at run time by the
+// dispatcher of a multiversioned function.
+type X86Feature uint32
+
+const (
+	X86SSE2 X86Feature = 1 << iota
+	X86SSE42
+	X86AVX
+	X86FMA
+	X86AVX2
+	X86AVX512F
+)
+
+// FunctionVersion describes a version of a function specialized for CPUs
+// with particular features.
+type FunctionVersion struct {
+	// Features are the target features with which the version is to be
+	// compiled, e.g. "+avx2,+fma".
+	Features string
+
+	// Requires is the set of CPU features which must be present for the
+	// version to be used.
+	Requires X86Feature
+}
+
+// MultiVersionX86 specializes the function name, defined in the module m,
+// for x86 CPUs with different features. It returns a module for each
+// version, a copy of m defining only the function, as name.v1, name.v2 and
+// so on, to be compiled by a TargetMachine with the version's features and
+// linked with m. In m, the function's body is moved to the internal
+// function name.default, and replaced by a dispatcher which, on its first
+// call, detects the CPU's features with cpuid, selects the first version
+// whose requirements are met, or the default, and calls it, as do later
+// calls. So the versions should be ordered from the most to the least
+// demanding. The choice is stored with release and loaded with acquire
+// ordering, so the dispatcher may be called from several threads at once.
+//
+// LLVM 3.2 has no per-function target features, nor ifuncs, so versions
+// must be compiled separately, and are dispatched to through a function
+// pointer. The function may call other functions of m, and refer to its
+// constant globals, but not to internal global variables, which cannot be
+// shared with the copies; those used only by code the function does not
+// reach are left out of the copies. On 32-bit x86, cpuid clobbers ebx,
+// which position independent code reserves, so m must then not be compiled
+// as such.
+func MultiVersionX86(m Module, name string, versions []FunctionVersion) ([]Module, error) {
+	f := m.NamedFunction(name)
+	if f.IsNil() || f.IsDeclaration() {
+		return nil, fmt.Errorf("module does not define function %s", name)
+	}
+	ft := f.Type().ElementType()
+	if ft.IsFunctionVarArg() {
+		return nil, fmt.Errorf("variadic function %s cannot be multiversioned", name)
+	}
+
+	modules := make([]Module, len(versions))
+	impls := make([]Value, len(versions))
+	for i := range versions {
+		vname := fmt.Sprintf("%s.v%d", name, i+1)
+		c := m.Clone()
+		if err := extractFunctionVersion(c, name, vname); err != nil {
+			c.Dispose()
+			for _, c := range modules[:i] {
+				c.Dispose()
+			}
+			return nil, err
+		}
+		modules[i] = c
+		impls[i] = AddFunction(m, vname, ft)
+		impls[i].SetFunctionCallConv(f.FunctionCallConv())
+		impls[i].CopyAttributes(f)
+	}
+
+	// Move the body to name.default, and make f the dispatcher.
+	params := ft.ParamTypes()
+	identity := make([]int, len(params))
+	for i := range identity {
+		identity[i] = i
+	}
+	def := CloneFunctionWithParams(f, name+".default", params, identity)
+	def.SetLinkage(InternalLinkage)
+	linkage := f.Linkage()
+	f.DeleteBody()
+	f.SetLinkage(linkage)
+
+	ctx := m.Context()
+	fptr := PointerType(ft, 0)
+	slot := AddGlobal(m, fptr, name+".impl")
+	slot.SetLinkage(InternalLinkage)
+	slot.SetInitializer(ConstNull(fptr))
+	td := NewTargetData(m.DataLayout())
+	align := td.ABITypeAlignment(fptr)
+	td.Dispose()
+	slot.SetAlignment(align)
+
+	b := ctx.NewBuilder()
+	defer b.Dispose()
+	entry := AddBasicBlock(f, "entry")
+	resolve := AddBasicBlock(f, "resolve")
+	call := AddBasicBlock(f, "call")
+	b.SetInsertPointAtEnd(entry)
+	// Threads may call f for the first time at once; the slot is accessed
+	// atomically, so that a thread which sees another's choice sees the
+	// version it chose.
+	impl := b.CreateLoad(slot, "impl")
+	impl.SetInstrAlignment(align)
+	impl.SetOrdering(Acquire)
+	b.CreateCondBr(b.CreateIsNull(impl, ""), resolve, call)
+
+	b.SetInsertPointAtEnd(resolve)
+	i32 := ctx.Int32Type()
+	cpu := b.CreateCall(x86CPUFeatures(m), nil, "cpu")
+	chosen := def
+	for i := len(versions) - 1; i >= 0; i-- {
+		req := ConstInt(i32, uint64(versions[i].Requires), false)
+		ok := b.CreateICmp(IntEQ, b.CreateAnd(cpu, req, ""), req, "")
+		chosen = b.CreateSelect(ok, impls[i], chosen, "")
+	}
+	store := b.CreateStore(chosen, slot)
+	store.SetInstrAlignment(align)
+	store.SetOrdering(Release)
+	b.CreateBr(call)
+
+	b.SetInsertPointAtEnd(call)
+	callee := b.CreatePHI(fptr, "")
+	callee.AddIncoming([]Value{impl, chosen}, []BasicBlock{entry, resolve})
+	result := b.CreateCall(callee, f.Params(), "")
+	result.SetTailCall(true)
+	result.SetInstructionCallConv(f.FunctionCallConv())
+	result.CopyAttributes(f)
+	if ft.ReturnType().TypeKind() == VoidTypeKind {
+		b.CreateRetVoid()
+	} else {
+		b.CreateRet(result)
+	}
+	return modules, nil
+}
+
+// extractFunctionVersion reduces the module c, a copy of the module
+// containing the function name, to a definition of the function, renamed
+// vname, the functions and constants with local linkage it reaches, and
+// declarations of the external functions and variables it uses.
+func extractFunctionVersion(c Module, name, vname string) error {
+	c.SetInlineAsm("")
+	f := c.NamedFunction(name)
+	reached := reachableLocals(f)
+	f.SetName(vname)
+	f.SetLinkage(ExternalLinkage)
+
+	// Local functions and variables which f does not reach are removed,
+	// once their bodies and initializers, which may refer to each other,
+	// have been dropped.
+	var unreached []Value
+	for g := c.FirstFunction(); !g.IsNil(); g = NextFunction(g) {
+		switch {
+		case g == f || g.IsDeclaration() || reached[g]:
+		case isLocalLinkage(g.Linkage()):
+			g.DeleteBody()
+			unreached = append(unreached, g)
+		default:
+			g.DeleteBody()
+			g.SetLinkage(ExternalLinkage)
+		}
+	}
+	for g := c.FirstGlobal(); !g.IsNil(); {
+		next := NextGlobal(g)
+		switch {
+		case g.Linkage() == AppendingLinkage:
+			// llvm.global_ctors and the like belong to the original.
+			g.EraseFromParentAsGlobal()
+		case isLocalLinkage(g.Linkage()):
+			if !reached[g] {
+				g.SetInitializer(ConstNull(g.Type().ElementType()))
+				unreached = append(unreached, g)
+			} else if !g.IsGlobalConstant() {
+				return fmt.Errorf("function %s uses internal variable %s", name, g.Name())
+			}
+		case !g.IsDeclaration():
+			g.SetInitializer(Value{})
+			g.SetLinkage(ExternalLinkage)
+		}
+		g = next
+	}
+	for _, g := range unreached {
+		if g.IsAFunction().IsNil() {
+			g.EraseFromParentAsGlobal()
+		} else {
+			g.EraseFromParentAsFunction()
+		}
+	}
+	return nil
+}
+
+// reachableLocals returns the set of functions and global variables with
+// local linkage to which the function f refers, directly or through the
+// bodies of local functions, the initializers of local variables and the
+// constant expressions it reaches.
+func reachableLocals(f Value) map[Value]bool {
+	reached := make(map[Value]bool)
+	visited := map[Value]bool{f: true}
+	work := []Value{f}
+	var visit func(v Value)
+	visit = func(v Value) {
+		if v.IsNil() || visited[v] {
+			return
+		}
+		visited[v] = true
+		switch {
+		case !v.IsAFunction().IsNil():
+			if isLocalLinkage(v.Linkage()) && !v.IsDeclaration() {
+				reached[v] = true
+				work = append(work, v)
+			}
+		case !v.IsAGlobalVariable().IsNil():
+			if isLocalLinkage(v.Linkage()) {
+				reached[v] = true
+				visit(v.Initializer())
+			}
+		case !v.IsAConstant().IsNil():
+			for i := 0; i < v.OperandsCount(); i++ {
+				visit(v.Operand(i))
+			}
+		}
+	}
+	for len(work) > 0 {
+		g := work[len(work)-1]
+		work = work[:len(work)-1]
+		for bb := g.FirstBasicBlock(); !bb.IsNil(); bb = NextBasicBlock(bb) {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = NextInstruction(inst) {
+				for i := 0; i < inst.OperandsCount(); i++ {
+					visit(inst.Operand(i))
+				}
+			}
+		}
+	}
+	return reached
+}
+
+func isLocalLinkage(l Linkage) bool {
+	switch l {
+	case InternalLinkage, PrivateLinkage, LinkerPrivateLinkage, LinkerPrivateWeakLinkage:
+		return true
+	}
+	return false
+}
+
+// x86CPUFeatures returns the internal function of the module m which
+// detects the features of the CPU with cpuid, returning an i32 X86Feature
+// set, defining it if necessary.
+func x86CPUFeatures(m Module) Value {
+	const name = "gollvm.x86.cpufeatures"
+	if f := m.NamedFunction(name); !f.IsNil() {
+		return f
+	}
+	ctx := m.Context()
+	i32 := ctx.Int32Type()
+	f := AddFunction(m, name, FunctionType(i32, nil, false))
+	f.SetLinkage(InternalLinkage)
+	f.AddFunctionAttr(NoUnwindAttribute)
+
+	regs := ctx.StructType([]Type{i32, i32, i32, i32}, false)
+	cpuid := InlineAsm(FunctionType(regs, []Type{i32, i32}, false), "cpuid",
+		"={ax},={bx},={cx},={dx},{ax},{cx},~{dirflag},~{fpsr},~{flags}", false, false)
+	xgetbv := InlineAsm(FunctionType(ctx.StructType([]Type{i32, i32}, false), []Type{i32}, false), "xgetbv",
+		"={ax},={dx},{cx},~{dirflag},~{fpsr},~{flags}", false, false)
+	c := func(n uint64) Value { return ConstInt(i32, n, false) }
+
+	b := ctx.NewBuilder()
+	defer b.Dispose()
+	entry := AddBasicBlock(f, "entry")
+	xcr := AddBasicBlock(f, "xgetbv")
+	leaf7 := AddBasicBlock(f, "leaf7")
+	cpuid7 := AddBasicBlock(f, "cpuid7")
+	done := AddBasicBlock(f, "done")
+
+	b.SetInsertPointAtEnd(entry)
+	maxLeaf := b.CreateExtractValue(b.CreateCall(cpuid, []Value{c(0), c(0)}, ""), 0, "maxleaf")
+	leaf1 := b.CreateCall(cpuid, []Value{c(1), c(0)}, "")
+	ecx1 := b.CreateExtractValue(leaf1, 2, "ecx1")
+	edx1 := b.CreateExtractValue(leaf1, 3, "edx1")
+	has := func(v Value, bits uint64) Value {
+		return b.CreateICmp(IntEQ, b.CreateAnd(v, c(bits), ""), c(bits), "")
+	}
+	b.CreateCondBr(has(ecx1, 1<<27), xcr, leaf7) // OSXSAVE
+
+	b.SetInsertPointAtEnd(xcr)
+	xcr0x := b.CreateExtractValue(b.CreateCall(xgetbv, []Value{c(0)}, ""), 0, "")
+	b.CreateBr(leaf7)
+
+	b.SetInsertPointAtEnd(leaf7)
+	xcr0 := b.CreatePHI(i32, "xcr0")
+	xcr0.AddIncoming([]Value{c(0), xcr0x}, []BasicBlock{entry, xcr})
+	b.CreateCondBr(b.CreateICmp(IntUGE, maxLeaf, c(7), ""), cpuid7, done)
+
+	b.SetInsertPointAtEnd(cpuid7)
+	ebx7x := b.CreateExtractValue(b.CreateCall(cpuid, []Value{c(7), c(0)}, ""), 1, "")
+	b.CreateBr(done)
+
+	b.SetInsertPointAtEnd(done)
+	ebx7 := b.CreatePHI(i32, "ebx7")
+	ebx7.AddIncoming([]Value{c(0), ebx7x}, []BasicBlock{leaf7, cpuid7})
+	avx := b.CreateAnd(has(ecx1, 1<<28), has(xcr0, 0x6), "") // XMM and YMM state
+	flags := []struct {
+		feature X86Feature
+		present Value
+	}{
+		{X86SSE2, has(edx1, 1<<26)},
+		{X86SSE42, has(ecx1, 1<<20)},
+		{X86AVX, avx},
+		{X86FMA, b.CreateAnd(avx, has(ecx1, 1<<12), "")},
+		{X86AVX2, b.CreateAnd(avx, has(ebx7, 1<<5), "")},
+		{X86AVX512F, b.CreateAnd(has(ebx7, 1<<16), has(xcr0, 0xe6), "")}, // and opmask, ZMM state
+	}
+	mask := c(0)
+	for _, flag := range flags {
+		bit := b.CreateSelect(flag.present, c(uint64(flag.feature)), c(0), "")
+		mask = b.CreateOr(mask, bit, "")
+	}
+	b.CreateRet(mask)
+	return f
+}
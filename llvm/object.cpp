@@ -0,0 +1,20 @@
+#include <llvm/ADT/StringRef.h>
+#include <llvm/Object/ObjectFile.h>
+#include <llvm/Support/MemoryBuffer.h>
+#include <stdint.h>
+
+extern "C" llvm::MemoryBuffer *createMemoryBufferCopy(const char *data,
+                                                      size_t len,
+                                                      const char *name) {
+	return llvm::MemoryBuffer::getMemBufferCopy(llvm::StringRef(data, len), name);
+}
+
+// The C API's symbol iterators wrap an llvm::object::symbol_iterator.
+extern "C" int getSymbolInfo(llvm::object::symbol_iterator *si,
+                             uint32_t *flags, int *type) {
+	llvm::object::SymbolRef::Type t;
+	if ((*si)->getFlags(*flags) || (*si)->getType(t))
+		return 1;
+	*type = t;
+	return 0;
+}
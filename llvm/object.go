@@ -0,0 +1,103 @@
+package llvm
+
+/*
+#include <llvm-c/Object.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+extern LLVMMemoryBufferRef createMemoryBufferCopy(const char *, size_t, const char *);
+extern int getSymbolInfo(LLVMSymbolIteratorRef, uint32_t *, int *);
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+)
+
+// SymbolKind classifies a symbol in an object file.
+type SymbolKind int
+
+// These correspond to llvm::object::SymbolRef::Type.
+const (
+	UnknownSymbol SymbolKind = iota
+	DataSymbol
+	DebugSymbol
+	FileSymbol
+	FunctionSymbol
+	OtherSymbol
+)
+
+// Symbol flags, from llvm::object::SymbolRef::Flags.
+const (
+	symbolUndefined      = 1 << 0
+	symbolGlobal         = 1 << 1
+	symbolWeak           = 1 << 2
+	symbolCommon         = 1 << 5
+	symbolFormatSpecific = 1 << 31
+)
+
+// ObjectSymbol describes a symbol in an object file.
+type ObjectSymbol struct {
+	Name string
+	Kind SymbolKind
+
+	Undefined bool // Referenced but not defined by the object.
+	Global    bool // Visible to other objects.
+	Weak      bool // May be overridden by a strong definition.
+	Common    bool // Tentative definition, merged by the linker.
+
+	Size uint64
+}
+
+// NewMemoryBufferFromBytes creates a MemoryBuffer holding a copy of data.
+func NewMemoryBufferFromBytes(data []byte, name string) MemoryBuffer {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	var p *C.char
+	if len(data) > 0 {
+		p = (*C.char)(unsafe.Pointer(&data[0]))
+	}
+	return MemoryBuffer{C.createMemoryBufferCopy(p, C.size_t(len(data)), cname)}
+}
+
+// ObjectSymbols lists the symbols of an object file, such as one emitted
+// by TargetMachine.EmitToMemoryBuffer, in the order they appear in its
+// symbol table. Symbols specific to the object file format, such as
+// section symbols, are omitted. The defined global symbols may be used as
+// an ArchiveMember's Symbols; the undefined ones must be provided by other
+// objects or libraries at link time.
+func ObjectSymbols(data []byte) ([]ObjectSymbol, error) {
+	// The object file takes ownership of the buffer, unless it cannot be
+	// created.
+	buf := NewMemoryBufferFromBytes(data, "")
+	obj := C.LLVMCreateObjectFile(buf.C)
+	if obj == nil {
+		buf.Dispose()
+		return nil, errors.New("unrecognized object file format")
+	}
+	defer C.LLVMDisposeObjectFile(obj)
+
+	var syms []ObjectSymbol
+	si := C.LLVMGetSymbols(obj)
+	defer C.LLVMDisposeSymbolIterator(si)
+	for ; C.LLVMIsSymbolIteratorAtEnd(obj, si) == 0; C.LLVMMoveToNextSymbol(si) {
+		var flags C.uint32_t
+		var kind C.int
+		if C.getSymbolInfo(si, &flags, &kind) != 0 {
+			return nil, errors.New("malformed symbol table")
+		}
+		if flags&symbolFormatSpecific != 0 {
+			continue
+		}
+		syms = append(syms, ObjectSymbol{
+			Name:      C.GoString(C.LLVMGetSymbolName(si)),
+			Kind:      SymbolKind(kind),
+			Undefined: flags&symbolUndefined != 0,
+			Global:    flags&symbolGlobal != 0,
+			Weak:      flags&symbolWeak != 0,
+			Common:    flags&symbolCommon != 0,
+			Size:      uint64(C.LLVMGetSymbolSize(si)),
+		})
+	}
+	return syms, nil
+}
@@ -0,0 +1,62 @@
+package llvm
+
+// Later versions of LLVM replace typed pointers with a single opaque
+// pointer type, so the type of the value loaded from a pointer, or of the
+// function called through it, can no longer be found from the pointer, and
+// must be given to the builder explicitly, as in LLVMBuildLoad2,
+// LLVMBuildGEP2 and LLVMBuildCall2. Code written with the methods below,
+// which take the type explicitly, works the same way with LLVM 3.2's typed
+// pointers: pointers whose element type differs from the given type are
+// bitcast to a pointer to it, in the same address space, so that the
+// pointee types recorded by the rest of the frontend need not be exact.
+
+// pointerTo returns the pointer p, bitcast if necessary to a pointer to t.
+func (b Builder) pointerTo(p Value, t Type) Value {
+	pt := p.Type()
+	if pt.ElementType() == t {
+		return p
+	}
+	return b.CreateBitCast(p, PointerType(t, pt.PointerAddressSpace()), "")
+}
+
+// CreateLoad2 creates a load of a value of type t from the pointer p.
+func (b Builder) CreateLoad2(t Type, p Value, name string) Value {
+	return b.CreateLoad(b.pointerTo(p, t), name)
+}
+
+// CreateGEP2 creates a getelementptr instruction indexing the pointer p as
+// a pointer to t.
+func (b Builder) CreateGEP2(t Type, p Value, indices []Value, name string) Value {
+	return b.CreateGEP(b.pointerTo(p, t), indices, name)
+}
+
+// CreateInBoundsGEP2 creates an inbounds getelementptr instruction indexing
+// the pointer p as a pointer to t.
+func (b Builder) CreateInBoundsGEP2(t Type, p Value, indices []Value, name string) Value {
+	return b.CreateInBoundsGEP(b.pointerTo(p, t), indices, name)
+}
+
+// CreateStructGEP2 creates a getelementptr instruction computing the
+// address of field i of the struct of type t pointed to by p.
+func (b Builder) CreateStructGEP2(t Type, p Value, i int, name string) Value {
+	return b.CreateStructGEP(b.pointerTo(p, t), i, name)
+}
+
+// CreateCall2 creates a call to fn, a function or pointer to a function,
+// of the function type ft.
+func (b Builder) CreateCall2(ft Type, fn Value, args []Value, name string) Value {
+	return b.CreateCall(b.pointerTo(fn, ft), args, name)
+}
+
+// CreateInvoke2 creates an invoke of fn, a function or pointer to a
+// function, of the function type ft.
+func (b Builder) CreateInvoke2(ft Type, fn Value, args []Value, then, catch BasicBlock, name string) Value {
+	return b.CreateInvoke(b.pointerTo(fn, ft), args, then, catch, name)
+}
+
+// GlobalValueType returns the type of the value of the global g, e.g. the
+// function type of a function, rather than that of its address.
+func GlobalValueType(g Value) Type { return g.Type().ElementType() }
+
+// AllocatedType returns the type allocated by the alloca instruction a.
+func AllocatedType(a Value) Type { return a.Type().ElementType() }
@@ -0,0 +1,35 @@
+#include <llvm/ADT/StringMap.h>
+#include <llvm/Support/CommandLine.h>
+#include <string.h>
+#include <string>
+
+extern "C" void parseCommandLineOptions(int argc, const char **argv,
+                                        const char *overview) {
+	llvm::cl::ParseCommandLineOptions(argc, argv, overview);
+}
+
+// setCommandLineOption sets a registered option directly, rather than with
+// ParseCommandLineOptions, which exits the process on errors. Occurrences
+// after the first are added as MultiArg, which does not count them, so
+// that options which may occur only once can be set again.
+extern "C" bool setCommandLineOption(const char *name, const char *value,
+                                     char **errmsg) {
+	llvm::StringMap<llvm::cl::Option*> opts;
+	llvm::cl::getRegisteredOptions(opts);
+	llvm::StringMap<llvm::cl::Option*>::iterator it = opts.find(name);
+	if (it == opts.end()) {
+		*errmsg = strdup((std::string("unknown option -") + name).c_str());
+		return false;
+	}
+	llvm::cl::Option *opt = it->second;
+	if (*value == 0 && opt->getValueExpectedFlag() == llvm::cl::ValueRequired) {
+		*errmsg = strdup((std::string("option -") + name + " requires a value").c_str());
+		return false;
+	}
+	bool again = opt->getNumOccurrences() > 0;
+	if (opt->addOccurrence(0, name, value, again)) {
+		*errmsg = strdup((std::string("invalid value for option -") + name + ": " + value).c_str());
+		return false;
+	}
+	return true;
+}
@@ -0,0 +1,56 @@
+package llvm
+
+/*
+#include <stdbool.h>
+#include <stdlib.h>
+
+extern void parseCommandLineOptions(int, const char **, const char *);
+extern bool setCommandLineOption(const char *, const char *, char **);
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// ParseCommandLineOptions sets LLVM's command line options, such as
+// -print-after-all or -debug-only=isel, from args, as if they were passed to
+// an LLVM tool; args[0] is the program name. overview is printed with the
+// options' help, requested with -help.
+//
+// LLVM reports invalid options on standard error and exits the process.
+// Most options may be given at most once per process, across all calls;
+// SetCommandLineOption returns errors instead.
+// See llvm::cl::ParseCommandLineOptions.
+func ParseCommandLineOptions(args []string, overview string) {
+	argv := make([]*C.char, len(args)+1)
+	for i, arg := range args {
+		argv[i] = C.CString(arg)
+		defer C.free(unsafe.Pointer(argv[i]))
+	}
+	coverview := C.CString(overview)
+	defer C.free(unsafe.Pointer(coverview))
+	C.parseCommandLineOptions(C.int(len(args)), &argv[0], coverview)
+}
+
+// SetCommandLineOption sets the LLVM command line option name, without its
+// leading dash, to value; an empty value sets a boolean option. Unlike
+// ParseCommandLineOptions, it returns an error, rather than exiting the
+// process, if there is no option named name or value is invalid for it,
+// and an option may be set any number of times. LLVM also reports invalid
+// values on standard error.
+// See llvm::cl::getRegisteredOptions and llvm::cl::Option::addOccurrence.
+func SetCommandLineOption(name, value string) error {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	cvalue := C.CString(value)
+	defer C.free(unsafe.Pointer(cvalue))
+	var errmsg *C.char
+	if !C.setCommandLineOption(cname, cvalue, &errmsg) {
+		err := errors.New(C.GoString(errmsg))
+		C.free(unsafe.Pointer(errmsg))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package llvm
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	errModuleOwned    = errors.New("module is owned by another object")
+	errModuleDisposed = errors.New("module has been disposed")
+)
+
+// disposedModule is recorded as the owner of modules which have been
+// destroyed, so that a subsequent use can be reported instead of touching
+// freed memory.
+type disposedModule struct{}
+
+// maxDisposedModules bounds the number of disposed modules which are
+// remembered, so that the record does not grow without bound in programs
+// which create and dispose many modules, e.g. by cloning them for each
+// build; only uses of the most recently disposed modules are reported.
+const maxDisposedModules = 1024
+
+// moduleOwners records the current owner of each module whose ownership
+// has been transferred, e.g. to an ExecutionEngine or ModuleProvider.
+// Modules which are not in the map are owned by the caller.
+//
+// The record is best-effort. Modules are registered when they are created
+// by NewModule, Context.NewModule, ParseBitcodeFile, ParseBitcodeFileLazily
+// or Module.Clone, which forgets anything recorded for an earlier module at
+// the same address; a module created outside the bindings, e.g. by C++ code
+// called with cgo, at the address of a disposed module is reported as
+// disposed, and modules destroyed outside the bindings are not noticed.
+var moduleOwners struct {
+	sync.Mutex
+	m        map[Module]interface{}
+	disposed []Module // in the order in which they were disposed
+}
+
+// registerModule is called when a module is created. It forgets any
+// ownership recorded for a previously disposed module at the same address.
+func registerModule(m Module) {
+	moduleOwners.Lock()
+	delete(moduleOwners.m, m)
+	moduleOwners.Unlock()
+}
+
+func checkModuleOwnerLocked(m Module) error {
+	switch moduleOwners.m[m].(type) {
+	case nil:
+		return nil
+	case disposedModule:
+		return errModuleDisposed
+	}
+	return errModuleOwned
+}
+
+// TakenBy records that ownership of the module has been transferred to
+// owner, which will be responsible for disposing it. An error is returned
+// if the module is already owned by another object, or has been disposed.
+//
+// The bindings call TakenBy when a module is handed to an ExecutionEngine
+// or ModuleProvider by TryAddModule, TryNewModuleProviderForModule or the
+// execution engine constructors; it only needs to be called directly when
+// a module is handed to some other object that disposes it.
+func (m Module) TakenBy(owner interface{}) error {
+	moduleOwners.Lock()
+	defer moduleOwners.Unlock()
+	if err := checkModuleOwnerLocked(m); err != nil {
+		return err
+	}
+	if moduleOwners.m == nil {
+		moduleOwners.m = make(map[Module]interface{})
+	}
+	moduleOwners.m[m] = owner
+	return nil
+}
+
+// Owner returns the object to which ownership of the module has been
+// transferred, or nil if the module is owned by the caller.
+func (m Module) Owner() interface{} {
+	moduleOwners.Lock()
+	defer moduleOwners.Unlock()
+	if _, disposed := moduleOwners.m[m].(disposedModule); disposed {
+		return nil
+	}
+	return moduleOwners.m[m]
+}
+
+// CheckOwnership returns an error if the module may not be used by its
+// creator, because ownership of it has been transferred (see TakenBy) or it
+// has been disposed. The check is best-effort; see TryDispose.
+func (m Module) CheckOwnership() error {
+	moduleOwners.Lock()
+	defer moduleOwners.Unlock()
+	return checkModuleOwnerLocked(m)
+}
+
+// setOwner records that ownership of the module has been transferred to
+// owner, without checking that it was owned by the caller.
+func (m Module) setOwner(owner interface{}) {
+	moduleOwners.Lock()
+	if moduleOwners.m == nil {
+		moduleOwners.m = make(map[Module]interface{})
+	}
+	moduleOwners.m[m] = owner
+	moduleOwners.Unlock()
+}
+
+// release returns ownership of the module from owner to the caller.
+func (m Module) release(owner interface{}) {
+	moduleOwners.Lock()
+	if moduleOwners.m[m] == owner {
+		delete(moduleOwners.m, m)
+	}
+	moduleOwners.Unlock()
+}
+
+// markDisposed records that the module is about to be destroyed by its
+// creator. An error is returned if the module may not be destroyed.
+func (m Module) markDisposed() error {
+	moduleOwners.Lock()
+	defer moduleOwners.Unlock()
+	if err := checkModuleOwnerLocked(m); err != nil {
+		return err
+	}
+	recordDisposedLocked(m)
+	return nil
+}
+
+// forceDisposed records that the module is about to be destroyed, whoever
+// owns it.
+func (m Module) forceDisposed() {
+	moduleOwners.Lock()
+	recordDisposedLocked(m)
+	moduleOwners.Unlock()
+}
+
+// recordDisposedLocked records that the module m has been destroyed,
+// forgetting the oldest disposed module if there are too many.
+func recordDisposedLocked(m Module) {
+	if moduleOwners.m == nil {
+		moduleOwners.m = make(map[Module]interface{})
+	}
+	moduleOwners.m[m] = disposedModule{}
+	moduleOwners.disposed = append(moduleOwners.disposed, m)
+	if len(moduleOwners.disposed) > maxDisposedModules {
+		old := moduleOwners.disposed[0]
+		moduleOwners.disposed = moduleOwners.disposed[1:]
+		if _, disposed := moduleOwners.m[old].(disposedModule); disposed {
+			delete(moduleOwners.m, old)
+		}
+	}
+}
+
+// disposeOwnedModules records that all modules owned by owner have been
+// destroyed along with it. The personalities of their functions are
+// forgotten after moduleOwners is unlocked, so that the personality
+// registry is never locked while moduleOwners is held.
+func disposeOwnedModules(owner interface{}) {
+	var owned []Module
+	moduleOwners.Lock()
+	for m, o := range moduleOwners.m {
+		if o == owner {
+			recordDisposedLocked(m)
+			owned = append(owned, m)
+		}
+	}
+	moduleOwners.Unlock()
+	for _, m := range owned {
+		forgetPersonalities(m)
+	}
+}
@@ -0,0 +1,5 @@
+#include <llvm/Support/Threading.h>
+
+extern "C" bool startMultithreaded() {
+	return llvm::llvm_start_multithreaded();
+}
@@ -0,0 +1,149 @@
+package llvm
+
+/*
+#include <stdbool.h>
+
+extern bool startMultithreaded(void);
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// TargetMachineConfig holds the arguments to Target.CreateTargetMachine, so
+// that a target machine may be created for each goroutine that needs one.
+type TargetMachineConfig struct {
+	Triple    string
+	CPU       string
+	Features  string
+	Level     CodeGenOptLevel
+	Reloc     RelocMode
+	CodeModel CodeModel
+
+	// Limits are set for each target machine created, and so checked
+	// against each module before code is generated for it.
+	Limits ResourceLimits
+}
+
+// NewTargetMachine creates a target machine from the configuration. It may
+// be passed to EmitParallel as is, or called by a function which also sets
+// other options of the target machine.
+func (cfg TargetMachineConfig) NewTargetMachine() (TargetMachine, error) {
+	t, err := GetTargetFromTriple(cfg.Triple)
+	if err != nil {
+		return TargetMachine{}, err
+	}
+	tm := t.CreateTargetMachine(cfg.Triple, cfg.CPU, cfg.Features, cfg.Level, cfg.Reloc, cfg.CodeModel)
+	if tm.C == nil {
+		return TargetMachine{}, errors.New("cannot create target machine for " + cfg.Triple)
+	}
+	tm.SetResourceLimits(cfg.Limits)
+	return tm, nil
+}
+
+// multithreaded records whether LLVM has been put into multithreaded mode.
+var multithreaded struct {
+	sync.Once
+	ok bool
+}
+
+// EmitParallel generates an object or assembly file for each of the
+// modules, using up to workers goroutines, or runtime.NumCPU() if workers
+// is not positive, and returns their contents in the order of the modules.
+//
+// A Context, and the modules and types in it, must not be used by more
+// than one goroutine at a time, and nor may a TargetMachine, so each
+// module must be in a context of its own, which is not used elsewhere
+// until EmitParallel returns, and each goroutine creates its own target
+// machine by calling newTargetMachine, and disposes it when done.
+// newTargetMachine may be a TargetMachineConfig's NewTargetMachine, or a
+// function which also sets options of the target machine, such as frame
+// pointer elimination, position independent executables or segmented
+// stacks. If LLVM was built without thread support, the modules are
+// generated one at a time. The first error, in the order of the modules,
+// is returned, after all have been attempted.
+func EmitParallel(modules []Module, newTargetMachine func() (TargetMachine, error), ft CodeGenFileType, workers int) ([][]byte, error) {
+	return EmitParallelWithCancel(modules, newTargetMachine, ft, workers, nil)
+}
+
+// EmitParallelWithCancel is like EmitParallel, but calls cancelled, if it
+// is not nil, before generating code for each module; if it returns true,
+// no further modules are started and, once the modules in progress are
+// done, ErrCancelled is returned. Code generation for a module cannot be
+// interrupted once it has started.
+func EmitParallelWithCancel(modules []Module, newTargetMachine func() (TargetMachine, error), ft CodeGenFileType, workers int, cancelled func() bool) ([][]byte, error) {
+	if cancelled == nil {
+		cancelled = func() bool { return false }
+	}
+	contexts := make(map[Context]int)
+	for i, m := range modules {
+		ctx := m.Context()
+		if j, ok := contexts[ctx]; ok {
+			return nil, fmt.Errorf("modules %d and %d share a context", j, i)
+		}
+		contexts[ctx] = i
+	}
+
+	multithreaded.Do(func() { multithreaded.ok = bool(C.startMultithreaded()) })
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if !multithreaded.ok {
+		workers = 1
+	}
+	if workers > len(modules) {
+		workers = len(modules)
+	}
+
+	objects := make([][]byte, len(modules))
+	errs := make([]error, len(modules))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tm, err := newTargetMachine()
+			if err != nil {
+				for i := range next {
+					errs[i] = err
+				}
+				return
+			}
+			defer tm.Dispose()
+			for i := range next {
+				buf, err := tm.EmitToMemoryBuffer(modules[i], ft)
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				objects[i] = buf.Bytes()
+				buf.Dispose()
+			}
+		}()
+	}
+	stopped := false
+	for i := range modules {
+		if cancelled() {
+			stopped = true
+			break
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	if stopped {
+		return nil, ErrCancelled
+	}
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("module %d: %v", i, err)
+		}
+	}
+	return objects, nil
+}
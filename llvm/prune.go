@@ -0,0 +1,54 @@
+package llvm
+
+import "strings"
+
+// RemoveUnusedDeclarations removes from the module m each declaration of a
+// function or global variable which has no uses, such as those of a
+// runtime API declared speculatively, and returns the number removed.
+func RemoveUnusedDeclarations(m Module) int {
+	removed := 0
+	for f := m.FirstFunction(); !f.IsNil(); {
+		next := NextFunction(f)
+		if f.IsDeclaration() && f.FirstUse().IsNil() {
+			f.EraseFromParentAsFunction()
+			removed++
+		}
+		f = next
+	}
+	for g := m.FirstGlobal(); !g.IsNil(); {
+		next := NextGlobal(g)
+		if g.IsDeclaration() && g.FirstUse().IsNil() {
+			g.EraseFromParentAsGlobal()
+			removed++
+		}
+		g = next
+	}
+	return removed
+}
+
+// Internalize gives internal linkage to each function and global variable
+// defined in the module m whose name is not in roots, so that it may be
+// removed by the global DCE pass if unused. Globals with local or
+// available_externally linkage, and those reserved by LLVM, such as
+// llvm.used and llvm.global_ctors, are unchanged.
+func Internalize(m Module, roots []string) {
+	keep := make(map[string]bool, len(roots))
+	for _, name := range roots {
+		keep[name] = true
+	}
+	internalize := func(v Value) {
+		switch v.Linkage() {
+		case InternalLinkage, PrivateLinkage, LinkerPrivateLinkage, LinkerPrivateWeakLinkage, AvailableExternallyLinkage:
+			return
+		}
+		if name := v.Name(); !v.IsDeclaration() && !keep[name] && !strings.HasPrefix(name, "llvm.") {
+			v.SetLinkage(InternalLinkage)
+		}
+	}
+	for f := m.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		internalize(f)
+	}
+	for g := m.FirstGlobal(); !g.IsNil(); g = NextGlobal(g) {
+		internalize(g)
+	}
+}
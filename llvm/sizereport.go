@@ -0,0 +1,102 @@
+package llvm
+
+import "sort"
+
+// SymbolSize is the size in bytes attributed to a global value.
+type SymbolSize struct {
+	Name string
+	Size uint64
+}
+
+// SizeReport attributes the size of a module's output to its globals.
+type SizeReport struct {
+	// Data lists the size of each global variable with an initializer,
+	// largest first.
+	Data []SymbolSize
+
+	// Code lists the size of the machine code of each function, largest
+	// first. It is empty if no target machine was given.
+	Code []SymbolSize
+
+	// DuplicateStringBytes is the number of bytes of constant string data
+	// which would be saved by merging identical strings, e.g. by creating
+	// them with Module.MergeableString. Strings which already have
+	// unnamed_addr are assumed to be merged.
+	DuplicateStringBytes uint64
+}
+
+// ReportSizes analyzes the size of the module m, with data sizes given by
+// the target data td. If tm is not nil, code is generated for a copy of m
+// to measure the size of each function.
+func ReportSizes(m Module, td TargetData, tm TargetMachine) (*SizeReport, error) {
+	r := new(SizeReport)
+	// copies counts the unmerged copies of each constant string, with all
+	// unnamed_addr copies counting as one.
+	type copies struct {
+		n, size uint64
+		unnamed bool
+	}
+	strs := make(map[string]*copies)
+	for g := m.FirstGlobal(); !g.IsNil(); g = NextGlobal(g) {
+		init := g.Initializer()
+		if init.IsNil() {
+			continue
+		}
+		size := td.TypeAllocSize(init.Type())
+		r.Data = append(r.Data, SymbolSize{g.Name(), size})
+		s, ok := init.ConstDataString()
+		if !ok || !g.IsGlobalConstant() {
+			continue
+		}
+		c := strs[s]
+		if c == nil {
+			c = &copies{size: size}
+			strs[s] = c
+		}
+		if !g.HasUnnamedAddr() || !c.unnamed {
+			c.n++
+		}
+		c.unnamed = c.unnamed || g.HasUnnamedAddr()
+	}
+	for _, c := range strs {
+		r.DuplicateStringBytes += (c.n - 1) * c.size
+	}
+	sortSymbolSizes(r.Data)
+
+	if tm.C != nil {
+		// Code generation modifies the IR, so it is run on a copy.
+		c := m.Clone()
+		defer c.Dispose()
+		b, err := tm.EmitToMemoryBuffer(c, ObjectFile)
+		if err != nil {
+			return nil, err
+		}
+		syms, err := ObjectSymbols(b.Bytes())
+		b.Dispose()
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range syms {
+			if s.Kind == FunctionSymbol && !s.Undefined {
+				r.Code = append(r.Code, SymbolSize{s.Name, s.Size})
+			}
+		}
+		sortSymbolSizes(r.Code)
+	}
+	return r, nil
+}
+
+func sortSymbolSizes(s []SymbolSize) {
+	sort.Sort(symbolSizesBySize(s))
+}
+
+type symbolSizesBySize []SymbolSize
+
+func (s symbolSizesBySize) Len() int      { return len(s) }
+func (s symbolSizesBySize) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s symbolSizesBySize) Less(i, j int) bool {
+	if s[i].Size != s[j].Size {
+		return s[i].Size > s[j].Size
+	}
+	return s[i].Name < s[j].Name
+}
@@ -0,0 +1,79 @@
+#include <llvm/DataLayout.h>
+#include <llvm/Function.h>
+#include <llvm/Module.h>
+#include <llvm/PassManager.h>
+#include <llvm/CodeGen/MachineFrameInfo.h>
+#include <llvm/CodeGen/MachineFunction.h>
+#include <llvm/CodeGen/MachineFunctionPass.h>
+#include <llvm/Support/FormattedStream.h>
+#include <llvm/Support/raw_ostream.h>
+#include <llvm/Target/TargetMachine.h>
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+#include <vector>
+
+struct stackSize {
+	char *name;
+	uint64_t size;
+	bool dynamic;
+};
+
+namespace {
+
+// stackSizePass records the size of the stack frame of each function for
+// which code is generated.
+struct stackSizePass : public llvm::MachineFunctionPass {
+	static char ID;
+	std::vector<stackSize> &sizes;
+
+	stackSizePass(std::vector<stackSize> &sizes)
+		: llvm::MachineFunctionPass(ID), sizes(sizes) {}
+
+	virtual void getAnalysisUsage(llvm::AnalysisUsage &AU) const {
+		AU.setPreservesAll();
+		llvm::MachineFunctionPass::getAnalysisUsage(AU);
+	}
+
+	virtual bool runOnMachineFunction(llvm::MachineFunction &MF) {
+		const llvm::MachineFrameInfo *mfi = MF.getFrameInfo();
+		stackSize s;
+		s.name = strdup(MF.getFunction()->getName().str().c_str());
+		s.size = mfi->getStackSize();
+		s.dynamic = mfi->hasVarSizedObjects();
+		sizes.push_back(s);
+		return false;
+	}
+};
+
+char stackSizePass::ID = 0;
+
+}
+
+extern "C" stackSize *getStackSizes(llvm::TargetMachine *tm, llvm::Module *m,
+                                    unsigned *n, char **errmsg) {
+	const llvm::DataLayout *td = tm->getDataLayout();
+	if (!td) {
+		*errmsg = strdup("No DataLayout in TargetMachine");
+		return 0;
+	}
+	std::vector<stackSize> sizes;
+	llvm::PassManager pm;
+	pm.add(new llvm::DataLayout(*td));
+	llvm::raw_null_ostream ostream;
+	llvm::formatted_raw_ostream fostream(ostream);
+	if (tm->addPassesToEmitFile(pm, fostream, llvm::TargetMachine::CGFT_AssemblyFile)) {
+		*errmsg = strdup("TargetMachine can't emit a file of this type");
+		return 0;
+	}
+	pm.add(new stackSizePass(sizes));
+	pm.run(*m);
+
+	*n = sizes.size();
+	// One byte more is allocated so that the result is not null when
+	// there are no functions.
+	stackSize *out = (stackSize *)malloc(sizes.size() * sizeof(stackSize) + 1);
+	if (!sizes.empty())
+		memcpy(out, &sizes[0], sizes.size() * sizeof(stackSize));
+	return out;
+}
@@ -0,0 +1,61 @@
+package llvm
+
+/*
+#include <llvm-c/Target.h>
+#include <llvm-c/TargetMachine.h>
+#include <stdbool.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+typedef struct {
+	char *name;
+	uint64_t size;
+	bool dynamic;
+} stackSize;
+
+extern stackSize *getStackSizes(LLVMTargetMachineRef, LLVMModuleRef,
+                                unsigned *, char **);
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+)
+
+// StackSize is the size of a function's stack frame.
+type StackSize struct {
+	// Bytes is the size of the frame allocated by the function's
+	// prologue, for its local variables, spilled registers and outgoing
+	// arguments, excluding the return address pushed by its caller.
+	Bytes uint64
+
+	// Dynamic is true if the function also allocates stack space of a
+	// size known only at run time, with alloca.
+	Dynamic bool
+}
+
+// StackSizes generates code for a copy of the module m and returns the
+// size of the stack frame of each function defined in it, by name, as
+// recorded in the stack size section by later versions of LLVM. The stack
+// usage of a call tree is the sum of the frames on its deepest path, plus
+// the return addresses.
+func (tm TargetMachine) StackSizes(m Module) (map[string]StackSize, error) {
+	// Code generation modifies the IR, so it is run on a copy.
+	c := m.Clone()
+	defer c.Dispose()
+	var n C.unsigned
+	var errmsg *C.char
+	csizes := C.getStackSizes(tm.C, c.C, &n, &errmsg)
+	if csizes == nil {
+		err := errors.New(C.GoString(errmsg))
+		C.free(unsafe.Pointer(errmsg))
+		return nil, err
+	}
+	defer C.free(unsafe.Pointer(csizes))
+	sizes := make(map[string]StackSize, n)
+	for _, s := range (*[1 << 24]C.stackSize)(unsafe.Pointer(csizes))[:n:n] {
+		sizes[C.GoString(s.name)] = StackSize{uint64(s.size), bool(s.dynamic)}
+		C.free(unsafe.Pointer(s.name))
+	}
+	return sizes, nil
+}
@@ -0,0 +1,31 @@
+#include <llvm/Pass.h>
+#include <llvm/ADT/Statistic.h>
+#include <llvm/Support/Timer.h>
+#include <llvm/Support/raw_ostream.h>
+#include <stdlib.h>
+#include <string.h>
+#include <string>
+
+extern "C" void enableStatistics() {
+	llvm::EnableStatistics();
+}
+
+extern "C" void setTimePassesEnabled(bool enabled) {
+	llvm::TimePassesIsEnabled = enabled;
+}
+
+// The report functions return a string allocated with malloc.
+
+extern "C" char *printStatistics() {
+	std::string s;
+	llvm::raw_string_ostream os(s);
+	llvm::PrintStatistics(os);
+	return strdup(os.str().c_str());
+}
+
+extern "C" char *printTimers() {
+	std::string s;
+	llvm::raw_string_ostream os(s);
+	llvm::TimerGroup::printAll(os);
+	return strdup(os.str().c_str());
+}
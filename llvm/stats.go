@@ -0,0 +1,134 @@
+package llvm
+
+/*
+#include <stdbool.h>
+#include <stdlib.h>
+
+extern void enableStatistics(void);
+extern void setTimePassesEnabled(bool);
+extern char *printStatistics(void);
+extern char *printTimers(void);
+*/
+import "C"
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// EnableStatistics enables the collection of LLVM's internal statistics, as
+// with the -stats option. Statistics are only collected by LLVM builds with
+// assertions enabled.
+// See llvm::EnableStatistics.
+func EnableStatistics() { C.enableStatistics() }
+
+// SetTimePassesEnabled enables or disables timing of each pass run by pass
+// managers created after the call, as with the -time-passes option.
+// See llvm::TimePassesIsEnabled.
+func SetTimePassesEnabled(enabled bool) { C.setTimePassesEnabled(C.bool(enabled)) }
+
+// Statistic is a counter maintained by an LLVM component.
+type Statistic struct {
+	Component   string // The component (DEBUG_TYPE) maintaining the counter.
+	Description string
+	Value       uint64
+}
+
+var statisticLine = regexp.MustCompile(`^\s*(\d+)\s+(\S+)\s+- (.*)$`)
+
+// Statistics returns the statistics collected since EnableStatistics was
+// called.
+// See llvm::PrintStatistics.
+func Statistics() []Statistic {
+	cs := C.printStatistics()
+	defer C.free(unsafe.Pointer(cs))
+	var stats []Statistic
+	for _, line := range strings.Split(C.GoString(cs), "\n") {
+		m := statisticLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		value, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		stats = append(stats, Statistic{m[2], m[3], value})
+	}
+	return stats
+}
+
+// Timing records the time spent in a pass or other timed LLVM activity.
+// Durations which LLVM did not measure are zero.
+type Timing struct {
+	Group      string // The name of the timer group, e.g. "Pass execution timing report".
+	Name       string
+	UserTime   time.Duration
+	SystemTime time.Duration
+	WallTime   time.Duration
+	MemUsed    int64
+}
+
+var (
+	timerGroupLine = regexp.MustCompile(`^\s*\.\.\. (.*) \.\.\.\s*$`)
+	timerTimeCol   = regexp.MustCompile(`^\s*(-?[0-9.]+) \(\s*-?[0-9.]+%\)`)
+	timerMemCol    = regexp.MustCompile(`^\s*(-?\d+)`)
+)
+
+func parseSeconds(s string) time.Duration {
+	f, _ := strconv.ParseFloat(s, 64)
+	return time.Duration(f * float64(time.Second))
+}
+
+// Timings returns the timings recorded since the last call, and resets all
+// timers. Each group's "Total" row is included.
+// See llvm::TimerGroup::printAll.
+func Timings() []Timing {
+	cs := C.printTimers()
+	defer C.free(unsafe.Pointer(cs))
+	var timings []Timing
+	var group string
+	var columns []string
+	for _, line := range strings.Split(C.GoString(cs), "\n") {
+		if m := timerGroupLine.FindStringSubmatch(line); m != nil {
+			group, columns = m[1], nil
+			continue
+		}
+		if strings.Contains(line, "--- Name ---") {
+			columns = strings.Fields(strings.Replace(line, " Time", "Time", -1))
+			columns = columns[:len(columns)-3] // "---", "Name", "---"
+			continue
+		}
+		if columns == nil || strings.TrimSpace(line) == "" || strings.HasPrefix(line, "===") {
+			continue
+		}
+		t := Timing{Group: group}
+		rest := line
+		for _, col := range columns {
+			re := timerTimeCol
+			if strings.Contains(col, "Mem") {
+				re = timerMemCol
+			}
+			m := re.FindStringSubmatch(rest)
+			if m == nil {
+				break
+			}
+			rest = rest[len(m[0]):]
+			switch {
+			case strings.Contains(col, "UserTime"):
+				t.UserTime = parseSeconds(m[1])
+			case strings.Contains(col, "SystemTime"):
+				t.SystemTime = parseSeconds(m[1])
+			case strings.Contains(col, "WallTime"):
+				t.WallTime = parseSeconds(m[1])
+			case strings.Contains(col, "Mem"):
+				t.MemUsed, _ = strconv.ParseInt(m[1], 10, 64)
+			}
+		}
+		t.Name = strings.TrimSpace(rest)
+		timings = append(timings, t)
+	}
+	return timings
+}
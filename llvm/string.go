@@ -6,6 +6,8 @@ func (t TypeKind) String() string {
 	switch t {
 	case VoidTypeKind:
 		return "VoidTypeKind"
+	case HalfTypeKind:
+		return "HalfTypeKind"
 	case FloatTypeKind:
 		return "FloatTypeKind"
 	case DoubleTypeKind:
@@ -32,6 +34,8 @@ func (t TypeKind) String() string {
 		return "VectorTypeKind"
 	case MetadataTypeKind:
 		return "MetadataTypeKind"
+	case X86_MMXTypeKind:
+		return "X86_MMXTypeKind"
 	}
 	panic("unreachable")
 }
@@ -46,16 +50,21 @@ func (t Type) String() string {
 		s += fmt.Sprintf("(%v[%v])", t.ElementType(), t.ArrayLength())
 	case PointerTypeKind:
 		s += fmt.Sprintf("(%v)", t.ElementType())
-	case StructTypeKind:
-		etypes := t.StructElementTypes()
-		s += "("
-		if n := len(etypes); n > 0 {
-			s += fmt.Sprint(etypes[0])
-			for i := 1; i < n; i++ {
-				s += fmt.Sprintf(", %v", etypes[i])
-			}
+	case VectorTypeKind:
+		s += fmt.Sprintf("(%v<%v>)", t.ElementType(), t.VectorSize())
+	case FunctionTypeKind:
+		s += fmt.Sprintf("(%v %v", t.ReturnType(), typeListString(t.ParamTypes()))
+		if t.IsFunctionVarArg() {
+			s += "..."
 		}
 		s += ")"
+	case StructTypeKind:
+		// Named structs may be recursive, so only their name is printed.
+		if name := t.StructName(); name != "" {
+			s += fmt.Sprintf("(%%%s)", name)
+		} else {
+			s += typeListString(t.StructElementTypes())
+		}
 	case IntegerTypeKind:
 		s += fmt.Sprintf("(%d bits)", t.IntTypeWidth())
 	}
@@ -63,4 +72,15 @@ func (t Type) String() string {
 	return s
 }
 
+func typeListString(types []Type) string {
+	s := "("
+	if n := len(types); n > 0 {
+		s += fmt.Sprint(types[0])
+		for i := 1; i < n; i++ {
+			s += fmt.Sprintf(", %v", types[i])
+		}
+	}
+	return s + ")"
+}
+
 // vim: set ft=go :
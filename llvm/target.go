@@ -76,6 +76,16 @@ func InitializeAllTargets() { C.LLVMInitializeAllTargets() }
 
 func InitializeAllTargetMCs() { C.LLVMInitializeAllTargetMCs() }
 
+// InitializeAllAsmPrinters - The main program should call this function if
+// it wants all asm printers that LLVM is configured to support, to make them
+// available via the TargetRegistry.
+func InitializeAllAsmPrinters() { C.LLVMInitializeAllAsmPrinters() }
+
+// InitializeAllAsmParsers - The main program should call this function if
+// it wants all asm parsers that LLVM is configured to support, to make them
+// available via the TargetRegistry.
+func InitializeAllAsmParsers() { C.LLVMInitializeAllAsmParsers() }
+
 var initializeNativeTargetError = errors.New("Failed to initialize native target")
 
 // InitializeNativeTarget - The main program should call this function to
@@ -239,7 +249,26 @@ func (tm TargetMachine) TargetData() TargetData {
 	return TargetData{C.LLVMGetTargetMachineData(tm.C)}
 }
 
+// EmitToFile generates an assembly or object file for the module m, and
+// writes it to the file named filename.
+// See llvm::TargetMachine::addPassesToEmitFile.
+func (tm TargetMachine) EmitToFile(m Module, filename string, ft CodeGenFileType) error {
+	if err := tm.checkLimits(m); err != nil {
+		return err
+	}
+	cfilename := C.CString(filename)
+	defer C.free(unsafe.Pointer(cfilename))
+	var errmsg *C.char
+	if C.LLVMTargetMachineEmitToFile(tm.C, m.C, cfilename, C.LLVMCodeGenFileType(ft), &errmsg) != 0 {
+		err := errors.New(C.GoString(errmsg))
+		C.LLVMDisposeMessage(errmsg)
+		return err
+	}
+	return nil
+}
+
 // Dispose releases resources related to the TargetMachine.
 func (tm TargetMachine) Dispose() {
+	tm.forgetLimits()
 	C.LLVMDisposeTargetMachine(tm.C)
 }
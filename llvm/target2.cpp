@@ -0,0 +1,117 @@
+#include <llvm-c/Target.h>
+#include <llvm-c/TargetMachine.h>
+#include <llvm/DataLayout.h>
+#include <llvm/Module.h>
+#include <llvm/PassManager.h>
+#include <llvm/ADT/SmallString.h>
+#include <llvm/Support/FormattedStream.h>
+#include <llvm/Support/MemoryBuffer.h>
+#include <llvm/Support/TargetRegistry.h>
+#include <llvm/Target/TargetMachine.h>
+#include <llvm/Target/TargetOptions.h>
+#include <string.h>
+
+extern "C" void setTargetMachineNoFramePointerElim(llvm::TargetMachine *tm,
+                                                   bool all, bool nonLeaf) {
+	tm->Options.NoFramePointerElim = all;
+	tm->Options.NoFramePointerElimNonLeaf = nonLeaf;
+}
+
+extern "C" LLVMRelocMode getTargetMachineRelocMode(llvm::TargetMachine *tm) {
+	switch (tm->getRelocationModel()) {
+	case llvm::Reloc::Static:
+		return LLVMRelocStatic;
+	case llvm::Reloc::PIC_:
+		return LLVMRelocPIC;
+	case llvm::Reloc::DynamicNoPIC:
+		return LLVMRelocDynamicNoPic;
+	default:
+		return LLVMRelocDefault;
+	}
+}
+
+extern "C" void setTargetMachinePIE(llvm::TargetMachine *tm, bool on) {
+	tm->Options.PositionIndependentExecutable = on;
+}
+
+extern "C" void setTargetMachineSegmentedStacks(llvm::TargetMachine *tm,
+                                                bool on) {
+	tm->Options.EnableSegmentedStacks = on;
+}
+
+// createTargetMachineWithFloatABI implements LLVMCreateTargetMachine, with
+// the float ABI abi, a FloatABI. The C API's enumerations correspond to
+// those of the code generator.
+extern "C" llvm::TargetMachine *createTargetMachineWithFloatABI(
+	const llvm::Target *t, const char *triple, const char *cpu,
+	const char *features, LLVMCodeGenOptLevel level, LLVMRelocMode reloc,
+	LLVMCodeModel cm, int abi) {
+	llvm::TargetOptions options;
+	switch (abi) {
+	case 1: // FloatABISoft
+		options.UseSoftFloat = true;
+		options.FloatABIType = llvm::FloatABI::Soft;
+		break;
+	case 2: // FloatABISoftFP
+		options.FloatABIType = llvm::FloatABI::Soft;
+		break;
+	case 3: // FloatABIHard
+		options.FloatABIType = llvm::FloatABI::Hard;
+		break;
+	}
+	return t->createTargetMachine(triple, cpu, features, options,
+	                              (llvm::Reloc::Model)reloc,
+	                              (llvm::CodeModel::Model)cm,
+	                              (llvm::CodeGenOpt::Level)level);
+}
+
+extern "C" llvm::MemoryBuffer *emitToMemoryBuffer(llvm::TargetMachine *tm,
+                                                  llvm::Module *m,
+                                                  bool assembly,
+                                                  char **errmsg) {
+	const llvm::DataLayout *td = tm->getDataLayout();
+	if (!td) {
+		*errmsg = strdup("No DataLayout in TargetMachine");
+		return 0;
+	}
+	llvm::PassManager pm;
+	pm.add(new llvm::DataLayout(*td));
+	llvm::SmallString<0> code;
+	llvm::raw_svector_ostream ostream(code);
+	llvm::formatted_raw_ostream fostream(ostream);
+	llvm::TargetMachine::CodeGenFileType ft = assembly ?
+		llvm::TargetMachine::CGFT_AssemblyFile :
+		llvm::TargetMachine::CGFT_ObjectFile;
+	if (tm->addPassesToEmitFile(pm, fostream, ft)) {
+		*errmsg = strdup("TargetMachine can't emit a file of this type");
+		return 0;
+	}
+	pm.run(*m);
+	fostream.flush();
+	ostream.flush();
+	return llvm::MemoryBuffer::getMemBufferCopy(code.str());
+}
+
+extern "C" const char *getMemoryBufferStart(llvm::MemoryBuffer *b) {
+	return b->getBufferStart();
+}
+
+extern "C" size_t getMemoryBufferSize(llvm::MemoryBuffer *b) {
+	return b->getBufferSize();
+}
+
+extern "C" const llvm::Target *lookupTarget(const char *triple, char **errmsg) {
+	std::string err;
+	const llvm::Target *t = llvm::TargetRegistry::lookupTarget(triple, err);
+	if (!t)
+		*errmsg = strdup(err.c_str());
+	return t;
+}
+
+extern "C" void setFunctionSections(bool on) {
+	llvm::TargetMachine::setFunctionSections(on);
+}
+
+extern "C" void setDataSections(bool on) {
+	llvm::TargetMachine::setDataSections(on);
+}
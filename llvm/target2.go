@@ -0,0 +1,243 @@
+package llvm
+
+/*
+#include <llvm-c/Target.h>
+#include <llvm-c/TargetMachine.h>
+#include <stdbool.h>
+#include <stdlib.h>
+
+extern void setTargetMachineNoFramePointerElim(LLVMTargetMachineRef, bool, bool);
+extern LLVMMemoryBufferRef emitToMemoryBuffer(LLVMTargetMachineRef, LLVMModuleRef, bool, char **);
+extern const char *getMemoryBufferStart(LLVMMemoryBufferRef);
+extern size_t getMemoryBufferSize(LLVMMemoryBufferRef);
+extern LLVMTargetRef lookupTarget(const char *, char **);
+extern void setFunctionSections(bool);
+extern LLVMRelocMode getTargetMachineRelocMode(LLVMTargetMachineRef);
+extern void setTargetMachinePIE(LLVMTargetMachineRef, bool);
+extern void setTargetMachineSegmentedStacks(LLVMTargetMachineRef, bool);
+extern LLVMTargetMachineRef createTargetMachineWithFloatABI(LLVMTargetRef,
+	const char *, const char *, const char *, LLVMCodeGenOptLevel,
+	LLVMRelocMode, LLVMCodeModel, int);
+extern void setDataSections(bool);
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// FloatABI selects how floating point arguments and results are passed,
+// and whether floating point instructions are used at all, for targets,
+// such as ARM, which support several conventions.
+type FloatABI int
+
+const (
+	// FloatABIDefault uses the target's default convention.
+	FloatABIDefault FloatABI = iota
+
+	// FloatABISoft passes floating point values in integer registers and
+	// implements floating point operations with library calls, as with
+	// -mfloat-abi=soft.
+	FloatABISoft
+
+	// FloatABISoftFP passes floating point values in integer registers,
+	// but uses the FPU selected by the target features for operations, as
+	// with -mfloat-abi=softfp.
+	FloatABISoftFP
+
+	// FloatABIHard passes floating point values in FPU registers, as with
+	// -mfloat-abi=hard.
+	FloatABIHard
+)
+
+// SetNoFramePointerElim controls whether code generated by the target
+// machine keeps the frame pointer, so that stacks may be walked by external
+// profilers and debuggers. If all is true, the frame pointer is kept in all
+// functions; if nonLeaf is true, it is kept in all non-leaf functions.
+//
+// Stack protectors are enabled per function with the StackProtectAttribute
+// (ssp) and StackProtectReqAttribute (sspreq) attributes, and unwind tables
+// with UWTableAttribute.
+//
+// Return address signing (sign-return-address), branch target enforcement
+// and shadow call stacks cannot be requested: LLVM 3.2 has no AArch64
+// backend, and its ARM backend predates them. Stack protectors are the only
+// return address protection it provides.
+func (tm TargetMachine) SetNoFramePointerElim(all, nonLeaf bool) {
+	C.setTargetMachineNoFramePointerElim(tm.C, C.bool(all), C.bool(nonLeaf))
+}
+
+// SetFunctionSections controls whether each function is emitted into a
+// section of its own, as with -ffunction-sections, so that the linker can
+// discard unused functions with --gc-sections. In LLVM 3.2 the setting is
+// global, applying to code generated by all target machines.
+func SetFunctionSections(on bool) { C.setFunctionSections(C.bool(on)) }
+
+// SetDataSections controls whether each global variable is emitted into a
+// section of its own, as with -fdata-sections. In LLVM 3.2 the setting is
+// global, applying to code generated by all target machines.
+func SetDataSections(on bool) { C.setDataSections(C.bool(on)) }
+
+// TrapUnreachable inserts a call to llvm.trap before each unreachable
+// instruction in the module m, so that control reaching one stops the
+// program instead of falling through into unrelated code, and returns the
+// number of traps inserted. Unreachable instructions already preceded by a
+// call to a noreturn function are unchanged. LLVM 3.2 has no
+// TrapUnreachable target option, so the traps are added to the IR, which
+// should be done after optimization, just before code generation.
+func TrapUnreachable(m Module) int {
+	b := m.Context().NewBuilder()
+	defer b.Dispose()
+	n := 0
+	for f := m.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		for bb := f.FirstBasicBlock(); !bb.IsNil(); bb = NextBasicBlock(bb) {
+			term := bb.LastInstruction()
+			if term.IsAUnreachableInst().IsNil() {
+				continue
+			}
+			if prev := PrevInstruction(term); !prev.IsNil() && !prev.IsACallInst().IsNil() {
+				callee := prev.Operand(prev.OperandsCount() - 1)
+				if !callee.IsAFunction().IsNil() && callee.FunctionAttr()&NoReturnAttribute != 0 {
+					continue
+				}
+			}
+			b.SetInsertPointBefore(term)
+			b.CreateTrap()
+			n++
+		}
+	}
+	return n
+}
+
+// CreateTargetMachineWithFloatABI creates a TargetMachine, as
+// CreateTargetMachine does, using the floating point convention abi. Code
+// compiled with different conventions cannot be linked together.
+func (t Target) CreateTargetMachineWithFloatABI(triple, cpu, features string,
+	level CodeGenOptLevel, reloc RelocMode, cm CodeModel, abi FloatABI) (tm TargetMachine) {
+	ctriple := C.CString(triple)
+	defer C.free(unsafe.Pointer(ctriple))
+	ccpu := C.CString(cpu)
+	defer C.free(unsafe.Pointer(ccpu))
+	cfeatures := C.CString(features)
+	defer C.free(unsafe.Pointer(cfeatures))
+	tm.C = C.createTargetMachineWithFloatABI(t.C, ctriple, ccpu, cfeatures,
+		C.LLVMCodeGenOptLevel(level), C.LLVMRelocMode(reloc), C.LLVMCodeModel(cm), C.int(abi))
+	return
+}
+
+// armFPUFeatures maps the names of ARM FPUs accepted by gcc's and clang's
+// -mfpu option to the target features selecting them.
+var armFPUFeatures = map[string]string{
+	"none":        "-vfp2,-vfp3,-vfp4,-neon",
+	"vfp":         "+vfp2,-vfp3,-vfp4,-neon",
+	"vfpv2":       "+vfp2,-vfp3,-vfp4,-neon",
+	"vfpv3":       "+vfp3,-vfp4,-neon,-d16",
+	"vfpv3-d16":   "+vfp3,+d16,-vfp4,-neon",
+	"vfpv4":       "+vfp4,-neon,-d16",
+	"vfpv4-d16":   "+vfp4,+d16,-neon",
+	"fpv4-sp-d16": "+vfp4,+d16,+fp-only-sp,-neon",
+	"neon":        "+vfp3,+neon",
+	"neon-vfpv4":  "+vfp4,+neon",
+}
+
+// ARMFPUFeatures returns the target features selecting the ARM FPU named
+// as by -mfpu, e.g. "vfpv3-d16" or "neon", to be appended to the features
+// of a TargetMachine for an ARM target.
+func ARMFPUFeatures(fpu string) (string, error) {
+	features, ok := armFPUFeatures[fpu]
+	if !ok {
+		return "", fmt.Errorf("unknown ARM FPU %q", fpu)
+	}
+	return features, nil
+}
+
+// RelocMode returns the relocation model of code generated by the target
+// machine, which is resolved from RelocDefault when the machine is
+// created. Shared libraries must be generated with RelocPIC.
+func (tm TargetMachine) RelocMode() RelocMode {
+	return RelocMode(C.getTargetMachineRelocMode(tm.C))
+}
+
+// SetPositionIndependentExecutable controls whether position independent
+// code generated by the target machine is for an executable, as with -fPIE,
+// allowing cheaper access to thread-local and global variables defined in
+// the module than code for a shared library. The target machine must have
+// been created with RelocPIC; the executable must be linked with -pie, e.g.
+// with LinkOptions.PIE.
+func (tm TargetMachine) SetPositionIndependentExecutable(on bool) {
+	C.setTargetMachinePIE(tm.C, C.bool(on))
+}
+
+// SetSegmentedStacks controls whether functions generated by the target
+// machine check for stack overflow in their prologue and, if there is not
+// enough room for their frame, call __morestack to allocate a new stack
+// segment, so that they may run on small, growable stacks. It is supported
+// for x86 targets. In LLVM 3.2 the setting applies to all functions; there
+// is no split-stack attribute to select them individually.
+//
+// Code for Windows targets probes the stack, with __chkstk or _alloca,
+// in each function whose frame is larger than a page, 4096 bytes; LLVM 3.2
+// has no option to change the probe size.
+func (tm TargetMachine) SetSegmentedStacks(on bool) {
+	C.setTargetMachineSegmentedStacks(tm.C, C.bool(on))
+}
+
+// UseGOTForExternalCalls adds the nonlazybind attribute to each function
+// declared in the module m, so that position independent code calls them
+// through the global offset table rather than the PLT, as with
+// -fno-plt, and returns the number of functions changed. LLVM 3.2 has no
+// RtLibUseGOT option, so calls the code generator itself emits to runtime
+// library functions, such as memcpy, still use the PLT.
+func UseGOTForExternalCalls(m Module) int {
+	n := 0
+	for f := m.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		if f.IsDeclaration() && f.IntrinsicID() == 0 && f.FunctionAttr()&NonLazyBindAttribute == 0 {
+			f.AddFunctionAttr(NonLazyBindAttribute)
+			n++
+		}
+	}
+	return n
+}
+
+// EmitToMemoryBuffer generates an assembly or object file for the module m,
+// returning it in a MemoryBuffer which the caller must dispose.
+//
+// LLVM 3.2 has no in-process linker: lld provides no library interface, so
+// emitted objects must be written to disk and linked externally, e.g. with
+// Link.
+func (tm TargetMachine) EmitToMemoryBuffer(m Module, ft CodeGenFileType) (MemoryBuffer, error) {
+	if err := tm.checkLimits(m); err != nil {
+		return MemoryBuffer{}, err
+	}
+	var errmsg *C.char
+	b := C.emitToMemoryBuffer(tm.C, m.C, C.bool(ft == AssemblyFile), &errmsg)
+	if b == nil {
+		err := errors.New(C.GoString(errmsg))
+		C.free(unsafe.Pointer(errmsg))
+		return MemoryBuffer{}, err
+	}
+	return MemoryBuffer{b}, nil
+}
+
+// Bytes returns a copy of the contents of the buffer.
+func (b MemoryBuffer) Bytes() []byte {
+	return C.GoBytes(unsafe.Pointer(C.getMemoryBufferStart(b.C)), C.int(C.getMemoryBufferSize(b.C)))
+}
+
+// GetTargetFromTriple returns the registered target for the target triple.
+// See llvm::TargetRegistry::lookupTarget.
+//
+// LLVM 3.2 has no RISC-V backend, so riscv32 and riscv64 triples are
+// rejected, and RISC-V extensions cannot be selected with target features.
+func GetTargetFromTriple(triple string) (t Target, err error) {
+	ctriple := C.CString(triple)
+	defer C.free(unsafe.Pointer(ctriple))
+	var errmsg *C.char
+	t.C = C.lookupTarget(ctriple, &errmsg)
+	if t.C == nil {
+		err = errors.New(C.GoString(errmsg))
+		C.free(unsafe.Pointer(errmsg))
+	}
+	return
+}
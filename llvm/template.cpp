@@ -0,0 +1,23 @@
+#include <llvm/Module.h>
+#include <llvm/Assembly/Parser.h>
+#include <llvm/Support/SourceMgr.h>
+#include <llvm/Support/raw_ostream.h>
+#include <string.h>
+#include <string>
+
+// parseAssemblyInto parses the textual IR source into the module m, which
+// may already declare globals the source refers to. On failure, the
+// diagnostic is returned in errmsg, as line:column: message.
+extern "C" int parseAssemblyInto(llvm::Module *m, const char *source,
+                                 char **errmsg) {
+	llvm::SMDiagnostic diag;
+	if (llvm::ParseAssemblyString(source, m, diag, m->getContext())) {
+		return 0;
+	}
+	std::string s;
+	llvm::raw_string_ostream os(s);
+	os << diag.getLineNo() << ":" << diag.getColumnNo() + 1 << ": "
+	   << diag.getMessage();
+	*errmsg = strdup(os.str().c_str());
+	return 1;
+}
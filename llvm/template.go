@@ -0,0 +1,265 @@
+// +build llvmsvn llvm3.2 llvm3.3
+
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+#include <stdlib.h>
+
+extern int parseAssemblyInto(LLVMModuleRef, const char *, char **);
+*/
+import "C"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// Template is a fragment of textual IR with holes, written ${name}, to be
+// filled with types, values and text when it is instantiated into a
+// module, e.g.
+//
+//	define ${T} @${name}(${T} %x) {
+//	  %y = call ${T} ${f:i32 (i32)*}(${T} %x)
+//	  ret ${T} %y
+//	}
+//
+// A hole bound to a Type is replaced by its IR, e.g. "{ i32, i8* }"; one
+// bound to a Value, by the value as an operand, without its type, e.g. "@f"
+// or "42"; and one bound to a string, by the string. A hole written
+// ${name:type} must be bound to a value of the given type.
+type Template struct {
+	parts []templatePart
+	holes []string
+}
+
+// templatePart is a run of literal text, or, if hole is not empty, a hole.
+type templatePart struct {
+	text string
+	hole string
+	typ  string
+}
+
+// ParseTemplate parses the text of a template. Hole names may contain
+// letters, digits, underscores and periods; a hole may appear more than
+// once, but its type, if given, must be the same each time.
+func ParseTemplate(text string) (*Template, error) {
+	t := &Template{}
+	types := make(map[string]string)
+	for {
+		i := strings.Index(text, "${")
+		if i < 0 {
+			break
+		}
+		j := holeEnd(text[i:])
+		if j < 0 {
+			return nil, errors.New("unterminated hole in template")
+		}
+		name, typ := text[i+2:i+j], ""
+		if k := strings.IndexByte(name, ':'); k >= 0 {
+			name, typ = name[:k], strings.TrimSpace(name[k+1:])
+			if typ == "" {
+				return nil, fmt.Errorf("hole %s has an empty type", name)
+			}
+		}
+		if !isHoleName(name) {
+			return nil, fmt.Errorf("invalid hole name %q in template", name)
+		}
+		if prev, ok := types[name]; !ok {
+			types[name] = typ
+			t.holes = append(t.holes, name)
+		} else if prev != typ {
+			return nil, fmt.Errorf("hole %s is given different types", name)
+		}
+		t.parts = append(t.parts, templatePart{text: text[:i]}, templatePart{hole: name, typ: typ})
+		text = text[i+j+1:]
+	}
+	t.parts = append(t.parts, templatePart{text: text})
+	return t, nil
+}
+
+// holeEnd returns the index of the brace closing the hole at the start of
+// text, allowing for braces in struct types, or -1.
+func holeEnd(text string) int {
+	depth := 0
+	for i, c := range text {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isHoleName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// Holes returns the names of the template's holes, in the order of their
+// first appearance.
+func (t *Template) Holes() []string {
+	return append([]string(nil), t.holes...)
+}
+
+// Expand returns the text of the template with its holes filled from args,
+// which must bind every hole, and no other names, to a Type, Value or
+// string.
+func (t *Template) Expand(args map[string]interface{}) (string, error) {
+	text, _, err := t.expand(args)
+	return text, err
+}
+
+// expand returns the text of the template with its holes filled from args,
+// and the globals bound to its holes.
+func (t *Template) expand(args map[string]interface{}) (string, []Value, error) {
+	for _, name := range t.holes {
+		if _, ok := args[name]; !ok {
+			return "", nil, fmt.Errorf("no value for hole %s", name)
+		}
+	}
+	if len(args) != len(t.holes) {
+		for name := range args {
+			if !isHole(t.holes, name) {
+				return "", nil, fmt.Errorf("template has no hole %s", name)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	var globals []Value
+	for _, p := range t.parts {
+		if p.hole == "" {
+			buf.WriteString(p.text)
+			continue
+		}
+		s, g, err := p.fill(args[p.hole])
+		if err != nil {
+			return "", nil, err
+		}
+		if !g.IsNil() {
+			globals = append(globals, g)
+		}
+		buf.WriteString(s)
+	}
+	return buf.String(), globals, nil
+}
+
+func isHole(holes []string, name string) bool {
+	for _, h := range holes {
+		if h == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fill returns the text with which the hole p is filled by arg, and arg, if
+// it is a global.
+func (p templatePart) fill(arg interface{}) (string, Value, error) {
+	switch arg := arg.(type) {
+	case string:
+		if p.typ != "" {
+			return "", Value{}, fmt.Errorf("hole %s has type %s, but is bound to a string", p.hole, p.typ)
+		}
+		return arg, Value{}, nil
+	case Type:
+		if p.typ != "" {
+			return "", Value{}, fmt.Errorf("hole %s has type %s, but is bound to a type", p.hole, p.typ)
+		}
+		s := arg.IRString()
+		if strings.Contains(s, "%") {
+			// The assembly parser only knows the named types declared
+			// in the text it parses.
+			return "", Value{}, fmt.Errorf("type %s bound to hole %s refers to a named struct type", s, p.hole)
+		}
+		return s, Value{}, nil
+	case Value:
+		if arg.IsNil() {
+			return "", Value{}, fmt.Errorf("hole %s is bound to a nil value", p.hole)
+		}
+		if p.typ != "" {
+			if typ := arg.Type().IRString(); stripSpace(typ) != stripSpace(p.typ) {
+				return "", Value{}, fmt.Errorf("hole %s has type %s, but is bound to a value of type %s", p.hole, p.typ, typ)
+			}
+		}
+		var global Value
+		switch {
+		case !arg.IsAGlobalValue().IsNil():
+			if arg.Name() == "" {
+				return "", Value{}, fmt.Errorf("hole %s is bound to an unnamed global", p.hole)
+			}
+			global = arg
+		case arg.IsAConstant().IsNil():
+			return "", Value{}, fmt.Errorf("hole %s is bound to a value which is neither a constant nor a global", p.hole)
+		}
+		return arg.OperandIRString(), global, nil
+	}
+	return "", Value{}, fmt.Errorf("hole %s is bound to a %T", p.hole, arg)
+}
+
+func stripSpace(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// Instantiate fills the holes of the template from args, as Expand does,
+// parses the result, and links it into the module m. Globals bound to
+// holes are declared for the parser, and so need not be declared by the
+// template; they may not have local linkage, since the declarations would
+// not be linked to them. Other globals of m it refers to must be declared.
+// Constant expressions are written out as text, so the same goes for the
+// globals and named types they refer to. If the template does not parse, m
+// is left unchanged, and the error gives the line and column of the problem
+// in the expanded text.
+func (t *Template) Instantiate(m Module, args map[string]interface{}) error {
+	text, globals, err := t.expand(args)
+	if err != nil {
+		return err
+	}
+	for _, g := range globals {
+		if isLocalLinkage(g.Linkage()) {
+			// The declaration would be linked to a new global, not to g.
+			return fmt.Errorf("template: global %s has local linkage", g.Name())
+		}
+	}
+	tmp := m.Context().NewModule("template")
+	tmp.SetDataLayout(m.DataLayout())
+	tmp.SetTarget(m.Target())
+	for _, g := range globals {
+		if !tmp.NamedFunction(g.Name()).IsNil() || !tmp.NamedGlobal(g.Name()).IsNil() {
+			continue
+		}
+		pt := g.Type()
+		if et := pt.ElementType(); et.TypeKind() == FunctionTypeKind {
+			AddFunction(tmp, g.Name(), et)
+		} else {
+			AddGlobalInAddressSpace(tmp, et, g.Name(), pt.PointerAddressSpace())
+		}
+	}
+
+	ctext := C.CString(text)
+	defer C.free(unsafe.Pointer(ctext))
+	var errmsg *C.char
+	if C.parseAssemblyInto(tmp.C, ctext, &errmsg) != 0 {
+		err := errors.New("template: " + C.GoString(errmsg))
+		C.free(unsafe.Pointer(errmsg))
+		tmp.Dispose()
+		return err
+	}
+	err = LinkModules(m, tmp, LinkerDestroySource)
+	tmp.Dispose()
+	return err
+}
@@ -0,0 +1,95 @@
+package llvm
+
+import "fmt"
+
+// ThunkBody computes the callee and arguments of a thunk's call from the
+// thunk's parameters, using b to emit any instructions needed, e.g. to
+// load a receiver from a closure or a method from an interface table.
+type ThunkBody func(b Builder, params []Value) (callee Value, args []Value)
+
+// DefineThunk defines the function name in module m, of function type ft,
+// whose body is generated by body, followed by a tail call to the callee and
+// a return of its result. Arguments and the result are converted between
+// pointer and integer types of the thunk and the callee as needed. If the
+// callee is a function, its calling convention is used for the call.
+//
+// LLVM 3.2 has no musttail marker, so the call is only marked tail; the
+// code generator may not honour it, in which case the thunk uses its own
+// stack frame. The call is not marked tail if the thunk or the callee is
+// variadic, or if the callee is a function with a byval or sret parameter,
+// since those arguments may refer to the thunk's frame. Variadic thunks
+// cannot forward their variable arguments.
+func DefineThunk(m Module, name string, ft Type, body ThunkBody) Value {
+	f := AddFunction(m, name, ft)
+	b := m.Context().NewBuilder()
+	defer b.Dispose()
+	b.SetInsertPointAtEnd(AddBasicBlock(f, "entry"))
+
+	callee, args := body(b, f.Params())
+	calleeType := callee.Type().ElementType()
+	paramTypes := calleeType.ParamTypes()
+	if len(args) != len(paramTypes) {
+		panic(fmt.Sprintf("thunk %s passes %d arguments to a function of %d parameters",
+			name, len(args), len(paramTypes)))
+	}
+	for i, arg := range args {
+		args[i] = convertThunkValue(b, arg, paramTypes[i])
+	}
+	call := b.CreateCall(callee, args, "")
+	call.SetTailCall(canTailCallThunk(ft, callee))
+	if !callee.IsAFunction().IsNil() {
+		call.SetInstructionCallConv(callee.FunctionCallConv())
+	}
+
+	if rt := ft.ReturnType(); rt.TypeKind() == VoidTypeKind {
+		b.CreateRetVoid()
+	} else {
+		b.CreateRet(convertThunkValue(b, call, rt))
+	}
+	return f
+}
+
+// ForwardingThunk defines the function name in module m, of function type
+// ft, which passes its parameters unchanged to target. See DefineThunk.
+func ForwardingThunk(m Module, name string, ft Type, target Value) Value {
+	return DefineThunk(m, name, ft, func(b Builder, params []Value) (Value, []Value) {
+		return target, params
+	})
+}
+
+// canTailCallThunk reports whether a thunk of function type ft may mark
+// its call to callee tail.
+func canTailCallThunk(ft Type, callee Value) bool {
+	if ft.IsFunctionVarArg() || callee.Type().ElementType().IsFunctionVarArg() {
+		return false
+	}
+	if callee.IsAFunction().IsNil() {
+		return true
+	}
+	for _, p := range callee.Params() {
+		if p.Attribute()&(ByValAttribute|StructRetAttribute) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// convertThunkValue converts v to the type t, which must be the same type
+// or, if v is of pointer or integer type, a pointer or integer type.
+func convertThunkValue(b Builder, v Value, t Type) Value {
+	vt := v.Type()
+	if vt == t {
+		return v
+	}
+	switch vk, tk := vt.TypeKind(), t.TypeKind(); {
+	case vk == PointerTypeKind && tk == PointerTypeKind:
+		return b.CreateBitCast(v, t, "")
+	case vk == PointerTypeKind && tk == IntegerTypeKind:
+		return b.CreatePtrToInt(v, t, "")
+	case vk == IntegerTypeKind && tk == PointerTypeKind:
+		return b.CreateIntToPtr(v, t, "")
+	case vk == IntegerTypeKind && tk == IntegerTypeKind:
+		return b.CreateIntCast(v, t, "")
+	}
+	panic(fmt.Sprintf("cannot convert thunk value of type %v to %v", vt, t))
+}
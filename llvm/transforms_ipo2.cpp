@@ -0,0 +1,10 @@
+#include <llvm/PassManager.h>
+#include <llvm/Transforms/IPO.h>
+#include <vector>
+
+extern "C" void addInternalizePassWithExportList(llvm::PassManagerBase *pm,
+                                                 const char **names,
+                                                 unsigned n) {
+	std::vector<const char *> exportList(names, names + n);
+	pm->add(llvm::createInternalizePass(exportList));
+}
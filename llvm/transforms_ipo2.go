@@ -0,0 +1,35 @@
+package llvm
+
+/*
+#include <llvm-c/Core.h>
+#include <stdlib.h>
+
+extern void addInternalizePassWithExportList(LLVMPassManagerRef, const char **, unsigned);
+*/
+import "C"
+import "unsafe"
+
+// AddInternalizePassWithExportList adds a pass which gives internal linkage
+// to each global defined in the module whose name is not in exports. In
+// LLVM 3.2 the pass does nothing if exports is empty; see Internalize.
+// See llvm::createInternalizePass.
+func (pm PassManager) AddInternalizePassWithExportList(exports []string) {
+	names := make([]*C.char, len(exports)+1)
+	for i, name := range exports {
+		names[i] = C.CString(name)
+		defer C.free(unsafe.Pointer(names[i]))
+	}
+	C.addInternalizePassWithExportList(pm.C, &names[0], C.unsigned(len(exports)))
+}
+
+// StripUnexported internalizes each global defined in the module m whose
+// name is not in exports, and then removes those which are unused, along
+// with unused declarations, for whole-program builds.
+func StripUnexported(m Module, exports []string) {
+	Internalize(m, exports)
+	pm := NewPassManager()
+	defer pm.Dispose()
+	pm.AddGlobalDCEPass()
+	pm.Run(m)
+	RemoveUnusedDeclarations(m)
+}
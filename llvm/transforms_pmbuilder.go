@@ -0,0 +1,130 @@
+package llvm
+
+/*
+#include <stdbool.h>
+#include <llvm-c/Transforms/PassManagerBuilder.h>
+*/
+import "C"
+import "errors"
+
+type PassManagerBuilder struct {
+	C C.LLVMPassManagerBuilderRef
+}
+
+// See llvm::PassManagerBuilder::PassManagerBuilder.
+func NewPassManagerBuilder() (pmb PassManagerBuilder) {
+	pmb.C = C.LLVMPassManagerBuilderCreate()
+	return
+}
+
+func (pmb PassManagerBuilder) SetOptLevel(level int) {
+	C.LLVMPassManagerBuilderSetOptLevel(pmb.C, C.unsigned(level))
+}
+func (pmb PassManagerBuilder) SetSizeLevel(level int) {
+	C.LLVMPassManagerBuilderSetSizeLevel(pmb.C, C.unsigned(level))
+}
+func (pmb PassManagerBuilder) SetDisableUnitAtATime(disable bool) {
+	C.LLVMPassManagerBuilderSetDisableUnitAtATime(pmb.C, boolToLLVMBool(disable))
+}
+func (pmb PassManagerBuilder) SetDisableUnrollLoops(disable bool) {
+	C.LLVMPassManagerBuilderSetDisableUnrollLoops(pmb.C, boolToLLVMBool(disable))
+}
+func (pmb PassManagerBuilder) SetDisableSimplifyLibCalls(disable bool) {
+	C.LLVMPassManagerBuilderSetDisableSimplifyLibCalls(pmb.C, boolToLLVMBool(disable))
+}
+func (pmb PassManagerBuilder) UseInlinerWithThreshold(threshold int) {
+	C.LLVMPassManagerBuilderUseInlinerWithThreshold(pmb.C, C.unsigned(threshold))
+}
+func (pmb PassManagerBuilder) PopulateFunc(pm PassManager) {
+	C.LLVMPassManagerBuilderPopulateFunctionPassManager(pmb.C, pm.C)
+}
+func (pmb PassManagerBuilder) Populate(pm PassManager) {
+	C.LLVMPassManagerBuilderPopulateModulePassManager(pmb.C, pm.C)
+}
+func (pmb PassManagerBuilder) PopulateLTO(pm PassManager, internalize, runInliner bool) {
+	C.LLVMPassManagerBuilderPopulateLTOPassManager(pmb.C, pm.C, C.bool(internalize), C.bool(runInliner))
+}
+func (pmb PassManagerBuilder) Dispose() {
+	C.LLVMPassManagerBuilderDispose(pmb.C)
+}
+
+// inlineThreshold returns the inliner threshold clang uses for the given
+// optimization and size levels.
+func inlineThreshold(level, sizeLevel int) int {
+	switch {
+	case sizeLevel >= 2:
+		return 25
+	case sizeLevel == 1:
+		return 75
+	case level >= 3:
+		return 275
+	}
+	return 225
+}
+
+// ErrCancelled is returned by OptimizeWithCancel, EmitParallelWithCancel
+// and Link when they are cancelled.
+var ErrCancelled = errors.New("cancelled")
+
+// Optimize runs a standard optimization pipeline over the module m, as
+// clang does for -O<level>. sizeLevel is 1 for -Os and 2 for -Oz, and 0 to
+// optimize for speed. At level 0, only functions marked always_inline are
+// inlined. It returns true if the module was modified.
+func Optimize(m Module, level, sizeLevel int) bool {
+	changed, _ := OptimizeWithCancel(m, level, sizeLevel, nil)
+	return changed
+}
+
+// OptimizeWithCancel is like Optimize, but calls cancelled, if it is not
+// nil, before optimizing each function and before running the module
+// passes; if it returns true, the optimization stops and ErrCancelled is
+// returned. LLVM provides no way to interrupt a pass once it has started,
+// so a single pathological function or module pass cannot be cancelled;
+// use a separate process where a hard time limit is required.
+//
+// Code generation for a single module cannot be cancelled: EmitToFile and
+// EmitToMemoryBuffer run a pass pipeline which LLVM 3.2 builds internally,
+// with no way to stop it between functions. EmitParallelWithCancel and
+// Link, with LinkOptions.Cancelled, check for cancellation between
+// modules, so large programs may be split into several modules, as
+// IncrementalBuild does, to be cancelled promptly.
+func OptimizeWithCancel(m Module, level, sizeLevel int, cancelled func() bool) (changed bool, err error) {
+	if cancelled == nil {
+		cancelled = func() bool { return false }
+	}
+	pmb := NewPassManagerBuilder()
+	defer pmb.Dispose()
+	pmb.SetOptLevel(level)
+	pmb.SetSizeLevel(sizeLevel)
+
+	fpm := NewFunctionPassManagerForModule(m)
+	defer fpm.Dispose()
+	mpm := NewPassManager()
+	defer mpm.Dispose()
+
+	if level > 1 {
+		pmb.UseInlinerWithThreshold(inlineThreshold(level, sizeLevel))
+	} else {
+		mpm.AddAlwaysInlinerPass()
+	}
+	pmb.PopulateFunc(fpm)
+	pmb.Populate(mpm)
+
+	changed = fpm.InitializeFunc()
+	for f := m.FirstFunction(); !f.IsNil(); f = NextFunction(f) {
+		if cancelled() {
+			fpm.FinalizeFunc()
+			return changed, ErrCancelled
+		}
+		if fpm.RunFunc(f) {
+			changed = true
+		}
+	}
+	if fpm.FinalizeFunc() {
+		changed = true
+	}
+	if cancelled() {
+		return changed, ErrCancelled
+	}
+	return mpm.Run(m) || changed, nil
+}
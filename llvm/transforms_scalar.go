@@ -30,3 +30,5 @@ func (pm PassManager) AddTailCallEliminationPass()    { C.LLVMAddTailCallElimina
 func (pm PassManager) AddConstantPropagationPass()    { C.LLVMAddConstantPropagationPass(pm.C) }
 func (pm PassManager) AddDemoteMemoryToRegisterPass() { C.LLVMAddDemoteMemoryToRegisterPass(pm.C) }
 func (pm PassManager) AddVerifierPass()               { C.LLVMAddVerifierPass(pm.C) }
+func (pm PassManager) AddTypeBasedAliasAnalysisPass() { C.LLVMAddTypeBasedAliasAnalysisPass(pm.C) }
+func (pm PassManager) AddBasicAliasAnalysisPass()     { C.LLVMAddBasicAliasAnalysisPass(pm.C) }
@@ -0,0 +1,501 @@
+package llvm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TType is the type of a TValue: an LLVM type, with the signedness of
+// integers, which LLVM types do not record, and the TTypes of its
+// elements, fields, parameters and result.
+type TType struct {
+	t      Type
+	signed bool
+	elem   *TType   // of a pointer, array or vector
+	fields []*TType // of a struct
+	params []*TType // of a function
+	result *TType   // of a function; nil if void
+}
+
+// IntT returns the integer type of the given width in bits, whose
+// arithmetic, comparisons and conversions are signed if signed is true.
+func IntT(ctx Context, bits int, signed bool) *TType {
+	return &TType{t: ctx.IntType(bits), signed: signed}
+}
+
+// BoolT returns the type of the results of comparisons, i1.
+func BoolT(ctx Context) *TType { return &TType{t: ctx.Int1Type()} }
+
+// FloatT returns the 32-bit floating point type.
+func FloatT(ctx Context) *TType { return &TType{t: ctx.FloatType()} }
+
+// DoubleT returns the 64-bit floating point type.
+func DoubleT(ctx Context) *TType { return &TType{t: ctx.DoubleType()} }
+
+// PtrT returns the type of pointers to elem.
+func PtrT(elem *TType) *TType {
+	return &TType{t: PointerType(elem.t, 0), elem: elem}
+}
+
+// ArrayT returns the type of arrays of n elems.
+func ArrayT(elem *TType, n int) *TType {
+	return &TType{t: ArrayType(elem.t, n), elem: elem}
+}
+
+// VectorT returns the type of vectors of n elems.
+func VectorT(elem *TType, n int) *TType {
+	return &TType{t: VectorType(elem.t, n), elem: elem}
+}
+
+// StructT returns the type of unpacked structs of fields.
+func StructT(ctx Context, fields ...*TType) *TType {
+	types := make([]Type, len(fields))
+	for i, f := range fields {
+		types[i] = f.t
+	}
+	return &TType{t: ctx.StructType(types, false), fields: fields}
+}
+
+// FuncT returns the type of functions taking params and returning result,
+// or nothing if result is nil.
+func FuncT(ctx Context, result *TType, params ...*TType) *TType {
+	rt := ctx.VoidType()
+	if result != nil {
+		rt = result.t
+	}
+	types := make([]Type, len(params))
+	for i, p := range params {
+		types[i] = p.t
+	}
+	return &TType{t: FunctionType(rt, types, false), params: params, result: result}
+}
+
+// LLVM returns the LLVM type underlying t.
+func (t *TType) LLVM() Type { return t.t }
+
+// Signed reports whether t is a signed integer type.
+func (t *TType) Signed() bool { return t.signed }
+
+// Elem returns the element type of a pointer, array or vector type.
+func (t *TType) Elem() *TType { return t.elem }
+
+// Field returns the type of field i of a struct type.
+func (t *TType) Field(i int) *TType { return t.fields[i] }
+
+// Identical reports whether t and u are the same type, including the
+// signedness of integers, at any depth: that of the elements, fields,
+// parameters and results of the types.
+func (t *TType) Identical(u *TType) bool {
+	if t == u {
+		return true
+	}
+	if t == nil || u == nil || t.t != u.t || t.signed != u.signed {
+		return false
+	}
+	return t.elem.Identical(u.elem) && t.result.Identical(u.result) &&
+		identicalTTypes(t.fields, u.fields) && identicalTTypes(t.params, u.params)
+}
+
+func identicalTTypes(ts, us []*TType) bool {
+	if len(ts) != len(us) {
+		return false
+	}
+	for i, t := range ts {
+		if !t.Identical(us[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *TType) String() string {
+	kind := t.t.TypeKind()
+	switch {
+	case kind == IntegerTypeKind && t.signed:
+		return fmt.Sprintf("i%d", t.t.IntTypeWidth())
+	case kind == IntegerTypeKind:
+		return fmt.Sprintf("u%d", t.t.IntTypeWidth())
+	case kind == PointerTypeKind && t.elem != nil:
+		return "*" + t.elem.String()
+	case kind == ArrayTypeKind && t.elem != nil:
+		return fmt.Sprintf("[%d]%s", t.t.ArrayLength(), t.elem)
+	case kind == StructTypeKind && t.fields != nil:
+		fields := make([]string, len(t.fields))
+		for i, f := range t.fields {
+			fields[i] = f.String()
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	return t.t.String()
+}
+
+func (t *TType) isInt() bool   { return t.t.TypeKind() == IntegerTypeKind }
+func (t *TType) isFloat() bool { return isFloatKind(t.t.TypeKind()) }
+func (t *TType) isPtr() bool   { return t.t.TypeKind() == PointerTypeKind }
+
+func isFloatKind(k TypeKind) bool {
+	switch k {
+	case HalfTypeKind, FloatTypeKind, DoubleTypeKind, X86_FP80TypeKind, FP128TypeKind, PPC_FP128TypeKind:
+		return true
+	}
+	return false
+}
+
+// TBuilder is a builder of instructions on TValues, which checks the types
+// of its operands, panicking if they are not valid for the operation, and
+// chooses the instruction for their types, e.g. sdiv, udiv or fdiv for a
+// division. It creates its instructions with the Builder B, which may be
+// used directly, e.g. to create control flow.
+type TBuilder struct {
+	B Builder
+}
+
+// TValue is an LLVM value with a TType.
+type TValue struct {
+	tb *TBuilder
+	v  Value
+	t  *TType
+}
+
+// Value returns v as a TValue of type t, which must be the type of v.
+func (tb *TBuilder) Value(v Value, t *TType) TValue {
+	if v.Type() != t.t {
+		panic(fmt.Sprintf("value of type %v is not of type %v", v.Type(), t))
+	}
+	return TValue{tb, v, t}
+}
+
+// Int returns an integer constant of type t.
+func (tb *TBuilder) Int(t *TType, x int64) TValue {
+	if !t.isInt() {
+		panic(fmt.Sprintf("cannot create integer constant of type %v", t))
+	}
+	return TValue{tb, ConstInt(t.t, uint64(x), t.signed), t}
+}
+
+// Float returns a floating point constant of type t.
+func (tb *TBuilder) Float(t *TType, x float64) TValue {
+	if !t.isFloat() {
+		panic(fmt.Sprintf("cannot create floating point constant of type %v", t))
+	}
+	return TValue{tb, ConstFloat(t.t, x), t}
+}
+
+// Alloca allocates a variable of type t on the stack, returning a pointer
+// to it.
+func (tb *TBuilder) Alloca(t *TType, name string) TValue {
+	return TValue{tb, tb.B.CreateAlloca(t.t, name), PtrT(t)}
+}
+
+// Function returns the function f, declared with the type ft, as a TValue
+// which may be called with Call.
+func (tb *TBuilder) Function(f Value, ft *TType) TValue {
+	return tb.Value(f, PtrT(ft))
+}
+
+// Params returns the parameters of the function f, whose type is ft.
+func (tb *TBuilder) Params(f Value, ft *TType) []TValue {
+	params := f.Params()
+	if len(params) != len(ft.params) {
+		panic(fmt.Sprintf("function %s has %d parameters, not %d", f.Name(), len(params), len(ft.params)))
+	}
+	out := make([]TValue, len(params))
+	for i, p := range params {
+		out[i] = tb.Value(p, ft.params[i])
+	}
+	return out
+}
+
+// Ret returns v, or nothing if v is omitted, from the current function.
+func (tb *TBuilder) Ret(v ...TValue) {
+	if len(v) == 0 {
+		tb.B.CreateRetVoid()
+	} else {
+		tb.B.CreateRet(v[0].v)
+	}
+}
+
+// Value returns the underlying LLVM value.
+func (x TValue) Value() Value { return x.v }
+
+// Type returns the type of x.
+func (x TValue) Type() *TType { return x.t }
+
+func (x TValue) with(v Value, t *TType) TValue { return TValue{x.tb, v, t} }
+
+// checkSame panics unless x and y have identical types, of integers,
+// floating point numbers or vectors of them.
+func (x TValue) checkSame(op string, y TValue) {
+	if !x.t.Identical(y.t) {
+		panic(fmt.Sprintf("mismatched types in %s: %v and %v", op, x.t, y.t))
+	}
+	t := x.t
+	if t.t.TypeKind() == VectorTypeKind {
+		t = t.elem
+	}
+	if t == nil || !t.isInt() && !t.isFloat() {
+		panic(fmt.Sprintf("invalid operand type for %s: %v", op, x.t))
+	}
+}
+
+func (x TValue) scalar() *TType {
+	if x.t.t.TypeKind() == VectorTypeKind {
+		return x.t.elem
+	}
+	return x.t
+}
+
+// Add returns x + y.
+func (x TValue) Add(y TValue) TValue {
+	x.checkSame("Add", y)
+	if x.scalar().isFloat() {
+		return x.with(x.tb.B.CreateFAdd(x.v, y.v, ""), x.t)
+	}
+	return x.with(x.tb.B.CreateAdd(x.v, y.v, ""), x.t)
+}
+
+// Sub returns x - y.
+func (x TValue) Sub(y TValue) TValue {
+	x.checkSame("Sub", y)
+	if x.scalar().isFloat() {
+		return x.with(x.tb.B.CreateFSub(x.v, y.v, ""), x.t)
+	}
+	return x.with(x.tb.B.CreateSub(x.v, y.v, ""), x.t)
+}
+
+// Mul returns x * y.
+func (x TValue) Mul(y TValue) TValue {
+	x.checkSame("Mul", y)
+	if x.scalar().isFloat() {
+		return x.with(x.tb.B.CreateFMul(x.v, y.v, ""), x.t)
+	}
+	return x.with(x.tb.B.CreateMul(x.v, y.v, ""), x.t)
+}
+
+// Div returns x / y.
+func (x TValue) Div(y TValue) TValue {
+	x.checkSame("Div", y)
+	switch s := x.scalar(); {
+	case s.isFloat():
+		return x.with(x.tb.B.CreateFDiv(x.v, y.v, ""), x.t)
+	case s.signed:
+		return x.with(x.tb.B.CreateSDiv(x.v, y.v, ""), x.t)
+	}
+	return x.with(x.tb.B.CreateUDiv(x.v, y.v, ""), x.t)
+}
+
+// Rem returns x % y, with the sign of x.
+func (x TValue) Rem(y TValue) TValue {
+	x.checkSame("Rem", y)
+	switch s := x.scalar(); {
+	case s.isFloat():
+		return x.with(x.tb.B.CreateFRem(x.v, y.v, ""), x.t)
+	case s.signed:
+		return x.with(x.tb.B.CreateSRem(x.v, y.v, ""), x.t)
+	}
+	return x.with(x.tb.B.CreateURem(x.v, y.v, ""), x.t)
+}
+
+// checkInt panics unless x and y have identical integer types.
+func (x TValue) checkInt(op string, y TValue) {
+	x.checkSame(op, y)
+	if !x.scalar().isInt() {
+		panic(fmt.Sprintf("invalid operand type for %s: %v", op, x.t))
+	}
+}
+
+// And returns x & y.
+func (x TValue) And(y TValue) TValue {
+	x.checkInt("And", y)
+	return x.with(x.tb.B.CreateAnd(x.v, y.v, ""), x.t)
+}
+
+// Or returns x | y.
+func (x TValue) Or(y TValue) TValue {
+	x.checkInt("Or", y)
+	return x.with(x.tb.B.CreateOr(x.v, y.v, ""), x.t)
+}
+
+// Xor returns x ^ y.
+func (x TValue) Xor(y TValue) TValue {
+	x.checkInt("Xor", y)
+	return x.with(x.tb.B.CreateXor(x.v, y.v, ""), x.t)
+}
+
+// Shl returns x << y.
+func (x TValue) Shl(y TValue) TValue {
+	x.checkInt("Shl", y)
+	return x.with(x.tb.B.CreateShl(x.v, y.v, ""), x.t)
+}
+
+// Shr returns x >> y, shifting in the sign bit if x is signed.
+func (x TValue) Shr(y TValue) TValue {
+	x.checkInt("Shr", y)
+	if x.scalar().signed {
+		return x.with(x.tb.B.CreateAShr(x.v, y.v, ""), x.t)
+	}
+	return x.with(x.tb.B.CreateLShr(x.v, y.v, ""), x.t)
+}
+
+// Neg returns -x.
+func (x TValue) Neg() TValue {
+	x.checkSame("Neg", x)
+	if x.scalar().isFloat() {
+		return x.with(x.tb.B.CreateFNeg(x.v, ""), x.t)
+	}
+	return x.with(x.tb.B.CreateNeg(x.v, ""), x.t)
+}
+
+// compare returns the result of comparing x and y with the predicate for
+// their type. Pointers are compared as unsigned integers, and vectors
+// element by element.
+func (x TValue) compare(op string, y TValue, signed, unsigned IntPredicate, float FloatPredicate) TValue {
+	boolT := BoolT(x.t.t.Context())
+	if x.t.isPtr() {
+		if x.t.t != y.t.t {
+			panic(fmt.Sprintf("mismatched types in %s: %v and %v", op, x.t, y.t))
+		}
+		return x.with(x.tb.B.CreateICmp(unsigned, x.v, y.v, ""), boolT)
+	}
+	x.checkSame(op, y)
+	if x.t.t.TypeKind() == VectorTypeKind {
+		boolT = VectorT(boolT, x.t.t.VectorSize())
+	}
+	switch s := x.scalar(); {
+	case s.isFloat():
+		return x.with(x.tb.B.CreateFCmp(float, x.v, y.v, ""), boolT)
+	case s.signed:
+		return x.with(x.tb.B.CreateICmp(signed, x.v, y.v, ""), boolT)
+	}
+	return x.with(x.tb.B.CreateICmp(unsigned, x.v, y.v, ""), boolT)
+}
+
+// Eq returns x == y, comparing integers, floating point numbers, pointers
+// or vectors of integers or floating point numbers, whose result is a
+// vector of i1. Floating point comparisons are false if either is NaN,
+// except for Ne, which is then true.
+func (x TValue) Eq(y TValue) TValue { return x.compare("Eq", y, IntEQ, IntEQ, FloatOEQ) }
+
+// Ne returns x != y.
+func (x TValue) Ne(y TValue) TValue { return x.compare("Ne", y, IntNE, IntNE, FloatUNE) }
+
+// Lt returns x < y.
+func (x TValue) Lt(y TValue) TValue { return x.compare("Lt", y, IntSLT, IntULT, FloatOLT) }
+
+// Le returns x <= y.
+func (x TValue) Le(y TValue) TValue { return x.compare("Le", y, IntSLE, IntULE, FloatOLE) }
+
+// Gt returns x > y.
+func (x TValue) Gt(y TValue) TValue { return x.compare("Gt", y, IntSGT, IntUGT, FloatOGT) }
+
+// Ge returns x >= y.
+func (x TValue) Ge(y TValue) TValue { return x.compare("Ge", y, IntSGE, IntUGE, FloatOGE) }
+
+// checkPtr panics unless x is a pointer with a known element type.
+func (x TValue) checkPtr(op string) {
+	if !x.t.isPtr() || x.t.elem == nil {
+		panic(fmt.Sprintf("invalid operand type for %s: %v", op, x.t))
+	}
+}
+
+// Load returns the value pointed to by x.
+func (x TValue) Load() TValue {
+	x.checkPtr("Load")
+	return x.with(x.tb.B.CreateLoad(x.v, ""), x.t.elem)
+}
+
+// Store stores v at the address x.
+func (x TValue) Store(v TValue) {
+	x.checkPtr("Store")
+	if !x.t.elem.Identical(v.t) {
+		panic(fmt.Sprintf("cannot store %v through %v", v.t, x.t))
+	}
+	x.tb.B.CreateStore(v.v, x.v)
+}
+
+// Field returns field i of x, a struct, or if x is a pointer to a struct,
+// a pointer to the field.
+func (x TValue) Field(i int) TValue {
+	t := x.t
+	if t.isPtr() && t.elem != nil {
+		t = t.elem
+	}
+	if t.fields == nil {
+		panic(fmt.Sprintf("%v is not a struct or pointer to a struct", x.t))
+	}
+	if i < 0 || i >= len(t.fields) {
+		panic(fmt.Sprintf("field index %d out of range for %v", i, t))
+	}
+	if x.t.isPtr() {
+		return x.with(x.tb.B.CreateStructGEP(x.v, i, ""), PtrT(t.fields[i]))
+	}
+	return x.with(x.tb.B.CreateExtractValue(x.v, i, ""), t.fields[i])
+}
+
+// Index returns a pointer to element i, an integer, of the array pointed
+// to by x, or if x points to a non-array, the pointer x offset by i
+// elements.
+func (x TValue) Index(i TValue) TValue {
+	x.checkPtr("Index")
+	if !i.t.isInt() {
+		panic(fmt.Sprintf("invalid index type: %v", i.t))
+	}
+	if x.t.elem.t.TypeKind() == ArrayTypeKind {
+		zero := ConstInt(i.t.t, 0, false)
+		return x.with(x.tb.B.CreateGEP(x.v, []Value{zero, i.v}, ""), PtrT(x.t.elem.elem))
+	}
+	return x.with(x.tb.B.CreateGEP(x.v, []Value{i.v}, ""), x.t)
+}
+
+// Convert returns x converted to the type t, as by a Go conversion:
+// integers are truncated or extended according to the signedness of x,
+// and converted to and from floating point numbers according to their
+// signedness; pointers are converted to other pointer types.
+func (x TValue) Convert(t *TType) TValue {
+	b := x.tb.B
+	from := x.t
+	switch {
+	case from.t == t.t:
+		return x.with(x.v, t)
+	case from.isInt() && t.isInt():
+		if from.t.IntTypeWidth() > t.t.IntTypeWidth() {
+			return x.with(b.CreateTrunc(x.v, t.t, ""), t)
+		} else if from.signed {
+			return x.with(b.CreateSExt(x.v, t.t, ""), t)
+		}
+		return x.with(b.CreateZExt(x.v, t.t, ""), t)
+	case from.isInt() && t.isFloat():
+		if from.signed {
+			return x.with(b.CreateSIToFP(x.v, t.t, ""), t)
+		}
+		return x.with(b.CreateUIToFP(x.v, t.t, ""), t)
+	case from.isFloat() && t.isInt():
+		if t.signed {
+			return x.with(b.CreateFPToSI(x.v, t.t, ""), t)
+		}
+		return x.with(b.CreateFPToUI(x.v, t.t, ""), t)
+	case from.isFloat() && t.isFloat():
+		return x.with(b.CreateFPCast(x.v, t.t, ""), t)
+	case from.isPtr() && t.isPtr():
+		return x.with(b.CreateBitCast(x.v, t.t, ""), t)
+	}
+	panic(fmt.Sprintf("cannot convert %v to %v", from, t))
+}
+
+// Call calls x, a pointer to a function, with args, returning its result,
+// or a TValue with a nil type if the function returns nothing.
+func (x TValue) Call(args ...TValue) TValue {
+	x.checkPtr("Call")
+	ft := x.t.elem
+	if ft.t.TypeKind() != FunctionTypeKind || len(ft.params) != len(args) {
+		panic(fmt.Sprintf("cannot call %v with %d arguments", x.t, len(args)))
+	}
+	vals := make([]Value, len(args))
+	for i, arg := range args {
+		if !ft.params[i].Identical(arg.t) {
+			panic(fmt.Sprintf("argument %d is %v, not %v", i, arg.t, ft.params[i]))
+		}
+		vals[i] = arg.v
+	}
+	return x.with(x.tb.B.CreateCall(x.v, vals, ""), ft.result)
+}
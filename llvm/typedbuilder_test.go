@@ -0,0 +1,155 @@
+package llvm
+
+import "testing"
+
+func TestTTypeIdentical(t *testing.T) {
+	ctx := NewContext()
+	defer ctx.Dispose()
+	i32, u32 := IntT(ctx, 32, true), IntT(ctx, 32, false)
+	tests := []struct {
+		a, b *TType
+		want bool
+	}{
+		{i32, IntT(ctx, 32, true), true},
+		{i32, u32, false},
+		{PtrT(i32), PtrT(IntT(ctx, 32, true)), true},
+		{PtrT(i32), PtrT(u32), false},
+		{StructT(ctx, i32, PtrT(u32)), StructT(ctx, i32, PtrT(u32)), true},
+		{StructT(ctx, i32, PtrT(u32)), StructT(ctx, i32, PtrT(i32)), false},
+		{FuncT(ctx, nil, i32), FuncT(ctx, nil, i32), true},
+		{FuncT(ctx, i32, i32), FuncT(ctx, u32, i32), false},
+		{VectorT(i32, 4), VectorT(u32, 4), false},
+	}
+	for _, test := range tests {
+		if got := test.a.Identical(test.b); got != test.want {
+			t.Errorf("%v.Identical(%v) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestTTypeString(t *testing.T) {
+	ctx := NewContext()
+	defer ctx.Dispose()
+	i32, u8 := IntT(ctx, 32, true), IntT(ctx, 8, false)
+	tests := []struct {
+		t    *TType
+		want string
+	}{
+		{i32, "i32"},
+		{u8, "u8"},
+		{PtrT(i32), "*i32"},
+		{ArrayT(u8, 4), "[4]u8"},
+		{StructT(ctx, i32, PtrT(u8)), "{i32, *u8}"},
+	}
+	for _, test := range tests {
+		if got := test.t.String(); got != test.want {
+			t.Errorf("String() = %q, want %q", got, test.want)
+		}
+	}
+}
+
+// newTestTBuilder returns a TBuilder positioned in the body of a function
+// with a signed and an unsigned 32-bit integer parameter and a float
+// parameter.
+func newTestTBuilder(ctx Context, m Module) (tb *TBuilder, i, u, f TValue) {
+	i32, u32, float := IntT(ctx, 32, true), IntT(ctx, 32, false), FloatT(ctx)
+	ft := FuncT(ctx, nil, i32, u32, float)
+	fn := AddFunction(m, "f", ft.LLVM())
+	tb = &TBuilder{B: ctx.NewBuilder()}
+	tb.B.SetInsertPointAtEnd(ctx.AddBasicBlock(fn, "entry"))
+	params := tb.Params(fn, ft)
+	return tb, params[0], params[1], params[2]
+}
+
+func TestTBuilderInstructions(t *testing.T) {
+	ctx := NewContext()
+	defer ctx.Dispose()
+	m := ctx.NewModule("typed")
+	defer m.Dispose()
+	tb, i, u, f := newTestTBuilder(ctx, m)
+	defer tb.B.Dispose()
+
+	opcodes := []struct {
+		name string
+		v    TValue
+		want Opcode
+	}{
+		{"signed Div", i.Div(i), SDiv},
+		{"unsigned Div", u.Div(u), UDiv},
+		{"float Div", f.Div(f), FDiv},
+		{"signed Shr", i.Shr(i), AShr},
+		{"unsigned Shr", u.Shr(u), LShr},
+		{"signed widening", i.Convert(IntT(ctx, 64, true)), SExt},
+		{"unsigned widening", u.Convert(IntT(ctx, 64, false)), ZExt},
+		{"narrowing", i.Convert(IntT(ctx, 8, true)), Trunc},
+		{"signed to float", i.Convert(FloatT(ctx)), SIToFP},
+		{"unsigned to float", u.Convert(FloatT(ctx)), UIToFP},
+		{"float to signed", f.Convert(IntT(ctx, 32, true)), FPToSI},
+	}
+	for _, test := range opcodes {
+		if got := test.v.Value().InstructionOpcode(); got != test.want {
+			t.Errorf("%s: opcode %d, want %d", test.name, got, test.want)
+		}
+	}
+
+	if got := i.Lt(i).Value().IntPredicate(); got != IntSLT {
+		t.Errorf("signed Lt: predicate %d, want %d", got, IntSLT)
+	}
+	if got := u.Lt(u).Value().IntPredicate(); got != IntULT {
+		t.Errorf("unsigned Lt: predicate %d, want %d", got, IntULT)
+	}
+	if !i.Eq(i).Type().Identical(BoolT(ctx)) {
+		t.Errorf("comparison is not of type i1")
+	}
+	if conv := i.Convert(IntT(ctx, 32, false)); !conv.Type().Identical(u.Type()) || conv.Value() != i.Value() {
+		t.Errorf("signedness conversion was not a no-op retyping")
+	}
+
+	s := StructT(ctx, i.Type(), f.Type())
+	p := tb.Alloca(s, "s")
+	field := p.Field(1)
+	if !field.Type().Identical(PtrT(f.Type())) {
+		t.Errorf("Field(1) of %v is %v, want *float", p.Type(), field.Type())
+	}
+	field.Store(f)
+	if !field.Load().Type().Identical(f.Type()) {
+		t.Errorf("loaded field has type %v", field.Load().Type())
+	}
+	tb.Ret()
+}
+
+func TestTBuilderTypeErrors(t *testing.T) {
+	ctx := NewContext()
+	defer ctx.Dispose()
+	m := ctx.NewModule("typed")
+	defer m.Dispose()
+	tb, i, u, f := newTestTBuilder(ctx, m)
+	defer tb.B.Dispose()
+
+	callee := tb.Function(AddFunction(m, "g", FuncT(ctx, nil, i.Type()).LLVM()), FuncT(ctx, nil, i.Type()))
+	tests := []struct {
+		name string
+		f    func()
+	}{
+		{"mixed signedness", func() { i.Add(u) }},
+		{"int and float", func() { i.Mul(f) }},
+		{"float bitwise", func() { f.And(f) }},
+		{"load of non-pointer", func() { i.Load() }},
+		{"store of wrong type", func() { tb.Alloca(i.Type(), "").Store(u) }},
+		{"field of non-struct", func() { i.Field(0) }},
+		{"call with wrong argument", func() { callee.Call(u) }},
+		{"call with too many arguments", func() { callee.Call(i, i) }},
+		{"int constant of float type", func() { tb.Int(f.Type(), 1) }},
+		{"value of wrong type", func() { tb.Value(i.Value(), f.Type()) }},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic", test.name)
+				}
+			}()
+			test.f()
+		}()
+	}
+}
@@ -0,0 +1,181 @@
+// Package mangle converts Go qualified names into symbol names, and back.
+//
+// Symbols are named as by the gc toolchain, so that debuggers and other
+// tools which understand Go symbols work with the output:
+//
+//	path/to/pkg.Func
+//	path/to/pkg.Type.Method
+//	path/to/pkg.(*Type).Method
+//	path/to/pkg.Func[int,string]
+//
+// Characters in the package path which are special to linkers or to this
+// encoding, including parentheses and brackets, are escaped as %xx, as are
+// dots in the last path element. The receiver of a method of a generic
+// type includes its type arguments, e.g. pkg.T[int].M.
+package mangle
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Symbol is a decoded Go symbol name.
+type Symbol struct {
+	PkgPath  string   // The import path of the defining package.
+	Recv     string   // The receiver type name of a method, or "".
+	PtrRecv  bool     // Whether the method has a pointer receiver.
+	Name     string   // The function, method or variable name.
+	TypeArgs []string // The type arguments of a generic instantiation.
+}
+
+// EscapePath escapes the package path path for use in a symbol name.
+func EscapePath(path string) string {
+	slash := strings.LastIndex(path, "/")
+	var b []byte
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`%"()[]`, c) >= 0 || (c == '.' && i > slash) {
+			b = append(b, fmt.Sprintf("%%%02x", c)...)
+		} else {
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+// UnescapePath reverses EscapePath.
+func UnescapePath(s string) (string, error) {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b = append(b, s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("mangle: truncated escape in %q", s)
+		}
+		c, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("mangle: invalid escape in %q", s)
+		}
+		b = append(b, byte(c))
+		i += 2
+	}
+	return string(b), nil
+}
+
+// Mangle returns the symbol name for s.
+func (s Symbol) Mangle() string {
+	name := EscapePath(s.PkgPath) + "."
+	switch {
+	case s.Recv != "" && s.PtrRecv:
+		name += "(*" + s.Recv + ")."
+	case s.Recv != "":
+		name += s.Recv + "."
+	}
+	name += s.Name
+	if len(s.TypeArgs) > 0 {
+		name += "[" + strings.Join(s.TypeArgs, ",") + "]"
+	}
+	return name
+}
+
+func (s Symbol) String() string { return s.Mangle() }
+
+// Func returns the symbol name of the function or variable name in the
+// package path.
+func Func(path, name string) string {
+	return Symbol{PkgPath: path, Name: name}.Mangle()
+}
+
+// Method returns the symbol name of the method name of the type recv in
+// the package path, with a pointer receiver if ptr is true.
+func Method(path, recv string, ptr bool, name string) string {
+	return Symbol{PkgPath: path, Recv: recv, PtrRecv: ptr, Name: name}.Mangle()
+}
+
+var errNoPackage = errors.New("mangle: symbol has no package qualifier")
+
+// Demangle decodes the symbol name sym.
+func Demangle(sym string) (s Symbol, err error) {
+	// The package path ends at the first dot after the last slash; dots
+	// in its last element are escaped. Slashes may occur in type
+	// arguments, so only the part before any bracket is searched.
+	head := sym
+	if i := strings.IndexAny(head, "(["); i >= 0 {
+		head = head[:i]
+	}
+	dot := strings.Index(head[strings.LastIndex(head, "/")+1:], ".")
+	if dot < 0 {
+		return s, errNoPackage
+	}
+	dot += strings.LastIndex(head, "/") + 1
+	if s.PkgPath, err = UnescapePath(sym[:dot]); err != nil {
+		return s, err
+	}
+	rest := sym[dot+1:]
+
+	// The receiver, which may have type arguments, ends at the first dot
+	// outside brackets.
+	if strings.HasPrefix(rest, "(*") {
+		end := indexTopLevel(rest[2:], ')')
+		if end < 0 || !strings.HasPrefix(rest[2+end:], ").") {
+			return s, fmt.Errorf("mangle: malformed receiver in %q", sym)
+		}
+		s.Recv, s.PtrRecv = rest[2:2+end], true
+		rest = rest[2+end+2:]
+	} else if i := indexTopLevel(rest, '.'); i >= 0 {
+		s.Recv, rest = rest[:i], rest[i+1:]
+	}
+	if i := strings.Index(rest, "["); i >= 0 {
+		if !strings.HasSuffix(rest, "]") {
+			return s, fmt.Errorf("mangle: malformed type arguments in %q", sym)
+		}
+		s.TypeArgs = splitTypeArgs(rest[i+1 : len(rest)-1])
+		rest = rest[:i]
+	}
+	if s.Name = rest; s.Name == "" {
+		return s, fmt.Errorf("mangle: symbol %q has no name", sym)
+	}
+	return s, nil
+}
+
+// indexTopLevel returns the index of the first byte c in s which is not
+// nested in brackets, parentheses or braces, or -1.
+func indexTopLevel(s string, c byte) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case depth == 0 && s[i] == c:
+			return i
+		case s[i] == '[' || s[i] == '(' || s[i] == '{':
+			depth++
+		case s[i] == ']' || s[i] == ')' || s[i] == '}':
+			depth--
+		}
+	}
+	return -1
+}
+
+// splitTypeArgs splits a comma separated list of type arguments, ignoring
+// commas nested in brackets, parentheses or braces.
+func splitTypeArgs(s string) []string {
+	var args []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(args, s[start:])
+}
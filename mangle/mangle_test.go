@@ -0,0 +1,61 @@
+package mangle
+
+import (
+	"reflect"
+	"testing"
+)
+
+var symbolTests = []struct {
+	sym  Symbol
+	name string
+}{
+	{Symbol{PkgPath: "main", Name: "main"}, "main.main"},
+	{Symbol{PkgPath: "path/to/pkg", Name: "Func"}, "path/to/pkg.Func"},
+	{Symbol{PkgPath: "example.com/a.b", Name: "F"}, "example.com/a%2eb.F"},
+	{Symbol{PkgPath: "path/to/pkg", Recv: "Type", Name: "Method"}, "path/to/pkg.Type.Method"},
+	{Symbol{PkgPath: "path/to/pkg", Recv: "Type", PtrRecv: true, Name: "Method"}, "path/to/pkg.(*Type).Method"},
+	{Symbol{PkgPath: "pkg", Name: "Func", TypeArgs: []string{"int", "string"}}, "pkg.Func[int,string]"},
+	{Symbol{PkgPath: "pkg", Name: "Func", TypeArgs: []string{"a/b.T", "map[int]c.U"}}, "pkg.Func[a/b.T,map[int]c.U]"},
+	{Symbol{PkgPath: "pkg", Recv: "T[int]", Name: "M"}, "pkg.T[int].M"},
+	{Symbol{PkgPath: "pkg", Recv: "T[a/b.U,int]", PtrRecv: true, Name: "M"}, "pkg.(*T[a/b.U,int]).M"},
+	{Symbol{PkgPath: "weird (pkg)/x[y]", Name: "F"}, "weird%20%28pkg%29/x%5by%5d.F"},
+	{Symbol{PkgPath: "a%b/c d", Name: "V"}, "a%25b/c%20d.V"},
+}
+
+func TestMangle(t *testing.T) {
+	for _, test := range symbolTests {
+		if got := test.sym.Mangle(); got != test.name {
+			t.Errorf("%#v.Mangle() = %q, want %q", test.sym, got, test.name)
+		}
+	}
+}
+
+func TestDemangle(t *testing.T) {
+	for _, test := range symbolTests {
+		got, err := Demangle(test.name)
+		if err != nil {
+			t.Errorf("Demangle(%q): %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.sym) {
+			t.Errorf("Demangle(%q) = %#v, want %#v", test.name, got, test.sym)
+		}
+	}
+}
+
+var malformedTests = []string{
+	"main",
+	"pkg.(*T.M",
+	"pkg.(*T)M",
+	"pkg.F[int",
+	"pkg.T.",
+	"a%zzb.F",
+}
+
+func TestDemangleMalformed(t *testing.T) {
+	for _, name := range malformedTests {
+		if got, err := Demangle(name); err == nil {
+			t.Errorf("Demangle(%q) = %#v, want error", name, got)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package match matches patterns against LLVM instructions and constants,
+// after the manner of LLVM's PatternMatch.h, for writing peephole rewrites
+// and assertions about generated code concisely:
+//
+//	var x llvm.Value
+//	var c int64
+//	if match.Match(v, match.Add(match.Bind(&x), match.BindInt(&c))) {
+//		// v is x + c
+//	}
+//
+// Operation patterns match instructions, and constant expressions with the
+// same opcode. Patterns bind values as they match, so bindings may be left
+// over from a partial match which failed.
+package match
+
+import "github.com/axw/gollvm/llvm"
+
+// Pattern matches values.
+type Pattern interface {
+	Match(v llvm.Value) bool
+}
+
+// Func is a Pattern which matches the values for which it returns true.
+type Func func(v llvm.Value) bool
+
+func (f Func) Match(v llvm.Value) bool { return f(v) }
+
+// Match reports whether v matches the pattern p.
+func Match(v llvm.Value, p Pattern) bool {
+	return !v.IsNil() && p.Match(v)
+}
+
+// Value matches any value.
+func Value() Pattern {
+	return Func(func(v llvm.Value) bool { return !v.IsNil() })
+}
+
+// Bind matches any value, storing it in *v.
+func Bind(v *llvm.Value) Pattern {
+	return Func(func(u llvm.Value) bool {
+		*v = u
+		return !u.IsNil()
+	})
+}
+
+// Capture matches the values matched by p, storing them in *v.
+func Capture(p Pattern, v *llvm.Value) Pattern {
+	return Func(func(u llvm.Value) bool {
+		if !Match(u, p) {
+			return false
+		}
+		*v = u
+		return true
+	})
+}
+
+// Specific matches the value v only.
+func Specific(v llvm.Value) Pattern {
+	return Func(func(u llvm.Value) bool { return !u.IsNil() && u == v })
+}
+
+// Any matches the values matched by any of the patterns ps, trying them in
+// order.
+func Any(ps ...Pattern) Pattern {
+	return Func(func(v llvm.Value) bool {
+		for _, p := range ps {
+			if Match(v, p) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// All matches the values matched by all of the patterns ps.
+func All(ps ...Pattern) Pattern {
+	return Func(func(v llvm.Value) bool {
+		for _, p := range ps {
+			if !Match(v, p) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// OneUse matches the values matched by p which have exactly one use.
+func OneUse(p Pattern) Pattern {
+	return Func(func(v llvm.Value) bool {
+		u := v.FirstUse()
+		return !u.IsNil() && u.NextUse().IsNil() && p.Match(v)
+	})
+}
+
+// Constant matches any constant, including globals and constant
+// expressions.
+func Constant() Pattern {
+	return Func(func(v llvm.Value) bool { return v.IsConstant() })
+}
+
+// ConstInt matches any constant integer.
+func ConstInt() Pattern {
+	return Func(func(v llvm.Value) bool { return !v.IsAConstantInt().IsNil() })
+}
+
+// isInt64 reports whether v is a constant integer of at most 64 bits, whose
+// value can be had with SExtValue and ZExtValue.
+func isInt64(v llvm.Value) bool {
+	return !v.IsAConstantInt().IsNil() && v.Type().IntTypeWidth() <= 64
+}
+
+// Int matches constant integers of at most 64 bits whose sign-extended
+// value is n.
+func Int(n int64) Pattern {
+	return Func(func(v llvm.Value) bool {
+		return isInt64(v) && v.SExtValue() == n
+	})
+}
+
+// BindInt matches any constant integer, storing its sign-extended value
+// in *n. Integers wider than 64 bits are not matched.
+func BindInt(n *int64) Pattern {
+	return Func(func(v llvm.Value) bool {
+		if !isInt64(v) {
+			return false
+		}
+		*n = v.SExtValue()
+		return true
+	})
+}
+
+// Zero matches the null value of any type, e.g. integer or floating point
+// zero, a null pointer, or zeroinitializer.
+func Zero() Pattern {
+	return Func(func(v llvm.Value) bool { return v.IsConstant() && v.IsNull() })
+}
+
+// One matches the constant integer 1.
+func One() Pattern {
+	return Func(func(v llvm.Value) bool {
+		return isInt64(v) && v.ZExtValue() == 1
+	})
+}
+
+// AllOnes matches constant integers of at most 64 bits with all bits set.
+func AllOnes() Pattern {
+	return Int(-1)
+}
+
+// Power2 matches constant integers of at most 64 bits which are powers of
+// two.
+func Power2() Pattern {
+	return Func(func(v llvm.Value) bool {
+		if !isInt64(v) {
+			return false
+		}
+		n := v.ZExtValue()
+		return n != 0 && n&(n-1) == 0
+	})
+}
+
+// Undef matches undefined values.
+func Undef() Pattern {
+	return Func(func(v llvm.Value) bool { return v.IsUndef() })
+}
+
+// opcode returns the opcode of v, if it is an instruction or a constant
+// expression.
+func opcode(v llvm.Value) (llvm.Opcode, bool) {
+	switch {
+	case !v.IsAInstruction().IsNil():
+		return v.InstructionOpcode(), true
+	case !v.IsAConstantExpr().IsNil():
+		return v.Opcode(), true
+	}
+	return 0, false
+}
+
+// Op matches instructions and constant expressions with the opcode op
+// whose operands are matched by the patterns ps, in order. Operands beyond
+// the patterns are not matched.
+func Op(op llvm.Opcode, ps ...Pattern) Pattern {
+	return Func(func(v llvm.Value) bool {
+		if o, ok := opcode(v); !ok || o != op || v.OperandsCount() < len(ps) {
+			return false
+		}
+		for i, p := range ps {
+			if !Match(v.Operand(i), p) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Commutative matches binary operations with the opcode op whose operands
+// are matched by l and r, in either order.
+func Commutative(op llvm.Opcode, l, r Pattern) Pattern {
+	return Any(Op(op, l, r), Op(op, r, l))
+}
+
+// Binary operations.
+func Add(l, r Pattern) Pattern  { return Op(llvm.Add, l, r) }
+func FAdd(l, r Pattern) Pattern { return Op(llvm.FAdd, l, r) }
+func Sub(l, r Pattern) Pattern  { return Op(llvm.Sub, l, r) }
+func FSub(l, r Pattern) Pattern { return Op(llvm.FSub, l, r) }
+func Mul(l, r Pattern) Pattern  { return Op(llvm.Mul, l, r) }
+func FMul(l, r Pattern) Pattern { return Op(llvm.FMul, l, r) }
+func UDiv(l, r Pattern) Pattern { return Op(llvm.UDiv, l, r) }
+func SDiv(l, r Pattern) Pattern { return Op(llvm.SDiv, l, r) }
+func FDiv(l, r Pattern) Pattern { return Op(llvm.FDiv, l, r) }
+func URem(l, r Pattern) Pattern { return Op(llvm.URem, l, r) }
+func SRem(l, r Pattern) Pattern { return Op(llvm.SRem, l, r) }
+func FRem(l, r Pattern) Pattern { return Op(llvm.FRem, l, r) }
+func Shl(l, r Pattern) Pattern  { return Op(llvm.Shl, l, r) }
+func LShr(l, r Pattern) Pattern { return Op(llvm.LShr, l, r) }
+func AShr(l, r Pattern) Pattern { return Op(llvm.AShr, l, r) }
+func And(l, r Pattern) Pattern  { return Op(llvm.And, l, r) }
+func Or(l, r Pattern) Pattern   { return Op(llvm.Or, l, r) }
+func Xor(l, r Pattern) Pattern  { return Op(llvm.Xor, l, r) }
+
+// Commutative binary operations, matching their operands in either order.
+func CAdd(l, r Pattern) Pattern { return Commutative(llvm.Add, l, r) }
+func CMul(l, r Pattern) Pattern { return Commutative(llvm.Mul, l, r) }
+func CAnd(l, r Pattern) Pattern { return Commutative(llvm.And, l, r) }
+func COr(l, r Pattern) Pattern  { return Commutative(llvm.Or, l, r) }
+func CXor(l, r Pattern) Pattern { return Commutative(llvm.Xor, l, r) }
+
+// Not matches bitwise negations of the values matched by p, i.e. xor p, -1.
+func Not(p Pattern) Pattern { return CXor(p, AllOnes()) }
+
+// Neg matches integer negations of the values matched by p, i.e. sub 0, p.
+func Neg(p Pattern) Pattern { return Sub(Zero(), p) }
+
+// Casts.
+func Trunc(p Pattern) Pattern    { return Op(llvm.Trunc, p) }
+func ZExt(p Pattern) Pattern     { return Op(llvm.ZExt, p) }
+func SExt(p Pattern) Pattern     { return Op(llvm.SExt, p) }
+func PtrToInt(p Pattern) Pattern { return Op(llvm.PtrToInt, p) }
+func IntToPtr(p Pattern) Pattern { return Op(llvm.IntToPtr, p) }
+func BitCast(p Pattern) Pattern  { return Op(llvm.BitCast, p) }
+
+// Load matches loads from the pointers matched by p.
+func Load(p Pattern) Pattern { return Op(llvm.Load, p) }
+
+// Select matches selects whose condition and values are matched by c, t
+// and f.
+func Select(c, t, f Pattern) Pattern { return Op(llvm.Select, c, t, f) }
+
+// ICmp matches integer comparisons whose operands are matched by l and r,
+// storing the predicate in *pred, if pred is not nil.
+func ICmp(pred *llvm.IntPredicate, l, r Pattern) Pattern {
+	return Func(func(v llvm.Value) bool {
+		if !Op(llvm.ICmp, l, r).Match(v) {
+			return false
+		}
+		if pred != nil {
+			*pred = v.IntPredicate()
+		}
+		return true
+	})
+}
+
+// ICmpPred matches integer comparisons with the predicate pred whose
+// operands are matched by l and r.
+func ICmpPred(pred llvm.IntPredicate, l, r Pattern) Pattern {
+	return Func(func(v llvm.Value) bool {
+		return Op(llvm.ICmp, l, r).Match(v) && v.IntPredicate() == pred
+	})
+}
@@ -0,0 +1,97 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/axw/gollvm/llvm"
+)
+
+// testValues holds instructions built from the parameters a and b of a
+// function, for matching against.
+type testValues struct {
+	a, b                      llvm.Value
+	add, mul, not, neg, cmp   llvm.Value
+	sel, expr, five, minusOne llvm.Value
+}
+
+func buildTestValues(m llvm.Module) testValues {
+	i32 := llvm.Int32Type()
+	f := llvm.AddFunction(m, "f", llvm.FunctionType(i32, []llvm.Type{i32, i32}, false))
+	b := llvm.NewBuilder()
+	defer b.Dispose()
+	b.SetInsertPointAtEnd(llvm.AddBasicBlock(f, "entry"))
+
+	var v testValues
+	v.a, v.b = f.Param(0), f.Param(1)
+	v.five = llvm.ConstInt(i32, 5, false)
+	v.minusOne = llvm.ConstInt(i32, ^uint64(0), true)
+	v.add = b.CreateAdd(v.a, v.five, "add")
+	v.mul = b.CreateMul(v.b, v.add, "mul")
+	v.not = b.CreateNot(v.mul, "not")
+	v.neg = b.CreateNeg(v.a, "neg")
+	v.cmp = b.CreateICmp(llvm.IntSLT, v.a, v.b, "cmp")
+	v.sel = b.CreateSelect(v.cmp, v.not, v.neg, "sel")
+	b.CreateRet(v.sel)
+
+	g := llvm.AddGlobal(m, i32, "g")
+	v.expr = llvm.ConstAdd(llvm.ConstPtrToInt(g, llvm.Int64Type()), llvm.ConstInt(llvm.Int64Type(), 8, false))
+	return v
+}
+
+func TestMatch(t *testing.T) {
+	m := llvm.NewModule("match")
+	defer m.Dispose()
+	v := buildTestValues(m)
+
+	tests := []struct {
+		name string
+		v    llvm.Value
+		p    Pattern
+		want bool
+	}{
+		{"add", v.add, Add(Specific(v.a), Int(5)), true},
+		{"add swapped", v.add, Add(Int(5), Specific(v.a)), false},
+		{"commutative add", v.add, CAdd(Int(5), Specific(v.a)), true},
+		{"sub is not add", v.neg, Add(Value(), Value()), false},
+		{"nested", v.mul, Mul(Specific(v.b), Add(Value(), ConstInt())), true},
+		{"not", v.not, Not(Specific(v.mul)), true},
+		{"neg", v.neg, Neg(Specific(v.a)), true},
+		{"one use", v.add, OneUse(Value()), true},
+		{"no use", v.expr, OneUse(Value()), false},
+		{"select", v.sel, Select(ICmpPred(llvm.IntSLT, Value(), Value()), Not(Value()), Neg(Value())), true},
+		{"wrong predicate", v.cmp, ICmpPred(llvm.IntEQ, Value(), Value()), false},
+		{"constant expression", v.expr, Add(PtrToInt(Value()), Int(8)), true},
+		{"constant", v.five, Constant(), true},
+		{"not constant", v.add, Constant(), false},
+		{"all ones", v.minusOne, AllOnes(), true},
+		{"power of two", v.five, Power2(), false},
+		{"any", v.neg, Any(Add(Value(), Value()), Sub(Zero(), Value())), true},
+		{"all", v.add, All(Add(Value(), Value()), Add(Value(), Int(6))), false},
+		{"nil", llvm.Value{}, Value(), false},
+	}
+	for _, test := range tests {
+		if got := Match(test.v, test.p); got != test.want {
+			t.Errorf("%s: Match = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMatchBindings(t *testing.T) {
+	m := llvm.NewModule("match")
+	defer m.Dispose()
+	v := buildTestValues(m)
+
+	var x, sum llvm.Value
+	var c int64
+	if !Match(v.mul, Mul(Bind(&x), Capture(Add(Value(), BindInt(&c)), &sum))) {
+		t.Fatal("mul not matched")
+	}
+	if x != v.b || sum != v.add || c != 5 {
+		t.Errorf("bound %v, %v and %d", x, sum, c)
+	}
+
+	var pred llvm.IntPredicate
+	if !Match(v.cmp, ICmp(&pred, Specific(v.a), Specific(v.b))) || pred != llvm.IntSLT {
+		t.Errorf("icmp not matched with predicate slt")
+	}
+}